@@ -3,23 +3,36 @@ package main
 import (
 	"cattymail/internal/api"
 	"cattymail/internal/config"
+	"cattymail/internal/logscrub"
+	"cattymail/internal/pop3"
 	"cattymail/internal/redisstore"
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"context"
 	"time"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg := config.FromFlags()
+	logscrub.Install(cfg.LogRedact)
 
-	store, err := redisstore.New(cfg.RedisURL, cfg.TTLSeconds)
+	store, err := redisstore.Open(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
+	if err := store.UseReplica(cfg.RedisReplicaURL); err != nil {
+		log.Fatalf("Failed to connect to Redis replica: %v", err)
+	}
+
+	if err := store.RunMigrations(context.Background(), cfg, cfg.MigrateDryRun); err != nil {
+		log.Fatalf("Failed to migrate Redis schema: %v", err)
+	}
+	store.CheckCapabilities(context.Background())
+
+	go store.WatchMemory(context.Background(), 10*time.Second)
 
 	handler := api.New(cfg, store)
 	srv := &http.Server{
@@ -34,6 +47,22 @@ func main() {
 		}
 	}()
 
+	// Optional read-only POP3 gateway for mail clients
+	popCtx, stopPOP3 := context.WithCancel(context.Background())
+	defer stopPOP3()
+	if cfg.POP3Addr != "" {
+		popServer, err := pop3.New(cfg, store)
+		if err != nil {
+			log.Fatalf("Failed to init POP3 gateway: %v", err)
+		}
+		go func() {
+			log.Printf("POP3 gateway listening on %s", cfg.POP3Addr)
+			if err := popServer.ListenAndServe(popCtx, cfg.POP3Addr); err != nil {
+				log.Printf("POP3 gateway stopped: %v", err)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
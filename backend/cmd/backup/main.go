@@ -0,0 +1,76 @@
+package main
+
+import (
+	"cattymail/internal/config"
+	"cattymail/internal/redisstore"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+const usage = `Usage:
+  backup export -o <file.jsonl.gz>
+  backup restore -i <file.jsonl.gz> [-overwrite]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+
+	// Open connects every residency backend too, so their keys are covered
+	store, err := redisstore.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		out := fs.String("o", "cattymail-backup.jsonl.gz", "output archive path")
+		fs.Parse(os.Args[2:])
+
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+
+		stats, err := store.ExportBackup(ctx, f)
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		log.Printf("Exported %d keys to %s", stats.Written, *out)
+
+	case "restore":
+		fs := flag.NewFlagSet("restore", flag.ExitOnError)
+		in := fs.String("i", "", "input archive path")
+		overwrite := fs.Bool("overwrite", false, "replace existing messages and addresses")
+		fs.Parse(os.Args[2:])
+
+		if *in == "" {
+			log.Fatal("restore requires -i <file>")
+		}
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *in, err)
+		}
+		defer f.Close()
+
+		stats, err := store.ImportBackup(ctx, f, *overwrite)
+		if err != nil {
+			log.Fatalf("Restore failed after %d keys: %v", stats.Restored, err)
+		}
+		log.Printf("Restored %d keys (%d skipped as expired or existing)", stats.Restored, stats.Skipped)
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
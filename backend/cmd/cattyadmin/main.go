@@ -0,0 +1,213 @@
+// Command cattyadmin is an operator CLI for maintenance on the Redis data,
+// going through the store package instead of hand-written redis-cli
+// commands. It reads the same environment as the servers. Commands that
+// change data only print what they would do unless given -yes.
+package main
+
+import (
+	"cattymail/internal/config"
+	"cattymail/internal/email"
+	"cattymail/internal/imapworker"
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `Usage:
+  cattyadmin inboxes [-domain <domain>] [-limit <n>]
+  cattyadmin purge <local@domain> [-yes]
+  cattyadmin message <id>
+  cattyadmin cursors
+  cattyadmin reset-cursor -folder <folder> -uid <uid> [-yes]
+  cattyadmin recount [-yes]
+  cattyadmin verify [-repair]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+
+	store, err := redisstore.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+
+	ctx := context.Background()
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "inboxes":
+		fs := flag.NewFlagSet("inboxes", flag.ExitOnError)
+		d := fs.String("domain", "", "only list inboxes of this domain")
+		limit := fs.Int("limit", 50, "inboxes to list, biggest first (0 for all)")
+		fs.Parse(args)
+
+		inboxes, err := store.ListInboxes(ctx, email.CanonicalDomain(*d))
+		if err != nil {
+			log.Fatalf("Failed to list inboxes: %v", err)
+		}
+		sort.Slice(inboxes, func(i, j int) bool { return inboxes[i].Messages > inboxes[j].Messages })
+		fmt.Printf("%d inboxes\n", len(inboxes))
+		if *limit > 0 && len(inboxes) > *limit {
+			inboxes = inboxes[:*limit]
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "INBOX\tMESSAGES")
+		for _, in := range inboxes {
+			fmt.Fprintf(tw, "%s@%s\t%d\n", in.Local, in.Domain, in.Messages)
+		}
+		tw.Flush()
+
+	case "purge":
+		fs := flag.NewFlagSet("purge", flag.ExitOnError)
+		yes := fs.Bool("yes", false, "delete the messages")
+		fs.Parse(reorder(args))
+		if fs.NArg() != 1 {
+			log.Fatal("purge requires <local@domain>")
+		}
+		local, d, ok := strings.Cut(fs.Arg(0), "@")
+		if !ok {
+			log.Fatalf("%q is not an address", fs.Arg(0))
+		}
+		local, d = strings.ToLower(local), email.CanonicalDomain(d)
+
+		if !*yes {
+			inboxes, err := store.ListInboxes(ctx, d)
+			if err != nil {
+				log.Fatalf("Failed to read inbox: %v", err)
+			}
+			var n int64
+			for _, in := range inboxes {
+				if in.Local == local {
+					n = in.Messages
+				}
+			}
+			log.Printf("Would delete %d messages from %s@%s (pass -yes to delete)", n, local, d)
+			return
+		}
+		n, err := store.PurgeInbox(ctx, d, local)
+		if err != nil {
+			log.Fatalf("Purge failed after %d messages: %v", n, err)
+		}
+		log.Printf("Deleted %d messages from %s@%s", n, local, d)
+
+	case "message":
+		if len(args) != 1 {
+			log.Fatal("message requires <id>")
+		}
+		msg, err := store.GetMessage(ctx, args[0])
+		if err != nil {
+			log.Fatalf("Failed to read message: %v", err)
+		}
+		if msg == nil {
+			log.Fatalf("Message %s not found", args[0])
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(msg)
+
+	case "cursors":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "FOLDER\tLAST UID")
+		for _, folder := range imapworker.Folders {
+			uid, err := store.GetFolderLastUID(ctx, cfg.IMAPUser+":"+folder)
+			if err != nil {
+				log.Fatalf("Failed to read cursor of %s: %v", folder, err)
+			}
+			fmt.Fprintf(tw, "%s\t%d\n", folder, uid)
+		}
+		tw.Flush()
+
+	case "reset-cursor":
+		fs := flag.NewFlagSet("reset-cursor", flag.ExitOnError)
+		folder := fs.String("folder", "", "IMAP folder")
+		uid := fs.Uint("uid", 0, "last processed UID; the ingestor resumes after it")
+		yes := fs.Bool("yes", false, "move the cursor")
+		fs.Parse(args)
+		if !knownFolder(*folder) {
+			log.Fatalf("-folder must be one of %s", strings.Join(imapworker.Folders, ", "))
+		}
+
+		key := cfg.IMAPUser + ":" + *folder
+		current, err := store.GetFolderLastUID(ctx, key)
+		if err != nil {
+			log.Fatalf("Failed to read cursor: %v", err)
+		}
+		if !*yes {
+			log.Printf("Would move the %s cursor from %d to %d (pass -yes to move it)", *folder, current, *uid)
+			return
+		}
+		if err := store.SetFolderLastUID(ctx, key, uint32(*uid)); err != nil {
+			log.Fatalf("Failed to set cursor: %v", err)
+		}
+		log.Printf("Moved the %s cursor from %d to %d", *folder, current, *uid)
+
+	case "recount":
+		fs := flag.NewFlagSet("recount", flag.ExitOnError)
+		yes := fs.Bool("yes", false, "rebuild the counters")
+		fs.Parse(args)
+		if !*yes {
+			log.Print("Would rebuild the storage counters from the stored messages; run while ingest is quiet (pass -yes to rebuild)")
+			return
+		}
+		res, err := store.RecountStorage(ctx)
+		if err != nil {
+			log.Fatalf("Recount failed: %v", err)
+		}
+		log.Printf("Recounted %d messages (%d bytes) in %d inboxes across %d domains", res.Messages, res.Bytes, res.Inboxes, res.Domains)
+
+	case "verify":
+		fs := flag.NewFlagSet("verify", flag.ExitOnError)
+		repair := fs.Bool("repair", false, "fix the discrepancies found")
+		fs.Parse(args)
+
+		rep, err := store.VerifyIndexes(ctx, *repair)
+		if err != nil {
+			log.Fatalf("Verify failed: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+		if rep.Discrepancies() > 0 && !*repair {
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// knownFolder reports whether folder is one the ingestor polls
+func knownFolder(folder string) bool {
+	for _, f := range imapworker.Folders {
+		if f == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// reorder moves flags ahead of positional arguments, so "purge a@b -yes"
+// works like "purge -yes a@b"
+func reorder(args []string) []string {
+	var flags, rest []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") {
+			flags = append(flags, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return append(flags, rest...)
+}
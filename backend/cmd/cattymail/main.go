@@ -0,0 +1,142 @@
+// Command cattymail runs the HTTP API and the IMAP ingestor in a single
+// process for small deployments. It accepts the same environment as the
+// api and ingestor binaries; /api/readyz reports each component.
+package main
+
+import (
+	"cattymail/internal/alert"
+	"cattymail/internal/api"
+	"cattymail/internal/archive"
+	"cattymail/internal/config"
+	"cattymail/internal/demo"
+	"cattymail/internal/digest"
+	"cattymail/internal/imapworker"
+	"cattymail/internal/logscrub"
+	"cattymail/internal/notify"
+	"cattymail/internal/pop3"
+	"cattymail/internal/redisstore"
+	"cattymail/internal/thumbnail"
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func main() {
+	cfg := config.FromFlags()
+	logscrub.Install(cfg.LogRedact)
+
+	store, err := redisstore.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to Redis: %v", err)
+	}
+	if err := store.UseReplica(cfg.RedisReplicaURL); err != nil {
+		log.Fatalf("Failed to connect to Redis replica: %v", err)
+	}
+	if err := store.RunMigrations(context.Background(), cfg, cfg.MigrateDryRun); err != nil {
+		log.Fatalf("Failed to migrate Redis schema: %v", err)
+	}
+	store.CheckCapabilities(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Background components stop when ctx is cancelled; wg lets shutdown
+	// wait for them
+	var wg sync.WaitGroup
+	run := func(name string, fn func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(ctx)
+			log.Printf("%s stopped", name)
+		}()
+	}
+
+	// The worker rewrites its AllowedDomains every cycle; give it its own
+	// copy so the API keeps treating only env domains as static
+	workerCfg := *cfg
+	worker := imapworker.New(&workerCfg, store)
+
+	run("Memory watcher", func(ctx context.Context) { store.WatchMemory(ctx, 10*time.Second) })
+	if cfg.ShadowWrites {
+		run("Shadow comparison", func(ctx context.Context) { store.WatchShadow(ctx, time.Minute) })
+	}
+	if cfg.DemoMode {
+		run("Demo seeder", demo.NewSeeder(cfg, store).Run)
+	} else {
+		run("IMAP worker", worker.Start)
+	}
+	run("Notification dispatcher", notify.NewDispatcher(cfg, store).Run)
+	run("Alert scheduler", alert.NewEngine(cfg, store).Run)
+	run("Digest sender", digest.New(cfg, store).Run)
+	if thumbs := thumbnail.New(cfg, store); thumbs != nil && cfg.ThumbnailOnIngest {
+		run("Thumbnail renderer", thumbs.Run)
+	}
+	if archiver, err := archive.New(cfg, store); err != nil {
+		log.Fatalf("Failed to init archiver: %v", err)
+	} else if archiver != nil {
+		run("Archiver", archiver.Run)
+	}
+	if cfg.POP3Addr != "" {
+		popServer, err := pop3.New(cfg, store)
+		if err != nil {
+			log.Fatalf("Failed to init POP3 gateway: %v", err)
+		}
+		run("POP3 gateway", func(ctx context.Context) {
+			log.Printf("POP3 gateway listening on %s", cfg.POP3Addr)
+			if err := popServer.ListenAndServe(ctx, cfg.POP3Addr); err != nil {
+				log.Printf("POP3 gateway: %v", err)
+			}
+		})
+	}
+
+	handler := api.New(cfg, store)
+	if !cfg.DemoMode {
+		handler.AddHealthCheck("ingestor", worker.Health)
+	}
+	srv := &http.Server{
+		Addr:    ":8080",
+		Handler: handler.Router(),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Println("API Server starting on :8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-quit:
+	case err := <-serveErr:
+		log.Printf("ListenAndServe: %v", err)
+	}
+	log.Println("Shutting down...")
+
+	shutdownCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		log.Println("Timed out waiting for background components")
+	}
+	log.Println("Server exiting")
+}
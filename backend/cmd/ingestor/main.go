@@ -1,34 +1,83 @@
 package main
 
 import (
+	"cattymail/internal/admin"
+	"cattymail/internal/alert"
+	"cattymail/internal/archive"
 	"cattymail/internal/config"
+	"cattymail/internal/demo"
+	"cattymail/internal/digest"
 	"cattymail/internal/imapworker"
+	"cattymail/internal/logscrub"
+	"cattymail/internal/notify"
 	"cattymail/internal/redisstore"
+	"cattymail/internal/thumbnail"
 	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func main() {
-	cfg := config.Load()
+	cfg := config.FromFlags()
+	logscrub.Install(cfg.LogRedact)
 
-	store, err := redisstore.New(cfg.RedisURL, cfg.TTLSeconds)
+	store, err := redisstore.Open(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
 	}
 
+	if err := store.RunMigrations(context.Background(), cfg, cfg.MigrateDryRun); err != nil {
+		log.Fatalf("Failed to migrate Redis schema: %v", err)
+	}
+	store.CheckCapabilities(context.Background())
+
 	worker := imapworker.New(cfg, store)
-	
+
+	// Optional diagnostics listener (pprof + runtime stats) behind admin auth
+	if cfg.DebugAddr != "" {
+		adminHandler, err := admin.NewAdminHandler(cfg, store)
+		if err != nil {
+			log.Fatalf("Failed to init diagnostics auth: %v", err)
+		}
+		go func() {
+			log.Printf("Diagnostics listening on %s", cfg.DebugAddr)
+			if err := http.ListenAndServe(cfg.DebugAddr, adminHandler.DebugRouter()); err != nil {
+				log.Printf("Diagnostics server stopped: %v", err)
+			}
+		}()
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
-	go worker.Start(ctx)
+	go store.WatchMemory(ctx, 10*time.Second)
+	if cfg.ShadowWrites {
+		go store.WatchShadow(ctx, time.Minute)
+	}
+	if cfg.DemoMode {
+		go demo.NewSeeder(cfg, store).Run(ctx)
+	} else {
+		go worker.Start(ctx)
+	}
+	go notify.NewDispatcher(cfg, store).Run(ctx)
+	go alert.NewEngine(cfg, store).Run(ctx)
+	go digest.New(cfg, store).Run(ctx)
+	if thumbs := thumbnail.New(cfg, store); thumbs != nil && cfg.ThumbnailOnIngest {
+		go thumbs.Run(ctx)
+	}
+	if archiver, err := archive.New(cfg, store); err != nil {
+		log.Fatalf("Failed to init archiver: %v", err)
+	} else if archiver != nil {
+		go archiver.Run(ctx)
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down Ingestor...")
-	
+
 	cancel()
 	// Wait a bit?
 }
@@ -0,0 +1,270 @@
+// Command loadgen synthesizes realistic email traffic against a test
+// deployment and polls the resulting inboxes through the public API,
+// reporting ingest and read latencies.
+//
+// Messages are either injected straight into Redis through the store
+// (default, exercises SaveMessage and the API) or sent over SMTP to the
+// catch-all mailbox (-smtp, exercises the full IMAP ingest path).
+//
+// Never point this at production: it creates addresses and, with
+// -spoof-ip, sidesteps per-IP rate limiting.
+package main
+
+import (
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var senders = []struct{ name, addr, subject string }{
+	{"GitHub", "noreply@github.com", "[GitHub] Please verify your device"},
+	{"Discord", "noreply@discord.com", "Verify Email Address for Discord"},
+	{"Steam Support", "noreply@steampowered.com", "Your Steam account: Access from new web or mobile device"},
+	{"Netflix", "info@account.netflix.com", "Complete your sign-up"},
+	{"Instagram", "security@mail.instagram.com", "%d is your Instagram code"},
+	{"Tokopedia", "noreply@tokopedia.com", "Kode OTP Tokopedia: %d"},
+}
+
+type stats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func (s *stats) record(op string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors[op]++
+		return
+	}
+	s.latencies[op] = append(s.latencies[op], d)
+}
+
+func (s *stats) report(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Printf("\n%-8s %8s %8s %10s %10s %10s %10s\n", "op", "ok", "errors", "rate/s", "p50", "p95", "p99")
+	for _, op := range []string{"create", "send", "poll"} {
+		l := s.latencies[op]
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		pct := func(p float64) time.Duration {
+			if len(l) == 0 {
+				return 0
+			}
+			return l[int(float64(len(l)-1)*p)]
+		}
+		fmt.Printf("%-8s %8d %8d %10.1f %10s %10s %10s\n", op, len(l), s.errors[op],
+			float64(len(l))/elapsed.Seconds(), pct(0.50), pct(0.95), pct(0.99))
+	}
+}
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:8080", "API base URL")
+	smtpAddr := flag.String("smtp", "", "send via this SMTP server (host:port) instead of writing to Redis")
+	domainName := flag.String("domain", "", "domain for generated addresses (default: first public domain)")
+	inboxes := flag.Int("inboxes", 20, "number of addresses to create")
+	rate := flag.Float64("rate", 10, "messages per second")
+	pollers := flag.Int("pollers", 10, "concurrent inbox pollers")
+	pollEvery := flag.Duration("poll-interval", time.Second, "delay between polls per poller")
+	duration := flag.Duration("duration", 30*time.Second, "test duration")
+	spoofIP := flag.Bool("spoof-ip", true, "send a distinct X-Real-IP per poller to avoid per-IP rate limits")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		cancel()
+	}()
+
+	var store *redisstore.Store
+	if *smtpAddr == "" {
+		cfg := config.Load()
+		var err error
+		store, err = redisstore.New(cfg.RedisURL, cfg.TTLSeconds)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	st := &stats{latencies: map[string][]time.Duration{}, errors: map[string]int{}}
+
+	if *domainName == "" {
+		var resp struct {
+			Domains []string `json:"domains"`
+		}
+		if err := getJSON(client, *apiURL+"/api/domains", "", &resp); err != nil || len(resp.Domains) == 0 {
+			log.Fatalf("Failed to discover domains (pass -domain): %v", err)
+		}
+		*domainName = resp.Domains[0]
+	}
+
+	// 1. Create addresses
+	var addrs []domain.Address
+	for i := 0; i < *inboxes; i++ {
+		start := time.Now()
+		addr, err := createAddress(client, *apiURL, *domainName, fmt.Sprintf("10.99.%d.%d", i/250, i%250+1))
+		st.record("create", time.Since(start), err)
+		if err != nil {
+			log.Printf("create address: %v", err)
+			continue
+		}
+		addrs = append(addrs, *addr)
+	}
+	if len(addrs) == 0 {
+		log.Fatal("No addresses created")
+	}
+	log.Printf("Created %d addresses on %s, running for %s", len(addrs), *domainName, *duration)
+
+	began := time.Now()
+	var wg sync.WaitGroup
+
+	// 2. Generate mail at a fixed rate
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				addr := addrs[rand.Intn(len(addrs))]
+				go func() {
+					start := time.Now()
+					var err error
+					if store != nil {
+						err = store.SaveMessage(context.Background(), synthesize(addr))
+					} else {
+						err = sendSMTP(*smtpAddr, addr)
+					}
+					st.record("send", time.Since(start), err)
+				}()
+			}
+		}
+	}()
+
+	// 3. Poll inboxes like browser tabs do
+	for p := 0; p < *pollers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			ip := ""
+			if *spoofIP {
+				ip = fmt.Sprintf("10.98.%d.%d", p/250, p%250+1)
+			}
+			for {
+				addr := addrs[rand.Intn(len(addrs))]
+				start := time.Now()
+				var msgs []json.RawMessage
+				err := getJSON(client, fmt.Sprintf("%s/api/inbox/%s/%s", *apiURL, addr.Domain, addr.Local), ip, &msgs)
+				st.record("poll", time.Since(start), err)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(*pollEvery):
+				}
+			}
+		}(p)
+	}
+
+	wg.Wait()
+	st.report(time.Since(began))
+}
+
+func createAddress(client *http.Client, apiURL, domainName, ip string) (*domain.Address, error) {
+	body, _ := json.Marshal(map[string]string{"domain": domainName})
+	req, _ := http.NewRequest(http.MethodPost, apiURL+"/api/address/random", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Real-IP", ip)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var addr domain.Address
+	return &addr, json.NewDecoder(resp.Body).Decode(&addr)
+}
+
+func getJSON(client *http.Client, url, ip string, out interface{}) error {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if ip != "" {
+		req.Header.Set("X-Real-IP", ip)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// synthesize builds a verification-style message like the ones that make up
+// most real traffic
+func synthesize(addr domain.Address) *domain.Message {
+	s := senders[rand.Intn(len(senders))]
+	code := rand.Intn(900000) + 100000
+	subject := s.subject
+	if strings.Contains(subject, "%d") {
+		subject = fmt.Sprintf(subject, code)
+	}
+	text := fmt.Sprintf("Hi,\n\nYour verification code is %d.\nIt expires in 10 minutes.\n\n-- %s", code, s.name)
+	html := fmt.Sprintf("<html><body><table><tr><td><h1>%s</h1><p>Your verification code is <b>%d</b>.</p>%s</td></tr></table></body></html>",
+		s.name, code, strings.Repeat("<p style=\"color:#666\">You received this email because an account was created.</p>", rand.Intn(40)+1))
+
+	return &domain.Message{
+		ID:         ulid.Make().String(),
+		Domain:     addr.Domain,
+		Local:      addr.Local,
+		OriginalTo: addr.Email,
+		From:       fmt.Sprintf("%q <%s>", s.name, s.addr),
+		Subject:    subject,
+		Date:       time.Now(),
+		Text:       text,
+		HTML:       html,
+		Size:       len(text) + len(html),
+	}
+}
+
+func sendSMTP(smtpAddr string, addr domain.Address) error {
+	msg := synthesize(addr)
+	from := senders[rand.Intn(len(senders))].addr
+	raw := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/alternative; boundary=\"lg\"\r\n\r\n"+
+		"--lg\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n"+
+		"--lg\r\nContent-Type: text/html; charset=utf-8\r\n\r\n%s\r\n--lg--\r\n",
+		msg.From, addr.Email, msg.Subject, msg.Date.Format(time.RFC1123Z), msg.Text, msg.HTML)
+	return smtp.SendMail(smtpAddr, nil, from, []string{addr.Email}, []byte(raw))
+}
@@ -0,0 +1,165 @@
+package addrgen
+
+import (
+	"cattymail/internal/privacy"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+)
+
+// ErrExhausted is returned when no free local could be found within the
+// attempt budget
+var ErrExhausted = errors.New("addrgen: no unique address available")
+
+// ErrInvalidPattern is returned when a vanity prefix or suffix cannot
+// produce a valid local
+var ErrInvalidPattern = errors.New("addrgen: invalid vanity pattern")
+
+// maxAttempts bounds the number of candidates tried per request
+const maxAttempts = 12
+
+// Store is the subset of redisstore.Store the generator relies on
+type Store interface {
+	ReserveAddress(ctx context.Context, emailDomain, local string) (bool, error)
+	RecentlyIssued(ctx context.Context, emailDomain, local string) (bool, error)
+	MarkIssued(ctx context.Context, emailDomain, local string) error
+}
+
+// Generator issues random locals of the form name + digits. Candidates are
+// drawn from crypto/rand, screened against a bloom filter of recently issued
+// locals, and grow in entropy after every few collisions.
+type Generator struct {
+	store Store
+}
+
+// New returns a generator backed by store
+func New(store Store) *Generator {
+	return &Generator{store: store}
+}
+
+// Pattern constrains generated locals. Prefix replaces the random name,
+// Suffix is appended after the digits; both are optional.
+type Pattern struct {
+	Prefix string
+	Suffix string
+}
+
+// maxVanityLen keeps prefix+suffix+9 digits within the 31-char local policy
+const maxVanityLen = 16
+
+var (
+	prefixPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+	suffixPattern = regexp.MustCompile(`^[a-z0-9._-]+$`)
+)
+
+// Validate checks that p can only yield locals accepted by the API policy
+func (p Pattern) Validate() error {
+	if len(p.Prefix)+len(p.Suffix) > maxVanityLen {
+		return fmt.Errorf("%w: prefix and suffix exceed %d chars", ErrInvalidPattern, maxVanityLen)
+	}
+	if p.Prefix != "" && !prefixPattern.MatchString(p.Prefix) {
+		return fmt.Errorf("%w: prefix must start alphanumeric and contain only a-z 0-9 . _ -", ErrInvalidPattern)
+	}
+	if p.Suffix != "" && !suffixPattern.MatchString(p.Suffix) {
+		return fmt.Errorf("%w: suffix may contain only a-z 0-9 . _ -", ErrInvalidPattern)
+	}
+	return nil
+}
+
+// Generate reserves and returns a fresh local for emailDomain
+func (g *Generator) Generate(ctx context.Context, emailDomain string) (string, error) {
+	return g.GenerateWith(ctx, emailDomain, Pattern{})
+}
+
+// GenerateWith is Generate constrained to a vanity pattern
+func (g *Generator) GenerateWith(ctx context.Context, emailDomain string, p Pattern) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		local, err := p.candidate(digitsFor(attempt))
+		if err != nil {
+			return "", err
+		}
+
+		// A bloom hit may be a false positive, but skipping it is cheaper
+		// than a round trip that will most likely fail
+		seen, err := g.store.RecentlyIssued(ctx, emailDomain, local)
+		if err != nil {
+			log.Printf("addrgen: bloom lookup failed: %v", err)
+		} else if seen {
+			continue
+		}
+
+		ok, err := g.store.ReserveAddress(ctx, emailDomain, local)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+
+		if err := g.store.MarkIssued(ctx, emailDomain, local); err != nil {
+			log.Printf("addrgen: failed to record %s: %v", privacy.Inbox(local, emailDomain), err)
+		}
+		if attempt > 0 {
+			log.Printf("addrgen: issued %s after %d collisions", privacy.Inbox(local, emailDomain), attempt)
+		}
+		return local, nil
+	}
+	return "", ErrExhausted
+}
+
+// digitsFor widens the numeric suffix as collisions accumulate: 5 digits for
+// the first attempts, then 7, then 9
+func digitsFor(attempt int) int {
+	switch {
+	case attempt < 3:
+		return 5
+	case attempt < 6:
+		return 7
+	default:
+		return 9
+	}
+}
+
+// candidate builds a local from the prefix (or a random name), a digit
+// run with no leading zero and the suffix
+func (p Pattern) candidate(digits int) (string, error) {
+	base := p.Prefix
+	if base == "" {
+		i, err := randInt(int64(len(names)))
+		if err != nil {
+			return "", err
+		}
+		base = names[i]
+	}
+
+	lo := pow10(digits - 1)
+	n, err := randInt(9 * lo)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d%s", base, lo+n, p.Suffix), nil
+}
+
+func randInt(max int64) (int64, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(max))
+	if err != nil {
+		return 0, fmt.Errorf("addrgen: entropy source: %w", err)
+	}
+	return n.Int64(), nil
+}
+
+func pow10(n int) int64 {
+	v := int64(1)
+	for i := 0; i < n; i++ {
+		v *= 10
+	}
+	return v
+}
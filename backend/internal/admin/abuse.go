@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetAbuseReports returns the review queue, oldest report first, or with
+// ?status=all every report kept, newest first
+func (h *AdminHandler) GetAbuseReports(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	limit := int64(100)
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = int64(l)
+	}
+	reports, err := h.store.ListAbuseReports(ctx, r.URL.Query().Get("status") != "all", limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch abuse reports", http.StatusInternalServerError)
+		return
+	}
+	if reports == nil {
+		reports = []*domain.AbuseReport{}
+	}
+	open, err := h.store.CountOpenAbuseReports(ctx)
+	if err != nil {
+		http.Error(w, "Failed to count abuse reports", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": reports,
+		"open":    open,
+	})
+}
+
+// GetAbuseReport returns a report along with the message it references,
+// if still stored, and the state of the address
+func (h *AdminHandler) GetAbuseReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rep, err := h.store.GetAbuseReport(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to fetch abuse report", http.StatusInternalServerError)
+		return
+	}
+	if rep == nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	var msg *domain.Message
+	if rep.MessageID != "" {
+		if msg, err = h.store.GetMessage(ctx, rep.MessageID); err != nil {
+			http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+			return
+		}
+	}
+	exists, err := h.store.AddressExists(ctx, rep.Domain, rep.Local)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report":  rep,
+		"message": msg,
+		"address": map[string]bool{
+			"exists":  exists,
+			"blocked": h.store.IsBlocked(ctx, rep.Domain, rep.Local),
+		},
+	})
+}
+
+// ResolveAbuseReport closes a report, taking the listed actions on its
+// address: "block" takes it out of service, "purge" deletes its messages.
+// Without actions the report is dismissed.
+func (h *AdminHandler) ResolveAbuseReport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req struct {
+		Actions []string `json:"actions"`
+		Note    string   `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for _, action := range req.Actions {
+		if action != "block" && action != "purge" {
+			http.Error(w, `actions must be "block" or "purge"`, http.StatusBadRequest)
+			return
+		}
+	}
+	rep, err := h.store.GetAbuseReport(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to fetch abuse report", http.StatusInternalServerError)
+		return
+	}
+	if rep == nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if rep.Status != redisstore.AbuseOpen {
+		http.Error(w, "Report already closed", http.StatusConflict)
+		return
+	}
+
+	purged := 0
+	for _, action := range req.Actions {
+		switch action {
+		case "block":
+			b := &domain.BlockedAddress{Domain: rep.Domain, Local: rep.Local, Reason: rep.Category, ReportID: rep.ID}
+			if err := h.store.BlockAddress(ctx, b); err != nil {
+				http.Error(w, "Failed to block address", http.StatusInternalServerError)
+				return
+			}
+		case "purge":
+			if purged, err = h.store.PurgeInbox(ctx, rep.Domain, rep.Local); err != nil {
+				http.Error(w, "Failed to purge inbox", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	rep.Status = redisstore.AbuseDismissed
+	if len(req.Actions) > 0 {
+		rep.Status = redisstore.AbuseResolved
+	}
+	rep.ResolvedAt = &now
+	rep.Actions = req.Actions
+	rep.Note = strings.TrimSpace(req.Note)
+	if err := h.store.SaveAbuseReport(ctx, rep); err != nil {
+		http.Error(w, "Failed to save abuse report", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, "abuse."+rep.Status, rep.Local+"@"+rep.Domain, "report "+rep.ID+" actions "+strings.Join(req.Actions, ","))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report": rep,
+		"purged": purged,
+	})
+}
+
+// GetBlockedAddresses lists the blocked addresses, most recent first
+func (h *AdminHandler) GetBlockedAddresses(w http.ResponseWriter, r *http.Request) {
+	blocked, err := h.store.ListBlockedAddresses(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch blocked addresses", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].BlockedAt.After(blocked[j].BlockedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"blocked": blocked,
+	})
+}
+
+// UnblockAddress puts a blocked address back into service
+func (h *AdminHandler) UnblockAddress(w http.ResponseWriter, r *http.Request) {
+	found, err := h.store.UnblockAddress(r.Context(), chi.URLParam(r, "domain"), strings.ToLower(chi.URLParam(r, "local")))
+	if err != nil {
+		http.Error(w, "Failed to unblock address", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Address not blocked", http.StatusNotFound)
+		return
+	}
+	h.audit(r, "address.unblock", strings.ToLower(chi.URLParam(r, "local"))+"@"+chi.URLParam(r, "domain"), "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "unblocked",
+	})
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"cattymail/internal/alert"
+	"cattymail/internal/domain"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// GetAlerts returns the alert rules and the alerts currently firing
+func (h *AdminHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.store.ListAlertRules(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch alert rules", http.StatusInternalServerError)
+		return
+	}
+	firing, err := h.store.ListFiringAlerts(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch alerts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":  rules,
+		"firing": firing,
+	})
+}
+
+// AddAlertRule creates a rule. The request body is an AlertRule without
+// ID; rules are evaluated by the ingestor's scheduler.
+func (h *AdminHandler) AddAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule domain.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := alert.Validate(h.cfg, &rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.ID = ulid.Make().String()
+	rule.CreatedAt = time.Now()
+
+	if err := h.store.SaveAlertRule(r.Context(), &rule); err != nil {
+		http.Error(w, "Failed to save alert rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// DeleteAlertRule removes a rule
+func (h *AdminHandler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.store.DeleteAlertRule(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to delete alert rule", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
@@ -0,0 +1,244 @@
+package admin
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// Admin API tokens let automation call admin endpoints without the
+// password. A token looks like "cat_<id>.<secret>" and carries scopes of
+// the form "<resource>:read" or "<resource>:write", where the resource is
+// the first path segment after /admin/ and write implies read. "*" grants
+// everything a token may do; managing credentials always needs an
+// interactive login.
+
+const apiTokenPrefix = "cat_"
+
+// tokenResources are the admin resources scopes can name
+var tokenResources = []string{
+	"abuse", "addresses", "alerts", "archive", "audit", "billing", "config",
+	"deadletters", "debug", "diagnostics", "domains", "greylist", "health",
+	"honeypots", "ingest", "logs", "memory", "messages", "notifications",
+	"pii", "quarantine", "ratelimits", "senders", "settings", "stats", "storage", "stream",
+}
+
+// scopePII is a pseudo-resource: with PRIVACY_MODE on, tokens without
+// pii:read get every address in responses masked
+const scopePII = "pii"
+
+// interactiveOnly resources are never reachable with an API token
+var interactiveOnly = map[string]bool{"tokens": true, "jwt": true, "webauthn": true}
+
+// requiredScope returns the scope a request needs, from its path and method
+func requiredScope(r *http.Request) (resource, action string) {
+	path := r.URL.Path
+	if i := strings.Index(path, "/admin/"); i >= 0 {
+		path = path[i+len("/admin/"):]
+	}
+	resource, _, _ = strings.Cut(path, "/")
+	action = "write"
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		action = "read"
+	}
+	return resource, action
+}
+
+func tokenAllows(tok *domain.APIToken, resource, action string) bool {
+	if interactiveOnly[resource] {
+		return false
+	}
+	for _, s := range tok.Scopes {
+		if s == "*" || s == resource+":write" || s == resource+":"+action {
+			return true
+		}
+	}
+	return false
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	known := make(map[string]bool, len(tokenResources))
+	for _, r := range tokenResources {
+		known[r] = true
+	}
+	for _, s := range scopes {
+		if s == "*" {
+			continue
+		}
+		resource, action, ok := strings.Cut(s, ":")
+		if !ok || !known[resource] || (action != "read" && action != "write") {
+			return fmt.Errorf("invalid scope %q", s)
+		}
+	}
+	return nil
+}
+
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+var errBadAPIToken = errors.New("invalid API token")
+
+// authenticateAPIToken resolves a "cat_" bearer token
+func (h *AdminHandler) authenticateAPIToken(ctx context.Context, raw string) (*domain.APIToken, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(raw, apiTokenPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return nil, errBadAPIToken
+	}
+	tok, err := h.store.GetAPIToken(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil || subtle.ConstantTimeCompare([]byte(tok.SecretHash), []byte(hashTokenSecret(secret))) != 1 {
+		return nil, errBadAPIToken
+	}
+	if tok.ExpiresAt != nil && time.Now().After(*tok.ExpiresAt) {
+		return nil, errBadAPIToken
+	}
+	// Record usage at most once a minute per token
+	now := time.Now()
+	if tok.LastUsedAt == nil || now.Sub(*tok.LastUsedAt) > time.Minute {
+		if err := h.store.TouchAPIToken(ctx, tok, now); err != nil {
+			log.Printf("Failed to record use of API token %s: %v", tok.ID, err)
+		}
+	}
+	return tok, nil
+}
+
+// serveAPIToken authorizes a request carrying an API token
+func (h *AdminHandler) serveAPIToken(w http.ResponseWriter, r *http.Request, raw string, next http.Handler) {
+	tok, err := h.authenticateAPIToken(r.Context(), raw)
+	if err == errBadAPIToken {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to verify token", http.StatusInternalServerError)
+		return
+	}
+	resource, action := requiredScope(r)
+	if !tokenAllows(tok, resource, action) {
+		http.Error(w, fmt.Sprintf("Token lacks scope %s:%s", resource, action), http.StatusForbidden)
+		return
+	}
+	if !tokenAllows(tok, scopePII, "read") {
+		// Under PRIVACY_MODE only interactive admins and tokens granted
+		// pii:read see addresses in the clear
+		var done func() error
+		w, r, done = privacy.Restrict(w, r)
+		defer done()
+	}
+	next.ServeHTTP(w, withActor(r, "token:"+tok.ID))
+}
+
+// GetAPITokens lists the API tokens without their secrets
+func (h *AdminHandler) GetAPITokens(w http.ResponseWriter, r *http.Request) {
+	toks, err := h.store.ListAPITokens(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch tokens", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(toks, func(i, j int) bool { return toks[i].CreatedAt.Before(toks[j].CreatedAt) })
+	for _, t := range toks {
+		t.SecretHash = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tokens":    toks,
+		"resources": tokenResources,
+	})
+}
+
+// CreateAPIToken issues a token. The secret is only returned here.
+func (h *AdminHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 never expires
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		http.Error(w, "expires_in_days must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	tok := &domain.APIToken{
+		ID:         strings.ToLower(ulid.Make().String()),
+		Name:       strings.TrimSpace(req.Name),
+		Scopes:     req.Scopes,
+		SecretHash: hashTokenSecret(secret),
+		CreatedAt:  time.Now(),
+	}
+	if req.ExpiresInDays > 0 {
+		exp := tok.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+		tok.ExpiresAt = &exp
+	}
+	if err := h.store.SaveAPIToken(r.Context(), tok); err != nil {
+		http.Error(w, "Failed to save token", http.StatusInternalServerError)
+		return
+	}
+
+	tok.SecretHash = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":   apiTokenPrefix + tok.ID + "." + secret,
+		"details": tok,
+	})
+}
+
+// DeleteAPIToken revokes a token
+func (h *AdminHandler) DeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.store.DeleteAPIToken(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to delete token", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
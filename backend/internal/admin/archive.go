@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"cattymail/internal/archive"
+	"cattymail/internal/domain"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ArchiveEnabled reports whether ARCHIVE_URL is configured
+func (h *AdminHandler) ArchiveEnabled() bool {
+	return h.archive != nil
+}
+
+// SearchArchive finds archived messages by expiry day (from/to, default
+// today), recipient address, sender, ID or text
+func (h *AdminHandler) SearchArchive(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := archive.Query{
+		ID:      q.Get("id"),
+		Address: q.Get("address"),
+		Sender:  q.Get("sender"),
+		Text:    q.Get("q"),
+		Limit:   100,
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 1000 {
+		query.Limit = l
+	}
+	today := time.Now().UTC()
+	query.From, query.To = today, today
+	for name, t := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if v := q.Get(name); v != "" {
+			day, err := archive.ParseDay(v)
+			if err != nil {
+				http.Error(w, name+" must be a YYYY-MM-DD date", http.StatusBadRequest)
+				return
+			}
+			*t = day
+		}
+	}
+	if q.Get("from") != "" && q.Get("to") == "" {
+		query.To = query.From
+	}
+
+	msgs, err := h.archive.Search(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msgs == nil {
+		msgs = []*domain.Message{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages": msgs,
+	})
+}
+
+// RestoreArchived puts an archived message back into its inbox
+func (h *AdminHandler) RestoreArchived(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID   string `json:"id"`
+		Date string `json:"date"` // expiry day the message was archived under
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	day, err := archive.ParseDay(req.Date)
+	if err != nil || req.ID == "" {
+		http.Error(w, "id and date (YYYY-MM-DD) are required", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := h.archive.Restore(r.Context(), day, req.ID)
+	if err != nil {
+		http.Error(w, "Failed to restore message", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, "Message not found in archive", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "restored",
+		"id":     msg.ID,
+		"inbox":  msg.Local + "@" + msg.Domain,
+	})
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cattymail/internal/domain"
+)
+
+type ctxKey int
+
+const actorCtxKey ctxKey = iota
+
+// withActor records who is behind an authenticated admin request, for the
+// audit log
+func withActor(r *http.Request, actor string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorCtxKey, actor))
+}
+
+func actorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorCtxKey).(string); ok {
+		return actor
+	}
+	return "unknown"
+}
+
+// audit records an admin action in the audit log. Failures are logged, not
+// returned: the action already happened.
+func (h *AdminHandler) audit(r *http.Request, action, target, detail string) {
+	e := &domain.AuditEntry{
+		Actor:  actorFrom(r.Context()),
+		Action: action,
+		Target: target,
+		Detail: detail,
+	}
+	if err := h.store.AppendAudit(r.Context(), e); err != nil {
+		log.Printf("Failed to audit %s on %s: %v", action, target, err)
+	}
+}
+
+// GetAudit returns recent admin actions, newest first, optionally only on
+// targets starting with ?target=
+func (h *AdminHandler) GetAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+	entries, err := h.store.ListAudit(r.Context(), r.URL.Query().Get("target"), limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+	})
+}
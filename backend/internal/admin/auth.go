@@ -1,9 +1,13 @@
 package admin
 
 import (
-	"crypto/rand"
+	"cattymail/internal/redisstore"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,9 +19,26 @@ var (
 	ErrInvalidToken    = errors.New("invalid token")
 )
 
+// keyringRefresh bounds how long a rotation on another process takes to
+// be picked up for signing; unknown key IDs trigger an immediate reload
+const keyringRefresh = 30 * time.Second
+
+// AuthService checks the admin password and issues admin JWTs signed with
+// the shared keyring in Redis, so tokens survive restarts and are accepted
+// by every replica. The token header carries the key ID (kid).
 type AuthService struct {
 	adminPasswordHash string
-	jwtSecret         []byte
+	store             *redisstore.Store
+	// initial is installed when the keyring is empty: JWT_SECRET when set,
+	// a random key otherwise
+	initial *redisstore.JWTKey
+	// legacyKID verifies tokens without a kid, issued before keys moved to
+	// Redis; only meaningful when JWT_SECRET is set
+	legacyKID string
+
+	mu       sync.Mutex
+	ring     *redisstore.JWTKeyring
+	loadedAt time.Time
 }
 
 type Claims struct {
@@ -25,36 +46,77 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(adminPassword, jwtSecret string) (*AuthService, error) {
-	// Hash the admin password
-	hash, err := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-
-	// Generate JWT secret if not provided
-	secret := []byte(jwtSecret)
-	if jwtSecret == "" {
-		secret = make([]byte, 32)
-		if _, err := rand.Read(secret); err != nil {
+func NewAuthService(adminPassword, jwtSecret string, store *redisstore.Store) (*AuthService, error) {
+	a := &AuthService{store: store}
+	if adminPassword == "" {
+		log.Println("ADMIN_PASSWORD is not set, admin password login is disabled")
+	} else {
+		hash, err := bcrypt.GenerateFromPassword([]byte(adminPassword), bcrypt.DefaultCost)
+		if err != nil {
 			return nil, err
 		}
+		a.adminPasswordHash = string(hash)
 	}
 
-	return &AuthService{
-		adminPasswordHash: string(hash),
-		jwtSecret:         secret,
-	}, nil
+	var err error
+	if jwtSecret != "" {
+		sum := sha256.Sum256([]byte(jwtSecret))
+		a.initial = &redisstore.JWTKey{ID: "env-" + hex.EncodeToString(sum[:6]), Secret: []byte(jwtSecret), CreatedAt: time.Now()}
+		a.legacyKID = a.initial.ID
+	} else if a.initial, err = redisstore.NewJWTKey(); err != nil {
+		return nil, err
+	}
+	return a, nil
 }
 
+// ValidatePassword checks password against ADMIN_PASSWORD. It always fails
+// when none is set.
 func (a *AuthService) ValidatePassword(password string) error {
+	if a.adminPasswordHash == "" {
+		return ErrInvalidPassword
+	}
 	if err := bcrypt.CompareHashAndPassword([]byte(a.adminPasswordHash), []byte(password)); err != nil {
 		return ErrInvalidPassword
 	}
 	return nil
 }
 
-func (a *AuthService) GenerateToken() (string, error) {
+// keyring returns the cached keyring, reloading it when stale or when
+// reload is set, and installs the initial key into an empty one
+func (a *AuthService) keyring(ctx context.Context, reload bool) (*redisstore.JWTKeyring, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.ring != nil && !reload && time.Since(a.loadedAt) < keyringRefresh {
+		return a.ring, nil
+	}
+
+	ring, err := a.store.GetJWTKeyring(ctx)
+	if err == nil && ring.Current == "" {
+		if err = a.store.EnsureJWTKey(ctx, a.initial); err == nil {
+			ring, err = a.store.GetJWTKeyring(ctx)
+		}
+	}
+	if err != nil {
+		if a.ring != nil {
+			// Keep serving with the last known keys while Redis is away
+			return a.ring, nil
+		}
+		return nil, err
+	}
+	a.ring, a.loadedAt = ring, time.Now()
+	return ring, nil
+}
+
+func (a *AuthService) GenerateToken(ctx context.Context) (string, error) {
+	ring, err := a.keyring(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	key, ok := ring.Keys[ring.Current]
+	if !ok {
+		return "", redisstore.ErrNoJWTKey
+	}
+
 	claims := &Claims{
 		Admin: true,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -64,13 +126,36 @@ func (a *AuthService) GenerateToken() (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(a.jwtSecret)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Secret)
 }
 
-func (a *AuthService) ValidateToken(tokenString string) (*Claims, error) {
+func (a *AuthService) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return a.jwtSecret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = a.legacyKID
+		}
+		if kid == "" {
+			return nil, ErrInvalidToken
+		}
+		ring, err := a.keyring(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := ring.Keys[kid]
+		if !ok {
+			// Possibly rotated in by another process since the last load
+			if ring, err = a.keyring(ctx, true); err != nil {
+				return nil, err
+			}
+			key, ok = ring.Keys[kid]
+		}
+		if !ok || key.Expired(time.Now()) {
+			return nil, ErrInvalidToken
+		}
+		return key.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, ErrInvalidToken
@@ -83,6 +168,22 @@ func (a *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 	return nil, ErrInvalidToken
 }
 
-func (a *AuthService) GetJWTSecretHex() string {
-	return hex.EncodeToString(a.jwtSecret)
+// Rotate signs new tokens with a fresh key; tokens signed with the current
+// key keep working for grace
+func (a *AuthService) Rotate(ctx context.Context, grace time.Duration) (*redisstore.JWTKey, error) {
+	// Make sure there is a key to rotate out
+	if _, err := a.keyring(ctx, true); err != nil {
+		return nil, err
+	}
+	next, err := redisstore.NewJWTKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.store.RotateJWTKey(ctx, next, grace); err != nil {
+		return nil, err
+	}
+	if _, err := a.keyring(ctx, true); err != nil {
+		return nil, err
+	}
+	return next, nil
 }
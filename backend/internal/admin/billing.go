@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"cattymail/internal/billing"
+	"encoding/json"
+	"net/http"
+)
+
+// GetBilling reports how many accounts are on each plan
+func (h *AdminHandler) GetBilling(w http.ResponseWriter, r *http.Request) {
+	plans := billing.Plans(h.cfg)
+	ids := make([]string, 0, len(plans))
+	for id := range plans {
+		if id != billing.PlanAnonymous {
+			ids = append(ids, id)
+		}
+	}
+
+	counts, err := h.store.PlanMemberCounts(r.Context(), ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch subscriber counts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"provider":    h.cfg.BillingProvider,
+		"subscribers": counts,
+		"plans":       plans,
+	})
+}
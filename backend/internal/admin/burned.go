@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"cattymail/internal/burned"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// burnReports returns, for each of domains that looks blocked, its verdict
+// with the evidence: bounces and refusals naming a provider, and how far
+// incoming mail dropped
+func (h *AdminHandler) burnReports(ctx context.Context, domains []string) map[string]map[string]interface{} {
+	reports := make(map[string]map[string]interface{})
+	signals, err := h.store.BurnSignals(ctx, domains)
+	if err != nil {
+		log.Printf("Failed to load burn signals: %v", err)
+	}
+	hourly, err := h.store.DomainHourly(ctx, domains, redisstore.BurnHistoryHours)
+	if err != nil {
+		log.Printf("Failed to load hourly domain volume: %v", err)
+	}
+	for _, d := range domains {
+		drop, dropped := burned.Drop(hourly[d])
+		verdict := burned.Verdict(signals[d], drop, dropped)
+		if verdict == "" {
+			continue
+		}
+		report := map[string]interface{}{"verdict": verdict}
+		if len(signals[d]) > 0 {
+			report["signals"] = signals[d]
+		}
+		if dropped {
+			report["volume_drop"] = drop
+		}
+		reports[d] = report
+	}
+	return reports
+}
+
+// ClearBurnSignals forgets the bounces and refusals recorded against a
+// domain, once an operator has looked into them
+func (h *AdminHandler) ClearBurnSignals(w http.ResponseWriter, r *http.Request) {
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	if !h.isKnownDomain(r, d) {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+	if err := h.store.ClearBurnSignals(r.Context(), d); err != nil {
+		http.Error(w, "Failed to clear burn signals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+}
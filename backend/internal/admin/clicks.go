@@ -0,0 +1,25 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetMessageClicks lists the links followed in a message through the link
+// proxy, with click counts
+func (h *AdminHandler) GetMessageClicks(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	clicks, err := h.store.MessageClicks(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to fetch clicks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"clicks": clicks,
+	})
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+)
+
+// GetConsentPolicy returns the current terms and privacy policy versions
+// and how often each version pair was accepted
+func (h *AdminHandler) GetConsentPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	policy, err := h.store.GetConsentPolicy(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch consent policy", http.StatusInternalServerError)
+		return
+	}
+	if policy == nil {
+		policy = &domain.ConsentPolicy{}
+	}
+	counts, err := h.store.ConsentCounts(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch consent counts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy":   policy,
+		"accepted": counts, // "<terms>|<privacy>" -> acceptances
+	})
+}
+
+// SaveConsentPolicy sets the current versions. Bumping a version makes
+// every client accept again before creating addresses, when required.
+func (h *AdminHandler) SaveConsentPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy domain.ConsentPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy.TermsVersion = strings.TrimSpace(policy.TermsVersion)
+	policy.PrivacyVersion = strings.TrimSpace(policy.PrivacyVersion)
+	if strings.Contains(policy.TermsVersion+policy.PrivacyVersion, "|") {
+		http.Error(w, `versions must not contain "|"`, http.StatusBadRequest)
+		return
+	}
+	if policy.Required && (policy.TermsVersion == "" || policy.PrivacyVersion == "") {
+		http.Error(w, "both versions are needed to require consent", http.StatusBadRequest)
+		return
+	}
+	policy.UpdatedAt = time.Now()
+
+	if err := h.store.SaveConsentPolicy(r.Context(), &policy); err != nil {
+		http.Error(w, "Failed to save consent policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy": policy,
+	})
+}
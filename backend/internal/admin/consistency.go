@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// VerifyStorage cross-checks inboxes, message indexes, storage counters and
+// the sender index against the stored messages. It scans every backend, so
+// it can take a while on large datasets.
+func (h *AdminHandler) VerifyStorage(w http.ResponseWriter, r *http.Request) {
+	h.verifyStorage(w, r, false)
+}
+
+// RepairStorage runs the same checks as VerifyStorage and fixes what it
+// finds
+func (h *AdminHandler) RepairStorage(w http.ResponseWriter, r *http.Request) {
+	h.verifyStorage(w, r, true)
+}
+
+func (h *AdminHandler) verifyStorage(w http.ResponseWriter, r *http.Request, repair bool) {
+	rep, err := h.store.VerifyIndexes(r.Context(), repair)
+	if err != nil {
+		http.Error(w, "Failed to verify storage", http.StatusInternalServerError)
+		return
+	}
+	if repair {
+		h.audit(r, "storage.repair", "", fmt.Sprintf("%d discrepancies", rep.Discrepancies()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"report":        rep,
+		"discrepancies": rep.Discrepancies(),
+	})
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// wantsCSV reports whether the request asked for ?format=csv
+func wantsCSV(r *http.Request) bool {
+	return strings.EqualFold(r.URL.Query().Get("format"), "csv")
+}
+
+// csvResponse streams rows as a CSV attachment named filename
+type csvResponse struct {
+	w  *csv.Writer
+	rw http.ResponseWriter
+}
+
+func newCSVResponse(w http.ResponseWriter, filename string, header ...string) *csvResponse {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	resp := &csvResponse{w: csv.NewWriter(w), rw: w}
+	resp.Write(header...)
+	return resp
+}
+
+// Write writes a single row. Cells that a spreadsheet would evaluate as a
+// formula are prefixed with a quote, since most values (subjects, senders)
+// are attacker controlled.
+func (c *csvResponse) Write(cells ...string) {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	c.w.Write(cells)
+}
+
+// Flush pushes buffered rows to the client
+func (c *csvResponse) Flush() {
+	c.w.Flush()
+	if f, ok := c.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
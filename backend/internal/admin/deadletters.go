@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetDeadLetters lists messages the ingestor failed to process
+func (h *AdminHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	entries, err := h.store.ListDeadLetters(ctx, offset, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch dead letters", http.StatusInternalServerError)
+		return
+	}
+	total, _ := h.store.CountDeadLetters(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deadLetters": entries,
+		"total":       total,
+		"offset":      offset,
+		"limit":       limit,
+	})
+}
+
+// GetDeadLetter returns one dead letter. With ?raw=1 the original message is
+// served as message/rfc822 for download.
+func (h *AdminHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	dl, err := h.store.GetDeadLetter(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to fetch dead letter", http.StatusInternalServerError)
+		return
+	}
+	if dl == nil {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("raw") == "1" {
+		w.Header().Set("Content-Type", "message/rfc822")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+id+".eml\"")
+		w.Write([]byte(dl.Raw))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dl)
+}
+
+// DeleteDeadLetter discards a dead letter
+func (h *AdminHandler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.DeleteDeadLetter(r.Context(), chi.URLParam(r, "id")); err != nil {
+		http.Error(w, "Failed to delete dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// GetDiagnostics reports runtime, GC and Redis pool statistics.
+// Pass ?goroutines=1 to include a full goroutine dump.
+func (h *AdminHandler) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	var gc debug.GCStats
+	gc.PauseQuantiles = make([]time.Duration, 5)
+	debug.ReadGCStats(&gc)
+
+	pool := h.store.PoolStats()
+
+	response := map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"memory": map[string]interface{}{
+			"heap_alloc_bytes":    m.HeapAlloc,
+			"heap_inuse_bytes":    m.HeapInuse,
+			"heap_idle_bytes":     m.HeapIdle,
+			"heap_released_bytes": m.HeapReleased,
+			"heap_objects":        m.HeapObjects,
+			"stack_inuse_bytes":   m.StackInuse,
+			"sys_bytes":           m.Sys,
+			"total_alloc_bytes":   m.TotalAlloc,
+		},
+		"gc": map[string]interface{}{
+			"num_gc":          gc.NumGC,
+			"last_gc":         gc.LastGC,
+			"pause_total_ms":  gc.PauseTotal.Milliseconds(),
+			"pause_quantiles": gc.PauseQuantiles,
+			"next_gc_bytes":   m.NextGC,
+			"gc_cpu_fraction": m.GCCPUFraction,
+		},
+		"redis_pool": map[string]interface{}{
+			"hits":        pool.Hits,
+			"misses":      pool.Misses,
+			"timeouts":    pool.Timeouts,
+			"total_conns": pool.TotalConns,
+			"idle_conns":  pool.IdleConns,
+			"stale_conns": pool.StaleConns,
+		},
+		"storage":   h.store.Pressure(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	if r.URL.Query().Get("goroutines") == "1" {
+		var buf bytes.Buffer
+		pprof.Lookup("goroutine").WriteTo(&buf, 2)
+		response["goroutine_dump"] = buf.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// MountDiagnostics registers the diagnostics endpoint and net/http/pprof
+// under /admin. Callers are responsible for applying AuthMiddleware.
+func (h *AdminHandler) MountDiagnostics(r chi.Router) {
+	r.Get("/admin/diagnostics", h.GetDiagnostics)
+	r.Mount("/admin/debug", middleware.Profiler())
+}
+
+// DebugRouter serves the admin diagnostics on their own listener for
+// binaries without an HTTP API (the ingestor), using the same paths and
+// the same admin JWT as the API server.
+func (h *AdminHandler) DebugRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Route("/api", func(r chi.Router) {
+		r.Use(h.AuthMiddleware)
+		h.MountDiagnostics(r)
+	})
+	return r
+}
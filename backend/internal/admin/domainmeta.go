@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxMetaTitle       = 200
+	maxMetaDescription = 500
+)
+
+// GetDomainMeta returns the metadata admins set per domain
+func (h *AdminHandler) GetDomainMeta(w http.ResponseWriter, r *http.Request) {
+	metas, err := h.store.ListDomainMeta(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch domain metadata", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domains": metas,
+	})
+}
+
+// SaveDomainMeta creates or replaces the landing and SEO metadata of a
+// domain, served on /api/domains/meta and /api/sitemap.xml
+func (h *AdminHandler) SaveDomainMeta(w http.ResponseWriter, r *http.Request) {
+	var meta domain.DomainMeta
+	if err := json.NewDecoder(r.Body).Decode(&meta); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	d, err := email.NormalizeDomain(meta.Domain)
+	if err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+	meta.Domain = d
+	if len(meta.Title) > maxMetaTitle || len(meta.Description) > maxMetaDescription {
+		http.Error(w, "Title or description too long", http.StatusBadRequest)
+		return
+	}
+	if meta.CanonicalURL != "" {
+		u, err := url.Parse(meta.CanonicalURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "Invalid canonical_url", http.StatusBadRequest)
+			return
+		}
+	}
+	meta.UpdatedAt = time.Now()
+
+	if err := h.store.SaveDomainMeta(r.Context(), &meta); err != nil {
+		http.Error(w, "Failed to save domain metadata", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meta)
+}
+
+// DeleteDomainMeta reverts a domain to the default metadata
+func (h *AdminHandler) DeleteDomainMeta(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.store.DeleteDomainMeta(r.Context(), email.CanonicalDomain(chi.URLParam(r, "domain")))
+	if err != nil {
+		http.Error(w, "Failed to delete domain metadata", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Domain metadata not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var domainStates = map[string]bool{domain.DomainActive: true, domain.DomainDraining: true, domain.DomainRetired: true}
+
+// SetDomainState moves a domain between active, draining and retired
+func (h *AdminHandler) SetDomainState(w http.ResponseWriter, r *http.Request) {
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !domainStates[req.State] {
+		http.Error(w, `state must be "active", "draining" or "retired"`, http.StatusBadRequest)
+		return
+	}
+	if !h.isKnownDomain(r, d) {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+	if err := h.store.SetDomainState(r.Context(), d, req.State); err != nil {
+		http.Error(w, "Failed to update domain state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"domain": d,
+		"state":  req.State,
+	})
+}
+
+// isKnownDomain reports whether d is a static or dynamic domain
+func (h *AdminHandler) isKnownDomain(r *http.Request, d string) bool {
+	for _, allowed := range h.cfg.AllowedDomains {
+		if d == email.CanonicalDomain(allowed) {
+			return true
+		}
+	}
+	dynamic, _ := h.store.GetDomains(r.Context())
+	for _, allowed := range dynamic {
+		if d == email.CanonicalDomain(allowed) {
+			return true
+		}
+	}
+	return false
+}
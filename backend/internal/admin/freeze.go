@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetFrozenAddresses lists the frozen addresses, most recent first
+func (h *AdminHandler) GetFrozenAddresses(w http.ResponseWriter, r *http.Request) {
+	frozen, err := h.store.ListFrozenAddresses(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch frozen addresses", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(frozen, func(i, j int) bool { return frozen[i].FrozenAt.After(frozen[j].FrozenAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"frozen": frozen,
+	})
+}
+
+// FreezeAddress freezes an address for investigation. Unlike deleting it,
+// this keeps its messages as evidence.
+func (h *AdminHandler) FreezeAddress(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	if !h.isKnownDomain(r, d) {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+	f := &domain.FrozenAddress{
+		Domain:   d,
+		Local:    strings.ToLower(chi.URLParam(r, "local")),
+		Reason:   req.Reason,
+		FrozenBy: actorFrom(r.Context()),
+		FrozenAt: time.Now().UTC(),
+	}
+	if err := h.store.FreezeAddress(r.Context(), f); err != nil {
+		http.Error(w, "Failed to freeze address", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, "address.freeze", f.Local+"@"+f.Domain, f.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
+}
+
+// UnfreezeAddress lifts a freeze. The local stays uncreatable for
+// FREEZE_COOLDOWN_HOURS.
+func (h *AdminHandler) UnfreezeAddress(w http.ResponseWriter, r *http.Request) {
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	local := strings.ToLower(chi.URLParam(r, "local"))
+	cooldown := time.Duration(h.cfg.FreezeCooldownHours) * time.Hour
+	f, err := h.store.UnfreezeAddress(r.Context(), d, local, cooldown)
+	if err != nil {
+		http.Error(w, "Failed to unfreeze address", http.StatusInternalServerError)
+		return
+	}
+	if f == nil {
+		http.Error(w, "Address not frozen", http.StatusNotFound)
+		return
+	}
+	h.audit(r, "address.unfreeze", local+"@"+d, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "unfrozen",
+		"cooldown_until": time.Now().Add(cooldown).UTC(),
+	})
+}
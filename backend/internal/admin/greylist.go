@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"cattymail/internal/email"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxGreylistDelay caps the per-domain delay
+const maxGreylistDelay = 24 * time.Hour
+
+// GetGreylist returns the per-domain delays and the messages being held
+func (h *AdminHandler) GetGreylist(w http.ResponseWriter, r *http.Request) {
+	delays, err := h.store.GreylistDelays(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch greylist settings", http.StatusInternalServerError)
+		return
+	}
+	held, err := h.store.ListHeldMessages(r.Context(), 200)
+	if err != nil {
+		http.Error(w, "Failed to fetch held messages", http.StatusInternalServerError)
+		return
+	}
+	secs := make(map[string]int64, len(delays))
+	for d, delay := range delays {
+		secs[d] = int64(delay / time.Second)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"delays": secs,
+		"held":   held,
+	})
+}
+
+// SetGreylistDelay delays the first message from each sender to an inbox
+// of a domain by delay_seconds; 0 turns greylisting off for the domain
+func (h *AdminHandler) SetGreylistDelay(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Domain       string `json:"domain"`
+		DelaySeconds int    `json:"delay_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	d, err := email.NormalizeDomain(req.Domain)
+	if err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+	delay := time.Duration(req.DelaySeconds) * time.Second
+	if delay < 0 || delay > maxGreylistDelay {
+		http.Error(w, "delay_seconds must be between 0 and 86400", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.SetGreylistDelay(r.Context(), d, delay); err != nil {
+		http.Error(w, "Failed to save greylist settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain":        d,
+		"delay_seconds": req.DelaySeconds,
+	})
+}
+
+// ReleaseHeldMessage delivers a held message immediately
+func (h *AdminHandler) ReleaseHeldMessage(w http.ResponseWriter, r *http.Request) {
+	released, err := h.store.ReleaseMessage(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to release message", http.StatusInternalServerError)
+		return
+	}
+	if !released {
+		http.Error(w, "Message is not held", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "released",
+	})
+}
@@ -1,10 +1,18 @@
 package admin
 
 import (
+	"cattymail/internal/archive"
 	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/privacy"
 	"cattymail/internal/redisstore"
+	"cattymail/internal/webauthn"
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
@@ -16,18 +24,27 @@ type AdminHandler struct {
 	cfg   *config.Config
 	store *redisstore.Store
 	auth  *AuthService
+	rp    *webauthn.RelyingParty // nil unless passkeys are configured
+	// archive is nil unless ARCHIVE_URL is set
+	archive *archive.Archiver
 }
 
 func NewAdminHandler(cfg *config.Config, store *redisstore.Store) (*AdminHandler, error) {
-	auth, err := NewAuthService(cfg.AdminPassword, cfg.JWTSecret)
+	auth, err := NewAuthService(cfg.AdminPassword, cfg.JWTSecret, store)
 	if err != nil {
 		return nil, err
 	}
+	archiver, err := archive.New(cfg, store)
+	if err != nil {
+		log.Printf("Archive search disabled: %v", err)
+	}
 
 	return &AdminHandler{
-		cfg:   cfg,
-		store: store,
-		auth:  auth,
+		cfg:     cfg,
+		store:   store,
+		auth:    auth,
+		rp:      newRelyingParty(cfg.WebAuthnRPID, cfg.WebAuthnOrigins),
+		archive: archiver,
 	}, nil
 }
 
@@ -48,13 +65,17 @@ func (h *AdminHandler) AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		token := parts[1]
-		_, err := h.auth.ValidateToken(token)
+		if strings.HasPrefix(token, apiTokenPrefix) {
+			h.serveAPIToken(w, r, token, next)
+			return
+		}
+		_, err := h.auth.ValidateToken(r.Context(), token)
 		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, withActor(r, "admin"))
 	})
 }
 
@@ -74,7 +95,7 @@ func (h *AdminHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.auth.GenerateToken()
+	token, err := h.auth.GenerateToken(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
@@ -96,6 +117,19 @@ func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	messagesLast24h, _ := h.store.GetMessagesLast24h(ctx)
 	domainStats, _ := h.store.GetDomainStats(ctx)
 
+	if wantsCSV(r) {
+		out := newCSVResponse(w, "stats.csv", "metric", "domain", "value")
+		out.Write("totalAddresses", "", strconv.FormatInt(totalAddresses, 10))
+		out.Write("totalMessages", "", strconv.FormatInt(totalMessages, 10))
+		out.Write("activeAddresses", "", strconv.FormatInt(activeAddresses, 10))
+		out.Write("messagesLast24h", "", strconv.FormatInt(messagesLast24h, 10))
+		for domain, count := range domainStats {
+			out.Write("domainMessages", domain, strconv.FormatInt(count, 10))
+		}
+		out.Flush()
+		return
+	}
+
 	// Convert domain stats to array format
 	var topDomains []map[string]interface{}
 	for domain, count := range domainStats {
@@ -133,12 +167,33 @@ func (h *AdminHandler) GetDomains(w http.ResponseWriter, r *http.Request) {
 		domainMap[d] = "custom"
 	}
 	
-	var result []map[string]string
+	states, _ := h.store.GetDomainStates(ctx)
+
+	// Retired domains no longer receive mail, so their volume always drops
+	var names []string
+	for d := range domainMap {
+		if states[email.CanonicalDomain(d)] != domain.DomainRetired {
+			names = append(names, email.CanonicalDomain(d))
+		}
+	}
+	burns := h.burnReports(ctx, names)
+
+	var result []map[string]interface{}
 	for d, source := range domainMap {
-		result = append(result, map[string]string{
-			"name":   d,
-			"source": source,
-		})
+		state := states[email.CanonicalDomain(d)]
+		if state == "" {
+			state = domain.DomainActive
+		}
+		entry := map[string]interface{}{
+			"name":    d,
+			"display": email.DisplayDomain(d),
+			"source":  source,
+			"state":   state,
+		}
+		if burn, ok := burns[email.CanonicalDomain(d)]; ok {
+			entry["burn"] = burn
+		}
+		result = append(result, entry)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -162,6 +217,12 @@ func (h *AdminHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Accept Unicode or xn-- spellings; the store keeps the punycode form
+	if _, err := email.NormalizeDomain(req.Domain); err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+
 	if err := h.store.AddDomain(r.Context(), req.Domain); err != nil {
 		http.Error(w, "Failed to add domain", http.StatusInternalServerError)
 		return
@@ -180,7 +241,7 @@ func (h *AdminHandler) RemoveDomain(w http.ResponseWriter, r *http.Request) {
 
 	// Check if it's a system domain
 	for _, d := range h.cfg.AllowedDomains {
-		if d == domain {
+		if d == email.CanonicalDomain(domain) {
 			http.Error(w, "Cannot remove system domain derived from environment variables", http.StatusForbidden)
 			return
 		}
@@ -251,6 +312,8 @@ func (h *AdminHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
 		"maxEmailBytes":        h.cfg.MaxEmailBytes,
 		"expiredWeb":           h.cfg.ExpiredWeb,
 		"allowedDomains":       h.cfg.AllowedDomains,
+		// Where each secret came from; values are never returned
+		"secretSources":        h.cfg.SecretSources(),
 	})
 }
 
@@ -268,6 +331,18 @@ func (h *AdminHandler) GetAddresses(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wantsCSV(r) {
+		out := newCSVResponse(w, "addresses.csv", "key")
+		for _, addr := range addresses {
+			out.Write(maskAddressKey(ctx, addr))
+		}
+		out.Flush()
+		return
+	}
+
+	for i, addr := range addresses {
+		addresses[i] = maskAddressKey(ctx, addr)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"addresses": addresses,
@@ -290,6 +365,20 @@ func (h *AdminHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if wantsCSV(r) {
+		out := newCSVResponse(w, "messages.csv", "id", "domain", "local", "original_to", "from", "subject", "date", "size", "imap_folder")
+		for _, m := range messages {
+			local := m.Local
+			if privacy.Masked(ctx) {
+				local = privacy.Local(local)
+			}
+			out.Write(m.ID, m.Domain, local, m.OriginalTo, m.From, m.Subject,
+				m.Date.Format(time.RFC3339), strconv.Itoa(m.Size), m.IMAPFolder)
+		}
+		out.Flush()
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"messages": messages,
@@ -319,14 +408,33 @@ func (h *AdminHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
+	// Checked at startup; processes that skipped it check on first request
+	caps := h.store.Capabilities()
+	if caps == nil {
+		caps = h.store.CheckCapabilities(r.Context())
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":          "online",
-		"goroutines":      runtime.NumGoroutine(),
-		"memory_alloc_mb": m.Alloc / 1024 / 1024,
-		"memory_sys_mb":   m.Sys / 1024 / 1024,
-		"cpu_num":         runtime.NumCPU(),
-		"redis":           "connected",
-		"timestamp":       time.Now().Unix(),
+		"status":             "online",
+		"goroutines":         runtime.NumGoroutine(),
+		"memory_alloc_mb":    m.Alloc / 1024 / 1024,
+		"memory_sys_mb":      m.Sys / 1024 / 1024,
+		"cpu_num":            runtime.NumCPU(),
+		"redis":              "connected",
+		"redis_capabilities": caps,
+		"timestamp":          time.Now().Unix(),
 	})
 }
+
+// maskAddressKey masks the local of an "addr:<domain>:<local>" key in
+// restricted views (see privacy.Restrict)
+func maskAddressKey(ctx context.Context, key string) string {
+	if !privacy.Masked(ctx) {
+		return key
+	}
+	if i := strings.LastIndexByte(key, ':'); i >= 0 {
+		return key[:i+1] + privacy.Local(key[i+1:])
+	}
+	return key
+}
@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetHoneypots lists the honeypot addresses
+func (h *AdminHandler) GetHoneypots(w http.ResponseWriter, r *http.Request) {
+	pots, err := h.store.ListHoneypots(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch honeypots", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(pots, func(i, j int) bool { return pots[i].CreatedAt.Before(pots[j].CreatedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"honeypots": pots,
+	})
+}
+
+// AddHoneypot marks a local as a honeypot on a domain, or on every domain
+// with "*". Addresses someone already uses cannot become one.
+func (h *AdminHandler) AddHoneypot(w http.ResponseWriter, r *http.Request) {
+	var hp domain.Honeypot
+	if err := json.NewDecoder(r.Body).Decode(&hp); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	local, err := email.NormalizeLocal(strings.TrimSpace(hp.Local))
+	if err != nil || local == "" {
+		http.Error(w, "Invalid local", http.StatusBadRequest)
+		return
+	}
+	hp.Local = local
+	if hp.Domain != redisstore.AnyDomain {
+		hp.Domain = email.CanonicalDomain(hp.Domain)
+		if !h.isKnownDomain(r, hp.Domain) {
+			http.Error(w, "Domain not found", http.StatusNotFound)
+			return
+		}
+		exists, err := h.store.AddressExists(r.Context(), hp.Domain, local)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			http.Error(w, "Address is in use", http.StatusConflict)
+			return
+		}
+	}
+	hp.CreatedAt = time.Now()
+	if err := h.store.SaveHoneypot(r.Context(), &hp); err != nil {
+		http.Error(w, "Failed to save honeypot", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hp)
+}
+
+// DeleteHoneypot turns a honeypot back into an ordinary local
+func (h *AdminHandler) DeleteHoneypot(w http.ResponseWriter, r *http.Request) {
+	found, err := h.store.DeleteHoneypot(r.Context(), chi.URLParam(r, "domain"), strings.ToLower(chi.URLParam(r, "local")))
+	if err != nil {
+		http.Error(w, "Failed to delete honeypot", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Honeypot not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
+
+// GetHoneypotHits lists recent mail and API requests to honeypots, newest
+// first, with the IPs and senders behind the most of them
+func (h *AdminHandler) GetHoneypotHits(w http.ResponseWriter, r *http.Request) {
+	limit := int64(100)
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = int64(l)
+	}
+	hits, err := h.store.ListHoneypotHits(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch honeypot hits", http.StatusInternalServerError)
+		return
+	}
+	if hits == nil {
+		hits = []*domain.HoneypotHit{}
+	}
+	ips, senders := map[string]int{}, map[string]int{}
+	for _, hit := range hits {
+		for _, ip := range []string{hit.SenderIP, hit.ClientIP} {
+			if ip != "" {
+				ips[ip]++
+			}
+		}
+		if hit.Sender != "" {
+			senders[hit.Sender]++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hits":    hits,
+		"ips":     ips,
+		"senders": senders,
+	})
+}
+
+// GetHoneypotMessage returns a message sent to a honeypot
+func (h *AdminHandler) GetHoneypotMessage(w http.ResponseWriter, r *http.Request) {
+	msg, err := h.store.GetHoneypotMessage(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
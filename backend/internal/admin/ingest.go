@@ -0,0 +1,19 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetIngestStats reports ingested, skipped, dropped and errored message
+// counts per IMAP folder and per recipient domain
+func (h *AdminHandler) GetIngestStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.store.GetIngestStats(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch ingest stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
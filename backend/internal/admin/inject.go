@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/imapworker"
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// InjectMessageRequest is the JSON form accepted by InjectMessage
+type InjectMessageRequest struct {
+	To      string    `json:"to"`
+	From    string    `json:"from"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	HTML    string    `json:"html"`
+	Date    time.Time `json:"date"`
+}
+
+// InjectMessage stores a message into an inbox without going through SMTP,
+// for test automation. It accepts either JSON (InjectMessageRequest) or a
+// raw RFC 822 message (Content-Type: message/rfc822) which is parsed exactly
+// like ingested mail; ?to= overrides the recipient of a raw message.
+func (h *AdminHandler) InjectMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(h.cfg.MaxEmailBytes))
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var msg *domain.Message
+	var err error
+	if mediaType == "message/rfc822" || mediaType == "text/plain" {
+		msg, err = h.parseRawInjection(ctx, r)
+	} else {
+		msg, err = h.parseJSONInjection(r)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !h.isAllowedDomain(ctx, msg.Domain) {
+		http.Error(w, "Recipient domain is not allowed", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SaveMessage(ctx, msg); err != nil {
+		if errors.Is(err, redisstore.ErrQuotaExceeded) {
+			http.Error(w, strings.TrimPrefix(err.Error(), "redisstore: "), http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, "Failed to store message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":     msg.ID,
+		"domain": msg.Domain,
+		"local":  msg.Local,
+	})
+}
+
+func (h *AdminHandler) parseJSONInjection(r *http.Request) (*domain.Message, error) {
+	var req InjectMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("Invalid request body")
+	}
+
+	to, err := email.Parse(req.To)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid recipient: %v", err)
+	}
+	if req.Date.IsZero() {
+		req.Date = time.Now()
+	}
+	if req.Subject == "" {
+		req.Subject = "(No Subject)"
+	}
+
+	return &domain.Message{
+		ID:         ulid.Make().String(),
+		Domain:     to.Domain,
+		Local:      to.Local,
+		OriginalTo: to.String(),
+		From:       req.From,
+		Subject:    req.Subject,
+		Date:       req.Date,
+		Text:       req.Text,
+		HTML:       req.HTML,
+		Size:       len(req.Text) + len(req.HTML),
+	}, nil
+}
+
+func (h *AdminHandler) parseRawInjection(ctx context.Context, r *http.Request) (*domain.Message, error) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Message exceeds %d bytes", h.cfg.MaxEmailBytes)
+	}
+
+	// X-Forwarded-To is the first header the parser checks for the recipient
+	if to := r.URL.Query().Get("to"); to != "" {
+		addr, err := email.Parse(to)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid recipient: %v", err)
+		}
+		raw = append([]byte("X-Forwarded-To: "+addr.String()+"\r\n"), raw...)
+	}
+
+	// Parse against every currently allowed domain, not just the static ones
+	cfg := &config.Config{AllowedDomains: h.allowedDomains(ctx), MaxEmailBytes: h.cfg.MaxEmailBytes}
+	msg, err := imapworker.Parse(cfg, raw, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("Invalid message: %v", err)
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("No recipient on an allowed domain (pass ?to=)")
+	}
+	return msg, nil
+}
+
+// allowedDomains merges static and dynamic domains
+func (h *AdminHandler) allowedDomains(ctx context.Context) []string {
+	domains := append([]string(nil), h.cfg.AllowedDomains...)
+	if custom, err := h.store.GetDomains(ctx); err == nil {
+		for _, d := range custom {
+			domains = append(domains, email.CanonicalDomain(d))
+		}
+	}
+	return domains
+}
+
+func (h *AdminHandler) isAllowedDomain(ctx context.Context, d string) bool {
+	d = email.CanonicalDomain(d)
+	for _, allowed := range h.allowedDomains(ctx) {
+		if d == allowed {
+			return true
+		}
+	}
+	return false
+}
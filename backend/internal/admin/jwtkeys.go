@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// RotateJWTKey switches admin tokens to a new signing key. Tokens signed
+// with the previous key stay valid for grace_seconds (default
+// JWT_ROTATION_GRACE_SECONDS), so nobody is logged out immediately.
+func (h *AdminHandler) RotateJWTKey(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		GraceSeconds *int `json:"grace_seconds"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	grace := h.cfg.JWTRotationGraceSecs
+	if req.GraceSeconds != nil {
+		grace = *req.GraceSeconds
+	}
+	if grace < 0 {
+		http.Error(w, "grace_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.auth.Rotate(r.Context(), time.Duration(grace)*time.Second)
+	if err != nil {
+		http.Error(w, "Failed to rotate signing key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kid":                     key.ID,
+		"previous_key_expires_at": time.Now().Add(time.Duration(grace) * time.Second),
+	})
+}
@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/localpolicy"
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetLocalPolicies lists the local part policies of custom addresses, by
+// domain ("*" is the default), and the profanity wordlists available
+func (h *AdminHandler) GetLocalPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.store.GetLocalPolicies(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch local part policies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies":  policies,
+		"languages": localpolicy.Languages(),
+		"defaults": map[string]int{
+			"min_length": localpolicy.DefaultMinLength,
+			"max_length": localpolicy.DefaultMaxLength,
+		},
+	})
+}
+
+// SetLocalPolicy saves the policy of a domain, or the default one for "*"
+func (h *AdminHandler) SetLocalPolicy(w http.ResponseWriter, r *http.Request) {
+	d := chi.URLParam(r, "domain")
+	if d != redisstore.DefaultLocalPolicy {
+		d = email.CanonicalDomain(d)
+		if !h.isKnownDomain(r, d) {
+			http.Error(w, "Domain not found", http.StatusNotFound)
+			return
+		}
+	}
+	var p domain.LocalPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := localpolicy.Validate(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.UpdatedAt = time.Now()
+	if err := h.store.SetLocalPolicy(r.Context(), d, &p); err != nil {
+		http.Error(w, "Failed to save local part policy", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain": d,
+		"policy": p,
+	})
+}
+
+// DeleteLocalPolicy drops the policy of a domain so the default applies
+func (h *AdminHandler) DeleteLocalPolicy(w http.ResponseWriter, r *http.Request) {
+	found, err := h.store.DeleteLocalPolicy(r.Context(), chi.URLParam(r, "domain"))
+	if err != nil {
+		http.Error(w, "Failed to delete local part policy", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "No local part policy", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
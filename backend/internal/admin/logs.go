@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cattymail/internal/redisstore"
+)
+
+// GetLogs lists request log entries, newest first. Filters: level
+// (minimum: info, warn, error), route (prefix of the route pattern or
+// path), since/until (RFC 3339 or unix seconds) and limit.
+func (h *AdminHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	query := redisstore.LogQuery{
+		Level: q.Get("level"),
+		Route: q.Get("route"),
+		Limit: 100,
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 1000 {
+		query.Limit = l
+	}
+	var ok bool
+	if query.Since, ok = parseLogTime(q.Get("since")); !ok {
+		http.Error(w, "Invalid since", http.StatusBadRequest)
+		return
+	}
+	if query.Until, ok = parseLogTime(q.Get("until")); !ok {
+		http.Error(w, "Invalid until", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.store.ListLogs(r.Context(), query)
+	if err != nil {
+		http.Error(w, "Failed to fetch logs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"logs":  entries,
+		"limit": query.Limit,
+	})
+}
+
+// parseLogTime accepts RFC 3339 or unix seconds; empty means unbounded
+func parseLogTime(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, true
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
@@ -0,0 +1,27 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// GetMemory reports estimated Redis memory per key namespace. The optional
+// ?sample= sets how many keys per namespace are measured (default 50).
+func (h *AdminHandler) GetMemory(w http.ResponseWriter, r *http.Request) {
+	sample := 50
+	if s := r.URL.Query().Get("sample"); s != "" {
+		if i, err := strconv.Atoi(s); err == nil && i > 0 && i <= 1000 {
+			sample = i
+		}
+	}
+
+	report, err := h.store.MemoryByNamespace(r.Context(), sample)
+	if err != nil {
+		http.Error(w, "Failed to measure memory", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
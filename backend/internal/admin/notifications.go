@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/notify"
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// GetNotifications lists the domain-wide notification channels
+func (h *AdminHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	all := make([]*domain.NotifyChannel, 0)
+	for _, d := range h.allowedDomains(r.Context()) {
+		channels, err := h.store.ListNotifyChannels(r.Context(), notify.ScopeDomain, d)
+		if err != nil {
+			http.Error(w, "Failed to fetch notification channels", http.StatusInternalServerError)
+			return
+		}
+		all = append(all, channels...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channels": all,
+	})
+}
+
+// AddNotification announces every message for a domain on a chat webhook.
+// The request body is a NotifyChannel whose target is the domain.
+func (h *AdminHandler) AddNotification(w http.ResponseWriter, r *http.Request) {
+	var ch domain.NotifyChannel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	ch.Target = email.CanonicalDomain(ch.Target)
+	if !h.isAllowedDomain(r.Context(), ch.Target) {
+		http.Error(w, "Unknown domain", http.StatusBadRequest)
+		return
+	}
+	ch.ID = ulid.Make().String()
+	ch.Scope = notify.ScopeDomain
+	ch.CreatedAt = time.Now()
+
+	if err := notify.Validate(&ch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := h.store.SaveNotifyChannel(r.Context(), &ch)
+	if errors.Is(err, redisstore.ErrTooManyChannels) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save notification channel", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ch)
+}
+
+// DeleteNotification removes a domain-wide channel
+func (h *AdminHandler) DeleteNotification(w http.ResponseWriter, r *http.Request) {
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	removed, err := h.store.DeleteNotifyChannel(r.Context(), notify.ScopeDomain, d, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to delete notification channel", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"cattymail/internal/email"
+	"cattymail/internal/imapworker"
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ingestCommandTimeout bounds how long admin requests wait for the ingestor
+const ingestCommandTimeout = 10 * time.Second
+
+// sendIngestCommand runs action on the ingestor over the command bus and
+// writes its result
+func (h *AdminHandler) sendIngestCommand(w http.ResponseWriter, r *http.Request, action string, args interface{}) {
+	reply, err := h.store.SendCommand(r.Context(), action, args, ingestCommandTimeout)
+	switch {
+	case errors.Is(err, redisstore.ErrNoListener):
+		http.Error(w, "No ingestor is listening", http.StatusServiceUnavailable)
+		return
+	case errors.Is(err, redisstore.ErrCommandTimeout):
+		http.Error(w, "Ingestor did not reply in time", http.StatusGatewayTimeout)
+		return
+	case err != nil:
+		http.Error(w, "Failed to signal ingestor", http.StatusInternalServerError)
+		return
+	}
+	if reply.Error != "" {
+		http.Error(w, reply.Error, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reply.Result)
+}
+
+// TriggerPoll asks the ingestor to poll IMAP immediately. The optional
+// JSON body limits the cycle to a folder, or re-scans the last
+// since_hours (default 24) of mail for one domain, e.g. after adding a
+// domain whose messages were skipped.
+func (h *AdminHandler) TriggerPoll(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Folder     string `json:"folder"`
+		Domain     string `json:"domain"`
+		SinceHours int    `json:"since_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var args imapworker.PollArgs
+	if req.Folder != "" {
+		known := false
+		for _, f := range imapworker.Folders {
+			known = known || f == req.Folder
+		}
+		if !known {
+			http.Error(w, "Unknown folder", http.StatusBadRequest)
+			return
+		}
+		args.Folder = req.Folder
+	}
+	if req.Domain != "" {
+		args.Domain = email.CanonicalDomain(req.Domain)
+		if !h.isAllowedDomain(r.Context(), args.Domain) {
+			http.Error(w, "Unknown domain", http.StatusBadRequest)
+			return
+		}
+		hours := req.SinceHours
+		if hours <= 0 {
+			hours = 24
+		}
+		args.Since = time.Now().Add(-time.Duration(hours) * time.Hour)
+	}
+
+	h.sendIngestCommand(w, r, imapworker.CommandPoll, args)
+}
+
+// ReloadIngestConfig makes the ingestor reload its domain allowlist now
+// rather than at the start of its next cycle
+func (h *AdminHandler) ReloadIngestConfig(w http.ResponseWriter, r *http.Request) {
+	h.sendIngestCommand(w, r, imapworker.CommandReloadConfig, nil)
+}
+
+// ResetIngestCursor moves a folder's UID cursor, e.g. to re-fetch mail
+// after an outage. Body: {"folder": "INBOX", "uid": 1234}.
+func (h *AdminHandler) ResetIngestCursor(w http.ResponseWriter, r *http.Request) {
+	var args imapworker.ResetCursorArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil || args.Folder == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	h.sendIngestCommand(w, r, imapworker.CommandResetCursor, args)
+}
+
+// GetIngestStatus reports the ingestor's cycle state, cursors and domains
+func (h *AdminHandler) GetIngestStatus(w http.ResponseWriter, r *http.Request) {
+	h.sendIngestCommand(w, r, imapworker.CommandStatus, nil)
+}
@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"cattymail/internal/providers"
+	"cattymail/internal/redisstore"
+)
+
+type providerDomain struct {
+	Domain string  `json:"domain"`
+	Total  int64   `json:"total"`
+	Daily  []int64 `json:"daily"` // oldest first, ending today (UTC)
+	Trend  string  `json:"trend"`
+}
+
+type providerReport struct {
+	Provider string           `json:"provider"`
+	Total    int64            `json:"total"`
+	Domains  []providerDomain `json:"domains"`
+}
+
+// GetProviderReport breaks stored messages down by sending provider and
+// receiving domain over the last ?days (default 14), with a trend per
+// pair, to tell which services currently get through to which domains
+func (h *AdminHandler) GetProviderReport(w http.ResponseWriter, r *http.Request) {
+	days := 14
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= redisstore.ProviderStatsDays {
+		days = d
+	}
+	counts, err := h.store.ProviderCounts(r.Context(), days)
+	if err != nil {
+		http.Error(w, "Failed to fetch provider stats", http.StatusInternalServerError)
+		return
+	}
+
+	byProvider := make(map[string]*providerReport)
+	for d, perProvider := range counts {
+		for p, daily := range perProvider {
+			rep := byProvider[p]
+			if rep == nil {
+				rep = &providerReport{Provider: p}
+				byProvider[p] = rep
+			}
+			var total int64
+			for _, n := range daily {
+				total += n
+			}
+			rep.Total += total
+			rep.Domains = append(rep.Domains, providerDomain{Domain: d, Total: total, Daily: daily, Trend: providers.Trend(daily)})
+		}
+	}
+	report := make([]*providerReport, 0, len(byProvider))
+	for _, rep := range byProvider {
+		sort.Slice(rep.Domains, func(i, j int) bool { return rep.Domains[i].Total > rep.Domains[j].Total })
+		report = append(report, rep)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Total > report[j].Total })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"days":      days,
+		"providers": report,
+	})
+}
@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"cattymail/internal/domain"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetQuarantine lists messages reported as spam
+func (h *AdminHandler) GetQuarantine(w http.ResponseWriter, r *http.Request) {
+	msgs, err := h.store.ListQuarantine(r.Context(), 200)
+	if err != nil {
+		http.Error(w, "Failed to fetch quarantine", http.StatusInternalServerError)
+		return
+	}
+	if msgs == nil {
+		msgs = []*domain.Message{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages": msgs,
+	})
+}
+
+// ReleaseQuarantined returns a message to its inbox, dismissing any
+// report of it. Reports only train the antispam model on confirmation, so
+// there is nothing to untrain.
+func (h *AdminHandler) ReleaseQuarantined(w http.ResponseWriter, r *http.Request) {
+	msg, err := h.store.ReleaseQuarantined(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to release message", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, "Message is not quarantined", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "released",
+	})
+}
+
+// DeleteQuarantined drops a quarantined message. Deleting a user's spam
+// report confirms it: it then counts against the sender and, with
+// antispam on, trains the model.
+func (h *AdminHandler) DeleteQuarantined(w http.ResponseWriter, r *http.Request) {
+	msg, reported, err := h.store.DeleteQuarantined(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, "Message is not quarantined", http.StatusNotFound)
+		return
+	}
+
+	// The message is already gone; the rest is best effort
+	if reported {
+		if _, err := h.store.RecordSpamReport(r.Context(), msg.From); err != nil {
+			log.Printf("Failed to record spam report for %s: %v", msg.ID, err)
+		}
+		if h.cfg.AntispamEnabled {
+			if err := h.store.LearnSpam(r.Context(), msg); err != nil {
+				log.Printf("Failed to train antispam model with %s: %v", msg.ID, err)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// ratePolicyView is one action's effective policy and where it comes from
+type ratePolicyView struct {
+	domain.RatePolicy
+	Source string `json:"source"` // "config" or "custom"
+}
+
+// ratePolicies returns the effective policy of every action
+func (h *AdminHandler) ratePolicies(ctx context.Context) (map[string]ratePolicyView, error) {
+	saved, err := h.store.GetRatePolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]ratePolicyView)
+	for action, p := range h.cfg.RatePolicies() {
+		policies[action] = ratePolicyView{RatePolicy: p, Source: "config"}
+	}
+	for action, p := range saved {
+		policies[action] = ratePolicyView{RatePolicy: p, Source: "custom"}
+	}
+	return policies, nil
+}
+
+// GetRatePolicies lists the effective rate limit policy of every action
+func (h *AdminHandler) GetRatePolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.ratePolicies(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch rate limits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+// SetRatePolicy overrides the policy of an action. It applies on every
+// API instance within a few seconds.
+func (h *AdminHandler) SetRatePolicy(w http.ResponseWriter, r *http.Request) {
+	action := chi.URLParam(r, "action")
+	if _, ok := h.cfg.RatePolicies()[action]; !ok {
+		http.Error(w, "Unknown action", http.StatusNotFound)
+		return
+	}
+	var p domain.RatePolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if p.Limit < 0 || p.WindowSecs <= 0 || p.Burst < 0 {
+		http.Error(w, "limit and burst must not be negative and window_seconds must be positive", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.SetRatePolicy(r.Context(), action, p); err != nil {
+		http.Error(w, "Failed to save rate limit", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"action": action,
+		"policy": p,
+	})
+}
+
+// DeleteRatePolicy reverts an action to its configured policy
+func (h *AdminHandler) DeleteRatePolicy(w http.ResponseWriter, r *http.Request) {
+	found, err := h.store.DeleteRatePolicy(r.Context(), chi.URLParam(r, "action"))
+	if err != nil {
+		http.Error(w, "Failed to delete rate limit", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "No custom rate limit", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
+
+// partnerKeyPrefix marks partner API keys exempted from rate limits
+const partnerKeyPrefix = "catk_"
+
+// GetRateExemptions lists the clients exempted from rate limits
+func (h *AdminHandler) GetRateExemptions(w http.ResponseWriter, r *http.Request) {
+	exemptions, err := h.store.ListRateExemptions(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch exemptions", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(exemptions, func(i, j int) bool { return exemptions[i].CreatedAt.Before(exemptions[j].CreatedAt) })
+	for _, ex := range exemptions {
+		ex.KeyHash = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"exemptions": exemptions,
+	})
+}
+
+// AddRateExemption exempts an IP or CIDR range, or issues a partner API
+// key (sent as X-API-Key) that is. The key is only returned here.
+func (h *AdminHandler) AddRateExemption(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Kind       string `json:"kind"`  // "ip" or "key"
+		Value      string `json:"value"` // IP or CIDR, for "ip"
+		Multiplier int    `json:"multiplier"`
+		Note       string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Multiplier < 0 {
+		http.Error(w, "multiplier must not be negative", http.StatusBadRequest)
+		return
+	}
+	ex := &domain.RateExemption{
+		ID:         strings.ToLower(ulid.Make().String()),
+		Kind:       req.Kind,
+		Multiplier: req.Multiplier,
+		Note:       strings.TrimSpace(req.Note),
+		CreatedAt:  time.Now(),
+	}
+
+	var key string
+	switch req.Kind {
+	case "ip":
+		value := strings.TrimSpace(req.Value)
+		if _, _, err := net.ParseCIDR(value); err != nil && net.ParseIP(value) == nil {
+			http.Error(w, "value must be an IP address or CIDR range", http.StatusBadRequest)
+			return
+		}
+		ex.Value = value
+	case "key":
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			http.Error(w, "Failed to generate key", http.StatusInternalServerError)
+			return
+		}
+		key = partnerKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+		ex.KeyHash = redisstore.RateKeyHash(key)
+	default:
+		http.Error(w, `kind must be "ip" or "key"`, http.StatusBadRequest)
+		return
+	}
+	if err := h.store.SaveRateExemption(r.Context(), ex); err != nil {
+		http.Error(w, "Failed to save exemption", http.StatusInternalServerError)
+		return
+	}
+
+	ex.KeyHash = ""
+	resp := map[string]interface{}{"details": ex}
+	if key != "" {
+		resp["key"] = key
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteRateExemption removes an exemption or revokes a partner key
+func (h *AdminHandler) DeleteRateExemption(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.store.DeleteRateExemption(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to delete exemption", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Exemption not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
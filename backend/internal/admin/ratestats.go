@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// rateActionView is how an action's limit held up over the period
+type rateActionView struct {
+	redisstore.RateStats
+	RejectedPct float64 `json:"rejected_pct"`
+}
+
+// GetRateLimits shows the rate limiter at work: the effective policies,
+// the requests each action allowed and rejected over the last ?minutes=
+// (default 15), and the clients rejected most with their current bucket.
+// ?action= narrows the clients to one action.
+func (h *AdminHandler) GetRateLimits(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	minutes := 15
+	if m, err := strconv.Atoi(q.Get("minutes")); err == nil && m > 0 && m <= redisstore.RateStatsMinutes {
+		minutes = m
+	}
+	limit := 20
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	ctx := r.Context()
+	policies, err := h.ratePolicies(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch rate limits", http.StatusInternalServerError)
+		return
+	}
+	stats, err := h.store.RateLimitStats(ctx, minutes)
+	if err != nil {
+		http.Error(w, "Failed to fetch rate limit stats", http.StatusInternalServerError)
+		return
+	}
+	top, err := h.store.TopRateLimited(ctx, q.Get("action"), minutes, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch rate limit counters", http.StatusInternalServerError)
+		return
+	}
+
+	actions := make(map[string]rateActionView, len(stats))
+	for action, st := range stats {
+		v := rateActionView{RateStats: *st}
+		if total := st.Allowed + st.Rejected; total > 0 {
+			v.RejectedPct = math.Round(float64(st.Rejected)*1000/float64(total)) / 10
+		}
+		actions[action] = v
+	}
+	now := time.Now()
+	for _, c := range top {
+		// Report the bucket as it is now: refilled since its last use, or
+		// full once it expired
+		if p, ok := policies[c.Action]; ok && p.WindowSecs > 0 {
+			capacity := float64(p.Limit + p.Burst)
+			if c.UpdatedAt.IsZero() {
+				c.Tokens = capacity
+			} else {
+				refill := now.Sub(c.UpdatedAt).Seconds() * float64(p.Limit) / float64(p.WindowSecs)
+				c.Tokens = math.Floor(math.Min(capacity, c.Tokens+refill)*10) / 10
+			}
+		}
+		if c.Action == "inbox" {
+			c.Key = maskAddressKey(ctx, c.Key)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"minutes":  minutes,
+		"policies": policies,
+		"actions":  actions,
+		"top":      top,
+	})
+}
+
+// ResetRateLimit clears one client's bucket for an action, e.g. to unblock
+// a user throttled by mistake
+func (h *AdminHandler) ResetRateLimit(w http.ResponseWriter, r *http.Request) {
+	action, key := chi.URLParam(r, "action"), chi.URLParam(r, "key")
+	found, err := h.store.ResetRateLimit(r.Context(), action, key)
+	if err != nil {
+		http.Error(w, "Failed to reset rate limit", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "No counter for this key", http.StatusNotFound)
+		return
+	}
+	h.audit(r, "ratelimit.reset", action+":"+key, "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+}
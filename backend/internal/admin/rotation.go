@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+)
+
+// rotationStrategies are the strategies domain.DomainRotation accepts
+var rotationStrategies = map[string]bool{"weighted": true, "least_loaded": true, "newest": true}
+
+// GetDomainRotation reports how domains are picked for addresses created
+// without one, and how many addresses each domain got lately
+func (h *AdminHandler) GetDomainRotation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	rot, err := h.store.GetDomainRotation(ctx)
+	if err != nil {
+		http.Error(w, "Failed to fetch domain rotation", http.StatusInternalServerError)
+		return
+	}
+	source := "custom"
+	if rot == nil {
+		rot = &domain.DomainRotation{Strategy: h.cfg.DomainRotation}
+		source = "config"
+	}
+
+	domains := append([]string(nil), h.cfg.AllowedDomains...)
+	if dynamic, err := h.store.GetDomains(ctx); err == nil {
+		domains = append(domains, dynamic...)
+	}
+	load := map[string]int64{}
+	if len(domains) > 0 {
+		if load, err = h.store.DomainLoad(ctx, domains); err != nil {
+			http.Error(w, "Failed to fetch domain usage", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rotation": rot,
+		"source":   source,
+		"assigned": load, // addresses handed out over the last one to two hours
+	})
+}
+
+// SaveDomainRotation sets the strategy and weights
+func (h *AdminHandler) SaveDomainRotation(w http.ResponseWriter, r *http.Request) {
+	var rot domain.DomainRotation
+	if err := json.NewDecoder(r.Body).Decode(&rot); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !rotationStrategies[rot.Strategy] {
+		http.Error(w, `strategy must be "weighted", "least_loaded" or "newest"`, http.StatusBadRequest)
+		return
+	}
+	weights := make(map[string]int, len(rot.Weights))
+	for d, weight := range rot.Weights {
+		if weight < 0 {
+			http.Error(w, "weights must not be negative", http.StatusBadRequest)
+			return
+		}
+		weights[email.CanonicalDomain(d)] = weight
+	}
+	rot.Weights = weights
+	rot.UpdatedAt = time.Now()
+
+	if err := h.store.SaveDomainRotation(r.Context(), &rot); err != nil {
+		http.Error(w, "Failed to save domain rotation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rotation": rot,
+	})
+}
@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSender returns the profile of a sender address
+func (h *AdminHandler) GetSender(w http.ResponseWriter, r *http.Request) {
+	address, err := url.PathUnescape(chi.URLParam(r, "address"))
+	if err != nil || address == "" {
+		http.Error(w, "Invalid sender address", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if i, err := strconv.Atoi(l); err == nil && i > 0 && i <= 100 {
+			limit = i
+		}
+	}
+
+	profile, err := h.store.GetSenderProfile(r.Context(), address, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch sender", http.StatusInternalServerError)
+		return
+	}
+	if profile == nil {
+		http.Error(w, "Sender not found", http.StatusNotFound)
+		return
+	}
+
+	if wantsCSV(r) {
+		out := newCSVResponse(w, "sender.csv", "address", "message_count", "first_seen", "last_seen", "domains", "message_id", "to", "subject", "date")
+		row := []string{
+			profile.Address,
+			strconv.FormatInt(profile.MessageCount, 10),
+			profile.FirstSeen.Format(time.RFC3339),
+			profile.LastSeen.Format(time.RFC3339),
+			strings.Join(profile.Domains, " "),
+		}
+		if len(profile.RecentMessages) == 0 {
+			out.Write(append(row, "", "", "", "")...)
+		}
+		for _, m := range profile.RecentMessages {
+			out.Write(append(row[:5:5], m.ID, m.OriginalTo, m.Subject, m.Date.Format(time.RFC3339))...)
+		}
+		out.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"cattymail/internal/domain"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// GetShadow reports how shadow ingestion into the dark-launched store is
+// going: write and comparison counters and the latest divergences
+func (h *AdminHandler) GetShadow(w http.ResponseWriter, r *http.Request) {
+	limit := int64(50)
+	if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+	stats, enabled, err := h.store.ShadowStats(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch shadow stats", http.StatusInternalServerError)
+		return
+	}
+	diffs, err := h.store.ShadowDivergences(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch shadow divergences", http.StatusInternalServerError)
+		return
+	}
+	if diffs == nil {
+		diffs = []*domain.ShadowDivergence{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":     enabled,
+		"stats":       stats,
+		"divergences": diffs,
+	})
+}
+
+// ResetShadow clears the shadow counters and divergences
+func (h *AdminHandler) ResetShadow(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.ResetShadow(r.Context()); err != nil {
+		http.Error(w, "Failed to reset shadow stats", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, "shadow.reset", "", "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+}
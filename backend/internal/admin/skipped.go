@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// GetSkipped lists the sampled messages the ingestor skipped for lack of
+// an allowed recipient, with a tally of the recipient domains they were
+// sent to so a missing domain stands out
+func (h *AdminHandler) GetSkipped(w http.ResponseWriter, r *http.Request) {
+	skipped, err := h.store.ListSkipped(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch skipped messages", http.StatusInternalServerError)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, sm := range skipped {
+		for _, d := range sm.Domains {
+			counts[d]++
+		}
+	}
+	var domains []map[string]interface{}
+	for d, n := range counts {
+		domains = append(domains, map[string]interface{}{
+			"domain": d,
+			"count":  n,
+		})
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		return domains[i]["count"].(int) > domains[j]["count"].(int)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"skipped": skipped,
+		"domains": domains,
+	})
+}
+
+// ClearSkipped empties the skipped-message sample
+func (h *AdminHandler) ClearSkipped(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.ClearSkipped(r.Context()); err != nil {
+		http.Error(w, "Failed to clear skipped messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "cleared",
+	})
+}
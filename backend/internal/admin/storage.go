@@ -0,0 +1,36 @@
+package admin
+
+import (
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// GetStorage reports the bytes stored per domain and the biggest inboxes
+func (h *AdminHandler) GetStorage(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+	inboxes, domains, err := h.store.StorageReport(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch storage usage", http.StatusInternalServerError)
+		return
+	}
+	if inboxes == nil {
+		inboxes = []redisstore.StorageUsage{}
+	}
+	if domains == nil {
+		domains = []redisstore.StorageUsage{}
+	}
+	inboxQuota, domainQuota := h.store.StorageQuotas()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"inboxes":            inboxes,
+		"domains":            domains,
+		"inbox_quota_bytes":  inboxQuota,
+		"domain_quota_bytes": domainQuota,
+	})
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StreamIngest streams metadata of every ingested message over SSE
+func (h *AdminHandler) StreamIngest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable Nginx buffering
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	pubsub := h.store.SubscribeIngestFeed(r.Context())
+	defer pubsub.Close()
+
+	keepalive := time.NewTicker(20 * time.Second)
+	defer keepalive.Stop()
+
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			// Payload is already a JSON-encoded domain.IngestEvent
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg.Payload)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package admin
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/webauthn"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// Passkey login for the admin panel. Enrollment needs a valid admin token;
+// login trades a signed challenge for the same token the password flow
+// issues. The browser side passes the options straight to
+// navigator.credentials.create/get after decoding the base64url fields.
+
+const webAuthnTimeout = 5 * time.Minute
+
+// adminUserHandle identifies the single admin account to authenticators
+var adminUserHandle = []byte("cattymail-admin")
+
+// WebAuthnEnabled reports whether WEBAUTHN_RP_ID is configured
+func (h *AdminHandler) WebAuthnEnabled() bool {
+	return h.rp != nil
+}
+
+func newRelyingParty(rpID string, origins []string) *webauthn.RelyingParty {
+	if rpID == "" {
+		return nil
+	}
+	if len(origins) == 0 {
+		origins = []string{"https://" + rpID}
+	}
+	return &webauthn.RelyingParty{ID: rpID, Name: "CattyMail Admin", Origins: origins}
+}
+
+type credentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// beginCeremony stores a fresh challenge and returns its session ID
+func (h *AdminHandler) beginCeremony(w http.ResponseWriter, r *http.Request) (string, []byte, bool) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		return "", nil, false
+	}
+	session := ulid.Make().String()
+	if err := h.store.SaveWebAuthnChallenge(r.Context(), session, challenge, webAuthnTimeout); err != nil {
+		http.Error(w, "Failed to store challenge", http.StatusInternalServerError)
+		return "", nil, false
+	}
+	return session, challenge, true
+}
+
+func (h *AdminHandler) credentialDescriptors(r *http.Request) ([]credentialDescriptor, error) {
+	creds, err := h.store.ListWebAuthnCredentials(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	descs := make([]credentialDescriptor, len(creds))
+	for i, c := range creds {
+		descs[i] = credentialDescriptor{Type: "public-key", ID: c.ID}
+	}
+	return descs, nil
+}
+
+// BeginWebAuthnRegistration returns creation options for a new passkey
+func (h *AdminHandler) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	exclude, err := h.credentialDescriptors(r)
+	if err != nil {
+		http.Error(w, "Failed to fetch credentials", http.StatusInternalServerError)
+		return
+	}
+	session, challenge, ok := h.beginCeremony(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": session,
+		"options": map[string]interface{}{
+			"challenge": webauthn.Encode(challenge),
+			"rp":        map[string]string{"id": h.rp.ID, "name": h.rp.Name},
+			"user": map[string]string{
+				"id":          webauthn.Encode(adminUserHandle),
+				"name":        "admin",
+				"displayName": "Administrator",
+			},
+			"pubKeyCredParams": []map[string]interface{}{
+				{"type": "public-key", "alg": webauthn.AlgES256},
+				{"type": "public-key", "alg": webauthn.AlgRS256},
+			},
+			"timeout":            webAuthnTimeout.Milliseconds(),
+			"attestation":        "none",
+			"excludeCredentials": exclude,
+			"authenticatorSelection": map[string]string{
+				"residentKey":      "preferred",
+				"userVerification": "required",
+			},
+		},
+	})
+}
+
+// FinishWebAuthnRegistration verifies and stores a new passkey
+func (h *AdminHandler) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionID string `json:"session_id"`
+		Name      string `json:"name"`
+		Response  struct {
+			ClientDataJSON    string `json:"clientDataJSON"`
+			AttestationObject string `json:"attestationObject"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	challenge, ok := h.takeChallenge(w, r, req.SessionID)
+	if !ok {
+		return
+	}
+	clientData, err1 := webauthn.Decode(req.Response.ClientDataJSON)
+	attestation, err2 := webauthn.Decode(req.Response.AttestationObject)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "Invalid response encoding", http.StatusBadRequest)
+		return
+	}
+
+	cred, err := h.rp.VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := req.Name
+	if name == "" {
+		name = "Security key"
+	}
+	stored := &domain.WebAuthnCredential{
+		ID:        webauthn.Encode(cred.ID),
+		Name:      name,
+		PublicKey: webauthn.Encode(cred.PublicKey),
+		Algorithm: cred.Algorithm,
+		SignCount: cred.SignCount,
+		CreatedAt: time.Now(),
+	}
+	if err := h.store.SaveWebAuthnCredential(r.Context(), stored); err != nil {
+		http.Error(w, "Failed to save credential", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// GetWebAuthnCredentials lists the registered passkeys
+func (h *AdminHandler) GetWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	creds, err := h.store.ListWebAuthnCredentials(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch credentials", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"credentials": creds,
+	})
+}
+
+// DeleteWebAuthnCredential revokes a passkey
+func (h *AdminHandler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.store.DeleteWebAuthnCredential(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to delete credential", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Credential not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "deleted",
+	})
+}
+
+// BeginWebAuthnLogin returns request options listing the registered
+// passkeys
+func (h *AdminHandler) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	allow, err := h.credentialDescriptors(r)
+	if err != nil {
+		http.Error(w, "Failed to fetch credentials", http.StatusInternalServerError)
+		return
+	}
+	if len(allow) == 0 {
+		http.Error(w, "No passkeys registered", http.StatusNotFound)
+		return
+	}
+	session, challenge, ok := h.beginCeremony(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": session,
+		"options": map[string]interface{}{
+			"challenge":        webauthn.Encode(challenge),
+			"rpId":             h.rp.ID,
+			"timeout":          webAuthnTimeout.Milliseconds(),
+			"allowCredentials": allow,
+			"userVerification": "required",
+		},
+	})
+}
+
+// FinishWebAuthnLogin verifies a signed challenge and issues an admin token
+func (h *AdminHandler) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionID string `json:"session_id"`
+		ID        string `json:"id"`
+		Response  struct {
+			ClientDataJSON    string `json:"clientDataJSON"`
+			AuthenticatorData string `json:"authenticatorData"`
+			Signature         string `json:"signature"`
+		} `json:"response"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	challenge, ok := h.takeChallenge(w, r, req.SessionID)
+	if !ok {
+		return
+	}
+	cred, err := h.store.GetWebAuthnCredential(r.Context(), req.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch credential", http.StatusInternalServerError)
+		return
+	}
+	if cred == nil {
+		http.Error(w, "Unknown credential", http.StatusUnauthorized)
+		return
+	}
+	clientData, err1 := webauthn.Decode(req.Response.ClientDataJSON)
+	authData, err2 := webauthn.Decode(req.Response.AuthenticatorData)
+	sig, err3 := webauthn.Decode(req.Response.Signature)
+	publicKey, err4 := webauthn.Decode(cred.PublicKey)
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		http.Error(w, "Invalid response encoding", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.rp.VerifyAssertion(challenge, publicKey, clientData, authData, sig)
+	if err != nil {
+		http.Error(w, "Invalid passkey", http.StatusUnauthorized)
+		return
+	}
+	// A counter that does not move forward means the key may be cloned;
+	// authenticators that do not count always report 0
+	if (count != 0 || cred.SignCount != 0) && count <= cred.SignCount {
+		log.Printf("Rejected passkey %s: sign count %d not above %d", cred.ID, count, cred.SignCount)
+		http.Error(w, "Invalid passkey", http.StatusUnauthorized)
+		return
+	}
+	cred.SignCount = count
+	cred.LastUsedAt = time.Now()
+	if err := h.store.SaveWebAuthnCredential(r.Context(), cred); err != nil {
+		http.Error(w, "Failed to update credential", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := h.auth.GenerateToken(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"token": token,
+	})
+}
+
+// takeChallenge consumes the challenge of session, answering the request
+// itself when it is unknown or expired
+func (h *AdminHandler) takeChallenge(w http.ResponseWriter, r *http.Request, session string) ([]byte, bool) {
+	if session == "" {
+		http.Error(w, "Missing session_id", http.StatusBadRequest)
+		return nil, false
+	}
+	challenge, err := h.store.TakeWebAuthnChallenge(r.Context(), session)
+	if err != nil {
+		http.Error(w, "Failed to fetch challenge", http.StatusInternalServerError)
+		return nil, false
+	}
+	if challenge == nil {
+		http.Error(w, "Challenge expired, start again", http.StatusBadRequest)
+		return nil, false
+	}
+	return challenge, true
+}
@@ -0,0 +1,245 @@
+// Package alert evaluates operator-defined health rules on a schedule and
+// notifies by email, webhook or Telegram when a rule starts or stops
+// firing.
+package alert
+
+import (
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"time"
+)
+
+// Rule kinds
+const (
+	KindIngestStalled = "ingest_stalled"
+	KindErrorRate     = "error_rate"
+	KindRedisDown     = "redis_down"
+	KindDLQNonEmpty   = "dlq_nonempty"
+	KindHoneypotHit   = "honeypot_hit"
+)
+
+// Delivery channels
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+)
+
+var (
+	ErrInvalidKind    = errors.New("kind must be ingest_stalled, error_rate, redis_down, dlq_nonempty or honeypot_hit")
+	ErrInvalidChannel = errors.New("channel must be email, webhook or telegram")
+	ErrInvalidTarget  = errors.New("target is not valid for the channel")
+	ErrNotConfigured  = errors.New("channel is not configured on this server")
+)
+
+// Validate checks rule against the kinds and channels this server supports
+func Validate(cfg *config.Config, rule *domain.AlertRule) error {
+	switch rule.Kind {
+	case KindIngestStalled, KindErrorRate, KindRedisDown, KindDLQNonEmpty, KindHoneypotHit:
+	default:
+		return ErrInvalidKind
+	}
+	if rule.Threshold < 0 {
+		return fmt.Errorf("threshold must not be negative")
+	}
+
+	switch rule.Channel {
+	case ChannelEmail:
+		if _, err := mail.ParseAddress(rule.Target); err != nil {
+			return ErrInvalidTarget
+		}
+		if cfg.SMTPAddr == "" || cfg.SMTPFrom == "" {
+			return ErrNotConfigured
+		}
+	case ChannelWebhook:
+		u, err := url.Parse(rule.Target)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return ErrInvalidTarget
+		}
+	case ChannelTelegram:
+		if rule.Target == "" {
+			return ErrInvalidTarget
+		}
+		if cfg.TelegramBotToken == "" {
+			return ErrNotConfigured
+		}
+	default:
+		return ErrInvalidChannel
+	}
+	return nil
+}
+
+// Engine evaluates rules every ALERT_INTERVAL_SECONDS. Run it in one
+// process only (the ingestor) so each transition is announced once.
+type Engine struct {
+	cfg      *config.Config
+	store    *redisstore.Store
+	notifier *notifier
+
+	// Rules and firing state survive Redis outages in memory, so a
+	// redis_down rule can still fire and be delivered
+	rules  []*domain.AlertRule
+	firing map[string]*domain.Alert
+}
+
+// NewEngine returns an engine for store
+func NewEngine(cfg *config.Config, store *redisstore.Store) *Engine {
+	return &Engine{
+		cfg:      cfg,
+		store:    store,
+		notifier: newNotifier(cfg),
+		firing:   make(map[string]*domain.Alert),
+	}
+}
+
+// Run blocks until ctx is cancelled
+func (e *Engine) Run(ctx context.Context) {
+	interval := time.Duration(e.cfg.AlertIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if alerts, err := e.store.ListFiringAlerts(ctx); err == nil {
+		for _, a := range alerts {
+			e.firing[a.RuleID] = a
+		}
+	}
+
+	log.Println("Alert scheduler started")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.evaluate(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Engine) evaluate(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	redisUp := e.store.Ping(ctx) == nil
+	if redisUp {
+		if rules, err := e.store.ListAlertRules(ctx); err == nil {
+			e.rules = rules
+		}
+	}
+
+	active := make(map[string]bool, len(e.rules))
+	for _, rule := range e.rules {
+		active[rule.ID] = true
+		message, firing := e.check(ctx, rule, redisUp)
+		current, wasFiring := e.firing[rule.ID]
+		switch {
+		case firing && !wasFiring:
+			a := &domain.Alert{RuleID: rule.ID, Name: rule.Name, Kind: rule.Kind, Message: message, Since: time.Now()}
+			e.firing[rule.ID] = a
+			e.send(ctx, rule, "FIRING: "+alertTitle(rule)+"\n"+message)
+		case firing:
+			current.Message = message
+		case wasFiring:
+			delete(e.firing, rule.ID)
+			e.send(ctx, rule, fmt.Sprintf("RESOLVED: %s (was firing since %s)", alertTitle(rule), current.Since.UTC().Format(time.RFC3339)))
+		}
+	}
+	// Deleted rules stop firing silently
+	for id := range e.firing {
+		if !active[id] {
+			delete(e.firing, id)
+		}
+	}
+
+	if redisUp {
+		alerts := make([]*domain.Alert, 0, len(e.firing))
+		for _, a := range e.firing {
+			alerts = append(alerts, a)
+		}
+		if err := e.store.SetFiringAlerts(ctx, alerts); err != nil {
+			log.Printf("alert: failed to store firing alerts: %v", err)
+		}
+	}
+}
+
+// check reports whether rule is firing and why. Rules other than
+// redis_down cannot be evaluated while Redis is down and keep their state.
+func (e *Engine) check(ctx context.Context, rule *domain.AlertRule, redisUp bool) (string, bool) {
+	if rule.Kind == KindRedisDown {
+		return "Redis is not responding", !redisUp
+	}
+	if !redisUp {
+		a, ok := e.firing[rule.ID]
+		if ok {
+			return a.Message, true
+		}
+		return "", false
+	}
+
+	switch rule.Kind {
+	case KindIngestStalled:
+		last, err := e.store.LastIngestCycle(ctx)
+		if err != nil || last.IsZero() {
+			return "", false
+		}
+		stalled := time.Since(last)
+		if stalled.Minutes() > rule.Threshold {
+			return fmt.Sprintf("No IMAP cycle completed for %s (last at %s)", stalled.Round(time.Second), last.UTC().Format(time.RFC3339)), true
+		}
+	case KindErrorRate:
+		window := time.Duration(rule.Window) * time.Minute
+		if window <= 0 {
+			window = 5 * time.Minute
+		}
+		total, failed, err := e.store.RequestErrorRate(ctx, time.Now().Add(-window))
+		if err != nil || total == 0 {
+			return "", false
+		}
+		rate := float64(failed) / float64(total) * 100
+		if rate > rule.Threshold {
+			return fmt.Sprintf("%.1f%% of requests failed in the last %s (%d of %d)", rate, window, failed, total), true
+		}
+	case KindDLQNonEmpty:
+		n, err := e.store.CountDeadLetters(ctx)
+		if err != nil {
+			return "", false
+		}
+		if float64(n) > rule.Threshold {
+			return fmt.Sprintf("%d messages in the dead-letter queue", n), true
+		}
+	case KindHoneypotHit:
+		window := time.Duration(rule.Window) * time.Minute
+		if window <= 0 {
+			window = time.Hour
+		}
+		n, err := e.store.CountHoneypotHits(ctx, time.Now().Add(-window))
+		if err != nil {
+			return "", false
+		}
+		if float64(n) > rule.Threshold {
+			return fmt.Sprintf("%d honeypot hits in the last %s; someone is enumerating addresses", n, window), true
+		}
+	}
+	return "", false
+}
+
+func (e *Engine) send(ctx context.Context, rule *domain.AlertRule, text string) {
+	if err := e.notifier.send(ctx, rule, text); err != nil {
+		log.Printf("alert: rule %s (%s via %s): %v", rule.ID, rule.Kind, rule.Channel, err)
+	}
+}
+
+func alertTitle(rule *domain.AlertRule) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return rule.Kind
+}
@@ -0,0 +1,96 @@
+package alert
+
+import (
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// notifier delivers alert texts over the rule's channel
+type notifier struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+func newNotifier(cfg *config.Config) *notifier {
+	return &notifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *notifier) send(ctx context.Context, rule *domain.AlertRule, text string) error {
+	switch rule.Channel {
+	case ChannelEmail:
+		return n.email(rule.Target, text)
+	case ChannelWebhook:
+		return n.postJSON(ctx, rule.Target, map[string]interface{}{
+			"rule_id": rule.ID,
+			"name":    rule.Name,
+			"kind":    rule.Kind,
+			"text":    text,
+			"sent_at": time.Now().UTC(),
+		})
+	case ChannelTelegram:
+		endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", n.cfg.TelegramBotToken)
+		return n.postJSON(ctx, endpoint, map[string]string{
+			"chat_id": rule.Target,
+			"text":    text,
+		})
+	}
+	return ErrInvalidChannel
+}
+
+func (n *notifier) postJSON(ctx context.Context, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The Telegram URL carries the bot token; keep it out of logs
+		if token := n.cfg.TelegramBotToken; token != "" {
+			return fmt.Errorf("post failed: %s", strings.ReplaceAll(err.Error(), token, "…"))
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (n *notifier) email(to, text string) error {
+	if n.cfg.SMTPAddr == "" || n.cfg.SMTPFrom == "" {
+		return ErrNotConfigured
+	}
+	subject, _, _ := strings.Cut(text, "\n")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.cfg.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: [cattymail] %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if n.cfg.SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(n.cfg.SMTPAddr)
+		auth = smtp.PlainAuth("", n.cfg.SMTPUser, n.cfg.SMTPPass, host)
+	}
+	return smtp.SendMail(n.cfg.SMTPAddr, auth, n.cfg.SMTPFrom, []string{to}, msg.Bytes())
+}
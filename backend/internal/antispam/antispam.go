@@ -0,0 +1,83 @@
+// Package antispam scores messages with a Naive Bayes model trained from
+// user spam reports. Token counts live in Redis (see redisstore/antispam.go);
+// this package only tokenizes and combines them.
+package antispam
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"cattymail/internal/domain"
+)
+
+const (
+	// maxTokens bounds the tokens taken from one message
+	maxTokens = 300
+	// MinDocs is how many messages of each class the model needs before
+	// its scores mean anything; until then Score returns Neutral
+	MinDocs = 20
+	// Neutral is the score of a message the model cannot judge
+	Neutral = 0.5
+)
+
+// Tokenize returns the distinct features of msg: words of the subject and
+// body (subject words prefixed "s:") and the sender's domain
+func Tokenize(msg *domain.Message) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(t string) {
+		if len(tokens) < maxTokens && !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+
+	if _, d, ok := strings.Cut(strings.ToLower(msg.From), "@"); ok {
+		add("from:" + strings.TrimRight(d, "> "))
+	}
+	for _, w := range words(msg.Subject) {
+		add("s:" + w)
+	}
+	for _, w := range words(msg.Text) {
+		add(w)
+	}
+	return tokens
+}
+
+// words splits s into lowercase words of 3 to 24 characters
+func words(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '$' && r != '\''
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if n := len([]rune(f)); n >= 3 && n <= 24 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Counts is the slice of the model relevant to one message: the number of
+// messages trained per class and, per token, how many of them contained it
+type Counts struct {
+	SpamDocs, HamDocs int64
+	Spam, Ham         []int64 // parallel to the tokens scored
+}
+
+// Score returns the probability in [0, 1] that a message with counts is
+// spam, using Laplace-smoothed per-token likelihoods
+func Score(c Counts) float64 {
+	if c.SpamDocs < MinDocs || c.HamDocs < MinDocs {
+		return Neutral
+	}
+	// Work in log odds so long messages do not underflow
+	logOdds := math.Log(float64(c.SpamDocs)) - math.Log(float64(c.HamDocs))
+	for i := range c.Spam {
+		pSpam := (float64(c.Spam[i]) + 1) / (float64(c.SpamDocs) + 2)
+		pHam := (float64(c.Ham[i]) + 1) / (float64(c.HamDocs) + 2)
+		logOdds += math.Log(pSpam) - math.Log(pHam)
+	}
+	return 1 / (1 + math.Exp(-logOdds))
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+)
+
+// abuseCategories are the kinds of abuse a report can be filed under
+var abuseCategories = map[string]bool{
+	"harassment": true,
+	"phishing":   true,
+	"spam":       true,
+	"fraud":      true,
+	"illegal":    true,
+	"other":      true,
+}
+
+// maxAbuseDescription bounds the free text of a report, in characters
+const maxAbuseDescription = 2000
+
+// reportAbuse files an abuse report against a temp address, for anyone
+// (typically someone receiving mail from it) to use. The report lands in
+// the admin review queue.
+func (h *Handler) reportAbuse(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Address     string `json:"address"`
+		MessageID   string `json:"message_id"`
+		Category    string `json:"category"`
+		Description string `json:"description"`
+		Contact     string `json:"contact"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	if !abuseCategories[req.Category] {
+		http.Error(w, "category must be harassment, phishing, spam, fraud, illegal or other", http.StatusBadRequest)
+		return
+	}
+	req.Description = strings.TrimSpace(req.Description)
+	if utf8.RuneCountInString(req.Description) > maxAbuseDescription || len(req.Contact) > 254 {
+		http.Error(w, "Report too long", http.StatusBadRequest)
+		return
+	}
+
+	rep := &domain.AbuseReport{
+		MessageID:   strings.TrimSpace(req.MessageID),
+		Category:    req.Category,
+		Description: req.Description,
+		Contact:     strings.TrimSpace(req.Contact),
+		ClientIP:    clientIP(r),
+	}
+	if req.Address != "" {
+		addr, err := email.Parse(strings.TrimSpace(req.Address))
+		if err != nil {
+			http.Error(w, "Invalid address", http.StatusBadRequest)
+			return
+		}
+		rep.Domain, rep.Local = addr.Domain, addr.Local
+	}
+	if rep.MessageID != "" {
+		msg, err := h.store.GetMessage(r.Context(), rep.MessageID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		switch {
+		case msg == nil && rep.Local == "":
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		case msg == nil:
+			rep.MessageID = "" // Expired; the address still identifies the inbox
+		case rep.Local == "":
+			rep.Domain, rep.Local = msg.Domain, msg.Local
+		case msg.Domain != rep.Domain || msg.Local != rep.Local:
+			http.Error(w, "Message does not belong to the address", http.StatusBadRequest)
+			return
+		}
+	}
+	if rep.Local == "" {
+		http.Error(w, "address or message_id is required", http.StatusBadRequest)
+		return
+	}
+	if !h.isValidDomain(r.Context(), rep.Domain) {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SaveAbuseReport(r.Context(), rep); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "received",
+		"id":     rep.ID,
+	})
+}
+
+// inboxGuard refuses the inbox routes (/inbox/{domain}/{local}/... and
+// /stream/{domain}/{local}) of blocked and frozen addresses
+func (h *Handler) inboxGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, local, ok := inboxFromPath(unversionedPath(r.URL.Path)); ok {
+			if refusal := h.inboxRefusal(r.Context(), d, local); refusal != "" {
+				http.Error(w, refusal, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// inboxRefusal returns why local@d may not be read, or ""
+func (h *Handler) inboxRefusal(ctx context.Context, d, local string) string {
+	if h.store.IsBlocked(ctx, d, local) {
+		return "Address blocked for abuse"
+	}
+	if reason, frozen := h.store.FrozenReason(ctx, d, local); frozen {
+		return "Address frozen: " + reason
+	}
+	return ""
+}
+
+// inboxFromPath returns the address an inbox route is about
+func inboxFromPath(path string) (d, local string, ok bool) {
+	for _, prefix := range []string{"/api/inbox/", "/api/stream/"} {
+		if rest, found := strings.CutPrefix(path, prefix); found {
+			parts := strings.SplitN(rest, "/", 3)
+			if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+				return "", "", false
+			}
+			return email.CanonicalDomain(parts[0]), strings.ToLower(parts[1]), true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"cattymail/internal/billing"
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLen is the shortest password accepted at registration
+const minPasswordLen = 8
+
+type accountRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// decodeAccountRequest reads credentials and canonicalizes the email
+func decodeAccountRequest(w http.ResponseWriter, r *http.Request) (*accountRequest, bool) {
+	var req accountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	addr, err := email.Parse(req.Email)
+	if err != nil {
+		http.Error(w, "Invalid email", http.StatusBadRequest)
+		return nil, false
+	}
+	req.Email = addr.String()
+	return &req, true
+}
+
+// register creates an account and logs the caller's session into it
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAccountRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Password) < minPasswordLen {
+		http.Error(w, "Password must be at least 8 characters", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Failed to create account", http.StatusInternalServerError)
+		return
+	}
+
+	acct := &domain.Account{
+		ID:        ulid.Make().String(),
+		Email:     req.Email,
+		Plan:      billing.PlanFree,
+		CreatedAt: time.Now(),
+	}
+	err = h.store.CreateAccount(r.Context(), acct, string(hash))
+	if errors.Is(err, redisstore.ErrAccountExists) {
+		http.Error(w, "Account already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create account", http.StatusInternalServerError)
+		return
+	}
+
+	h.logIn(w, r, acct)
+}
+
+// login verifies credentials and logs the caller's session into the account
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAccountRequest(w, r)
+	if !ok {
+		return
+	}
+
+	acct, hash, err := h.store.GetAccountByEmail(r.Context(), req.Email)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if acct == nil || bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil {
+		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	h.logIn(w, r, acct)
+}
+
+func (h *Handler) logIn(w http.ResponseWriter, r *http.Request, acct *domain.Account) {
+	// Always issue a fresh session on login so a pre-login token cannot be
+	// used to ride the account
+	sess := h.startSession(w, r)
+	sess.AccountID = acct.ID
+	sess.Plan = acct.Plan
+
+	ttl := h.planFor(sess).TTL
+	if err := h.store.BindSessionAccount(r.Context(), sess.ID, acct.ID, ttl); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"account": acct,
+		"limits":  h.limitsFor(sess),
+	})
+}
+
+// logout detaches the caller's session from its account
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	if sess := sessionFrom(r.Context()); sess != nil && sess.AccountID != "" {
+		if err := h.store.UnbindSessionAccount(r.Context(), sess.ID); err != nil {
+			log.Printf("accounts: failed to log out session %s: %v", sess.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) limitsFor(sess *session) map[string]interface{} {
+	plan := h.planFor(sess)
+	return map[string]interface{}{
+		"plan":            plan.ID,
+		"max_addresses":   plan.MaxAddresses,
+		"max_domains":     plan.MaxDomains,
+		"ttl_seconds":     int(plan.TTL / time.Second),
+		"rate_multiplier": plan.RateMultiplier,
+	}
+}
+
+// getMe describes the caller's tier and, when logged in, their account
+func (h *Handler) getMe(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFrom(r.Context())
+	resp := map[string]interface{}{
+		"limits": h.limitsFor(sess),
+	}
+
+	if sess != nil && sess.AccountID != "" {
+		acct, _, err := h.store.GetAccount(r.Context(), sess.AccountID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		resp["account"] = acct
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// getMyHistory lists every address the logged-in account has created
+func (h *Handler) getMyHistory(w http.ResponseWriter, r *http.Request) {
+	sess := sessionFrom(r.Context())
+	if sess == nil || sess.AccountID == "" {
+		http.Error(w, "Login required", http.StatusUnauthorized)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if i, err := strconv.Atoi(l); err == nil && i > 0 && i <= 500 {
+			limit = i
+		}
+	}
+
+	history, err := h.store.AddressHistory(r.Context(), sess.AccountID, limit)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"history": history,
+	})
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/localpolicy"
+	"cattymail/internal/privacy"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var reservedLocals = []string{"admin", "root", "postmaster", "support", "noreply", "abuse", "mailer-daemon"}
+
+// PolicyViolation describes why a local or domain cannot be used
+type PolicyViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	violationFormat   = PolicyViolation{"invalid_format", "Invalid username format."}
+	violationReserved = PolicyViolation{"reserved", "Username is reserved"}
+	violationDomain   = PolicyViolation{"invalid_domain", "Invalid domain"}
+	violationDraining = PolicyViolation{"domain_draining", "Domain is not accepting new addresses"}
+	violationBlocked  = PolicyViolation{"blocked", "Address is blocked for abuse"}
+	violationFrozen   = PolicyViolation{"frozen", "Address is frozen"}
+)
+
+// checkLocal normalizes raw and applies the local part policy of domain d
+// (see internal/localpolicy) on top of RFC validity. Role accounts are
+// reserved on every domain.
+func (h *Handler) checkLocal(ctx context.Context, d, raw string) (string, []PolicyViolation) {
+	local, err := email.NormalizeLocal(strings.TrimSpace(raw))
+	if err != nil {
+		return "", []PolicyViolation{violationFormat}
+	}
+
+	policy, err := h.store.LocalPolicyFor(ctx, d)
+	if err != nil {
+		// The defaults still apply
+		log.Printf("Failed to load local part policy of %s: %v", d, err)
+	}
+	var violations []PolicyViolation
+	for _, v := range localpolicy.Check(local, policy) {
+		violations = append(violations, PolicyViolation(v))
+	}
+	for _, word := range reservedLocals {
+		if local == word {
+			violations = append(violations, violationReserved)
+			break
+		}
+	}
+	return local, violations
+}
+
+// checkAddress reports availability and policy violations for a local
+// before it is created
+func (h *Handler) checkAddress(w http.ResponseWriter, r *http.Request) {
+	d := email.CanonicalDomain(r.URL.Query().Get("domain"))
+	local, violations := h.checkLocal(r.Context(), d, r.URL.Query().Get("local"))
+	if !h.isValidDomain(r.Context(), d) {
+		violations = append(violations, violationDomain)
+	}
+	if len(violations) == 0 && h.honeypotTouched(r, d, local) {
+		violations = append(violations, violationReserved)
+	}
+	if len(violations) == 0 && h.store.IsBlocked(r.Context(), d, local) {
+		violations = append(violations, violationBlocked)
+	}
+	if len(violations) == 0 && h.frozenLocal(r.Context(), d, local) {
+		violations = append(violations, violationFrozen)
+	}
+
+	exists := false
+	if len(violations) == 0 {
+		var err error
+		exists, err = h.store.AddressExists(r.Context(), d, local)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists && h.store.DomainState(r.Context(), d) != domain.DomainActive {
+			violations = append(violations, violationDraining)
+		}
+	}
+
+	if violations == nil {
+		violations = []PolicyViolation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"local":      local,
+		"domain":     d,
+		"valid":      len(violations) == 0,
+		"available":  len(violations) == 0 && !exists,
+		"violations": violations,
+	})
+}
+
+// frozenLocal reports whether local@d is frozen or was until recently
+// (FREEZE_COOLDOWN_HOURS), so it cannot be created
+func (h *Handler) frozenLocal(ctx context.Context, d, local string) bool {
+	if _, frozen := h.store.FrozenReason(ctx, d, local); frozen {
+		return true
+	}
+	cooling, err := h.store.InFreezeCooldown(ctx, d, local)
+	if err != nil {
+		log.Printf("Failed to check freeze cooldown of %s: %v", privacy.Inbox(local, d), err)
+	}
+	return cooling
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"cattymail/internal/archive"
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// parseAsOf accepts unix seconds or RFC 3339
+func parseAsOf(s string) (time.Time, bool) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil && secs > 0 {
+		return time.Unix(secs, 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// inboxAsOf reconstructs an inbox as it looked at asOf: stored messages
+// received up to then plus, when archiving is on, messages received up to
+// then that have since expired (see redisstore.Store.InboxTime). Deleted messages cannot be brought back, and
+// messages that expired shortly before asOf may be included.
+func (h *Handler) inboxAsOf(ctx context.Context, d, local string, limit int, before int64, asOf time.Time) ([]*domain.Message, error) {
+	if cutoff := asOf.Unix() + 1; before <= 0 || before > cutoff {
+		before = cutoff
+	}
+	msgs, err := h.store.GetInbox(ctx, d, local, limit, before)
+	if err != nil || h.archive == nil {
+		return msgs, err
+	}
+
+	// Anything present at asOf expires after it; search until the longest
+	// address lifetime has passed
+	to := asOf.Add(h.longestTTL())
+	if limit := asOf.Add((archive.MaxSearchDays - 1) * 24 * time.Hour); to.After(limit) {
+		to = limit
+	}
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+	archived, err := h.archive.Search(ctx, archive.Query{From: asOf, To: to, Address: local + "@" + d})
+	if err != nil {
+		// Still answer with what Redis has
+		log.Printf("Failed to search archive for %s: %v", privacy.Inbox(local, d), err)
+		return msgs, nil
+	}
+
+	seen := make(map[string]bool, len(msgs))
+	for _, msg := range msgs {
+		seen[msg.ID] = true
+	}
+	for _, msg := range archived {
+		if !seen[msg.ID] && h.store.InboxTime(msg).Unix() < before {
+			seen[msg.ID] = true
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return h.store.InboxTime(msgs[i]).After(h.store.InboxTime(msgs[j]))
+	})
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"cattymail/internal/billing"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// checkout returns the provider URL where the caller can buy a plan
+func (h *Handler) checkout(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountFrom(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Plan string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	url, err := h.payments.CheckoutURL(accountID, req.Plan)
+	if errors.Is(err, billing.ErrUnsupportedPlan) {
+		http.Error(w, "Plan not available", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to start checkout", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"url": url,
+	})
+}
+
+// billingWebhook applies subscription events from the payment provider
+func (h *Handler) billingWebhook(w http.ResponseWriter, r *http.Request) {
+	ev, err := h.payments.ParseWebhook(r)
+	if err != nil {
+		log.Printf("billing: rejected %s webhook: %v", h.payments.Name(), err)
+		http.Error(w, "Invalid webhook", http.StatusBadRequest)
+		return
+	}
+	if ev.Type == billing.EventIgnored || ev.AccountID == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Providers retry deliveries; only apply each event once. The event
+	// is claimed first so concurrent deliveries are not both applied, and
+	// released again if applying it fails so the retry goes through.
+	first, err := h.store.MarkBillingEvent(r.Context(), ev.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !first {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	plan := billing.PlanFree
+	if ev.Type == billing.EventActivated {
+		plan = billing.PlanFor(h.cfg, ev.PlanID).ID
+	}
+	if err := h.store.SetAccountPlan(r.Context(), ev.AccountID, plan, ev.SubscriptionID); err != nil {
+		log.Printf("billing: failed to move account %s to %s: %v", ev.AccountID, plan, err)
+		if err := h.store.ForgetBillingEvent(r.Context(), ev.ID); err != nil {
+			log.Printf("billing: failed to release event %s for retry: %v", ev.ID, err)
+		}
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("billing: account %s is now on plan %s (%s)", ev.AccountID, plan, ev.SubscriptionID)
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getBurnAfterReading reports whether an inbox burns messages on read
+func (h *Handler) getBurnAfterReading(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	on, err := h.store.BurnsAfterReading(r.Context(), d, local)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": on,
+	})
+}
+
+// setBurnAfterReading turns burn-after-reading on or off for an inbox.
+// Once on, every message can be fetched from /message/{id} only once.
+func (h *Handler) setBurnAfterReading(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	if err := h.store.SetBurnAfterReading(r.Context(), d, local, req.Enabled); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": req.Enabled,
+	})
+}
+
+// withoutBody returns a copy of msg reduced to what an inbox summary shows:
+// the body is replaced by its preview and inline parts and headers dropped
+func withoutBody(msg *domain.Message) *domain.Message {
+	cp := *msg
+	cp.Text = textPreview(msg.Text, 140)
+	cp.HTML = ""
+	cp.Inline = nil
+	cp.Headers = nil
+	return &cp
+}
+
+// burnSafe strips the bodies of msgs when local@d burns messages after
+// reading, since those are only served, once, by /message/{id}
+func (h *Handler) burnSafe(ctx context.Context, d, local string, msgs []*domain.Message) ([]*domain.Message, error) {
+	burn, err := h.store.BurnsAfterReading(ctx, d, local)
+	if err != nil || !burn {
+		return msgs, err
+	}
+	out := make([]*domain.Message, len(msgs))
+	for i, msg := range msgs {
+		out[i] = withoutBody(msg)
+	}
+	return out, nil
+}
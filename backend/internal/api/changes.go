@@ -0,0 +1,63 @@
+package api
+
+import (
+	"cattymail/internal/email"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// getInboxChanges returns the messages added after ?since_id= (oldest
+// first) and the IDs removed since, so polling clients only transfer
+// deltas. Clients pass the returned cursor as since_id next time and call
+// again at once while more is set.
+func (h *Handler) getInboxChanges(w http.ResponseWriter, r *http.Request) {
+	domainParam := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	localParam := chi.URLParam(r, "local")
+
+	sinceID := r.URL.Query().Get("since_id")
+	if sinceID != "" {
+		if _, err := ulid.ParseStrict(sinceID); err != nil {
+			http.Error(w, "since_id must be a message ID", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if i, err := strconv.Atoi(l); err == nil && i > 0 && i <= 100 {
+			limit = i
+		}
+	}
+
+	changes, err := h.store.GetInboxChanges(r.Context(), domainParam, localParam, sinceID, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch inbox changes", http.StatusInternalServerError)
+		return
+	}
+	if changes.Added, err = h.burnSafe(r.Context(), domainParam, localParam, changes.Added); err != nil {
+		http.Error(w, "Failed to fetch inbox changes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if apiVersion(r) >= apiV1 {
+		summaries := make([]messageSummary, len(changes.Added))
+		for i, msg := range changes.Added {
+			summaries[i] = summarize(msg)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"added":   summaries,
+			"removed": changes.Removed,
+			"cursor":  changes.Cursor,
+			"more":    changes.More,
+		})
+		return
+	}
+	for i, msg := range changes.Added {
+		changes.Added[i] = withoutInlineData(msg)
+	}
+	json.NewEncoder(w).Encode(changes)
+}
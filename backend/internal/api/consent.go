@@ -0,0 +1,134 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"cattymail/internal/domain"
+)
+
+// consentIPHash returns the keyed hash consent is recorded under for the
+// caller's IP. The key is the session key, so the hash cannot be reversed
+// by hashing candidate IPs.
+func (h *Handler) consentIPHash(r *http.Request) string {
+	mac := hmac.New(sha256.New, h.sessions.secret)
+	mac.Write([]byte("consent ip " + clientIP(r)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// consented reports whether c accepted the versions of policy p
+func consented(p *domain.ConsentPolicy, c *domain.Consent) bool {
+	return c != nil && c.TermsVersion == p.TermsVersion && c.PrivacyVersion == p.PrivacyVersion
+}
+
+// callerConsent returns the latest acceptance of the caller's session or IP
+func (h *Handler) callerConsent(r *http.Request) (*domain.Consent, error) {
+	var sessionID string
+	if sess := sessionFrom(r.Context()); sess != nil {
+		sessionID = sess.ID
+	}
+	return h.store.ConsentOf(r.Context(), sessionID, h.consentIPHash(r))
+}
+
+// getConsent reports the current terms and privacy policy versions and
+// whether the caller accepted them
+func (h *Handler) getConsent(w http.ResponseWriter, r *http.Request) {
+	policy, err := h.store.GetConsentPolicy(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if policy == nil {
+		policy = &domain.ConsentPolicy{}
+	}
+	c, err := h.callerConsent(r)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"terms_version":   policy.TermsVersion,
+		"privacy_version": policy.PrivacyVersion,
+		"required":        policy.Required,
+		"accepted":        consented(policy, c),
+		"consent":         c,
+	})
+}
+
+// acceptConsent records that the caller accepted the current versions,
+// starting a session when it has none
+func (h *Handler) acceptConsent(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TermsVersion   string `json:"terms_version"`
+		PrivacyVersion string `json:"privacy_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	policy, err := h.store.GetConsentPolicy(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if policy == nil || (policy.TermsVersion == "" && policy.PrivacyVersion == "") {
+		http.Error(w, "No terms to accept", http.StatusNotFound)
+		return
+	}
+	c := &domain.Consent{
+		TermsVersion:   req.TermsVersion,
+		PrivacyVersion: req.PrivacyVersion,
+		AcceptedAt:     time.Now().UTC(),
+		IPHash:         h.consentIPHash(r),
+	}
+	if !consented(policy, c) {
+		http.Error(w, "Terms or privacy policy version is not current", http.StatusConflict)
+		return
+	}
+
+	sess := sessionFrom(r.Context())
+	if sess == nil {
+		sess = h.startSession(w, r)
+	}
+	if err := h.store.RecordConsent(r.Context(), sess.ID, c); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "accepted",
+		"consent": c,
+	})
+}
+
+// checkConsent rejects the request when the consent policy is required
+// and the caller has not accepted its current versions
+func (h *Handler) checkConsent(w http.ResponseWriter, r *http.Request) bool {
+	policy, err := h.store.GetConsentPolicy(r.Context())
+	if err != nil {
+		log.Printf("Failed to load consent policy: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	if policy == nil || !policy.Required {
+		return true
+	}
+	c, err := h.callerConsent(r)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+	if !consented(policy, c) {
+		http.Error(w, "The current terms and privacy policy must be accepted first", http.StatusForbidden)
+		return false
+	}
+	return true
+}
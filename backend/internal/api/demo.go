@@ -0,0 +1,16 @@
+package api
+
+import "net/http"
+
+// demoReadOnly rejects admin requests that change state, so a public demo
+// can expose the admin panel without letting visitors break it
+func demoReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			http.Error(w, "Disabled in demo mode", http.StatusForbidden)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cattymail/internal/digest"
+	"cattymail/internal/email"
+	"cattymail/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxDigestsPerDay caps the digests one recipient can be sent, so the
+// endpoint cannot be used to flood a mailbox
+const maxDigestsPerDay = 5
+
+// digestRecipient decodes and checks the recipient of a digest request,
+// writing the error response when it is refused
+func (h *Handler) digestRecipient(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		To string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+	to, err := h.digests.Validate(req.To)
+	if err == digest.ErrNotConfigured {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	allowed, err := h.store.RateLimit(r.Context(), to, "digest", maxDigestsPerDay, 24*time.Hour)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", false
+	}
+	if !allowed {
+		http.Error(w, "Too many digests for this recipient", http.StatusTooManyRequests)
+		return "", false
+	}
+	return to, true
+}
+
+// getDigest reports whether an expiry digest is scheduled for an inbox.
+// The recipient is masked.
+func (h *Handler) getDigest(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	to, err := h.store.DigestRecipient(r.Context(), d, local)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled": to != "",
+		"to":        privacy.Redact(to),
+	})
+}
+
+// scheduleDigest mails a digest of an inbox to a recipient shortly before
+// the address expires
+func (h *Handler) scheduleDigest(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	to, ok := h.digestRecipient(w, r)
+	if !ok {
+		return
+	}
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	if err := h.store.ScheduleDigest(r.Context(), d, local, to); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled": true,
+		"to":        privacy.Redact(to),
+	})
+}
+
+// cancelDigest drops the expiry digest of an inbox
+func (h *Handler) cancelDigest(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	if err := h.store.CancelDigest(r.Context(), d, local); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+}
+
+// sendDigest mails a digest of an inbox now
+func (h *Handler) sendDigest(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	to, ok := h.digestRecipient(w, r)
+	if !ok {
+		return
+	}
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	n, err := h.digests.Send(r.Context(), d, local, to)
+	if err != nil {
+		http.Error(w, "Failed to send digest", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "sent",
+		"messages": n,
+	})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"time"
+)
+
+// feedSize is how many of the newest messages a feed carries
+const feedSize = 50
+
+// jsonFeed is a JSON Feed 1.1 document
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	ContentHTML   string           `json:"content_html,omitempty"`
+	DatePublished time.Time        `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// atomFeed is an RFC 4287 feed document
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Content atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedMessages authenticates the feed request with the inbox access token
+// and returns the newest messages, as summaries for burn-after-reading
+// inboxes
+func (h *Handler) feedMessages(w http.ResponseWriter, r *http.Request) (string, []*domain.Message, bool) {
+	d, local, ok := h.inboxTokenAuth(w, r)
+	if !ok {
+		return "", nil, false
+	}
+
+	msgs, err := h.store.GetInbox(r.Context(), d, local, feedSize, 0)
+	if err == nil {
+		msgs, err = h.burnSafe(r.Context(), d, local, msgs)
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch inbox", http.StatusInternalServerError)
+		return "", nil, false
+	}
+	return local + "@" + d, msgs, true
+}
+
+// getJSONFeed serves an inbox as a JSON Feed
+func (h *Handler) getJSONFeed(w http.ResponseWriter, r *http.Request) {
+	inbox, msgs, ok := h.feedMessages(w, r)
+	if !ok {
+		return
+	}
+
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       inbox,
+		Description: "Incoming mail for " + inbox,
+		Items:       make([]jsonFeedItem, 0, len(msgs)),
+	}
+	for _, m := range msgs {
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            m.ID,
+			Title:         m.Subject,
+			ContentText:   m.Text,
+			ContentHTML:   m.HTML,
+			DatePublished: m.Date,
+			Authors:       []jsonFeedAuthor{{Name: m.From}},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json")
+	json.NewEncoder(w).Encode(feed)
+}
+
+// getAtomFeed serves an inbox as an Atom feed
+func (h *Handler) getAtomFeed(w http.ResponseWriter, r *http.Request) {
+	inbox, msgs, ok := h.feedMessages(w, r)
+	if !ok {
+		return
+	}
+
+	updated := time.Now()
+	if len(msgs) > 0 {
+		updated = msgs[0].Date
+	}
+	feed := atomFeed{
+		ID:      "urn:cattymail:inbox:" + inbox,
+		Title:   inbox,
+		Updated: updated.UTC().Format(time.RFC3339),
+	}
+	for _, m := range msgs {
+		content := atomContent{Type: "text", Body: m.Text}
+		if m.HTML != "" {
+			content = atomContent{Type: "html", Body: m.HTML}
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:cattymail:message:" + m.ID,
+			Title:   m.Subject,
+			Updated: m.Date.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: m.From},
+			Content: content,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
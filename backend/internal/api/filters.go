@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/filters"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// loadInboxFilters resolves the inbox in the URL and returns its filters,
+// empty when it has none. It writes the error response when it fails.
+func (h *Handler) loadInboxFilters(w http.ResponseWriter, r *http.Request) (d, local string, f *domain.InboxFilters, ok bool) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return "", "", nil, false
+	}
+	d, local = email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	f, err := h.store.GetInboxFilters(r.Context(), d, local)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", nil, false
+	}
+	if f == nil {
+		f = &domain.InboxFilters{}
+	}
+	if f.AllowSenders == nil {
+		f.AllowSenders = []string{}
+	}
+	return d, local, f, true
+}
+
+// saveInboxFilters validates and stores f, writing it back as the response
+func (h *Handler) saveInboxFilters(w http.ResponseWriter, r *http.Request, d, local string, f *domain.InboxFilters, status int) {
+	if err := filters.Normalize(f); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.UpdatedAt = time.Now()
+	if err := h.store.SaveInboxFilters(r.Context(), d, local, f); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(f)
+}
+
+// getInboxFilters returns the filters of an inbox
+func (h *Handler) getInboxFilters(w http.ResponseWriter, r *http.Request) {
+	_, _, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
+}
+
+// setInboxFilters replaces the sender allowlist of an inbox. Mail it
+// rejects is quarantined at ingest.
+func (h *Handler) setInboxFilters(w http.ResponseWriter, r *http.Request) {
+	d, local, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		AllowSenders []string `json:"allow_senders"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	f.AllowSenders = req.AllowSenders
+	h.saveInboxFilters(w, r, d, local, f, http.StatusOK)
+}
+
+// deleteInboxFilters lets an inbox accept all senders again. Its rules
+// stay.
+func (h *Handler) deleteInboxFilters(w http.ResponseWriter, r *http.Request) {
+	d, local, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	f.AllowSenders = nil
+	h.saveInboxFilters(w, r, d, local, f, http.StatusOK)
+}
+
+// getInboxRules lists the filter rules of an inbox, in evaluation order
+func (h *Handler) getInboxRules(w http.ResponseWriter, r *http.Request) {
+	_, _, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	rules := f.Rules
+	if rules == nil {
+		rules = []domain.FilterRule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// addInboxRule appends a filter rule to an inbox
+func (h *Handler) addInboxRule(w http.ResponseWriter, r *http.Request) {
+	d, local, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	var rule domain.FilterRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule.ID = ulid.Make().String()
+	rule.CreatedAt = time.Now()
+	f.Rules = append(f.Rules, rule)
+	h.saveInboxFilters(w, r, d, local, f, http.StatusCreated)
+}
+
+// updateInboxRule replaces a filter rule, keeping its place in the order
+func (h *Handler) updateInboxRule(w http.ResponseWriter, r *http.Request) {
+	d, local, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	var rule domain.FilterRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	for i := range f.Rules {
+		if f.Rules[i].ID == id {
+			rule.ID, rule.CreatedAt = id, f.Rules[i].CreatedAt
+			f.Rules[i] = rule
+			h.saveInboxFilters(w, r, d, local, f, http.StatusOK)
+			return
+		}
+	}
+	http.Error(w, "Rule not found", http.StatusNotFound)
+}
+
+// deleteInboxRule removes a filter rule
+func (h *Handler) deleteInboxRule(w http.ResponseWriter, r *http.Request) {
+	d, local, f, ok := h.loadInboxFilters(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	for i := range f.Rules {
+		if f.Rules[i].ID == id {
+			f.Rules = append(f.Rules[:i], f.Rules[i+1:]...)
+			h.saveInboxFilters(w, r, d, local, f, http.StatusOK)
+			return
+		}
+	}
+	http.Error(w, "Rule not found", http.StatusNotFound)
+}
@@ -0,0 +1,418 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/graphql-go/graphql"
+)
+
+// GraphQL lets the frontend fetch an inbox with nested message previews in
+// one round trip. Queries go over POST or GET; the newMessage subscription
+// streams over SSE when the client sends Accept: text/event-stream.
+const (
+	graphqlMaxBody       = 64 << 10
+	graphqlInboxLimit    = 100
+	graphqlPreviewLength = 140
+)
+
+var errSubscriptionTransport = errors.New("subscriptions require Accept: text/event-stream")
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphqlInbox is the source value of the Inbox type
+type graphqlInbox struct {
+	Domain string
+	Local  string
+}
+
+// textPreview collapses whitespace in a message body and cuts it to n runes
+func textPreview(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n]) + "…"
+}
+
+// parseInbox canonicalizes a local@domain argument
+func parseInbox(raw string) (*graphqlInbox, error) {
+	addr, err := email.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q", raw)
+	}
+	return &graphqlInbox{Domain: addr.Domain, Local: addr.Local}, nil
+}
+
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// graphqlSchema builds the schema. Resolvers close over h.
+func (h *Handler) graphqlSchema() (graphql.Schema, error) {
+	messageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Message",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).ID, nil
+			}},
+			"from": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).From, nil
+			}},
+			"to": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				m := p.Source.(*domain.Message)
+				if m.OriginalTo != "" {
+					return m.OriginalTo, nil
+				}
+				return m.Local + "@" + m.Domain, nil
+			}},
+			"subject": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).Subject, nil
+			}},
+			"date": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(*domain.Message).Date), nil
+			}},
+			"receivedAt": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(*domain.Message).ReceivedAt), nil
+			}},
+			"size": &graphql.Field{Type: graphql.Int, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).Size, nil
+			}},
+			"text": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).Text, nil
+			}},
+			"html": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).HTML, nil
+			}},
+			"preview": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"length": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: graphqlPreviewLength},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					n, _ := p.Args["length"].(int)
+					if n <= 0 || n > 1000 {
+						n = graphqlPreviewLength
+					}
+					return textPreview(p.Source.(*domain.Message).Text, n), nil
+				},
+			},
+		},
+	})
+
+	addressType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Address",
+		Fields: graphql.Fields{
+			"email": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Address).Email, nil
+			}},
+			"local": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Address).Local, nil
+			}},
+			"domain": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Address).Domain, nil
+			}},
+			"displayDomain": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(domain.Address).DisplayDomain, nil
+			}},
+			"expiresAt": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return formatTime(p.Source.(domain.Address).ExpiresAt), nil
+			}},
+		},
+	})
+
+	inboxType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Inbox",
+		Fields: graphql.Fields{
+			"address": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				in := p.Source.(*graphqlInbox)
+				return in.Local + "@" + in.Domain, nil
+			}},
+			"local": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*graphqlInbox).Local, nil
+			}},
+			"domain": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*graphqlInbox).Domain, nil
+			}},
+			"messages": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(messageType))),
+				Args: graphql.FieldConfigArgument{
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 50},
+					"before": &graphql.ArgumentConfig{Type: graphql.Float, Description: "Unix milliseconds cursor"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					in := p.Source.(*graphqlInbox)
+					limit, _ := p.Args["limit"].(int)
+					if limit <= 0 || limit > graphqlInboxLimit {
+						limit = 50
+					}
+					var before int64
+					if b, ok := p.Args["before"].(float64); ok {
+						before = int64(b)
+					}
+					if refusal := h.inboxRefusal(p.Context, in.Domain, in.Local); refusal != "" {
+						return nil, errors.New(refusal)
+					}
+					msgs, err := h.store.GetInbox(p.Context, in.Domain, in.Local, limit, before)
+					if err == nil {
+						msgs, err = h.burnSafe(p.Context, in.Domain, in.Local, msgs)
+					}
+					if err != nil {
+						return nil, errors.New("failed to fetch inbox")
+					}
+					if msgs == nil {
+						msgs = []*domain.Message{}
+					}
+					return msgs, nil
+				},
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"domains": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return h.publicDomains(p.Context), nil
+				},
+			},
+			"addresses": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(addressType))),
+				Description: "Addresses bound to the caller's session",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					set, _, _ := h.addressSet(sessionFrom(p.Context))
+					if set == "" {
+						return []domain.Address{}, nil
+					}
+					addresses, err := h.sessionAddresses(p.Context, set)
+					if err != nil {
+						return nil, errors.New("database error")
+					}
+					return addresses, nil
+				},
+			},
+			"inbox": &graphql.Field{
+				Type: inboxType,
+				Args: graphql.FieldConfigArgument{
+					"address": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return parseInbox(p.Args["address"].(string))
+				},
+			},
+			"message": &graphql.Field{
+				Type: messageType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					msg, err := h.store.GetMessage(p.Context, p.Args["id"].(string))
+					if err != nil {
+						return nil, errors.New("failed to fetch message")
+					}
+					if msg == nil {
+						return nil, nil
+					}
+					if refusal := h.inboxRefusal(p.Context, msg.Domain, msg.Local); refusal != "" {
+						return nil, errors.New(refusal)
+					}
+					msgs, err := h.burnSafe(p.Context, msg.Domain, msg.Local, []*domain.Message{msg})
+					if err != nil {
+						return nil, errors.New("failed to fetch message")
+					}
+					return msgs[0], nil
+				},
+			},
+		},
+	})
+
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"newMessage": &graphql.Field{
+				Type: graphql.NewNonNull(messageType),
+				Args: graphql.FieldConfigArgument{
+					"address": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					in, err := parseInbox(p.Args["address"].(string))
+					if err != nil {
+						return nil, err
+					}
+					if refusal := h.inboxRefusal(p.Context, in.Domain, in.Local); refusal != "" {
+						return nil, errors.New(refusal)
+					}
+					return h.newMessages(p.Context, in), nil
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					// The source is nil when a subscription is sent as a
+					// plain request
+					msg, ok := p.Source.(*domain.Message)
+					if !ok {
+						return nil, errSubscriptionTransport
+					}
+					return msg, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:        query,
+		Subscription: subscription,
+	})
+}
+
+// newMessages feeds the messages published to an inbox until ctx is done
+func (h *Handler) newMessages(ctx context.Context, in *graphqlInbox) chan interface{} {
+	ch := make(chan interface{})
+	pubsub := h.store.Subscribe(ctx, in.Domain, in.Local)
+
+	go func() {
+		defer close(ch)
+		defer pubsub.Close()
+
+		ids := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case id, ok := <-ids:
+				if !ok {
+					return
+				}
+				// Ends the subscription once the inbox gets blocked or frozen
+				if h.inboxRefusal(ctx, in.Domain, in.Local) != "" {
+					return
+				}
+				msg, err := h.store.GetMessage(ctx, id.Payload)
+				if err != nil || msg == nil {
+					continue
+				}
+				msgs, err := h.burnSafe(ctx, in.Domain, in.Local, []*domain.Message{msg})
+				if err != nil {
+					continue
+				}
+				select {
+				case ch <- msgs[0]:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// decodeGraphQLRequest reads a request from a JSON body or, for GET, from
+// the query string
+func decodeGraphQLRequest(w http.ResponseWriter, r *http.Request) (*graphqlRequest, error) {
+	var req graphqlRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphqlMaxBody)).Decode(&req); err != nil {
+		return nil, err
+	}
+	if req.Query == "" {
+		return nil, errors.New("missing query")
+	}
+	return &req, nil
+}
+
+// graphqlHandler serves POST/GET /api/graphql
+func (h *Handler) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeGraphQLRequest(w, r)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	params := graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		h.graphqlStream(w, r, params)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(graphql.Do(params))
+}
+
+// graphqlStream runs a subscription and writes each result as an SSE
+// "next" event, ending with "complete"
+func (h *Handler) graphqlStream(w http.ResponseWriter, r *http.Request, params graphql.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable Nginx buffering
+
+	fmt.Fprintf(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(20 * time.Second)
+	defer keepalive.Stop()
+
+	results := graphql.Subscribe(params)
+	defer func() {
+		// Let the executor finish its pending send and exit
+		go func() {
+			for range results {
+			}
+		}()
+	}()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprintf(w, ": keepalive\n\n")
+			flusher.Flush()
+		case res, ok := <-results:
+			if !ok {
+				fmt.Fprintf(w, "event: complete\ndata:\n\n")
+				flusher.Flush()
+				return
+			}
+			data, _ := json.Marshal(res)
+			fmt.Fprintf(w, "event: next\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -1,23 +1,34 @@
 package api
 
 import (
+	"cattymail/internal/addrgen"
 	"cattymail/internal/admin"
+	"cattymail/internal/archive"
+	"cattymail/internal/billing"
 	"cattymail/internal/config"
+	"cattymail/internal/digest"
+	"cattymail/internal/dnsbl"
 	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/linkproxy"
+	"cattymail/internal/privacy"
 	"cattymail/internal/redisstore"
+	"cattymail/internal/thumbnail"
+	"cattymail/internal/unsubscribe"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"log"
 	"net"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/graphql-go/graphql"
 	"github.com/rs/cors"
 )
 
@@ -25,20 +36,64 @@ type Handler struct {
 	cfg          *config.Config
 	store        *redisstore.Store
 	adminHandler *admin.AdminHandler
+	generator    *addrgen.Generator
+	sessions     *sessionSigner
+	payments     billing.Provider
+	schema       graphql.Schema
+	thumbnails   *thumbnail.Service
+	archive      *archive.Archiver // nil unless ARCHIVE_URL is set
+	unsubscriber *unsubscribe.Unsubscriber
+	digests      *digest.Sender
+	links        *linkproxy.Signer
+	linkCheck    *dnsbl.Checker // nil unless LINK_BLOCKLISTS is set
+	requestLog   *requestLogger
+	ratePolicies ratePolicies
+	rateBreaker  rateBreaker
+	rendered     *renderCache // nil unless RENDER_CACHE_SIZE > 0
+	publicStats  publicStats
+	providerList providerList
+	// Components running in this process besides the API (see AddHealthCheck)
+	healthChecks map[string]func() error
 }
 
 func New(cfg *config.Config, store *redisstore.Store) *Handler {
+	privacy.Configure(cfg.PrivacyMode, cfg.PrivacySalt)
 	adminHandler, err := admin.NewAdminHandler(cfg, store)
 	if err != nil {
-		// Log error but continue - admin panel will be unavailable
-		// In production, you might want to handle this differently
+		// Production must not come up without its admin panel; elsewhere
+		// the API runs without the /admin routes
+		if cfg.IsProduction() {
+			log.Fatalf("Failed to init admin panel: %v", err)
+		}
+		log.Printf("Admin panel unavailable: %v", err)
 	}
 
-	return &Handler{
+	h := &Handler{
 		cfg:          cfg,
 		store:        store,
 		adminHandler: adminHandler,
+		generator:    addrgen.New(store),
+		sessions:     newSessionSigner(cfg.JWTSecret),
+		payments:     billing.New(cfg),
+		thumbnails:   thumbnail.New(cfg, store),
+		unsubscriber: unsubscribe.New(cfg),
+		digests:      digest.New(cfg, store),
+		links:        linkproxy.NewSigner(cfg.JWTSecret),
+		linkCheck:    dnsbl.New(cfg.LinkBlocklists, time.Duration(cfg.DNSBLTimeoutMs)*time.Millisecond),
+		rendered:     newRenderCache(cfg.RenderCacheSize),
+	}
+	if cfg.RequestLogMax > 0 {
+		h.requestLog = newRequestLogger(h)
+	}
+	if h.archive, err = archive.New(cfg, store); err != nil {
+		log.Printf("Archived messages unavailable for as_of: %v", err)
+	}
+	h.schema, err = h.graphqlSchema()
+	if err != nil {
+		// The schema is static; failing to build it is a programming error
+		panic(fmt.Sprintf("graphql: invalid schema: %v", err))
 	}
+	return h
 }
 
 func (h *Handler) Router() http.Handler {
@@ -46,71 +101,321 @@ func (h *Handler) Router() http.Handler {
 
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	if h.requestLog != nil {
+		r.Use(h.requestLog.middleware)
+	}
 
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedOrigins:   h.cfg.CORSOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", sessionHeader, headerRateKey},
+		ExposedHeaders:   []string{sessionHeader, "X-Remote-Content-Blocked", "Deprecation", "Sunset", "Link", "Retry-After"},
 		AllowCredentials: true,
 	})
 	r.Use(c.Handler)
 	r.Use(h.expirationMiddleware)
+	r.Use(h.sessionMiddleware)
+	r.Use(h.honeypotWatch)
+	r.Use(h.inboxGuard)
 
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
+		r.Route("/v1", func(r chi.Router) {
+			r.Use(withVersion(apiV1), structuredErrors)
+			h.routes(r)
 		})
-		r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
+		// Original unversioned API, kept for the existing frontend
+		r.Group(func(r chi.Router) {
+			r.Use(withVersion(apiV0), h.deprecated)
+			h.routes(r)
 		})
-		r.Get("/status", h.getStatus)
-		r.Get("/domains", h.getPublicDomains)
+	})
 
-		r.Post("/address/random", h.createRandomAddress)
-		r.Post("/address/custom", h.createCustomAddress)
+	return r
+}
 
-		r.Get("/inbox/{domain}/{local}", h.getInbox)
-		r.Get("/stream/{domain}/{local}", h.streamInbox)
-		r.Get("/message/{id}", h.getMessage)
+// routes registers every API endpoint; it is mounted once per API version
+// (see versions.go)
+func (h *Handler) routes(r chi.Router) {
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		components, _ := h.componentHealth()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":       "ok",
+			"storage":      h.store.Pressure(),
+			"components":   components,
+			"rate_limiter": h.rateBreaker.stats(),
+		})
+	})
+	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		components, healthy := h.componentHealth()
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"components": components,
+		})
+	})
+	r.Get("/status", h.getStatus)
+	r.Get("/domains", h.getPublicDomains)
+	r.Get("/domains/meta", h.getDomainMeta)
+	r.Get("/domains/providers", h.getDomainProviders)
+	r.Get("/sitemap.xml", h.getSitemap)
+	if h.cfg.PublicStats {
+		r.Get("/stats/public", h.getPublicStats)
+	}
+
+	r.With(h.rateLimit("create")).Post("/address/random", h.createRandomAddress)
+	r.With(h.rateLimit("create")).Post("/address/custom", h.createCustomAddress)
+	r.With(h.rateLimit("check")).Get("/address/check", h.checkAddress)
+	r.Get("/consent", h.getConsent)
+	r.With(h.rateLimit("check")).Post("/consent", h.acceptConsent)
+
+	r.Get("/me", h.getMe)
+	r.Get("/me/addresses", h.getMyAddresses)
+	r.Delete("/me/addresses/{domain}/{local}", h.forgetMyAddress)
+
+	// Optional accounts
+	if h.cfg.AccountsEnabled {
+		r.With(h.rateLimit("account")).Post("/accounts/register", h.register)
+		r.With(h.rateLimit("account")).Post("/accounts/login", h.login)
+		r.Post("/accounts/logout", h.logout)
+		r.Get("/me/history", h.getMyHistory)
+
+		// Bring-your-own domains
+		r.Get("/me/domains", h.getMyDomains)
+		r.Post("/me/domains", h.addMyDomain)
+		r.With(h.rateLimit("verify")).Post("/me/domains/{domain}/verify", h.verifyMyDomain)
+		r.Delete("/me/domains/{domain}", h.deleteMyDomain)
+
+		// Premium subscriptions
+		if h.payments != nil {
+			r.Post("/me/billing/checkout", h.checkout)
+			r.Post("/billing/webhook", h.billingWebhook)
+		}
+	}
+	r.With(h.rateLimit("create")).Post("/address/{domain}/{local}/selftest", h.selfTest)
+
+	r.With(h.rateLimit("fetch"), h.inboxRateLimit("inbox")).Get("/inbox/{domain}/{local}", h.getInbox)
+	r.With(h.rateLimit("fetch"), h.inboxRateLimit("inbox")).Get("/inbox/{domain}/{local}/wait", h.waitInbox)
+	r.With(h.rateLimit("fetch"), h.inboxRateLimit("inbox")).Get("/inbox/{domain}/{local}/changes", h.getInboxChanges)
+	r.With(h.rateLimit("fetch")).Get("/inbox/{domain}/{local}/usage", h.getInboxUsage)
+	r.Get("/inbox/{domain}/{local}/notifications", h.getInboxNotifications)
+	r.With(h.rateLimit("create")).Post("/inbox/{domain}/{local}/notifications", h.addInboxNotification)
+	r.Delete("/inbox/{domain}/{local}/notifications/{id}", h.deleteInboxNotification)
+	r.With(h.rateLimit("create")).Post("/inbox/{domain}/{local}/access-token", h.createAccessToken)
+	r.With(h.rateLimit("fetch"), h.inboxRateLimit("inbox")).Get("/inbox/{domain}/{local}/feed.json", h.getJSONFeed)
+	r.With(h.rateLimit("fetch"), h.inboxRateLimit("inbox")).Get("/inbox/{domain}/{local}/feed.xml", h.getAtomFeed)
+	r.Get("/inbox/{domain}/{local}/filters", h.getInboxFilters)
+	r.Put("/inbox/{domain}/{local}/filters", h.setInboxFilters)
+	r.Delete("/inbox/{domain}/{local}/filters", h.deleteInboxFilters)
+	r.Get("/inbox/{domain}/{local}/rules", h.getInboxRules)
+	r.With(h.rateLimit("create")).Post("/inbox/{domain}/{local}/rules", h.addInboxRule)
+	r.Put("/inbox/{domain}/{local}/rules/{id}", h.updateInboxRule)
+	r.Delete("/inbox/{domain}/{local}/rules/{id}", h.deleteInboxRule)
+	r.Get("/inbox/{domain}/{local}/autoclean", h.getReadClean)
+	r.Put("/inbox/{domain}/{local}/autoclean", h.setReadClean)
+	r.Post("/inbox/{domain}/{local}/read", h.markRead)
+	r.Get("/inbox/{domain}/{local}/burn", h.getBurnAfterReading)
+	r.Put("/inbox/{domain}/{local}/burn", h.setBurnAfterReading)
+	r.Get("/inbox/{domain}/{local}/digest", h.getDigest)
+	r.With(h.rateLimit("report")).Put("/inbox/{domain}/{local}/digest", h.scheduleDigest)
+	r.Delete("/inbox/{domain}/{local}/digest", h.cancelDigest)
+	r.With(h.rateLimit("report")).Post("/inbox/{domain}/{local}/digest/send", h.sendDigest)
+	r.Get("/inbox/{domain}/{local}/hooks", h.getHooks)
+	r.Post("/inbox/{domain}/{local}/hooks", h.subscribeHook)
+	r.Delete("/inbox/{domain}/{local}/hooks/{id}", h.unsubscribeHook)
+	r.With(h.rateLimit("fetch"), h.inboxRateLimit("inbox")).Get("/inbox/{domain}/{local}/hooks/poll", h.pollHooks)
+	r.Get("/push/vapid-public-key", h.getVAPIDPublicKey)
+	r.With(h.rateLimit("create")).Post("/inbox/{domain}/{local}/push-subscriptions", h.addPushSubscription)
+	r.Delete("/inbox/{domain}/{local}/push-subscriptions", h.deletePushSubscription)
+	r.With(h.inboxRateLimit("inbox")).Get("/stream/{domain}/{local}", h.streamInbox)
+	r.With(h.rateLimit("fetch")).Get("/message/{id}", h.getMessage)
+	r.With(h.rateLimit("fetch")).Get("/message/{id}/preview", h.getMessagePreview)
+	r.With(h.rateLimit("fetch")).Get("/message/{id}/headers", h.getMessageHeaders)
+	r.With(h.rateLimit("fetch")).Get("/message/{id}/inline/{cid}", h.getMessageInline)
+	r.With(h.rateLimit("fetch")).Get("/message/{id}/print", h.getMessagePrint)
+	r.With(h.rateLimit("report")).Post("/message/{id}/report-spam", h.reportSpam)
+	r.With(h.rateLimit("report")).Post("/report", h.reportAbuse)
+	r.With(h.rateLimit("unsubscribe")).Post("/message/{id}/unsubscribe", h.unsubscribeMessage)
+	r.Get("/l/{token}", h.followLink)
+	if h.thumbnails != nil {
+		r.With(h.rateLimit("fetch")).Get("/message/{id}/thumbnail.png", h.getMessageThumbnail)
+		r.With(h.rateLimit("fetch")).Get("/message/{id}/pdf", h.getMessagePDF)
+	}
+	r.With(h.rateLimit("fetch")).Get("/graphql", h.graphqlHandler)
+	r.With(h.rateLimit("fetch")).Post("/graphql", h.graphqlHandler)
+
+	// Admin routes
+	if h.adminHandler != nil {
+		r.With(h.rateLimit("account")).Post("/admin/login", h.adminHandler.Login)
+		if h.adminHandler.WebAuthnEnabled() {
+			r.With(h.rateLimit("account")).Post("/admin/webauthn/login/begin", h.adminHandler.BeginWebAuthnLogin)
+			r.With(h.rateLimit("account")).Post("/admin/webauthn/login/finish", h.adminHandler.FinishWebAuthnLogin)
+		}
+
+		// Protected admin routes
+		r.Group(func(r chi.Router) {
+			r.Use(h.adminHandler.AuthMiddleware)
+			if h.cfg.DemoMode {
+				r.Use(demoReadOnly)
+			}
 
-		// Admin routes
-		if h.adminHandler != nil {
-			r.Post("/admin/login", h.adminHandler.Login)
+			r.Get("/admin/stats", h.adminHandler.GetStats)
+			r.Get("/admin/billing", h.adminHandler.GetBilling)
+			r.Get("/admin/memory", h.adminHandler.GetMemory)
+			r.Get("/admin/storage", h.adminHandler.GetStorage)
+			r.Get("/admin/storage/shadow", h.adminHandler.GetShadow)
+			r.Delete("/admin/storage/shadow", h.adminHandler.ResetShadow)
+			r.Get("/admin/storage/verify", h.adminHandler.VerifyStorage)
+			r.Post("/admin/storage/verify", h.adminHandler.RepairStorage)
+			r.Get("/admin/stats/providers", h.adminHandler.GetProviderReport)
+			r.Get("/admin/logs", h.adminHandler.GetLogs)
+			r.Get("/admin/ingest/stats", h.adminHandler.GetIngestStats)
+			r.Get("/admin/ingest/skipped", h.adminHandler.GetSkipped)
+			r.Post("/admin/ingest/poll", h.adminHandler.TriggerPoll)
+			r.Post("/admin/ingest/reload", h.adminHandler.ReloadIngestConfig)
+			r.Post("/admin/ingest/cursor", h.adminHandler.ResetIngestCursor)
+			r.Get("/admin/ingest/status", h.adminHandler.GetIngestStatus)
+			r.Delete("/admin/ingest/skipped", h.adminHandler.ClearSkipped)
+			r.Get("/admin/stream", h.adminHandler.StreamIngest)
+
+			// Greylisting of first contacts
+			r.Get("/admin/greylist", h.adminHandler.GetGreylist)
+			r.Post("/admin/greylist/domains", h.adminHandler.SetGreylistDelay)
+			r.Post("/admin/greylist/{id}/release", h.adminHandler.ReleaseHeldMessage)
+
+			// Domains
+			r.Get("/admin/domains", h.adminHandler.GetDomains)
+			r.Post("/admin/domains", h.adminHandler.AddDomain)
+			r.Delete("/admin/domains/{domain}", h.adminHandler.RemoveDomain)
+			r.Post("/admin/domains/{domain}/state", h.adminHandler.SetDomainState)
+			r.Delete("/admin/domains/{domain}/burn", h.adminHandler.ClearBurnSignals)
+			r.Get("/admin/domains/meta", h.adminHandler.GetDomainMeta)
+			r.Post("/admin/domains/meta", h.adminHandler.SaveDomainMeta)
+			r.Delete("/admin/domains/meta/{domain}", h.adminHandler.DeleteDomainMeta)
+
+			// Config & Settings
+			r.Get("/admin/config", h.adminHandler.GetConfig)
+			r.Get("/admin/settings", h.adminHandler.GetSettings)
+			r.Post("/admin/settings", h.adminHandler.UpdateSettings)
+			r.Get("/admin/ratelimits", h.adminHandler.GetRateLimits)
+			r.Delete("/admin/ratelimits/{action}/{key}", h.adminHandler.ResetRateLimit)
+			r.Get("/admin/settings/ratelimits", h.adminHandler.GetRatePolicies)
+			r.Put("/admin/settings/ratelimits/{action}", h.adminHandler.SetRatePolicy)
+			r.Delete("/admin/settings/ratelimits/{action}", h.adminHandler.DeleteRatePolicy)
+			r.Get("/admin/settings/ratelimits/exempt", h.adminHandler.GetRateExemptions)
+			r.Post("/admin/settings/ratelimits/exempt", h.adminHandler.AddRateExemption)
+			r.Delete("/admin/settings/ratelimits/exempt/{id}", h.adminHandler.DeleteRateExemption)
+			r.Get("/admin/settings/local-policies", h.adminHandler.GetLocalPolicies)
+			r.Put("/admin/settings/local-policies/{domain}", h.adminHandler.SetLocalPolicy)
+			r.Delete("/admin/settings/local-policies/{domain}", h.adminHandler.DeleteLocalPolicy)
+			r.Get("/admin/settings/domain-rotation", h.adminHandler.GetDomainRotation)
+			r.Put("/admin/settings/domain-rotation", h.adminHandler.SaveDomainRotation)
+			r.Get("/admin/settings/consent", h.adminHandler.GetConsentPolicy)
+			r.Put("/admin/settings/consent", h.adminHandler.SaveConsentPolicy)
+
+			r.Get("/admin/addresses", h.adminHandler.GetAddresses)
+			r.Get("/admin/addresses/frozen", h.adminHandler.GetFrozenAddresses)
+			r.Post("/admin/addresses/{domain}/{local}/freeze", h.adminHandler.FreezeAddress)
+			r.Delete("/admin/addresses/{domain}/{local}/freeze", h.adminHandler.UnfreezeAddress)
+			r.Get("/admin/audit", h.adminHandler.GetAudit)
+			r.Get("/admin/messages", h.adminHandler.GetMessages)
+			r.Post("/admin/messages", h.adminHandler.InjectMessage)
+			r.Get("/admin/messages/{id}/clicks", h.adminHandler.GetMessageClicks)
+			r.Delete("/admin/messages/{id}", h.adminHandler.DeleteMessage)
+			r.Get("/admin/senders/{address}", h.adminHandler.GetSender)
+			r.Get("/admin/quarantine", h.adminHandler.GetQuarantine)
+			r.Post("/admin/quarantine/{id}/release", h.adminHandler.ReleaseQuarantined)
+			r.Delete("/admin/quarantine/{id}", h.adminHandler.DeleteQuarantined)
+			r.Get("/admin/abuse", h.adminHandler.GetAbuseReports)
+			r.Get("/admin/abuse/blocked", h.adminHandler.GetBlockedAddresses)
+			r.Delete("/admin/abuse/blocked/{domain}/{local}", h.adminHandler.UnblockAddress)
+			r.Get("/admin/abuse/{id}", h.adminHandler.GetAbuseReport)
+			r.Post("/admin/abuse/{id}/resolve", h.adminHandler.ResolveAbuseReport)
+
+			r.Get("/admin/honeypots", h.adminHandler.GetHoneypots)
+			r.Post("/admin/honeypots", h.adminHandler.AddHoneypot)
+			r.Get("/admin/honeypots/hits", h.adminHandler.GetHoneypotHits)
+			r.Get("/admin/honeypots/messages/{id}", h.adminHandler.GetHoneypotMessage)
+			r.Delete("/admin/honeypots/{domain}/{local}", h.adminHandler.DeleteHoneypot)
+			if h.adminHandler.ArchiveEnabled() {
+				r.Get("/admin/archive", h.adminHandler.SearchArchive)
+				r.Post("/admin/archive/restore", h.adminHandler.RestoreArchived)
+			}
+			r.Get("/admin/health", h.adminHandler.GetHealth)
+
+			// Domain-wide chat notifications
+			r.Get("/admin/notifications", h.adminHandler.GetNotifications)
+			r.Post("/admin/notifications", h.adminHandler.AddNotification)
+			r.Delete("/admin/notifications/{domain}/{id}", h.adminHandler.DeleteNotification)
+
+			r.Post("/admin/jwt/rotate", h.adminHandler.RotateJWTKey)
+
+			// Scoped API tokens for automation
+			r.Get("/admin/tokens", h.adminHandler.GetAPITokens)
+			r.Post("/admin/tokens", h.adminHandler.CreateAPIToken)
+			r.Delete("/admin/tokens/{id}", h.adminHandler.DeleteAPIToken)
+
+			// Passkeys
+			if h.adminHandler.WebAuthnEnabled() {
+				r.Get("/admin/webauthn/credentials", h.adminHandler.GetWebAuthnCredentials)
+				r.Post("/admin/webauthn/register/begin", h.adminHandler.BeginWebAuthnRegistration)
+				r.Post("/admin/webauthn/register/finish", h.adminHandler.FinishWebAuthnRegistration)
+				r.Delete("/admin/webauthn/credentials/{id}", h.adminHandler.DeleteWebAuthnCredential)
+			}
 
-			// Protected admin routes
-			r.Group(func(r chi.Router) {
-				r.Use(h.adminHandler.AuthMiddleware)
+			// Alerting
+			r.Get("/admin/alerts", h.adminHandler.GetAlerts)
+			r.Post("/admin/alerts/rules", h.adminHandler.AddAlertRule)
+			r.Delete("/admin/alerts/rules/{id}", h.adminHandler.DeleteAlertRule)
 
-				r.Get("/admin/stats", h.adminHandler.GetStats)
+			// Ingest dead-letter queue
+			r.Get("/admin/deadletters", h.adminHandler.GetDeadLetters)
+			r.Get("/admin/deadletters/{id}", h.adminHandler.GetDeadLetter)
+			r.Delete("/admin/deadletters/{id}", h.adminHandler.DeleteDeadLetter)
 
-				// Domains
-				r.Get("/admin/domains", h.adminHandler.GetDomains)
-				r.Post("/admin/domains", h.adminHandler.AddDomain)
-				r.Delete("/admin/domains/{domain}", h.adminHandler.RemoveDomain)
+			// Runtime diagnostics & pprof
+			h.adminHandler.MountDiagnostics(r)
+		})
+	}
+}
 
-				// Config & Settings
-				r.Get("/admin/config", h.adminHandler.GetConfig)
-				r.Get("/admin/settings", h.adminHandler.GetSettings)
-				r.Post("/admin/settings", h.adminHandler.UpdateSettings)
+// AddHealthCheck reports another component of this process (e.g. the
+// IMAP worker in single-binary mode) on /healthz and /readyz. check returns
+// nil while the component is healthy. Call it before serving.
+func (h *Handler) AddHealthCheck(name string, check func() error) {
+	if h.healthChecks == nil {
+		h.healthChecks = make(map[string]func() error)
+	}
+	h.healthChecks[name] = check
+}
 
-				r.Get("/admin/addresses", h.adminHandler.GetAddresses)
-				r.Get("/admin/messages", h.adminHandler.GetMessages)
-				r.Delete("/admin/messages/{id}", h.adminHandler.DeleteMessage)
-				r.Get("/admin/health", h.adminHandler.GetHealth)
-			})
+// componentHealth returns the state of the API and every registered
+// component, and whether all of them are healthy
+func (h *Handler) componentHealth() (map[string]string, bool) {
+	components := map[string]string{"api": "ok"}
+	healthy := true
+	for name, check := range h.healthChecks {
+		if err := check(); err != nil {
+			components[name] = err.Error()
+			healthy = false
+			continue
 		}
-	})
-
-	return r
+		components[name] = "ok"
+	}
+	return components, healthy
 }
 
-func (h *Handler) getPublicDomains(w http.ResponseWriter, r *http.Request) {
+// publicDomains returns the configured domains followed by the ones added
+// at runtime, deduplicated
+func (h *Handler) publicDomains(ctx context.Context) []string {
 	// Get static domains from config
 	domains := make([]string, len(h.cfg.AllowedDomains))
 	copy(domains, h.cfg.AllowedDomains)
 
 	// Get dynamic domains from Redis
-	dynamicDomains, err := h.store.GetDomains(r.Context())
+	dynamicDomains, err := h.store.GetDomains(ctx)
 	if err == nil {
 		// Dedup map
 		seen := make(map[string]bool)
@@ -127,118 +432,134 @@ func (h *Handler) getPublicDomains(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Draining and retired domains take no new addresses
+	states, _ := h.store.GetDomainStates(ctx)
+	active := domains[:0]
+	for _, d := range domains {
+		if state, ok := states[email.CanonicalDomain(d)]; !ok || state == domain.DomainActive {
+			active = append(active, d)
+		}
+	}
+	return active
+}
+
+func (h *Handler) getPublicDomains(w http.ResponseWriter, r *http.Request) {
+	domains := h.publicDomains(r.Context())
+
+	// Unicode names for IDN domains so the UI can show them as users type them
+	display := make(map[string]string)
+	for _, d := range domains {
+		if u := email.DisplayDomain(d); u != d {
+			display[d] = u
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string][]string{
-		"domains": domains,
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domains":         domains,
+		"display_domains": display,
 	})
 }
 
 type CreateAddressRequest struct {
-	Domain string `json:"domain"`
+	Domain string `json:"domain"` // empty: picked by the domain rotation
 	Local  string `json:"local,omitempty"`
-}
-
-var indonesianNames = []string{
-	"adi", "agus", "ahmad", "andi", "arif", "bambang", "budi", "candra",
-	"dedi", "deni", "edi", "eko", "fajar", "ferry", "gunawan", "hadi",
-	"hendra", "indra", "joko", "kevin", "kurnia", "lukman", "made",
-	"mahendra", "muhammad", "nanda", "putra", "rahmat", "rendi", "rizki",
-	"sandi", "slamet", "sugeng", "taufik", "wahyu", "wawan", "yoga", "yudi",
-	"zainal", "zaki", "dewi", "fitri", "maya", "putri", "rani", "sari",
-	"wati", "yuni", "ani", "dian", "eka", "intan", "lina", "nina",
-	"ratna", "rina", "sinta", "tika", "wulan", "yanti",
-	"abdul", "aditya", "agung", "anwar", "ari", "arum", "astuti", "bagus",
-	"bayu", "bintang", "cahyo", "danang", "darmawan", "desy", "dwi", "enny",
-	"farhan", "febri", "galih", "gita", "hafiz", "hasan", "heru", "iman",
-	"irwan", "kartika", "kusuma", "lestari", "mulyono", "nur", "panji", "pratama",
-	"purnama", "ridwan", "saputra", "setiawan", "teguh", "tri", "utami", "widodo",
-	"ade", "adnan", "aisyah", "akbar", "alamsyah", "aldy", "ali", "alif",
-	"amalia", "aminah", "amir", "andika", "anggi", "anggun", "anisa", "annisa",
-	"antono", "apriani", "ardian", "arianto", "arifin", "ariyanto", "arizona", "arya",
-	"asri", "aura", "aziz", "azizah", "badar", "basuki", "benny", "berlian",
-	"bima", "bisma", "chairul", "citra", "damar", "danu", "darsono", "david",
-	"deri", "dicky", "didik", "dimas", "dina", "dinda", "erik", "erlangga",
-	"erna", "erwin", "fadlan", "fadli", "fany", "farid", "fathir", "fauzan",
-	"fauzi", "feby", "fira", "firman", "fitria", "gia", "gilang", "grace",
-	"gumilar", "hamzah", "hana", "hanif", "haris", "hendri", "hidayat", "hikmah",
-	"husen", "ibrahim", "ihsan", "ika", "ikhsan", "ikbal", "indah", "ira",
-	"irfan", "ismail", "iswan", "iwan", "jamal", "jefri", "johan", "juli",
-	"julia", "julio", "kadir", "kamal", "karina", "kasih", "kemal", "khairul",
-	"khoirul", "kiki", "komang", "krishna", "laksamana", "laras", "latif", "lia",
-	"linda", "lucky", "lutfi", "maman", "mansur", "mardi", "marwan", "maulana",
-	"mega", "melati", "mira", "muamar", "mulyadi", "munir", "mutia", "nabil",
-	"nadia", "nadir", "najwa", "nanang", "nasir", "naufal", "nazar", "nila",
-	"novi", "novita", "nugroho", "nurul", "nyoman", "okta", "oktavia", "panjaitan",
-	"permadi", "permata", "perdana", "ponco", "prasetyo", "prayitno", "puji", "purwanto",
-	"raden", "radit", "raffi", "rafli", "raihan", "rama", "ramadhan", "ramlan",
-	"raya", "reza", "rizal", "rizky", "roni", "rosyid", "rudy", "ruslan",
+	// Vanity constraints for random addresses
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
 }
 
 func (h *Handler) createRandomAddress(w http.ResponseWriter, r *http.Request) {
-	if !h.checkRateLimit(w, r, "create", h.cfg.RateLimitCreatePerMin) {
-		return
-	}
-
 	var req CreateAddressRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	req.Domain = email.CanonicalDomain(req.Domain)
+	if req.Domain == "" {
+		req.Domain = h.pickDomain(r.Context())
+	}
 	if !h.isValidDomain(r.Context(), req.Domain) {
 		http.Error(w, "Invalid domain", http.StatusBadRequest)
 		return
 	}
 
-	// Retry loop for random address
-	for i := 0; i < 5; i++ {
-		// Pick a random Indonesian name
-		name := indonesianNames[rand.Intn(len(indonesianNames))]
-		// Generate 5 random digits
-		digits := rand.Intn(90000) + 10000 // generates 10000-99999
-		local := fmt.Sprintf("%s%d", name, digits)
+	if h.store.DomainState(r.Context(), req.Domain) != domain.DomainActive {
+		http.Error(w, "Domain is not accepting new addresses", http.StatusBadRequest)
+		return
+	}
 
-		success, err := h.store.ReserveAddress(r.Context(), req.Domain, local)
-		if err != nil {
-			http.Error(w, "Database error", http.StatusInternalServerError)
-			return
-		}
-		if success {
-			h.respondWithAddress(w, req.Domain, local)
-			return
-		}
+	if !h.checkConsent(w, r) || !h.checkAddressQuota(w, r) {
+		return
 	}
-	http.Error(w, "Failed to generate unique address", http.StatusConflict)
-}
 
-func (h *Handler) createCustomAddress(w http.ResponseWriter, r *http.Request) {
-	if !h.checkRateLimit(w, r, "create", h.cfg.RateLimitCreatePerMin) {
+	pattern := addrgen.Pattern{
+		Prefix: strings.ToLower(strings.TrimSpace(req.Prefix)),
+		Suffix: strings.ToLower(strings.TrimSpace(req.Suffix)),
+	}
+	local, err := h.generator.GenerateWith(r.Context(), req.Domain, pattern)
+	if errors.Is(err, addrgen.ErrInvalidPattern) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, addrgen.ErrExhausted) {
+		http.Error(w, "Failed to generate unique address", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	h.openPinWindow(r, req.Domain, local)
+	h.bindToSession(w, r, req.Domain, local)
+	h.respondWithAddress(w, r, req.Domain, local)
+}
 
+func (h *Handler) createCustomAddress(w http.ResponseWriter, r *http.Request) {
 	var req CreateAddressRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	req.Domain = email.CanonicalDomain(req.Domain)
+	if req.Domain == "" {
+		req.Domain = h.pickDomain(r.Context())
+	}
 	if !h.isValidDomain(r.Context(), req.Domain) {
 		http.Error(w, "Invalid domain", http.StatusBadRequest)
 		return
 	}
 
-	local := strings.ToLower(strings.TrimSpace(req.Local))
+	local, violations := h.checkLocal(r.Context(), req.Domain, req.Local)
+	if len(violations) == 0 && h.honeypotTouched(r, req.Domain, local) {
+		violations = append(violations, violationReserved)
+	}
+	if len(violations) == 0 && h.store.IsBlocked(r.Context(), req.Domain, local) {
+		violations = append(violations, violationBlocked)
+	}
+	if len(violations) == 0 && h.frozenLocal(r.Context(), req.Domain, local) {
+		violations = append(violations, violationFrozen)
+	}
+	if len(violations) > 0 {
+		http.Error(w, violations[0].Message, http.StatusBadRequest)
+		return
+	}
 
-	match, _ := regexp.MatchString(`^[a-z0-9][a-z0-9._-]{2,30}$`, local)
-	if !match {
-		http.Error(w, "Invalid username format. Must be 3-30 chars, alphanumeric with dots/scores.", http.StatusBadRequest)
+	if !h.checkConsent(w, r) || !h.checkAddressQuota(w, r) {
 		return
 	}
 
-	reserved := []string{"admin", "root", "postmaster", "support", "noreply", "abuse", "mailer-daemon"}
-	for _, word := range reserved {
-		if local == word {
-			http.Error(w, "Username is reserved", http.StatusBadRequest)
+	// A draining domain still serves its existing inboxes
+	if h.store.DomainState(r.Context(), req.Domain) != domain.DomainActive {
+		exists, err := h.store.AddressExists(r.Context(), req.Domain, local)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Domain is not accepting new addresses", http.StatusBadRequest)
 			return
 		}
 	}
@@ -251,27 +572,44 @@ func (h *Handler) createCustomAddress(w http.ResponseWriter, r *http.Request) {
 	}
 	// Success implied, proceed to respond
 
-	h.respondWithAddress(w, req.Domain, local)
+	h.openPinWindow(r, req.Domain, local)
+	h.bindToSession(w, r, req.Domain, local)
+	h.respondWithAddress(w, r, req.Domain, local)
 }
 
-func (h *Handler) respondWithAddress(w http.ResponseWriter, d, local string) {
+func (h *Handler) respondWithAddress(w http.ResponseWriter, r *http.Request, d, local string) {
+	_, ttl, _ := h.addressSet(sessionFrom(r.Context()))
 	resp := domain.Address{
 		Email:     fmt.Sprintf("%s@%s", local, d),
 		Local:     local,
 		Domain:    d,
-		ExpiresAt: time.Now().Add(time.Duration(h.cfg.TTLSeconds) * time.Second),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if display := email.DisplayDomain(d); display != d {
+		resp.DisplayDomain = display
+	}
+	if h.cfg.PinWindowSecs > 0 {
+		// Best effort: the inbox still has the message if this fails
+		resp.LatestEvent, _ = h.store.LatestAddressEvent(r.Context(), d, local)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (h *Handler) getInbox(w http.ResponseWriter, r *http.Request) {
-	domainParam := chi.URLParam(r, "domain")
-	localParam := chi.URLParam(r, "local")
-
-	if !h.checkRateLimit(w, r, "fetch", h.cfg.RateLimitFetchPerMin) {
+// openPinWindow starts pinning codes sent to a new or claimed address
+func (h *Handler) openPinWindow(r *http.Request, d, local string) {
+	if h.cfg.PinWindowSecs <= 0 {
 		return
 	}
+	window := time.Duration(h.cfg.PinWindowSecs) * time.Second
+	if err := h.store.OpenPinWindow(r.Context(), d, local, window); err != nil {
+		log.Printf("Failed to open pin window for %s: %v", privacy.Inbox(local, d), err)
+	}
+}
+
+func (h *Handler) getInbox(w http.ResponseWriter, r *http.Request) {
+	domainParam := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	localParam := chi.URLParam(r, "local")
 
 	limit := 50
 	if l := r.URL.Query().Get("limit"); l != "" {
@@ -287,22 +625,82 @@ func (h *Handler) getInbox(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	msgs, err := h.store.GetInbox(r.Context(), domainParam, localParam, limit, before)
+	var msgs []*domain.Message
+	var err error
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		// ?as_of= shows the inbox as it was at a past time (see asof.go)
+		asOf, ok := parseAsOf(v)
+		if !ok {
+			http.Error(w, "as_of must be unix seconds or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		msgs, err = h.inboxAsOf(r.Context(), domainParam, localParam, limit, before, asOf)
+	} else {
+		msgs, err = h.store.GetInbox(r.Context(), domainParam, localParam, limit, before)
+	}
 	if err != nil {
 		http.Error(w, "Failed to fetch inbox", http.StatusInternalServerError)
 		return
 	}
 
+	// ?dnsbl=hide drops messages from blocklisted relays, ?dnsbl=only keeps
+	// just those; filtering happens after paging
+	if mode := r.URL.Query().Get("dnsbl"); mode == "hide" || mode == "only" {
+		kept := msgs[:0]
+		for _, msg := range msgs {
+			if (len(msg.DNSBL) > 0) == (mode == "only") {
+				kept = append(kept, msg)
+			}
+		}
+		msgs = kept
+	}
+
+	// Inboxes that burn messages on read list summaries only, so bodies
+	// can only be read once through /message/{id}
+	burn, err := h.store.BurnsAfterReading(r.Context(), domainParam, localParam)
+	if err != nil {
+		http.Error(w, "Failed to fetch inbox", http.StatusInternalServerError)
+		return
+	}
+	if burn {
+		writeSummaries(w, msgs)
+		return
+	}
+	writeInbox(w, r, msgs)
+}
+
+// writeInbox encodes messages the way the inbox listing does for r's
+// API version
+func writeInbox(w http.ResponseWriter, r *http.Request, msgs []*domain.Message) {
 	// Ensure we return [] not null in JSON
 	if msgs == nil {
 		msgs = []*domain.Message{}
 	}
+	if apiVersion(r) >= apiV1 {
+		// v1 lists summaries only; bodies come from /message/{id}
+		writeSummaries(w, msgs)
+		return
+	}
+	listed := make([]*domain.Message, len(msgs))
+	for i, msg := range msgs {
+		listed[i] = withoutInlineData(msg)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(msgs)
+	json.NewEncoder(w).Encode(listed)
+}
+
+// writeSummaries writes an inbox page as message summaries
+func writeSummaries(w http.ResponseWriter, msgs []*domain.Message) {
+	summaries := make([]messageSummary, len(msgs))
+	for i, msg := range msgs {
+		summaries[i] = summarize(msg)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
 }
 
 func (h *Handler) streamInbox(w http.ResponseWriter, r *http.Request) {
-	domainParam := chi.URLParam(r, "domain")
+	domainParam := email.CanonicalDomain(chi.URLParam(r, "domain"))
 	localParam := chi.URLParam(r, "local")
 
 	// Set SSE headers
@@ -351,6 +749,7 @@ func (h *Handler) streamInbox(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) getMessage(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	burn := r.URL.Query().Get("burn") == "true"
 
 	msg, err := h.store.GetMessage(r.Context(), id)
 	if err != nil {
@@ -361,6 +760,40 @@ func (h *Handler) getMessage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Message not found", http.StatusNotFound)
 		return
 	}
+	if refusal := h.inboxRefusal(r.Context(), msg.Domain, msg.Local); refusal != "" {
+		http.Error(w, refusal, http.StatusForbidden)
+		return
+	}
+	if !h.allowInbox(w, r, msg.Domain, msg.Local) {
+		return
+	}
+
+	// Burned messages are deleted as they are returned (see burnread.go)
+	if !burn {
+		if burn, err = h.store.BurnsAfterReading(r.Context(), msg.Domain, msg.Local); err != nil {
+			http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+			return
+		}
+	}
+	if burn {
+		if msg, err = h.store.BurnMessage(r.Context(), id); err != nil {
+			http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if msg == nil {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		etag := messageETag(r, id, "json")
+		if h.notModified(w, r, etag) {
+			return
+		}
+		h.cacheMessage(w, etag)
+		// Burned messages keep their images, since this is the only read
+		msg = withoutInlineData(msg)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(msg)
@@ -382,6 +815,9 @@ func (h *Handler) getStatus(w http.ResponseWriter, r *http.Request) {
 	if expired {
 		response["message"] = "This service has expired"
 	}
+	if h.cfg.DemoMode {
+		response["demo"] = true
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -390,7 +826,8 @@ func (h *Handler) getStatus(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) expirationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Allow /api/status to always work so frontend can check expiration
-		if r.URL.Path == "/api/status" || r.URL.Path == "/api/healthz" || r.URL.Path == "/api/readyz" {
+		switch unversionedPath(r.URL.Path) {
+		case "/api/status", "/api/healthz", "/api/readyz":
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -410,27 +847,20 @@ func (h *Handler) expirationMiddleware(next http.Handler) http.Handler {
 }
 
 func (h *Handler) isValidDomain(ctx context.Context, d string) bool {
-	// 1. Check static config first
-	for _, allowed := range h.cfg.AllowedDomains {
-		if d == allowed {
-			return true
-		}
-	}
+	// Unicode and punycode spellings of an IDN are the same domain
+	d = email.CanonicalDomain(d)
 
-	// 2. Check dynamic domains from Redis
-	dynamicDomains, err := h.store.GetDomains(ctx)
-	if err == nil {
-		for _, allowed := range dynamicDomains {
-			if d == allowed {
-				return true
-			}
-		}
+	// 1. Static config and dynamic domains from Redis, unless retired
+	if h.isSystemDomain(ctx, d) {
+		return h.store.DomainState(ctx, d) != domain.DomainRetired
 	}
 
-	return false
+	// 2. Verified domains connected by the caller's own account
+	return h.ownsVerifiedDomain(ctx, d)
 }
 
-func (h *Handler) checkRateLimit(w http.ResponseWriter, r *http.Request, action string, limit int) bool {
+// clientIP returns the caller's address, honouring proxy headers
+func clientIP(r *http.Request) string {
 	ip := r.RemoteAddr
 	// Very basic IP extraction. Behind proxy might need X-Real-IP
 	if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
@@ -446,16 +876,5 @@ func (h *Handler) checkRateLimit(w http.ResponseWriter, r *http.Request, action
 			ip = host
 		}
 	}
-
-	allowed, err := h.store.RateLimit(r.Context(), ip, action, limit, time.Minute)
-	if err != nil {
-		// Open fail? Or block? Let's log and allow
-		// For now, block on error to be safe or allowed
-		return true
-	}
-	if !allowed {
-		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-		return false
-	}
-	return true
+	return ip
 }
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/privacy"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// honeypotWatch records requests to inbox routes ({domain}/{local}) of a
+// honeypot. The request is served as usual, and since mail to honeypots
+// never reaches an inbox there is nothing to leak; the scraper just never
+// learns it was seen.
+func (h *Handler) honeypotWatch(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || strings.Contains(rctx.RoutePattern(), "/admin/") {
+			return
+		}
+		if local := rctx.URLParam("local"); local != "" {
+			h.honeypotTouched(r, rctx.URLParam("domain"), local)
+		}
+	})
+}
+
+// honeypotTouched reports whether local@d is a honeypot, recording the
+// request as a hit when it is
+func (h *Handler) honeypotTouched(r *http.Request, d, local string) bool {
+	d, local = email.CanonicalDomain(d), strings.ToLower(local)
+	if local == "" || !h.store.IsHoneypot(r.Context(), d, local) {
+		return false
+	}
+	hit := &domain.HoneypotHit{
+		Kind:      "api",
+		Domain:    d,
+		Local:     local,
+		ClientIP:  clientIP(r),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		UserAgent: r.UserAgent(),
+	}
+	if err := h.store.RecordHoneypotHit(r.Context(), hit, nil); err != nil {
+		log.Printf("Failed to record honeypot hit on %s: %v", privacy.Inbox(local, d), err)
+	}
+	return true
+}
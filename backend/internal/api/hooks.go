@@ -0,0 +1,165 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/notify"
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// REST Hooks (as used by Zapier and Make): integrations subscribe a target
+// URL to "new_email" on an inbox and fall back to polling the same records.
+// Every endpoint authenticates with the inbox access token, passed as
+// "Authorization: Bearer <token>" or ?token=.
+
+// hookPollSize is how many records the polling fallback returns
+const hookPollSize = 25
+
+// inboxTokenAuth checks the access token for the inbox in the URL
+func (h *Handler) inboxTokenAuth(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	local := chi.URLParam(r, "local")
+
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	ok, err := h.store.CheckInboxToken(r.Context(), d, local, token)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", "", false
+	}
+	if !ok {
+		http.Error(w, "Invalid or missing token", http.StatusUnauthorized)
+		return "", "", false
+	}
+	return d, local, true
+}
+
+// getHooks lists an inbox's hook subscriptions; integrations also use it to
+// test credentials
+func (h *Handler) getHooks(w http.ResponseWriter, r *http.Request) {
+	d, local, ok := h.inboxTokenAuth(w, r)
+	if !ok {
+		return
+	}
+
+	channels, err := h.store.ListNotifyChannels(r.Context(), notify.ScopeInbox, local+"@"+d)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	hooks := make([]*domain.NotifyChannel, 0, len(channels))
+	for _, ch := range channels {
+		if ch.Kind == notify.KindWebhook {
+			hooks = append(hooks, ch)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// subscribeHook registers a target URL for new_email
+func (h *Handler) subscribeHook(w http.ResponseWriter, r *http.Request) {
+	d, local, ok := h.inboxTokenAuth(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		TargetURL string `json:"target_url"`
+		Event     string `json:"event"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Event != "" && req.Event != notify.EventNewEmail {
+		http.Error(w, "Unsupported event", http.StatusBadRequest)
+		return
+	}
+
+	ch := &domain.NotifyChannel{
+		ID:         ulid.Make().String(),
+		Kind:       notify.KindWebhook,
+		Scope:      notify.ScopeInbox,
+		Target:     local + "@" + d,
+		WebhookURL: req.TargetURL,
+		CreatedAt:  time.Now(),
+	}
+	if err := notify.ValidateHook(ch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := h.store.SaveNotifyChannel(r.Context(), ch)
+	if errors.Is(err, redisstore.ErrTooManyChannels) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":         ch.ID,
+		"event":      notify.EventNewEmail,
+		"target_url": ch.WebhookURL,
+	})
+}
+
+// unsubscribeHook removes a subscription
+func (h *Handler) unsubscribeHook(w http.ResponseWriter, r *http.Request) {
+	d, local, ok := h.inboxTokenAuth(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := h.store.DeleteNotifyChannel(r.Context(), notify.ScopeInbox, local+"@"+d, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Hook not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pollHooks is the polling fallback: the newest messages, newest first, in
+// the same shape hooks receive
+func (h *Handler) pollHooks(w http.ResponseWriter, r *http.Request) {
+	d, local, ok := h.inboxTokenAuth(w, r)
+	if !ok {
+		return
+	}
+
+	msgs, err := h.store.GetInbox(r.Context(), d, local, hookPollSize, 0)
+	if err == nil {
+		msgs, err = h.burnSafe(r.Context(), d, local, msgs)
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch inbox", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]notify.HookPayload, 0, len(msgs))
+	for _, m := range msgs {
+		records = append(records, notify.NewHookPayload(m))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Messages are immutable once stored, so every representation of one is
+// identified by its ID alone. Successful responses carry an ETag derived
+// from it and are cacheable for the message TTL. A matching If-None-Match
+// is only answered with 304 once the message has been loaded (cached
+// in-process) and may still be read, so deletes and refusals take effect.
+
+// messageETag is the ETag of one representation (variant) of a message
+// for the API version of r
+func messageETag(r *http.Request, id, variant string) string {
+	return `"m-` + id + "-" + variant + "-v" + strconv.Itoa(apiVersion(r)) + `"`
+}
+
+// notModified writes a 304 and returns true when the client already has
+// this version of a message. Call it only after loading the message.
+func (h *Handler) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	h.cacheMessage(w, etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// cacheMessage sets the caching headers of a successful message response
+func (h *Handler) cacheMessage(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(h.cfg.TTLSeconds)+", immutable")
+}
+
+// etagMatches implements the weak comparison of If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCache is an in-process LRU of rendered message variants (sanitized
+// previews), keyed by message ID and variant
+type renderCache struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+type renderEntry struct {
+	key   string
+	value []byte
+	extra string
+}
+
+// newRenderCache returns a cache of max entries, or nil (caching nothing)
+// when max is 0
+func newRenderCache(max int) *renderCache {
+	if max <= 0 {
+		return nil
+	}
+	return &renderCache{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *renderCache) Get(key string) ([]byte, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, "", false
+	}
+	c.order.MoveToFront(el)
+	e := el.Value.(*renderEntry)
+	return e.value, e.extra, true
+}
+
+// Add stores value and a small extra string (e.g. a header value)
+func (c *renderCache) Add(key string, value []byte, extra string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value = &renderEntry{key: key, value: value, extra: extra}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&renderEntry{key: key, value: value, extra: extra})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*renderEntry).key)
+	}
+}
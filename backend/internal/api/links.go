@@ -0,0 +1,69 @@
+package api
+
+import (
+	"cattymail/internal/linkproxy"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// longestTTL is the lifetime of the longest-lived addresses, which bounds
+// how long any message can still be around
+func (h *Handler) longestTTL() time.Duration {
+	return time.Duration(max(h.cfg.TTLSeconds, h.cfg.AccountTTLSeconds, h.cfg.PremiumTTLSeconds)) * time.Second
+}
+
+// proxiedLink rewrites a link of message msgID to go through followLink
+func (h *Handler) proxiedLink(msgID string) func(string) string {
+	exp := time.Now().Add(h.longestTTL())
+	return func(target string) string {
+		return "/api/l/" + h.links.Token(msgID, target, exp)
+	}
+}
+
+// followLink redirects to a proxied link with its tracking parameters
+// removed, unless the target's domain is on a blocklist, and counts the
+// click against the message for the abuse team. No referrer is sent.
+func (h *Handler) followLink(w http.ResponseWriter, r *http.Request) {
+	msgID, target, err := h.links.Parse(chi.URLParam(r, "token"))
+	if errors.Is(err, linkproxy.ErrExpired) {
+		http.Error(w, "Link expired", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid link", http.StatusNotFound)
+		return
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "Invalid link", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.RecordClick(r.Context(), msgID, target); err != nil {
+		log.Printf("Failed to record click in message %s: %v", msgID, err)
+	}
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.linkCheck != nil {
+		if lists := h.linkCheck.CheckDomain(r.Context(), u.Hostname()); len(lists) > 0 {
+			// Show the link rather than follow it; users can still copy it
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Security-Policy", "default-src 'none'")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "<!DOCTYPE html><meta charset=\"utf-8\"><title>Blocked link</title>"+
+				"<p>This link points to a domain listed as unsafe (%s) and was not opened:</p><pre>%s</pre>\n",
+				html.EscapeString(strings.Join(lists, ", ")), html.EscapeString(target))
+			return
+		}
+	}
+	http.Redirect(w, r, linkproxy.StripTracking(target), http.StatusFound)
+}
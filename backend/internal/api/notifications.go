@@ -0,0 +1,111 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/notify"
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// inboxTarget resolves the inbox in the URL, writing a 404 when the
+// address is not live
+func (h *Handler) inboxTarget(w http.ResponseWriter, r *http.Request) (string, bool) {
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	local := chi.URLParam(r, "local")
+
+	exists, err := h.store.AddressExists(r.Context(), d, local)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", false
+	}
+	if !exists {
+		http.Error(w, "Address not found", http.StatusNotFound)
+		return "", false
+	}
+	return local + "@" + d, true
+}
+
+// getInboxNotifications lists the chat webhooks of an inbox
+func (h *Handler) getInboxNotifications(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.inboxTarget(w, r)
+	if !ok {
+		return
+	}
+
+	channels, err := h.store.ListNotifyChannels(r.Context(), notify.ScopeInbox, target)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	masked := make([]*domain.NotifyChannel, 0, len(channels))
+	for _, ch := range channels {
+		masked = append(masked, notify.Masked(ch))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channels": masked,
+	})
+}
+
+// addInboxNotification posts new-mail summaries of an inbox to Discord or Slack
+func (h *Handler) addInboxNotification(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.inboxTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var ch domain.NotifyChannel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	ch.ID = ulid.Make().String()
+	ch.Scope = notify.ScopeInbox
+	ch.Target = target
+	ch.CreatedAt = time.Now()
+
+	if err := notify.Validate(&ch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := h.store.SaveNotifyChannel(r.Context(), &ch)
+	if errors.Is(err, redisstore.ErrTooManyChannels) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(notify.Masked(&ch))
+}
+
+// deleteInboxNotification removes a chat webhook from an inbox
+func (h *Handler) deleteInboxNotification(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.inboxTarget(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := h.store.DeleteNotifyChannel(r.Context(), notify.ScopeInbox, target, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
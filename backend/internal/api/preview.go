@@ -0,0 +1,243 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/preview"
+	"cattymail/internal/thumbnail"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getMessagePreview serves a message as a sanitized standalone HTML
+// document for iframe embedding
+func (h *Handler) getMessagePreview(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	w.Header().Set("Content-Security-Policy", preview.CSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	msg, ok := h.viewableMessage(w, r, id)
+	if !ok {
+		return
+	}
+	etag := messageETag(r, id, "preview")
+	if h.notModified(w, r, etag) {
+		return
+	}
+
+	html, blocked, ok := h.rendered.Get(id + ":preview")
+	if !ok {
+		var opts preview.Options
+		if h.cfg.LinkProxy {
+			opts.Link = h.proxiedLink(msg.ID)
+		}
+		res := preview.RenderWith(msg, opts)
+		html, blocked = res.HTML, strconv.Itoa(res.RemoteBlocked)
+		h.rendered.Add(id+":preview", html, blocked)
+	}
+	h.cacheMessage(w, etag)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Remote-Content-Blocked", blocked)
+	w.Write(html)
+}
+
+// getMessageThumbnail serves a PNG rendering of the message preview
+func (h *Handler) getMessageThumbnail(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	msg, ok := h.viewableMessage(w, r, id)
+	if !ok {
+		return
+	}
+	etag := messageETag(r, id, "png")
+	if h.notModified(w, r, etag) {
+		return
+	}
+
+	img, err := h.thumbnails.Get(r.Context(), msg)
+	if err != nil {
+		log.Printf("thumbnail: %v", err)
+		http.Error(w, "Failed to render thumbnail", http.StatusBadGateway)
+		return
+	}
+
+	h.cacheMessage(w, etag)
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(img)
+}
+
+// getMessagePrint serves the print-friendly version of a message preview:
+// the same sanitized document with the sender, recipient, date and subject
+// on top
+func (h *Handler) getMessagePrint(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	w.Header().Set("Content-Security-Policy", preview.CSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	msg, ok := h.viewableMessage(w, r, id)
+	if !ok {
+		return
+	}
+	etag := messageETag(r, id, "print")
+	if h.notModified(w, r, etag) {
+		return
+	}
+	html, _, ok := h.rendered.Get(id + ":print")
+	if !ok {
+		res := preview.RenderWith(msg, preview.Options{Print: true})
+		html = res.HTML
+		h.rendered.Add(id+":print", html, strconv.Itoa(res.RemoteBlocked))
+	}
+	h.cacheMessage(w, etag)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(html)
+}
+
+// getMessagePDF serves the print-friendly version of a message as a PDF
+// download
+func (h *Handler) getMessagePDF(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	msg, ok := h.viewableMessage(w, r, id)
+	if !ok {
+		return
+	}
+	etag := messageETag(r, id, "pdf")
+	if h.notModified(w, r, etag) {
+		return
+	}
+	doc, err := h.thumbnails.PDF(r.Context(), msg)
+	if err == thumbnail.ErrNoPrinter {
+		http.Error(w, "PDF export is not available", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		log.Printf("pdf: %v", err)
+		http.Error(w, "Failed to render PDF", http.StatusBadGateway)
+		return
+	}
+
+	h.cacheMessage(w, etag)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.pdf"`)
+	w.Write(doc)
+}
+
+// viewableMessage loads a message for one of its views (preview, print,
+// PDF, thumbnail, headers, inline parts), refusing messages of blocked or
+// frozen inboxes and of inboxes that burn messages after reading, and
+// charges the per-inbox rate limit. It writes the error response when it
+// fails.
+func (h *Handler) viewableMessage(w http.ResponseWriter, r *http.Request, id string) (*domain.Message, bool) {
+	msg, err := h.store.GetMessage(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+		return nil, false
+	}
+	if msg == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return nil, false
+	}
+	if refusal := h.inboxRefusal(r.Context(), msg.Domain, msg.Local); refusal != "" {
+		http.Error(w, refusal, http.StatusForbidden)
+		return nil, false
+	}
+	if !h.allowInbox(w, r, msg.Domain, msg.Local) {
+		return nil, false
+	}
+	burn, err := h.store.BurnsAfterReading(r.Context(), msg.Domain, msg.Local)
+	if err != nil {
+		http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+		return nil, false
+	}
+	if burn {
+		http.Error(w, "Messages of this inbox can only be read once, through /message/{id}", http.StatusForbidden)
+		return nil, false
+	}
+	return msg, true
+}
+
+// getMessageHeaders serves the headers kept with a message, for the "show
+// original headers" view
+func (h *Handler) getMessageHeaders(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	msg, ok := h.viewableMessage(w, r, id)
+	if !ok {
+		return
+	}
+	etag := messageETag(r, id, "headers")
+	if h.notModified(w, r, etag) {
+		return
+	}
+
+	headers := msg.Headers
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	h.cacheMessage(w, etag)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        msg.ID,
+		"from":      msg.From,
+		"to":        msg.OriginalTo,
+		"subject":   msg.Subject,
+		"date":      msg.Date,
+		"sender_ip": msg.SenderIP,
+		"headers":   headers,
+	})
+}
+
+// getMessageInline serves one inline image of a message by Content-ID.
+// Message JSON only lists the parts, see withoutInlineData.
+func (h *Handler) getMessageInline(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	cid, err := url.PathUnescape(chi.URLParam(r, "cid"))
+	if err != nil {
+		http.Error(w, "Invalid content ID", http.StatusBadRequest)
+		return
+	}
+	msg, ok := h.viewableMessage(w, r, id)
+	if !ok {
+		return
+	}
+	n := -1
+	for i, part := range msg.Inline {
+		if part.ContentID == cid {
+			n = i
+			break
+		}
+	}
+	if n < 0 {
+		http.Error(w, "Inline part not found", http.StatusNotFound)
+		return
+	}
+	part := msg.Inline[n]
+	etag := messageETag(r, id, "inline"+strconv.Itoa(n))
+	if h.notModified(w, r, etag) {
+		return
+	}
+
+	h.cacheMessage(w, etag)
+	w.Header().Set("Content-Type", part.ContentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(part.Data)
+}
+
+// withoutInlineData returns msg with its inline parts listed but without
+// their bytes, which would bloat every message and listing as base64
+func withoutInlineData(msg *domain.Message) *domain.Message {
+	if len(msg.Inline) == 0 {
+		return msg
+	}
+	cp := *msg
+	cp.Inline = make([]domain.InlinePart, len(msg.Inline))
+	for i, part := range msg.Inline {
+		cp.Inline[i] = domain.InlinePart{ContentID: part.ContentID, ContentType: part.ContentType}
+	}
+	return &cp
+}
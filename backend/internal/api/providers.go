@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cattymail/internal/email"
+	"cattymail/internal/providers"
+)
+
+const (
+	// providerListDays is how recent a delivery must be for a provider to
+	// count as working with a domain
+	providerListDays = 7
+	providerListTTL  = 10 * time.Minute
+)
+
+// providerList is the cached body of GET /domains/providers
+type providerList struct {
+	mu      sync.Mutex
+	body    []byte
+	expires time.Time
+}
+
+// getDomainProviders lists, per public domain, the known services that
+// delivered to it over the last week, so users can pick a domain that
+// works for the site they are signing up to
+func (h *Handler) getDomainProviders(w http.ResponseWriter, r *http.Request) {
+	h.providerList.mu.Lock()
+	defer h.providerList.mu.Unlock()
+	if h.providerList.body == nil || time.Now().After(h.providerList.expires) {
+		ctx := r.Context()
+		counts, err := h.store.ProviderCounts(ctx, providerListDays)
+		if err != nil {
+			if h.providerList.body == nil {
+				http.Error(w, "Provider list unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			log.Printf("Failed to refresh provider list: %v", err)
+		} else {
+			working := make(map[string][]string)
+			for _, d := range h.publicDomains(ctx) {
+				names := []string{}
+				for p, daily := range counts[email.CanonicalDomain(d)] {
+					if p == providers.Other {
+						continue
+					}
+					for _, n := range daily {
+						if n > 0 {
+							names = append(names, p)
+							break
+						}
+					}
+				}
+				sort.Strings(names)
+				working[d] = names
+			}
+			body, _ := json.Marshal(map[string]interface{}{
+				"days":    providerListDays,
+				"domains": working,
+			})
+			h.providerList.body = body
+			h.providerList.expires = time.Now().Add(providerListTTL)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.providerList.body)
+	w.Write([]byte("\n"))
+}
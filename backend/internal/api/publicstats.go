@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// publicStats is the cached body of GET /stats/public
+type publicStats struct {
+	mu      sync.Mutex
+	body    []byte
+	expires time.Time
+}
+
+// getPublicStats serves sanitized aggregate numbers for the landing page.
+// It is opt-in (PUBLIC_STATS) and only reads the daily counters and the
+// domain list, cached for PUBLIC_STATS_CACHE_SECONDS, so it never touches
+// the admin stats scans.
+func (h *Handler) getPublicStats(w http.ResponseWriter, r *http.Request) {
+	ttl := time.Duration(h.cfg.PublicStatsCacheSecs) * time.Second
+
+	h.publicStats.mu.Lock()
+	defer h.publicStats.mu.Unlock()
+	if h.publicStats.body == nil || time.Now().After(h.publicStats.expires) {
+		ctx := r.Context()
+		today, err := h.store.GetDailyStats(ctx, time.Now())
+		if err != nil {
+			if h.publicStats.body == nil {
+				http.Error(w, "Stats unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			// Serve the stale numbers rather than fail the landing page
+			log.Printf("Failed to refresh public stats: %v", err)
+		} else {
+			body, _ := json.Marshal(map[string]interface{}{
+				"emails_today":            today.Delivered,
+				"active_domains":          len(h.publicDomains(ctx)),
+				"avg_delivery_latency_ms": today.AvgLatency.Milliseconds(),
+				"updated_at":              time.Now().UTC(),
+			})
+			h.publicStats.body = body
+			h.publicStats.expires = time.Now().Add(ttl)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(h.cfg.PublicStatsCacheSecs))
+	w.Write(h.publicStats.body)
+	w.Write([]byte("\n"))
+}
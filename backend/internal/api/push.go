@@ -0,0 +1,79 @@
+package api
+
+import (
+	"cattymail/internal/email"
+	"cattymail/internal/notify"
+	"cattymail/internal/redisstore"
+	"cattymail/internal/webpush"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// getVAPIDPublicKey returns the applicationServerKey browsers subscribe with
+func (h *Handler) getVAPIDPublicKey(w http.ResponseWriter, r *http.Request) {
+	vapid, err := notify.VAPID(r.Context(), h.cfg, h.store)
+	if err != nil {
+		http.Error(w, "Push notifications unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"public_key": vapid.PublicKey,
+	})
+}
+
+// addPushSubscription stores a browser PushSubscription for an inbox
+func (h *Handler) addPushSubscription(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+
+	var sub webpush.Subscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&sub); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := sub.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	raw, _ := json.Marshal(sub)
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	err := h.store.SavePushSubscription(r.Context(), d, chi.URLParam(r, "local"), sub.Endpoint, raw)
+	if errors.Is(err, redisstore.ErrTooManySubscriptions) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// deletePushSubscription unsubscribes a browser from an inbox. The body
+// only needs the endpoint.
+func (h *Handler) deletePushSubscription(w http.ResponseWriter, r *http.Request) {
+	var sub webpush.Subscription
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&sub); err != nil || sub.Endpoint == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	removed, err := h.store.DeletePushSubscription(r.Context(), d, chi.URLParam(r, "local"), sub.Endpoint)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cattymail/internal/domain"
+)
+
+// Rate limiter behaviour while Redis fails (RATE_LIMIT_ON_ERROR)
+const (
+	rateOnErrorLocal  = "local"  // fall back to a per-instance in-memory limiter
+	rateOnErrorOpen   = "open"   // let every request through
+	rateOnErrorClosed = "closed" // reject every limited request
+)
+
+// rateBreaker stops sending rate limit checks to Redis after
+// RATE_LIMIT_BREAKER_FAILURES consecutive errors, for
+// RATE_LIMIT_BREAKER_SECONDS, so a struggling store isn't hammered by
+// every request. Decisions are taken by the fallback meanwhile.
+type rateBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+
+	// Metrics, reported on /healthz
+	trips       atomic.Int64
+	redisErrors atomic.Int64
+	fallbacks   atomic.Int64 // decisions taken without Redis
+	rejected    atomic.Int64 // requests refused while failing closed
+
+	local rateLocal
+}
+
+// allowRedis reports whether to try Redis; false while the breaker is open
+func (b *rateBreaker) allowRedis() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+// record notes the outcome of a Redis check and trips the breaker after
+// maxFailures consecutive errors
+func (b *rateBreaker) record(err error, maxFailures int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.redisErrors.Add(1)
+	b.failures++
+	if maxFailures > 0 && b.failures >= maxFailures {
+		b.failures = 0
+		b.openUntil = time.Now().Add(cooldown)
+		b.trips.Add(1)
+		log.Printf("Rate limiter: Redis failing (%v), not using it for %s", err, cooldown)
+	}
+}
+
+// state is "closed" (healthy, using Redis) or "open"
+func (b *rateBreaker) state() string {
+	if b.allowRedis() {
+		return "closed"
+	}
+	return "open"
+}
+
+// stats are the breaker metrics
+func (b *rateBreaker) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"breaker":         b.state(),
+		"trips":           b.trips.Load(),
+		"redis_errors":    b.redisErrors.Load(),
+		"fallback_checks": b.fallbacks.Load(),
+		"rejected":        b.rejected.Load(),
+	}
+}
+
+// rateLocal is an in-memory token bucket limiter. Limits are per instance,
+// so with several API instances clients get that many times the budget
+// while it is in use.
+type rateLocal struct {
+	mu      sync.Mutex
+	buckets map[string]*localBucket
+	swept   time.Time
+}
+
+type localBucket struct {
+	tokens float64
+	ts     time.Time
+	window time.Duration
+}
+
+// localSweepEvery bounds how often idle buckets are dropped
+const localSweepEvery = time.Minute
+
+// take mirrors redisstore.TakeRateToken
+func (l *rateLocal) take(action, key string, p domain.RatePolicy) (bool, time.Duration) {
+	window := time.Duration(p.WindowSecs) * time.Second
+	if p.Limit <= 0 {
+		return false, window
+	}
+	capacity := float64(p.Limit + p.Burst)
+	rate := float64(p.Limit) / float64(window)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*localBucket)
+	}
+	if now.Sub(l.swept) > localSweepEvery {
+		// A bucket idle for its whole window is full again; drop it
+		for k, b := range l.buckets {
+			if now.Sub(b.ts) > b.window {
+				delete(l.buckets, k)
+			}
+		}
+		l.swept = now
+	}
+
+	id := action + ":" + key
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &localBucket{tokens: capacity, ts: now, window: window}
+		l.buckets[id] = b
+	}
+	b.tokens += float64(now.Sub(b.ts)) * rate
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.ts = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rate)
+	}
+	b.tokens--
+	return true, 0
+}
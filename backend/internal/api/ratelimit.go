@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ratePolicyRefresh is how long policies and exemptions saved by admins are
+// cached, so edits take effect on every instance within that time
+const ratePolicyRefresh = 10 * time.Second
+
+// headerRateKey carries a partner API key exempted from rate limits
+const headerRateKey = "X-API-Key"
+
+// ratePolicies caches the effective policy of every action (the configured
+// ones, see config.RatePolicies, overridden by those saved in Redis) and
+// the exemptions
+type ratePolicies struct {
+	mu         sync.Mutex
+	policies   map[string]domain.RatePolicy
+	exemptNets []rateExemptNet
+	exemptKeys map[string]int // key hash -> multiplier
+	proxies    []*net.IPNet   // RATE_LIMIT_TRUSTED_PROXIES
+	loaded     time.Time
+}
+
+type rateExemptNet struct {
+	net        *net.IPNet
+	multiplier int
+}
+
+// refreshRatePolicies reloads the saved policies and exemptions when
+// they are stale. Called with c.mu held.
+func (h *Handler) refreshRatePolicies(ctx context.Context) {
+	c := &h.ratePolicies
+	if c.policies != nil && time.Since(c.loaded) < ratePolicyRefresh {
+		return
+	}
+	saved, err := h.store.GetRatePolicies(ctx)
+	var exemptions []*domain.RateExemption
+	if err == nil {
+		exemptions, err = h.store.ListRateExemptions(ctx)
+	}
+	if err != nil && c.policies != nil {
+		// Keep the last known state until Redis is back
+		log.Printf("Failed to load rate limit policies: %v", err)
+		c.loaded = time.Now()
+		return
+	}
+
+	c.policies = h.cfg.RatePolicies()
+	c.proxies = nil
+	for _, s := range h.cfg.RateExemptProxies {
+		if n := parseIPOrCIDR(s); n != nil {
+			c.proxies = append(c.proxies, n)
+		}
+	}
+	for action, p := range saved {
+		c.policies[action] = p
+	}
+	c.exemptNets, c.exemptKeys = nil, make(map[string]int)
+	for _, ex := range exemptions {
+		switch ex.Kind {
+		case "key":
+			c.exemptKeys[ex.KeyHash] = ex.Multiplier
+		case "ip":
+			if n := parseIPOrCIDR(ex.Value); n != nil {
+				c.exemptNets = append(c.exemptNets, rateExemptNet{net: n, multiplier: ex.Multiplier})
+			}
+		}
+	}
+	c.loaded = time.Now()
+}
+
+// ratePolicy returns the policy of action for r, adjusted for its
+// exemption if any. ok is false when the request is not limited.
+func (h *Handler) ratePolicy(r *http.Request, action string) (p domain.RatePolicy, ok bool) {
+	c := &h.ratePolicies
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h.refreshRatePolicies(r.Context())
+
+	if p, ok = c.policies[action]; !ok {
+		return p, false
+	}
+	mult := 1
+	if key := r.Header.Get(headerRateKey); key != "" {
+		if m, found := c.exemptKeys[redisstore.RateKeyHash(key)]; found {
+			mult = m
+		}
+	}
+	if mult == 1 {
+		if ip := c.exemptionIP(r); ip != nil {
+			for _, ex := range c.exemptNets {
+				if ex.net.Contains(ip) {
+					mult = ex.multiplier
+					break
+				}
+			}
+		}
+	}
+	if mult == 0 {
+		return p, false
+	}
+	p.Limit *= mult
+	p.Burst *= mult
+	return p, true
+}
+
+// exemptionIP returns the address IP exemptions are matched against: the
+// peer of the connection, or the client it forwards for when the peer is a
+// trusted proxy. Forwarded headers from anyone else are ignored, since an
+// exemption can lift the limits entirely. Called with c.mu held.
+func (c *ratePolicies) exemptionIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil {
+		return nil
+	}
+	for _, n := range c.proxies {
+		if n.Contains(peer) {
+			return net.ParseIP(clientIP(r))
+		}
+	}
+	return peer
+}
+
+// parseIPOrCIDR parses an IP address (as a single-address network) or a
+// CIDR range
+func parseIPOrCIDR(s string) *net.IPNet {
+	s = strings.TrimSpace(s)
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+// rateLimit limits the routes it wraps by the policy of action, per client
+// IP. Paid plans and exempted clients get proportionally more headroom.
+func (h *Handler) rateLimit(action string) func(http.Handler) http.Handler {
+	return h.rateLimitBy(action, clientIP)
+}
+
+// inboxRateLimit limits the routes it wraps by the policy of action, per
+// inbox (the {domain} and {local} route parameters) whatever the client,
+// so one widely shared address can't be read into the ground
+func (h *Handler) inboxRateLimit(action string) func(http.Handler) http.Handler {
+	return h.rateLimitBy(action, func(r *http.Request) string {
+		return inboxRateKey(email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local"))
+	})
+}
+
+func inboxRateKey(emailDomain, local string) string {
+	return emailDomain + ":" + local
+}
+
+// allowInbox charges the "inbox" policy of local@emailDomain for routes
+// that only learn the inbox once the message is loaded. It writes the 429
+// and returns false when the inbox is over its limit.
+func (h *Handler) allowInbox(w http.ResponseWriter, r *http.Request, emailDomain, local string) bool {
+	return h.allowRate(w, r, "inbox", inboxRateKey(emailDomain, local))
+}
+
+// rateLimitBy limits by the policy of action per key(r)
+func (h *Handler) rateLimitBy(action string, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.allowRate(w, r, action, key(r)) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allowRate takes a token of action for key. Rejected requests get a 429
+// with Retry-After and false.
+func (h *Handler) allowRate(w http.ResponseWriter, r *http.Request, action, key string) bool {
+	p, ok := h.ratePolicy(r, action)
+	if !ok {
+		return true
+	}
+	if mult := h.planFor(sessionFrom(r.Context())).RateMultiplier; mult > 1 {
+		p.Limit *= mult
+		p.Burst *= mult
+	}
+
+	if allowed, wait := h.takeRateToken(r.Context(), action, key, p); !allowed {
+		secs := int((wait + time.Second - 1) / time.Second)
+		if secs < 1 {
+			secs = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// takeRateToken takes a token from Redis, or decides per RATE_LIMIT_ON_ERROR
+// when Redis fails or the breaker is open. When refused, it returns how
+// long until a token is available.
+func (h *Handler) takeRateToken(ctx context.Context, action, key string, p domain.RatePolicy) (bool, time.Duration) {
+	b := &h.rateBreaker
+	if b.allowRedis() {
+		allowed, wait, err := h.store.TakeRateToken(ctx, action, key, p)
+		b.record(err, h.cfg.RateLimitBreakerFails, time.Duration(h.cfg.RateLimitBreakerSecs)*time.Second)
+		if err == nil {
+			return allowed, wait
+		}
+	}
+
+	b.fallbacks.Add(1)
+	switch h.cfg.RateLimitOnError {
+	case rateOnErrorOpen:
+		return true, 0
+	case rateOnErrorClosed:
+		b.rejected.Add(1)
+		return false, time.Duration(h.cfg.RateLimitBreakerSecs) * time.Second
+	}
+	return b.local.take(action, key, p)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cattymail/internal/email"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxReadCleanMinutes caps the auto-clean delay at a week; longer
+	// delays outlive most inboxes anyway
+	maxReadCleanMinutes = 7 * 24 * 60
+	// maxMarkRead caps how many messages one request marks read
+	maxMarkRead = 100
+)
+
+// getReadClean returns the auto-clean delay of an inbox; zero is off
+func (h *Handler) getReadClean(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	after, err := h.store.ReadCleanAfter(r.Context(), d, local)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"minutes": int(after / time.Minute),
+	})
+}
+
+// setReadClean makes an inbox delete messages some minutes after they
+// are read; zero turns it off. Messages read earlier are not affected.
+func (h *Handler) setReadClean(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	var req struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Minutes < 0 || req.Minutes > maxReadCleanMinutes {
+		http.Error(w, fmt.Sprintf("minutes must be between 0 and %d", maxReadCleanMinutes), http.StatusBadRequest)
+		return
+	}
+
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	if err := h.store.SetReadCleanAfter(r.Context(), d, local, time.Duration(req.Minutes)*time.Minute); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"minutes": req.Minutes,
+	})
+}
+
+// markRead flags messages of an inbox as read, which also schedules their
+// deletion when the inbox has auto-clean on. IDs of other inboxes are
+// ignored.
+func (h *Handler) markRead(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.inboxTarget(w, r); !ok {
+		return
+	}
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxMarkRead {
+		http.Error(w, fmt.Sprintf("ids must list 1 to %d messages", maxMarkRead), http.StatusBadRequest)
+		return
+	}
+
+	d, local := email.CanonicalDomain(chi.URLParam(r, "domain")), chi.URLParam(r, "local")
+	ids := make([]string, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		msg, err := h.store.GetMessage(r.Context(), id)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if msg != nil && msg.Domain == d && msg.Local == local {
+			ids = append(ids, id)
+		}
+	}
+	if err := h.store.MarkRead(r.Context(), d, local, ids...); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"marked": len(ids),
+	})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	// requestLogQueue bounds entries waiting to be written; when Redis
+	// falls behind, entries are dropped rather than slowing requests
+	requestLogQueue = 1024
+	// requestLogErrorBytes is how much of an error response body is kept
+	requestLogErrorBytes = 256
+)
+
+// requestLogger writes access/error entries to the Redis request log in
+// the background for the admin log viewer
+type requestLogger struct {
+	h       *Handler
+	entries chan *domain.LogEntry
+}
+
+func newRequestLogger(h *Handler) *requestLogger {
+	l := &requestLogger{h: h, entries: make(chan *domain.LogEntry, requestLogQueue)}
+	go l.run()
+	return l
+}
+
+func (l *requestLogger) run() {
+	for e := range l.entries {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := l.h.store.AppendLog(ctx, e, int64(l.h.cfg.RequestLogMax)); err != nil {
+			log.Printf("request log: %v", err)
+		}
+		cancel()
+	}
+}
+
+// middleware records every request except health probes
+func (l *requestLogger) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch unversionedPath(r.URL.Path) {
+		case "/api/healthz", "/api/readyz":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		body := &cappedBuffer{max: requestLogErrorBytes}
+		ww.Tee(body)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		e := &domain.LogEntry{
+			Level:      "info",
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     status,
+			DurationMs: time.Since(start).Milliseconds(),
+			IP:         clientIP(r),
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			e.Route = rctx.RoutePattern()
+			if local := rctx.URLParam("local"); local != "" && privacy.Enabled() {
+				e.Path = strings.Replace(e.Path, "/"+local, "/"+privacy.Local(local), 1)
+			}
+		}
+		switch {
+		case status >= 500:
+			e.Level = "error"
+		case status >= 400:
+			e.Level = "warn"
+		}
+		if status >= 400 {
+			e.Error = privacy.Text(strings.TrimSpace(string(body.buf)))
+		}
+
+		select {
+		case l.entries <- e:
+		default:
+		}
+	})
+}
+
+// cappedBuffer keeps the first max bytes written to it
+type cappedBuffer struct {
+	buf []byte
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room > 0 {
+		if len(p) > room {
+			b.buf = append(b.buf, p[:room]...)
+		} else {
+			b.buf = append(b.buf, p...)
+		}
+	}
+	return len(p), nil
+}
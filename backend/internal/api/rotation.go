@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sort"
+
+	"cattymail/internal/domain"
+)
+
+// Domain rotation strategies, see domain.DomainRotation
+const (
+	rotateWeighted    = "weighted"
+	rotateLeastLoaded = "least_loaded"
+	rotateNewest      = "newest"
+)
+
+// domainRotation returns the rotation saved by admins, or the configured
+// strategy with equal weights
+func (h *Handler) domainRotation(ctx context.Context) *domain.DomainRotation {
+	rot, err := h.store.GetDomainRotation(ctx)
+	if err != nil {
+		log.Printf("Failed to load domain rotation: %v", err)
+	}
+	if rot == nil {
+		rot = &domain.DomainRotation{Strategy: h.cfg.DomainRotation}
+	}
+	return rot
+}
+
+// pickDomain chooses the domain of an address created without one. Only
+// public domains with a positive weight are candidates; if every domain
+// is weighted out, the first public domain is used.
+func (h *Handler) pickDomain(ctx context.Context) string {
+	domains := h.publicDomains(ctx)
+	if len(domains) == 0 {
+		return ""
+	}
+	rot := h.domainRotation(ctx)
+
+	var candidates []string
+	weights := make(map[string]int, len(domains))
+	for _, d := range domains {
+		weight, ok := rot.Weights[d]
+		if !ok {
+			weight = 1
+		}
+		if weight > 0 {
+			candidates = append(candidates, d)
+			weights[d] = weight
+		}
+	}
+	if len(candidates) == 0 {
+		return domains[0]
+	}
+
+	var picked string
+	switch rot.Strategy {
+	case rotateLeastLoaded:
+		load, err := h.store.DomainLoad(ctx, candidates)
+		if err != nil {
+			log.Printf("Failed to load domain usage: %v", err)
+			picked = pickWeighted(candidates, weights)
+			break
+		}
+		// Shuffle first so ties spread out
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		picked = candidates[0]
+		for _, d := range candidates[1:] {
+			if load[d] < load[picked] {
+				picked = d
+			}
+		}
+	case rotateNewest:
+		added, err := h.store.DomainsAddedAt(ctx)
+		if err != nil {
+			log.Printf("Failed to load domain ages: %v", err)
+		}
+		// Static domains have no time and keep their configured order
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return added[candidates[i]].After(added[candidates[j]])
+		})
+		picked = candidates[0]
+	default:
+		picked = pickWeighted(candidates, weights)
+	}
+
+	if err := h.store.RecordDomainAssigned(ctx, picked); err != nil {
+		log.Printf("Failed to record domain assignment: %v", err)
+	}
+	return picked
+}
+
+// pickWeighted picks a domain at random, proportionally to its weight
+func pickWeighted(domains []string, weights map[string]int) string {
+	total := 0
+	for _, d := range domains {
+		total += weights[d]
+	}
+	n := rand.Intn(total)
+	for _, d := range domains {
+		if n -= weights[d]; n < 0 {
+			return d
+		}
+	}
+	return domains[len(domains)-1]
+}
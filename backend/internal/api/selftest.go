@@ -0,0 +1,63 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/oklog/ulid/v2"
+)
+
+// selfTest injects a synthetic message straight into an inbox so users and
+// support can confirm delivery end-to-end (store, SSE, polling) without
+// waiting for an external sender.
+func (h *Handler) selfTest(w http.ResponseWriter, r *http.Request) {
+	domainParam := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	if !h.isValidDomain(r.Context(), domainParam) {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+	local, err := email.NormalizeLocal(chi.URLParam(r, "local"))
+	if err != nil {
+		http.Error(w, "Invalid address", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	text := fmt.Sprintf("This is a self-test message generated at %s.\n\n"+
+		"If you can read this, your inbox %s@%s is receiving mail.",
+		now.UTC().Format(time.RFC1123), local, domainParam)
+
+	msg := &domain.Message{
+		ID:         ulid.Make().String(),
+		Domain:     domainParam,
+		Local:      local,
+		OriginalTo: fmt.Sprintf("%s@%s", local, domainParam),
+		From:       fmt.Sprintf("\"CattyMail Self-Test\" <selftest@%s>", domainParam),
+		Subject:    "CattyMail self-test",
+		Date:       now,
+		Text:       text,
+		Size:       len(text),
+	}
+
+	if err := h.store.SaveMessage(r.Context(), msg); err != nil {
+		if errors.Is(err, redisstore.ErrQuotaExceeded) {
+			http.Error(w, quotaMessage(err), http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, "Failed to deliver test message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "delivered",
+		"id":     msg.ID,
+	})
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"cattymail/internal/billing"
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Anonymous sessions: the client holds a signed, opaque session ID either
+// as a cookie or in the X-Session-Token header (for cross-origin clients
+// that cannot rely on cookies). Nothing about the user is stored besides
+// the addresses they created.
+const (
+	sessionCookie = "catty_session"
+	sessionHeader = "X-Session-Token"
+)
+
+type ctxKey int
+
+const sessionCtxKey ctxKey = iota
+
+// sessionSigner signs and verifies session tokens
+type sessionSigner struct {
+	secret []byte
+}
+
+func newSessionSigner(secret string) *sessionSigner {
+	key := []byte(secret)
+	if secret == "" {
+		// Sessions will not survive a restart, same as admin tokens
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	// Derive a dedicated key so session tokens can never pass as JWTs
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("cattymail session"))
+	return &sessionSigner{secret: mac.Sum(nil)}
+}
+
+func (s *sessionSigner) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *sessionSigner) verify(token string) (string, bool) {
+	id, _, ok := strings.Cut(token, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(s.sign(id)), []byte(token)) {
+		return "", false
+	}
+	return id, true
+}
+
+func newSessionID() string {
+	b := make([]byte, 18)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// session is the caller's resolved session. AccountID and Plan are set
+// when the session is logged into an account.
+type session struct {
+	ID        string
+	AccountID string
+	Plan      string
+}
+
+// planFor returns the limits the caller is entitled to
+func (h *Handler) planFor(sess *session) billing.Plan {
+	if sess == nil || sess.AccountID == "" {
+		return billing.PlanFor(h.cfg, billing.PlanAnonymous)
+	}
+	return billing.PlanFor(h.cfg, sess.Plan)
+}
+
+// addressSet returns the session ID addresses are grouped under along with
+// the lifetime and address cap of the caller's plan
+func (h *Handler) addressSet(sess *session) (string, time.Duration, int) {
+	plan := h.planFor(sess)
+	if sess != nil && sess.AccountID != "" {
+		return redisstore.AccountSession(sess.AccountID), plan.TTL, plan.MaxAddresses
+	}
+	var id string
+	if sess != nil {
+		id = sess.ID
+	}
+	return id, plan.TTL, plan.MaxAddresses
+}
+
+// sessionMiddleware resolves the caller's session, if any, and keeps its
+// addresses alive while the client is active
+func (h *Handler) sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(sessionHeader)
+		if token == "" {
+			if c, err := r.Cookie(sessionCookie); err == nil {
+				token = c.Value
+			}
+		}
+
+		if id, ok := h.sessions.verify(token); ok {
+			sess := &session{ID: id}
+			if h.cfg.AccountsEnabled {
+				accountID, err := h.store.SessionAccount(r.Context(), id)
+				if err != nil {
+					log.Printf("session: failed to resolve account for %s: %v", id, err)
+				}
+				sess.AccountID = accountID
+				if accountID != "" {
+					if sess.Plan, err = h.store.AccountPlan(r.Context(), accountID); err != nil {
+						log.Printf("session: failed to resolve plan for %s: %v", accountID, err)
+					}
+				}
+			}
+
+			set, ttl, _ := h.addressSet(sess)
+			if err := h.store.TouchSession(r.Context(), set, ttl); err != nil {
+				log.Printf("session: failed to extend %s: %v", set, err)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), sessionCtxKey, sess))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func sessionFrom(ctx context.Context) *session {
+	sess, _ := ctx.Value(sessionCtxKey).(*session)
+	return sess
+}
+
+// startSession issues a new session to the caller
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request) *session {
+	sess := &session{ID: newSessionID()}
+	token := h.sessions.sign(sess.ID)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   h.cfg.AccountTTLSeconds,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set(sessionHeader, token)
+	return sess
+}
+
+// checkAddressQuota rejects the request when the caller's session already
+// holds as many live addresses as its tier allows
+func (h *Handler) checkAddressQuota(w http.ResponseWriter, r *http.Request) bool {
+	set, _, max := h.addressSet(sessionFrom(r.Context()))
+	if set == "" || max <= 0 {
+		return true
+	}
+
+	n, err := h.store.CountSessionAddresses(r.Context(), set)
+	if err != nil || int(n) < max {
+		return true
+	}
+	// The count may include expired members; prune before refusing
+	live, err := h.store.SessionAddresses(r.Context(), set)
+	if err != nil || len(live) < max {
+		return true
+	}
+
+	http.Error(w, "Address limit reached", http.StatusForbidden)
+	return false
+}
+
+// bindToSession attaches a freshly created address to the caller's
+// session, starting a new one when the caller has none. Addresses created
+// while logged in take the account TTL and are recorded in its history.
+func (h *Handler) bindToSession(w http.ResponseWriter, r *http.Request, d, local string) {
+	sess := sessionFrom(r.Context())
+	if sess == nil {
+		sess = h.startSession(w, r)
+	}
+
+	set, ttl, _ := h.addressSet(sess)
+	if err := h.store.AddSessionAddress(r.Context(), set, d, local, ttl); err != nil {
+		log.Printf("session: failed to bind %s: %v", privacy.Inbox(local, d), err)
+	}
+	if sess.AccountID == "" {
+		return
+	}
+
+	if err := h.store.ExtendAddress(r.Context(), d, local, ttl); err != nil {
+		log.Printf("session: failed to extend %s: %v", privacy.Inbox(local, d), err)
+	}
+	if err := h.store.RecordAddressHistory(r.Context(), sess.AccountID, d, local); err != nil {
+		log.Printf("session: failed to record history for %s: %v", privacy.Inbox(local, d), err)
+	}
+}
+
+// getMyAddresses lists the live addresses bound to the caller's session
+func (h *Handler) getMyAddresses(w http.ResponseWriter, r *http.Request) {
+	set, _, _ := h.addressSet(sessionFrom(r.Context()))
+	if set == "" {
+		http.Error(w, "No session", http.StatusUnauthorized)
+		return
+	}
+
+	addresses, err := h.sessionAddresses(r.Context(), set)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"addresses": addresses,
+	})
+}
+
+// sessionAddresses lists the live addresses in a session's address set
+func (h *Handler) sessionAddresses(ctx context.Context, set string) ([]domain.Address, error) {
+	bound, err := h.store.SessionAddresses(ctx, set)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]domain.Address, 0, len(bound))
+	for _, a := range bound {
+		addr := domain.Address{
+			Email:  a.Local + "@" + a.Domain,
+			Local:  a.Local,
+			Domain: a.Domain,
+		}
+		if a.TTL > 0 {
+			addr.ExpiresAt = time.Now().Add(a.TTL)
+		}
+		if display := email.DisplayDomain(a.Domain); display != a.Domain {
+			addr.DisplayDomain = display
+		}
+		addresses = append(addresses, addr)
+	}
+	return addresses, nil
+}
+
+// forgetMyAddress removes an address from the caller's session
+func (h *Handler) forgetMyAddress(w http.ResponseWriter, r *http.Request) {
+	set, _, _ := h.addressSet(sessionFrom(r.Context()))
+	if set == "" {
+		http.Error(w, "No session", http.StatusUnauthorized)
+		return
+	}
+
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	local := chi.URLParam(r, "local")
+	if err := h.store.RemoveSessionAddress(r.Context(), set, d, local); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// domainMeta returns the metadata of every public domain, filling fields
+// admins left empty with defaults
+func (h *Handler) domainMeta(ctx context.Context) []*domain.DomainMeta {
+	stored, err := h.store.ListDomainMeta(ctx)
+	if err != nil {
+		stored = nil
+	}
+
+	domains := h.publicDomains(ctx)
+	metas := make([]*domain.DomainMeta, 0, len(domains))
+	for _, d := range domains {
+		meta := domain.DomainMeta{Domain: d}
+		if s, ok := stored[d]; ok {
+			meta = *s
+		}
+		display := email.DisplayDomain(d)
+		if meta.Title == "" {
+			meta.Title = fmt.Sprintf("Disposable email @%s", display)
+		}
+		if meta.Description == "" {
+			meta.Description = fmt.Sprintf("Free temporary inboxes at @%s. Receive mail instantly, no sign-up, deleted automatically.", display)
+		}
+		if meta.CanonicalURL == "" {
+			meta.CanonicalURL = "https://" + d + "/"
+		}
+		metas = append(metas, &meta)
+	}
+	return metas
+}
+
+func (h *Handler) getDomainMeta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domains": h.domainMeta(r.Context()),
+	})
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// getSitemap lists the canonical landing page of each indexed public domain
+func (h *Handler) getSitemap(w http.ResponseWriter, r *http.Request) {
+	set := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, meta := range h.domainMeta(r.Context()) {
+		if meta.NoIndex {
+			continue
+		}
+		u := sitemapURL{Loc: meta.CanonicalURL}
+		if !meta.UpdatedAt.IsZero() {
+			u.LastMod = meta.UpdatedAt.UTC().Format("2006-01-02")
+		}
+		set.URLs = append(set.URLs, u)
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(set)
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// reportSpam quarantines a message. The report only counts against its
+// sender and trains the antispam model once an admin confirms it, so
+// anonymous reports can't skew either.
+func (h *Handler) reportSpam(w http.ResponseWriter, r *http.Request) {
+	msg, err := h.store.ReportSpam(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to quarantine message", http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "quarantined",
+	})
+}
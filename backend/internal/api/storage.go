@@ -0,0 +1,34 @@
+package api
+
+import (
+	"cattymail/internal/email"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// quotaMessage renders a redisstore.ErrQuotaExceeded for API clients
+func quotaMessage(err error) string {
+	return strings.TrimPrefix(err.Error(), "redisstore: ")
+}
+
+// getInboxUsage reports the bytes an inbox stores against its quota
+func (h *Handler) getInboxUsage(w http.ResponseWriter, r *http.Request) {
+	domainParam := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	localParam := chi.URLParam(r, "local")
+
+	used, err := h.store.InboxUsage(r.Context(), domainParam, localParam)
+	if err != nil {
+		http.Error(w, "Failed to fetch usage", http.StatusInternalServerError)
+		return
+	}
+	quota, _ := h.store.StorageQuotas()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bytes":       used,
+		"quota_bytes": quota, // 0 when unlimited
+	})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"cattymail/internal/email"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// createAccessToken issues a fresh inbox access token, replacing any
+// previous one. Mail clients use it as the POP3 password; feeds and REST
+// Hooks accept it as a bearer token or ?token=.
+func (h *Handler) createAccessToken(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.inboxTarget(w, r)
+	if !ok {
+		return
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	if err := h.store.SetInboxToken(r.Context(), d, chi.URLParam(r, "local"), token); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"username": target,
+		"token":    token,
+	}
+	if h.cfg.POP3Addr != "" {
+		resp["pop3"] = map[string]interface{}{
+			"addr": h.cfg.POP3Addr,
+			"tls":  h.cfg.POP3TLSCert != "",
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"cattymail/internal/unsubscribe"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// unsubscribeMessage unsubscribes the inbox from the list that sent a
+// message, using its List-Unsubscribe header. Each message is acted on
+// once, and never for refused or burn-after-reading inboxes.
+func (h *Handler) unsubscribeMessage(w http.ResponseWriter, r *http.Request) {
+	msg, ok := h.viewableMessage(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	first, err := h.store.ClaimUnsubscribe(r.Context(), msg.ID)
+	if err != nil {
+		http.Error(w, "Failed to record unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	if !first {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "already_unsubscribed",
+		})
+		return
+	}
+
+	res, err := h.unsubscriber.Unsubscribe(r.Context(), msg)
+	if err != nil {
+		if rerr := h.store.ReleaseUnsubscribe(r.Context(), msg.ID); rerr != nil {
+			log.Printf("Failed to clear unsubscribe mark of %s: %v", msg.ID, rerr)
+		}
+		if errors.Is(err, unsubscribe.ErrNoMethod) {
+			http.Error(w, "Message has no one-click or mailto unsubscribe", http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Failed to unsubscribe message %s: %v", msg.ID, err)
+		http.Error(w, "Unsubscribe request failed", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "unsubscribed",
+		"method": res.Method,
+		"target": res.Target,
+	})
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Bring-your-own domains: a logged-in account connects a domain, proves
+// ownership with a TXT record and points MX at the service. Only the owning
+// account may create addresses on it.
+const (
+	verifyRecordPrefix = "_cattymail."
+	verifyValuePrefix  = "cattymail-verify="
+)
+
+// dnsRecord is one record the user has to publish
+type dnsRecord struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+type userDomainResponse struct {
+	*domain.UserDomain
+	DisplayDomain string      `json:"display_domain,omitempty"`
+	Records       []dnsRecord `json:"records"`
+}
+
+// accountFrom returns the logged-in account ID or writes a 401
+func accountFrom(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sess := sessionFrom(r.Context())
+	if sess == nil || sess.AccountID == "" {
+		http.Error(w, "Login required", http.StatusUnauthorized)
+		return "", false
+	}
+	return sess.AccountID, true
+}
+
+// mxHosts returns the hosts user domains should point MX at: MX_HOST when
+// configured, otherwise whatever the first system domain uses
+func (h *Handler) mxHosts(ctx context.Context) []*net.MX {
+	if h.cfg.MXHost != "" {
+		return []*net.MX{{Host: h.cfg.MXHost, Pref: 10}}
+	}
+	if len(h.cfg.AllowedDomains) == 0 {
+		return nil
+	}
+	mxs, err := net.DefaultResolver.LookupMX(ctx, h.cfg.AllowedDomains[0])
+	if err != nil {
+		return nil
+	}
+	return mxs
+}
+
+// describeUserDomain attaches the DNS records needed to finish setup
+func (h *Handler) describeUserDomain(ctx context.Context, ud *domain.UserDomain) userDomainResponse {
+	resp := userDomainResponse{UserDomain: ud}
+	if display := email.DisplayDomain(ud.Domain); display != ud.Domain {
+		resp.DisplayDomain = display
+	}
+
+	resp.Records = append(resp.Records, dnsRecord{
+		Type:  "TXT",
+		Name:  verifyRecordPrefix + ud.Domain,
+		Value: verifyValuePrefix + ud.Token,
+	})
+	for _, mx := range h.mxHosts(ctx) {
+		resp.Records = append(resp.Records, dnsRecord{
+			Type:     "MX",
+			Name:     ud.Domain,
+			Value:    strings.TrimSuffix(mx.Host, "."),
+			Priority: int(mx.Pref),
+		})
+	}
+	return resp
+}
+
+// ownsVerifiedDomain reports whether the caller's account may create
+// addresses on d
+func (h *Handler) ownsVerifiedDomain(ctx context.Context, d string) bool {
+	sess := sessionFrom(ctx)
+	if sess == nil || sess.AccountID == "" {
+		return false
+	}
+	ud, err := h.store.GetUserDomain(ctx, d)
+	return err == nil && ud != nil && ud.Verified && ud.AccountID == sess.AccountID
+}
+
+// isSystemDomain reports whether d is served for everyone
+func (h *Handler) isSystemDomain(ctx context.Context, d string) bool {
+	for _, allowed := range h.cfg.AllowedDomains {
+		if d == allowed {
+			return true
+		}
+	}
+	dynamicDomains, _ := h.store.GetDomains(ctx)
+	for _, allowed := range dynamicDomains {
+		if d == email.CanonicalDomain(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// getMyDomains lists the caller's connected domains
+func (h *Handler) getMyDomains(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountFrom(w, r)
+	if !ok {
+		return
+	}
+
+	domains, err := h.store.ListAccountDomains(r.Context(), accountID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]userDomainResponse, 0, len(domains))
+	for _, ud := range domains {
+		resp = append(resp, h.describeUserDomain(r.Context(), ud))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domains": resp,
+	})
+}
+
+// addMyDomain connects a domain and returns the DNS records to publish
+func (h *Handler) addMyDomain(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountFrom(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	d, err := email.NormalizeDomain(req.Domain)
+	if err != nil || !strings.Contains(d, ".") {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+	if h.isSystemDomain(r.Context(), d) {
+		http.Error(w, "Domain already served", http.StatusConflict)
+		return
+	}
+
+	existing, err := h.store.ListAccountDomains(r.Context(), accountID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= h.planFor(sessionFrom(r.Context())).MaxDomains {
+		http.Error(w, "Domain limit reached", http.StatusForbidden)
+		return
+	}
+
+	token := make([]byte, 16)
+	rand.Read(token)
+	ud := &domain.UserDomain{
+		Domain:    d,
+		AccountID: accountID,
+		Token:     hex.EncodeToString(token),
+		CreatedAt: time.Now(),
+	}
+	err = h.store.ClaimUserDomain(r.Context(), ud)
+	if errors.Is(err, redisstore.ErrDomainClaimed) {
+		http.Error(w, "Domain already claimed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(h.describeUserDomain(r.Context(), ud))
+}
+
+// verifyMyDomain checks the TXT record and opens the domain for mail
+func (h *Handler) verifyMyDomain(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountFrom(w, r)
+	if !ok {
+		return
+	}
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	ud, err := h.store.GetUserDomain(r.Context(), d)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if ud == nil || ud.AccountID != accountID {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	if !ud.Verified {
+		txts, err := net.DefaultResolver.LookupTXT(r.Context(), verifyRecordPrefix+d)
+		found := false
+		for _, txt := range txts {
+			if strings.TrimSpace(txt) == verifyValuePrefix+ud.Token {
+				found = true
+				break
+			}
+		}
+		if err != nil || !found {
+			http.Error(w, "Verification record not found", http.StatusPreconditionFailed)
+			return
+		}
+
+		if err := h.store.MarkUserDomainVerified(r.Context(), d); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		ud.Verified = true
+		ud.VerifiedAt = time.Now()
+	}
+
+	// MX is informational: mail may be routed through forwarding instead
+	mxOK := false
+	if mxs, err := net.DefaultResolver.LookupMX(r.Context(), d); err == nil {
+		want := h.mxHosts(r.Context())
+		for _, mx := range mxs {
+			for _, host := range want {
+				if strings.EqualFold(strings.TrimSuffix(mx.Host, "."), strings.TrimSuffix(host.Host, ".")) {
+					mxOK = true
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain": h.describeUserDomain(r.Context(), ud),
+		"mx_ok":  mxOK,
+	})
+}
+
+// deleteMyDomain disconnects a domain; addresses on it stop receiving mail
+func (h *Handler) deleteMyDomain(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := accountFrom(w, r)
+	if !ok {
+		return
+	}
+
+	d := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	ud, err := h.store.GetUserDomain(r.Context(), d)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if ud == nil || ud.AccountID != accountID {
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.store.DeleteUserDomain(r.Context(), accountID, d); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
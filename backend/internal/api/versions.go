@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API versions. Every endpoint is registered once (see routes) and
+// mounted twice:
+//
+//	/api/...     v0, the original unversioned API used by the frontend.
+//	             Responses carry Deprecation/Sunset/Link headers.
+//	/api/v1/...  v1: errors are JSON objects and inbox listings return
+//	             message summaries instead of full messages.
+//
+// Handlers whose response shape changed branch on apiVersion(r); the rest
+// are shared as-is and get v1 errors through structuredErrors.
+const (
+	apiV0 = 0
+	apiV1 = 1
+)
+
+type apiVersionKey struct{}
+
+// withVersion tags requests with the API version they were routed under
+func withVersion(v int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, v)))
+		})
+	}
+}
+
+// apiVersion returns the API version of r, v0 when untagged
+func apiVersion(r *http.Request) int {
+	v, _ := r.Context().Value(apiVersionKey{}).(int)
+	return v
+}
+
+// unversionedPath maps /api/v1/x to /api/x
+func unversionedPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/api/v1/"); ok {
+		return "/api/" + rest
+	}
+	return path
+}
+
+// deprecated marks responses of the unversioned API as deprecated and
+// points clients at the v1 equivalent
+func (h *Handler) deprecated(next http.Handler) http.Handler {
+	var sunset string
+	if t, err := time.Parse(time.RFC3339, h.cfg.APIV0Sunset); err == nil {
+		sunset = t.UTC().Format(http.TimeFormat)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
+		}
+		successor := "/api/v1" + strings.TrimPrefix(r.URL.Path, "/api")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiError is the v1 error body
+type apiError struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// structuredErrors rewrites plain-text error responses (http.Error) into
+// apiError JSON so handlers shared between versions need no changes.
+// Responses that already are JSON pass through untouched.
+func structuredErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &errorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// errorWriter buffers the body of plain-text error responses
+type errorWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	capture     bool
+	body        bytes.Buffer
+}
+
+func (ew *errorWriter) WriteHeader(status int) {
+	if ew.wroteHeader {
+		return
+	}
+	ew.wroteHeader = true
+	ew.status = status
+	ct := ew.Header().Get("Content-Type")
+	if status >= 400 && (ct == "" || strings.HasPrefix(ct, "text/plain")) {
+		ew.capture = true
+		return
+	}
+	ew.ResponseWriter.WriteHeader(status)
+}
+
+func (ew *errorWriter) Write(b []byte) (int, error) {
+	if !ew.wroteHeader {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.capture {
+		return ew.body.Write(b)
+	}
+	return ew.ResponseWriter.Write(b)
+}
+
+// Flush keeps SSE endpoints working behind the wrapper
+func (ew *errorWriter) Flush() {
+	if ew.capture {
+		return
+	}
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (ew *errorWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+func (ew *errorWriter) finish() {
+	if !ew.capture {
+		return
+	}
+	var body apiError
+	body.Error.Status = ew.status
+	body.Error.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(ew.status)), " ", "_")
+	body.Error.Message = strings.TrimSpace(ew.body.String())
+
+	h := ew.ResponseWriter.Header()
+	h.Set("Content-Type", "application/json")
+	h.Del("X-Content-Type-Options")
+	ew.ResponseWriter.WriteHeader(ew.status)
+	json.NewEncoder(ew.ResponseWriter).Encode(body)
+}
+
+// messageSummary is a message without its bodies, as listed by v1 inboxes
+type messageSummary struct {
+	ID          string    `json:"id"`
+	Domain      string    `json:"domain"`
+	Local       string    `json:"local"`
+	OriginalTo  string    `json:"original_to"`
+	From        string    `json:"from"`
+	FromName    string    `json:"from_name,omitempty"`
+	FromAddress string    `json:"from_address,omitempty"`
+	Subject     string    `json:"subject"`
+	Date        time.Time `json:"date"`
+	Size        int       `json:"size,omitempty"`
+	HasHTML     bool      `json:"has_html"`
+	Preview     string    `json:"preview"`
+	DNSBL       []string  `json:"dnsbl,omitempty"` // blocklists naming the sending relay
+	SpamScore   *float64  `json:"spam_score,omitempty"`
+}
+
+func summarize(msg *domain.Message) messageSummary {
+	return messageSummary{
+		ID:          msg.ID,
+		Domain:      msg.Domain,
+		Local:       msg.Local,
+		OriginalTo:  msg.OriginalTo,
+		From:        msg.From,
+		FromName:    msg.FromName,
+		FromAddress: msg.FromAddress,
+		Subject:     msg.Subject,
+		Date:        msg.Date,
+		Size:        msg.Size,
+		HasHTML:     msg.HTML != "",
+		Preview:     textPreview(msg.Text, 140),
+		DNSBL:       msg.DNSBL,
+		SpamScore:   msg.SpamScore,
+	}
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 60 * time.Second
+	// waitBacklog bounds how many already stored messages are checked
+	// against since
+	waitBacklog = 50
+)
+
+// waitInbox long-polls for clients that cannot keep an SSE stream open.
+// It answers at once with messages received after ?since= (unix seconds or
+// RFC 3339, default now), otherwise blocks until one arrives or ?timeout=
+// seconds pass, answering with an empty list on timeout.
+func (h *Handler) waitInbox(w http.ResponseWriter, r *http.Request) {
+	domainParam := email.CanonicalDomain(chi.URLParam(r, "domain"))
+	localParam := chi.URLParam(r, "local")
+
+	since := time.Now()
+	if v := r.URL.Query().Get("since"); v != "" {
+		var ok bool
+		if since, ok = parseAsOf(v); !ok {
+			http.Error(w, "since must be unix seconds or RFC 3339", http.StatusBadRequest)
+			return
+		}
+	}
+	timeout := defaultWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			http.Error(w, "timeout must be a number of seconds", http.StatusBadRequest)
+			return
+		}
+		timeout = min(time.Duration(secs)*time.Second, maxWaitTimeout)
+	}
+
+	// Subscribe before looking at the inbox so nothing saved in between
+	// is missed
+	pubsub := h.store.Subscribe(r.Context(), domainParam, localParam)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(r.Context()); err != nil {
+		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
+		return
+	}
+
+	msgs, err := h.store.GetInbox(r.Context(), domainParam, localParam, waitBacklog, 0)
+	if err == nil {
+		msgs, err = h.burnSafe(r.Context(), domainParam, localParam, msgs)
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch inbox", http.StatusInternalServerError)
+		return
+	}
+	var newer []*domain.Message
+	for _, msg := range msgs {
+		if h.store.InboxTime(msg).After(since) {
+			newer = append(newer, msg)
+		}
+	}
+	if len(newer) > 0 || timeout == 0 {
+		writeInbox(w, r, newer)
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			writeInbox(w, r, nil)
+			return
+		case note, ok := <-ch:
+			if !ok {
+				writeInbox(w, r, nil)
+				return
+			}
+			// The payload is the ID of the message just saved
+			msg, err := h.store.GetMessage(r.Context(), note.Payload)
+			if err != nil {
+				http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+				return
+			}
+			if msg != nil {
+				msgs, err := h.burnSafe(r.Context(), domainParam, localParam, []*domain.Message{msg})
+				if err != nil {
+					http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+					return
+				}
+				writeInbox(w, r, msgs)
+				return
+			}
+		}
+	}
+}
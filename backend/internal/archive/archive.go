@@ -0,0 +1,247 @@
+// Package archive copies messages to object storage shortly before their
+// Redis TTL runs out, so abuse reports can still be investigated after the
+// fact. Each pass writes gzip-compressed JSONL objects grouped by the UTC
+// day the messages expire: <YYYY-MM-DD>/<ulid>.jsonl.gz.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	dayLayout = "2006-01-02"
+	// step is the expiry window read from Redis at a time
+	step = time.Minute
+	// MaxSearchDays bounds the days a search reads
+	MaxSearchDays = 31
+)
+
+// Archiver writes expiring messages to a Bucket
+type Archiver struct {
+	store    *redisstore.Store
+	bucket   Bucket
+	interval time.Duration
+}
+
+// New returns an archiver for ARCHIVE_URL, or nil when archiving is off
+func New(cfg *config.Config, store *redisstore.Store) (*Archiver, error) {
+	bucket, err := OpenBucket(cfg)
+	if err != nil || bucket == nil {
+		return nil, err
+	}
+	interval := time.Duration(cfg.ArchiveIntervalSecs) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &Archiver{store: store, bucket: bucket, interval: interval}, nil
+}
+
+// Run archives on every interval until ctx is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		if err := a.pass(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("archive: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pass archives messages expiring before the pass after next, so each is
+// copied at least one interval ahead of its expiry
+func (a *Archiver) pass(ctx context.Context) error {
+	ok, err := a.store.AcquireArchiveLock(ctx, a.interval)
+	if err != nil || !ok {
+		return err // Another process is archiving
+	}
+
+	now := time.Now()
+	target := now.Add(2 * a.interval).Truncate(step)
+	cursor, err := a.store.ArchiveCursor(ctx)
+	if err != nil {
+		return err
+	}
+	if cursor.Before(now) {
+		// Anything expiring before now is already gone
+		cursor = now.Truncate(step)
+	}
+
+	for cursor.Before(target) {
+		next := cursor.Add(a.interval).Truncate(step)
+		if !next.After(cursor) {
+			next = cursor.Add(step)
+		}
+		if next.After(target) {
+			next = target
+		}
+		// Keep each object within one expiry day
+		if midnight := cursor.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour); next.After(midnight) {
+			next = midnight
+		}
+		msgs, err := a.store.ExpiringMessages(ctx, cursor, next)
+		if err != nil {
+			return err
+		}
+		if err := a.write(ctx, cursor, msgs); err != nil {
+			return err
+		}
+		if err := a.store.SetArchiveCursor(ctx, next); err != nil {
+			return err
+		}
+		cursor = next
+	}
+	return nil
+}
+
+// write stores msgs, expiring after from, grouped by expiry day
+func (a *Archiver) write(ctx context.Context, from time.Time, msgs []*domain.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, msg := range msgs {
+		if err := enc.Encode(msg); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s/%s.jsonl.gz", from.UTC().Format(dayLayout), strings.ToLower(ulid.Make().String()))
+	if err := a.bucket.Put(ctx, key, buf.Bytes()); err != nil {
+		return err
+	}
+	log.Printf("archive: wrote %d messages to %s", len(msgs), key)
+	return nil
+}
+
+// Query selects archived messages. Empty fields match everything; Text
+// matches the subject and bodies case-insensitively.
+type Query struct {
+	From, To time.Time // expiry days, inclusive
+	ID       string
+	Address  string // recipient local@domain
+	Sender   string
+	Text     string
+	Limit    int
+}
+
+func (q *Query) match(msg *domain.Message) bool {
+	if q.ID != "" && msg.ID != q.ID {
+		return false
+	}
+	if q.Address != "" && !strings.EqualFold(msg.Local+"@"+msg.Domain, q.Address) {
+		return false
+	}
+	if q.Sender != "" && !strings.Contains(strings.ToLower(msg.From), strings.ToLower(q.Sender)) {
+		return false
+	}
+	if q.Text != "" {
+		t := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(msg.Subject), t) &&
+			!strings.Contains(strings.ToLower(msg.Text), t) &&
+			!strings.Contains(strings.ToLower(msg.HTML), t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Search scans the archive objects of the queried days
+func (a *Archiver) Search(ctx context.Context, q Query) ([]*domain.Message, error) {
+	from, to := q.From.UTC().Truncate(24*time.Hour), q.To.UTC().Truncate(24*time.Hour)
+	if to.Before(from) {
+		return nil, errors.New("archive: search ends before it starts")
+	}
+	if to.Sub(from) >= MaxSearchDays*24*time.Hour {
+		return nil, fmt.Errorf("archive: search at most %d days at once", MaxSearchDays)
+	}
+
+	var found []*domain.Message
+	for day := from; !day.After(to); day = day.Add(24 * time.Hour) {
+		keys, err := a.bucket.List(ctx, day.Format(dayLayout)+"/")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			done, err := a.scan(ctx, key, func(msg *domain.Message) bool {
+				if q.match(msg) {
+					found = append(found, msg)
+				}
+				return q.Limit > 0 && len(found) >= q.Limit
+			})
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return found, nil
+			}
+		}
+	}
+	return found, nil
+}
+
+// scan calls fn with every message of an object until fn returns true,
+// reporting whether it did
+func (a *Archiver) scan(ctx context.Context, key string, fn func(*domain.Message) bool) (bool, error) {
+	data, err := a.bucket.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("archive: %s: %w", key, err)
+	}
+	sc := bufio.NewScanner(gz)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var msg domain.Message
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			continue
+		}
+		if fn(&msg) {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
+
+// Restore puts an archived message back into its inbox with a fresh TTL.
+// It returns nil when no message with id expired on day.
+func (a *Archiver) Restore(ctx context.Context, day time.Time, id string) (*domain.Message, error) {
+	found, err := a.Search(ctx, Query{From: day, To: day, ID: id, Limit: 1})
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	msg := found[0]
+	if err := a.store.SaveMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ParseDay parses a YYYY-MM-DD search day
+func ParseDay(s string) (time.Time, error) {
+	return time.Parse(dayLayout, s)
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cattymail/internal/config"
+)
+
+// ErrNotFound is returned by Bucket.Get for missing objects
+var ErrNotFound = errors.New("archive: object not found")
+
+// Bucket is the object storage archives are written to. Keys use "/" as
+// separator.
+type Bucket interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the keys starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// OpenBucket returns the bucket named by ARCHIVE_URL: file:///path for a
+// local (or mounted) directory, s3://bucket/prefix for S3-compatible
+// storage. It returns nil when ARCHIVE_URL is empty.
+func OpenBucket(cfg *config.Config) (Bucket, error) {
+	if cfg.ArchiveURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.ArchiveURL)
+	if err != nil {
+		return nil, fmt.Errorf("archive: invalid ARCHIVE_URL: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		return &DirBucket{Root: u.Path}, nil
+	case "s3":
+		return NewS3Bucket(S3Config{
+			Endpoint:  cfg.ArchiveS3Endpoint,
+			Region:    cfg.ArchiveS3Region,
+			Bucket:    u.Host,
+			Prefix:    strings.TrimPrefix(u.Path, "/"),
+			AccessKey: cfg.ArchiveS3AccessKey,
+			SecretKey: cfg.ArchiveS3SecretKey,
+		})
+	}
+	return nil, fmt.Errorf("archive: unsupported ARCHIVE_URL scheme %q", u.Scheme)
+}
+
+// DirBucket stores objects as files under Root
+type DirBucket struct {
+	Root string
+}
+
+func (b *DirBucket) path(key string) string {
+	return filepath.Join(b.Root, filepath.FromSlash(key))
+}
+
+func (b *DirBucket) Put(ctx context.Context, key string, data []byte) error {
+	p := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return err
+	}
+	// Write then rename so readers never see a partial object
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (b *DirBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (b *DirBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(b.Root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(b.Root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
@@ -0,0 +1,62 @@
+package billing
+
+import (
+	"cattymail/internal/config"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidSignature is returned for webhooks that fail verification
+var ErrInvalidSignature = errors.New("billing: invalid webhook signature")
+
+// ErrUnsupportedPlan is returned when no checkout exists for a plan
+var ErrUnsupportedPlan = errors.New("billing: plan not available for purchase")
+
+// EventType is the subscription change carried by a webhook
+type EventType string
+
+const (
+	// EventActivated moves an account onto a paid plan
+	EventActivated EventType = "activated"
+	// EventCanceled returns an account to PlanFree
+	EventCanceled EventType = "canceled"
+	// EventIgnored is any provider event that does not change entitlements
+	EventIgnored EventType = "ignored"
+)
+
+// Event is a provider-neutral subscription change
+type Event struct {
+	ID             string
+	Type           EventType
+	AccountID      string
+	PlanID         string
+	CustomerID     string
+	SubscriptionID string
+	At             time.Time
+}
+
+// Provider abstracts the payment processor
+type Provider interface {
+	Name() string
+	// CheckoutURL returns where accountID should be sent to buy planID
+	CheckoutURL(accountID, planID string) (string, error)
+	// ParseWebhook verifies and decodes a webhook request
+	ParseWebhook(r *http.Request) (*Event, error)
+}
+
+// New returns the provider selected by BILLING_PROVIDER, or nil when
+// billing is disabled
+func New(cfg *config.Config) Provider {
+	switch cfg.BillingProvider {
+	case "stripe":
+		return &Stripe{
+			webhookSecret: []byte(cfg.BillingWebhookSecret),
+			checkoutURLs: map[string]string{
+				PlanPremium: cfg.BillingCheckoutURL,
+			},
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package billing
+
+import (
+	"cattymail/internal/config"
+	"time"
+)
+
+// Plan IDs. Anonymous callers have no account; registered accounts start
+// on PlanFree until a subscription moves them to PlanPremium.
+const (
+	PlanAnonymous = "anonymous"
+	PlanFree      = "free"
+	PlanPremium   = "premium"
+)
+
+// Plan is the set of limits a tier is entitled to
+type Plan struct {
+	ID           string        `json:"id"`
+	MaxAddresses int           `json:"max_addresses"`
+	TTL          time.Duration `json:"-"`
+	MaxDomains   int           `json:"max_domains"`
+	// RateMultiplier scales the per-minute rate limits
+	RateMultiplier int `json:"rate_multiplier"`
+}
+
+// Plans returns every plan with limits taken from cfg
+func Plans(cfg *config.Config) map[string]Plan {
+	return map[string]Plan{
+		PlanAnonymous: {
+			ID:             PlanAnonymous,
+			MaxAddresses:   cfg.AnonMaxAddresses,
+			TTL:            time.Duration(cfg.TTLSeconds) * time.Second,
+			RateMultiplier: 1,
+		},
+		PlanFree: {
+			ID:             PlanFree,
+			MaxAddresses:   cfg.AccountMaxAddresses,
+			TTL:            time.Duration(cfg.AccountTTLSeconds) * time.Second,
+			MaxDomains:     cfg.AccountMaxDomains,
+			RateMultiplier: 1,
+		},
+		PlanPremium: {
+			ID:             PlanPremium,
+			MaxAddresses:   cfg.PremiumMaxAddresses,
+			TTL:            time.Duration(cfg.PremiumTTLSeconds) * time.Second,
+			MaxDomains:     cfg.PremiumMaxDomains,
+			RateMultiplier: cfg.PremiumRateMultiplier,
+		},
+	}
+}
+
+// PlanFor resolves id, falling back to PlanFree for unknown or empty IDs
+func PlanFor(cfg *config.Config, id string) Plan {
+	plans := Plans(cfg)
+	if p, ok := plans[id]; ok {
+		return p
+	}
+	return plans[PlanFree]
+}
@@ -0,0 +1,176 @@
+// Package burned spots signs that a receiving domain has been blocked by a
+// mail provider or a site: bounces and rejections naming a provider, and a
+// sudden drop in incoming mail.
+package burned
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cattymail/internal/domain"
+)
+
+var (
+	// bounceSubject marks a delivery status notification
+	bounceSubject = regexp.MustCompile(`(?i)undeliver|delivery (status notification|failure|has failed)|returned mail|failure notice|mail delivery failed|non-delivery`)
+	// blockPhrase is how providers word a rejection on policy or reputation
+	// grounds, as opposed to a missing mailbox
+	blockPhrase = regexp.MustCompile(`(?i)\b5\.7\.(1|26|606|708)\b|block(ed|list)|blacklist|deny ?list|poor reputation|low reputation|spamhaus|policy reasons|TSS0[0-9]|S3150`)
+	// disposableWord and refusalWord together are a site refusing an
+	// address as throwaway
+	disposableWord = regexp.MustCompile(`(?i)\b(disposable|temporary|throwaway|burner)\s+(e-?mail|address|domain)`)
+	refusalWord    = regexp.MustCompile(`(?i)not (allowed|accepted|supported|permitted)|\b(blocked|rejected|refused)\b`)
+
+	// providers maps a hint in a bounce to the provider it names
+	providers = []struct{ hint, name string }{
+		{"spamhaus", "Spamhaus"},
+		{"gmail", "Gmail"},
+		{"google.com", "Gmail"},
+		{"outlook", "Outlook"},
+		{"hotmail", "Outlook"},
+		{"S3150", "Outlook"},
+		{"yahoo", "Yahoo"},
+		{"TSS0", "Yahoo"},
+		{"icloud", "iCloud"},
+		{"me.com", "iCloud"},
+		{"proton", "Proton"},
+		{"zoho", "Zoho"},
+		{"gmx", "GMX"},
+		{"mail.ru", "Mail.ru"},
+		{"yandex", "Yandex"},
+	}
+)
+
+// Match inspects a stored message for a block verdict against the domain
+// it was delivered to. ok is false when the message says nothing of the
+// sort.
+func Match(msg *domain.Message) (sig domain.BurnSignal, ok bool) {
+	body := msg.Subject + "\n" + msg.Text
+	if isBounce(msg) {
+		loc := blockPhrase.FindStringIndex(body)
+		if loc == nil {
+			return sig, false
+		}
+		return domain.BurnSignal{
+			Provider:  provider(body),
+			Reason:    "bounce: " + excerpt(body, loc[0]),
+			MessageID: msg.ID,
+		}, true
+	}
+	if loc := disposableWord.FindStringIndex(body); loc != nil && refusalWord.MatchString(body) {
+		name := senderDomain(msg.FromAddress)
+		if name == "" {
+			name = "unknown"
+		}
+		return domain.BurnSignal{
+			Provider:  name,
+			Reason:    "refused as disposable: " + excerpt(body, loc[0]),
+			MessageID: msg.ID,
+		}, true
+	}
+	return sig, false
+}
+
+func isBounce(msg *domain.Message) bool {
+	local := strings.ToLower(msg.FromAddress)
+	if i := strings.IndexByte(local, '@'); i >= 0 {
+		local = local[:i]
+	}
+	return local == "mailer-daemon" || local == "postmaster" || bounceSubject.MatchString(msg.Subject)
+}
+
+// provider names the first known provider mentioned in body
+func provider(body string) string {
+	lower := strings.ToLower(body)
+	for _, p := range providers {
+		if strings.Contains(lower, strings.ToLower(p.hint)) {
+			return p.name
+		}
+	}
+	return "unknown"
+}
+
+func senderDomain(address string) string {
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		return strings.ToLower(address[i+1:])
+	}
+	return ""
+}
+
+// excerpt is the line of body around offset i, shortened for display
+func excerpt(body string, i int) string {
+	start := strings.LastIndexByte(body[:i], '\n') + 1
+	end := strings.IndexByte(body[i:], '\n')
+	if end < 0 {
+		end = len(body)
+	} else {
+		end += i
+	}
+	line := strings.TrimSpace(body[start:end])
+	if r := []rune(line); len(r) > 160 {
+		line = string(r[:160]) + "…"
+	}
+	return line
+}
+
+// Volume drop detection. Hourly ingest counts of a domain are compared
+// over the last RecentHours against the average of the same span across
+// the rest of the history.
+const (
+	RecentHours = 6
+	// MinBaseline is the average messages per RecentHours below which a
+	// domain is too quiet to judge
+	MinBaseline = 30
+	// DropThreshold is the fraction of the baseline that must be missing
+	DropThreshold = 0.8
+)
+
+// Drop returns by how much (0-1) the recent volume fell short of the
+// baseline, given hourly counts oldest first, and whether that is
+// suspicious. Histories too short for a baseline never are.
+func Drop(hourly []int64) (float64, bool) {
+	if len(hourly) < 4*RecentHours {
+		return 0, false
+	}
+	split := len(hourly) - RecentHours
+	var past, recent int64
+	for i, n := range hourly {
+		if i < split {
+			past += n
+		} else {
+			recent += n
+		}
+	}
+	baseline := float64(past) * RecentHours / float64(split)
+	if baseline < MinBaseline {
+		return 0, false
+	}
+	drop := 1 - float64(recent)/baseline
+	if drop < 0 {
+		drop = 0
+	}
+	return drop, drop >= DropThreshold
+}
+
+// Verdict summarises the signals of a domain for operators, or "" when
+// nothing points at a block
+func Verdict(signals []domain.BurnSignal, drop float64, dropped bool) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, s := range signals {
+		if s.Provider != "unknown" && !seen[s.Provider] {
+			seen[s.Provider] = true
+			names = append(names, s.Provider)
+		}
+	}
+	switch {
+	case len(names) > 0:
+		return "possibly blocked by " + strings.Join(names, ", ")
+	case len(signals) > 0:
+		return "possibly blocked (bounces mention a block)"
+	case dropped:
+		return fmt.Sprintf("possibly blocked (incoming mail down %.0f%%)", drop*100)
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrInjected is the error of an injected failure
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Faults describes the faults injected into one target
+type Faults struct {
+	Target     string
+	LatencyMax time.Duration
+	ErrorPct   float64
+	DropPct    float64
+	ResetPct   float64
+}
+
+// FromEnv reads the faults of target (e.g. "redis") from the
+// CHAOS_<TARGET>_* variables and logs them
+func FromEnv(target string) Faults {
+	prefix := "CHAOS_" + strings.ToUpper(target) + "_"
+	pct := func(name string) float64 {
+		v, _ := strconv.ParseFloat(os.Getenv(prefix+name), 64)
+		return v
+	}
+	ms, _ := strconv.Atoi(os.Getenv(prefix + "LATENCY_MS"))
+	f := Faults{
+		Target:     target,
+		LatencyMax: time.Duration(ms) * time.Millisecond,
+		ErrorPct:   pct("ERROR_PCT"),
+		DropPct:    pct("DROP_PCT"),
+		ResetPct:   pct("RESET_PCT"),
+	}
+	log.Printf("chaos: %s", f)
+	return f
+}
+
+func (f Faults) String() string {
+	return fmt.Sprintf("%s faults: latency up to %s, %.1f%% errors, %.1f%% dropped pipelines, %.1f%% resets",
+		f.Target, f.LatencyMax, f.ErrorPct, f.DropPct, f.ResetPct)
+}
+
+// Hit reports whether a fault with probability pct percent happens now
+func Hit(pct float64) bool {
+	return pct > 0 && rand.Float64()*100 < pct
+}
+
+// Delay sleeps for a random time up to LatencyMax, or until ctx is done
+func (f Faults) Delay(ctx context.Context) error {
+	if f.LatencyMax <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(time.Duration(rand.Int63n(int64(f.LatencyMax)))):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WrapConn returns conn with latency and connection resets injected into
+// its reads and writes
+func (f Faults) WrapConn(conn net.Conn) net.Conn {
+	if f.LatencyMax <= 0 && f.ResetPct <= 0 {
+		return conn
+	}
+	return &faultyConn{Conn: conn, faults: f}
+}
+
+type faultyConn struct {
+	net.Conn
+	faults Faults
+}
+
+func (c *faultyConn) Read(p []byte) (int, error) {
+	if err := c.inject("read"); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *faultyConn) Write(p []byte) (int, error) {
+	if err := c.inject("write"); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+// inject delays the operation and may reset the connection
+func (c *faultyConn) inject(op string) error {
+	c.faults.Delay(context.Background())
+	if !Hit(c.faults.ResetPct) {
+		return nil
+	}
+	c.Conn.Close()
+	return &net.OpError{Op: op, Net: "tcp", Addr: c.RemoteAddr(), Err: syscall.ECONNRESET}
+}
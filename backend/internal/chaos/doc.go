@@ -0,0 +1,16 @@
+// Package chaos injects faults (random latency, failed commands, dropped
+// pipelines and connection resets) into the Redis and IMAP clients, to
+// check the retry, dead letter and circuit breaker paths in staging.
+//
+// It only exists in binaries built with -tags chaos; regular builds
+// compile the hooks out entirely. Faults are configured per target with
+// environment variables, e.g. for Redis:
+//
+//	CHAOS_REDIS_LATENCY_MS   upper bound of the random delay added to each operation
+//	CHAOS_REDIS_ERROR_PCT    percentage of commands failing
+//	CHAOS_REDIS_DROP_PCT     percentage of pipelines failing as a whole
+//	CHAOS_REDIS_RESET_PCT    percentage of socket reads and writes resetting the connection
+//
+// and the same with CHAOS_IMAP_ for the IMAP client, where ERROR_PCT fails
+// dials.
+package chaos
@@ -1,61 +1,336 @@
 package config
 
 import (
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"log"
 	"os"
 	"strconv"
 	"strings"
 )
 
 type Config struct {
+	Environment           string // "production" enables Validate's checks
 	RedisURL              string
+	RedisDomainURLs       map[string]string // domain -> Redis URL for data residency
+	RedisReplicaURL       string
+	ShadowWrites          bool   // also write every message to ShadowStoreURL
+	ShadowStoreURL        string // second message store being dark-launched
 	IMAPHost              string
 	IMAPPort              int
 	IMAPUser              string
 	IMAPPass              string
 	AllowedDomains        []string
+	DomainRotation        string // default strategy for addresses created without a domain
 	TTLSeconds            int
+	PinWindowSecs         int // codes arriving this soon after address creation are pinned, 0 disables
+	InboxMaxMessages      int
+	InboxOrder            string // "received" (ingest time) or "date" (Date header)
+	InboxQuotaBytes       int    // bytes stored per inbox, 0 for unlimited
+	DomainQuotaBytes      int    // bytes stored per domain, 0 for unlimited
+	ArchiveURL            string // file:///dir or s3://bucket/prefix; empty disables archiving
+	ArchiveS3Endpoint     string
+	ArchiveS3Region       string
+	ArchiveS3AccessKey    string
+	ArchiveS3SecretKey    string
+	ArchiveIntervalSecs   int
+	MessageCodec          string
+	MessageGzipThreshold  int
+	RedisMemoryLimitBytes int // used when Redis has no maxmemory
+	MemoryDegradedPct     int // percent of memory limit
+	MemoryPausedPct       int
+	DegradedMaxBytes      int
+	DegradedTTLSeconds    int
 	PollSeconds           int
+	PollBatchSize         int // messages taken per folder per cycle, 0 for unlimited
+	IMAPFetchChunk        int // UIDs per IMAP FETCH command
 	MaxEmailBytes         int
+	SkippedSampleMax      int // skipped messages kept for the admin panel, 0 disables
+	DNSBLZones            []string
+	DNSBLTimeoutMs        int
+	DNSBLTrustedRelays    []string // CIDRs of forwarding hops to look past
+	LinkProxy             bool     // route preview links through /api/l/{token}
+	LinkBlocklists        []string // domain blocklist zones checked before redirecting
+	AntispamEnabled       bool     // score ingests with the model trained from spam reports
+	ClamdAddr             string   // host:port of clamd; empty disables malware scanning
+	ScanTimeoutMs         int
+	ScanAction            string // "strip" infected parts or "quarantine" the message
 	RateLimitCreatePerMin int
 	RateLimitFetchPerMin  int
+	RateLimitReportPerMin int
+	RateLimitInboxPerMin  int                          // reads of one inbox by all clients, 0 for unlimited
+	RateLimits            map[string]domain.RatePolicy // per-action overrides, see ratelimits.go
+	RateLimitOnError      string                       // "local", "open" or "closed" while Redis fails
+	RateLimitBreakerFails int                          // consecutive Redis errors that trip the breaker
+	RateLimitBreakerSecs  int
+	RateExemptProxies     []string // CIDRs of proxies whose forwarded client IP is matched against IP exemptions
 	LogLevel              string
+	RequestLogMax         int    // entries kept in the Redis request log, 0 disables it
+	FreezeCooldownHours   int    // how long a local stays uncreatable after a freeze is lifted
+	LogRedact             bool   // scrub addresses, credentials and codes from log lines
+	PrivacyMode           string // "off", "mask" or "hash" addresses in logs and token admin views
+	PrivacySalt           string // keys the hashes of PRIVACY_MODE=hash, defaults to JWT_SECRET
 	ExpiredWeb            string
+	APIV0Sunset           string // RFC 3339 date the unversioned /api routes go away
 	AdminPassword         string
-	JWTSecret             string
+	JWTSecret             string // initial signing key, see admin.AuthService
+	JWTRotationGraceSecs  int    // how long tokens signed with a rotated-out key stay valid
+	CORSOrigins           []string
+	WebAuthnRPID          string // domain admin passkeys are bound to, empty disables them
+	WebAuthnOrigins       []string
+	MigrateDryRun         bool
+	DebugAddr             string
+	AnonMaxAddresses      int
+	AccountsEnabled       bool
+	AccountTTLSeconds     int
+	AccountMaxAddresses   int
+	AccountMaxDomains     int
+	MXHost                string
+	PremiumMaxAddresses   int
+	PremiumTTLSeconds     int
+	PremiumMaxDomains     int
+	PremiumRateMultiplier int
+	BillingProvider       string
+	BillingWebhookSecret  string
+	BillingCheckoutURL    string
+	NotifyMaxPerMin       int
+	AlertIntervalSeconds  int
+	SMTPAddr              string // host:port for alert emails
+	SMTPUser              string
+	SMTPPass              string
+	SMTPFrom              string
+	TelegramBotToken      string
+	VAPIDPublicKey        string
+	VAPIDPrivateKey       string
+	VAPIDSubject          string
+	POP3Addr              string
+	POP3TLSCert           string
+	POP3TLSKey            string
+	ThumbnailChromePath   string
+	ThumbnailChromeFlags  string
+	ThumbnailOnIngest     bool
+	ThumbnailWidth        int
+	ThumbnailConcurrency  int
+	RenderCacheSize       int  // sanitized previews kept in memory, 0 disables
+	DemoMode              bool // seed fake messages instead of polling IMAP
+	DemoIntervalSeconds   int
+	PublicStats           bool // serve GET /api/stats/public
+	PublicStatsCacheSecs  int
+
+	// settings records where each value came from, for Print
+	settings []setting
 }
 
+// Load reads the configuration from the environment and, when CONFIG_FILE
+// is set, from that file. Invalid configuration is fatal.
 func Load() *Config {
-	return &Config{
-		RedisURL:              getEnv("REDIS_URL", "redis://localhost:6379/0"),
-		IMAPHost:              getEnv("IMAP_HOST", "imap.gmail.com"),
-		IMAPPort:              getEnvInt("IMAP_PORT", 993),
-		IMAPUser:              getEnv("IMAP_USER", "ananda.nampung@gmail.com"),
-		IMAPPass:              getEnv("IMAP_PASS", "pbslvxbkgqnhczmo"),
-		AllowedDomains:        strings.Split(getEnv("ALLOWED_DOMAINS", "catty.my.id,cattyprems.top"), ","),
-		TTLSeconds:            getEnvInt("TTL_SECONDS", 86400),
-		PollSeconds:           getEnvInt("POLL_SECONDS", 20),
-		MaxEmailBytes:         getEnvInt("MAX_EMAIL_BYTES", 5242880), // 5MB
-		RateLimitCreatePerMin: getEnvInt("RATE_LIMIT_CREATE_PER_MIN", 10),
-		RateLimitFetchPerMin:  getEnvInt("RATE_LIMIT_FETCH_PER_MIN", 60),
-		LogLevel:              getEnv("LOG_LEVEL", "info"),
-		ExpiredWeb:            getEnv("EXPIRED_WEB", ""),
-		AdminPassword:         getEnv("ADMIN_PASSWORD", "0401"),
-		JWTSecret:             getEnv("JWT_SECRET", ""),
-	}
-}
-
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
+	cfg, err := LoadFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+// LoadFile reads the configuration from path (skipped when empty) with
+// environment variables taking precedence, see file.go
+func LoadFile(path string) (*Config, error) {
+	l, err := newLoader(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Environment:           l.getEnv("APP_ENV", "development"),
+		RedisURL:              l.getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		RedisDomainURLs:       l.getEnvDomainMap("REDIS_DOMAIN_URLS"),
+		RedisReplicaURL:       l.getEnv("REDIS_REPLICA_URL", ""),
+		ShadowWrites:          l.getEnvBool("SHADOW_WRITES", false),
+		ShadowStoreURL:        l.getEnv("SHADOW_STORE_URL", ""),
+		IMAPHost:              l.getEnv("IMAP_HOST", "imap.gmail.com"),
+		IMAPPort:              l.getEnvInt("IMAP_PORT", 993),
+		IMAPUser:              l.getEnv("IMAP_USER", ""),
+		IMAPPass:              l.getSecret("IMAP_PASS", ""),
+		AllowedDomains:        l.getEnvDomains("ALLOWED_DOMAINS", "catty.my.id,cattyprems.top"),
+		DomainRotation:        l.getEnvChoice("DOMAIN_ROTATION", "weighted", "least_loaded", "newest"),
+		TTLSeconds:            l.getEnvInt("TTL_SECONDS", 86400),
+		PinWindowSecs:         l.getEnvInt("PIN_WINDOW_SECONDS", 300),
+		InboxMaxMessages:      l.getEnvInt("INBOX_MAX_MESSAGES", 0),
+		InboxOrder:            l.getEnvChoice("INBOX_ORDER", "received", "date"),
+		InboxQuotaBytes:       l.getEnvInt("INBOX_QUOTA_BYTES", 0),
+		DomainQuotaBytes:      l.getEnvInt("DOMAIN_QUOTA_BYTES", 0),
+		ArchiveURL:            l.getEnv("ARCHIVE_URL", ""),
+		ArchiveS3Endpoint:     l.getEnv("ARCHIVE_S3_ENDPOINT", ""),
+		ArchiveS3Region:       l.getEnv("ARCHIVE_S3_REGION", "us-east-1"),
+		ArchiveS3AccessKey:    l.getEnv("ARCHIVE_S3_ACCESS_KEY", ""),
+		ArchiveS3SecretKey:    l.getEnv("ARCHIVE_S3_SECRET_KEY", ""),
+		ArchiveIntervalSecs:   l.getEnvInt("ARCHIVE_INTERVAL_SECONDS", 300),
+		MessageCodec:          l.getEnv("MESSAGE_CODEC", "json"),
+		MessageGzipThreshold:  l.getEnvInt("MESSAGE_GZIP_THRESHOLD", 0),
+		RedisMemoryLimitBytes: l.getEnvInt("REDIS_MEMORY_LIMIT_BYTES", 0),
+		MemoryDegradedPct:     l.getEnvInt("MEMORY_DEGRADED_PCT", 80),
+		MemoryPausedPct:       l.getEnvInt("MEMORY_PAUSED_PCT", 95),
+		DegradedMaxBytes:      l.getEnvInt("DEGRADED_MAX_BYTES", 262144), // 256KB
+		DegradedTTLSeconds:    l.getEnvInt("DEGRADED_TTL_SECONDS", 3600),
+		PollSeconds:           l.getEnvInt("POLL_SECONDS", 20),
+		PollBatchSize:         l.getEnvInt("POLL_BATCH_SIZE", 500),
+		IMAPFetchChunk:        l.getEnvInt("IMAP_FETCH_CHUNK", 50),
+		MaxEmailBytes:         l.getEnvInt("MAX_EMAIL_BYTES", 5242880), // 5MB
+		SkippedSampleMax:      l.getEnvInt("SKIPPED_SAMPLE_MAX", 200),
+		DNSBLZones:            l.getEnvList("DNSBL_ZONES", ""),
+		DNSBLTimeoutMs:        l.getEnvInt("DNSBL_TIMEOUT_MS", 1500),
+		DNSBLTrustedRelays:    l.getEnvList("DNSBL_TRUSTED_RELAYS", ""),
+		LinkProxy:             l.getEnvBool("LINK_PROXY", true),
+		LinkBlocklists:        l.getEnvList("LINK_BLOCKLISTS", ""),
+		AntispamEnabled:       l.getEnvBool("ANTISPAM_ENABLED", false),
+		ClamdAddr:             l.getEnv("CLAMD_ADDR", ""),
+		ScanTimeoutMs:         l.getEnvInt("SCAN_TIMEOUT_MS", 5000),
+		ScanAction:            l.getEnv("SCAN_ACTION", "strip"),
+		RateLimitCreatePerMin: l.getEnvInt("RATE_LIMIT_CREATE_PER_MIN", 10),
+		RateLimitFetchPerMin:  l.getEnvInt("RATE_LIMIT_FETCH_PER_MIN", 60),
+		RateLimitReportPerMin: l.getEnvInt("RATE_LIMIT_REPORT_PER_MIN", 10),
+		RateLimitInboxPerMin:  l.getEnvInt("RATE_LIMIT_INBOX_PER_MIN", 300),
+		RateLimits:            l.getEnvRatePolicies("RATE_LIMITS"),
+		RateLimitOnError:      l.getEnvChoice("RATE_LIMIT_ON_ERROR", "local", "open", "closed"),
+		RateLimitBreakerFails: l.getEnvInt("RATE_LIMIT_BREAKER_FAILURES", 5),
+		RateLimitBreakerSecs:  l.getEnvInt("RATE_LIMIT_BREAKER_SECONDS", 30),
+		RateExemptProxies:     l.getEnvList("RATE_LIMIT_TRUSTED_PROXIES", ""),
+		LogLevel:              l.getEnv("LOG_LEVEL", "info"),
+		RequestLogMax:         l.getEnvInt("REQUEST_LOG_MAX", 10000),
+		LogRedact:             l.getEnvBool("LOG_REDACT", true),
+		FreezeCooldownHours:   l.getEnvInt("FREEZE_COOLDOWN_HOURS", 720),
+		PrivacyMode:           l.getEnvChoice("PRIVACY_MODE", "off", "mask", "hash"),
+		PrivacySalt:           l.getSecret("PRIVACY_SALT", ""),
+		ExpiredWeb:            l.getEnv("EXPIRED_WEB", ""),
+		APIV0Sunset:           l.getEnv("API_V0_SUNSET", ""),
+		AdminPassword:         l.getSecret("ADMIN_PASSWORD", ""),
+		JWTSecret:             l.getSecret("JWT_SECRET", ""),
+		JWTRotationGraceSecs:  l.getEnvInt("JWT_ROTATION_GRACE_SECONDS", 86400),
+		CORSOrigins:           l.getEnvList("CORS_ORIGINS", "*"),
+		WebAuthnRPID:          l.getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnOrigins:       l.getEnvList("WEBAUTHN_ORIGINS", ""),
+		MigrateDryRun:         l.getEnvBool("MIGRATE_DRY_RUN", false),
+		DebugAddr:             l.getEnv("DEBUG_ADDR", ""),
+		AnonMaxAddresses:      l.getEnvInt("ANON_MAX_ADDRESSES", 10),
+		AccountsEnabled:       l.getEnvBool("ACCOUNTS_ENABLED", false),
+		AccountTTLSeconds:     l.getEnvInt("ACCOUNT_TTL_SECONDS", 7*86400),
+		AccountMaxAddresses:   l.getEnvInt("ACCOUNT_MAX_ADDRESSES", 50),
+		AccountMaxDomains:     l.getEnvInt("ACCOUNT_MAX_DOMAINS", 3),
+		MXHost:                l.getEnv("MX_HOST", ""),
+		PremiumMaxAddresses:   l.getEnvInt("PREMIUM_MAX_ADDRESSES", 200),
+		PremiumTTLSeconds:     l.getEnvInt("PREMIUM_TTL_SECONDS", 30*86400),
+		PremiumMaxDomains:     l.getEnvInt("PREMIUM_MAX_DOMAINS", 10),
+		PremiumRateMultiplier: l.getEnvInt("PREMIUM_RATE_MULTIPLIER", 5),
+		BillingProvider:       l.getEnv("BILLING_PROVIDER", ""),
+		BillingWebhookSecret:  l.getEnv("BILLING_WEBHOOK_SECRET", ""),
+		BillingCheckoutURL:    l.getEnv("BILLING_CHECKOUT_URL", ""),
+		NotifyMaxPerMin:       l.getEnvInt("NOTIFY_MAX_PER_MIN", 20),
+		AlertIntervalSeconds:  l.getEnvInt("ALERT_INTERVAL_SECONDS", 60),
+		SMTPAddr:              l.getEnv("SMTP_ADDR", ""),
+		SMTPUser:              l.getEnv("SMTP_USER", ""),
+		SMTPPass:              l.getEnv("SMTP_PASS", ""),
+		SMTPFrom:              l.getEnv("SMTP_FROM", ""),
+		TelegramBotToken:      l.getEnv("TELEGRAM_BOT_TOKEN", ""),
+		VAPIDPublicKey:        l.getEnv("VAPID_PUBLIC_KEY", ""),
+		VAPIDPrivateKey:       l.getEnv("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:          l.getEnv("VAPID_SUBJECT", ""),
+		POP3Addr:              l.getEnv("POP3_ADDR", ""),
+		POP3TLSCert:           l.getEnv("POP3_TLS_CERT", ""),
+		POP3TLSKey:            l.getEnv("POP3_TLS_KEY", ""),
+		ThumbnailChromePath:   l.getEnv("THUMBNAIL_CHROME_PATH", ""),
+		ThumbnailChromeFlags:  l.getEnv("THUMBNAIL_CHROME_FLAGS", ""),
+		ThumbnailOnIngest:     l.getEnvBool("THUMBNAIL_ON_INGEST", false),
+		ThumbnailWidth:        l.getEnvInt("THUMBNAIL_WIDTH", 320),
+		ThumbnailConcurrency:  l.getEnvInt("THUMBNAIL_CONCURRENCY", 2),
+		RenderCacheSize:       l.getEnvInt("RENDER_CACHE_SIZE", 256),
+		DemoMode:              l.getEnvBool("DEMO_MODE", false),
+		DemoIntervalSeconds:   l.getEnvInt("DEMO_INTERVAL_SECONDS", 30),
+		PublicStats:           l.getEnvBool("PUBLIC_STATS", false),
+		PublicStatsCacheSecs:  l.getEnvInt("PUBLIC_STATS_CACHE_SECONDS", 60),
+	}
+	if cfg.PrivacySalt == "" {
+		cfg.PrivacySalt = cfg.JWTSecret
+	}
+	if err := l.finish(); err != nil {
+		return nil, err
+	}
+	cfg.settings = l.settings
+	return cfg, nil
+}
+
+func (l *loader) getEnv(key, fallback string) string {
+	if value, src, ok := l.lookup(key); ok {
+		l.record(key, value, src)
 		return value
 	}
+	l.record(key, fallback, sourceDefault)
 	return fallback
 }
 
-func getEnvInt(key string, fallback int) int {
-	if value, ok := os.LookupEnv(key); ok {
+func (l *loader) getEnvInt(key string, fallback int) int {
+	if value, src, ok := l.lookup(key); ok {
 		if i, err := strconv.Atoi(value); err == nil {
+			l.record(key, value, src)
 			return i
 		}
+		l.invalid(key, value, src, "an integer")
+	}
+	l.record(key, strconv.Itoa(fallback), sourceDefault)
+	return fallback
+}
+
+func (l *loader) getEnvBool(key string, fallback bool) bool {
+	if value, src, ok := l.lookup(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			l.record(key, value, src)
+			return b
+		}
+		l.invalid(key, value, src, "a boolean")
+	}
+	l.record(key, strconv.FormatBool(fallback), sourceDefault)
+	return fallback
+}
+
+// getEnvChoice is getEnv for a value that must be fallback or one of others
+func (l *loader) getEnvChoice(key, fallback string, others ...string) string {
+	if value, src, ok := l.lookup(key); ok {
+		for _, choice := range append([]string{fallback}, others...) {
+			if value == choice {
+				l.record(key, value, src)
+				return value
+			}
+		}
+		l.invalid(key, value, src, "one of "+strings.Join(append([]string{fallback}, others...), ", "))
 	}
+	l.record(key, fallback, sourceDefault)
 	return fallback
 }
+
+func (l *loader) getEnvDomainMap(key string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(l.getEnv(key, ""), ",") {
+		d, value, ok := strings.Cut(pair, "=")
+		if d = email.CanonicalDomain(d); ok && d != "" && strings.TrimSpace(value) != "" {
+			m[d] = strings.TrimSpace(value)
+		}
+	}
+	return m
+}
+
+func (l *loader) getEnvDomains(key, fallback string) []string {
+	var domains []string
+	for _, d := range strings.Split(l.getEnv(key, fallback), ",") {
+		if d = email.CanonicalDomain(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+func (l *loader) getEnvList(key, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(l.getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
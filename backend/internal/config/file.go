@@ -0,0 +1,176 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config files are YAML with one top-level key per environment variable,
+// in lower case:
+//
+//	redis_url: redis://localhost:6379/0
+//	allowed_domains: [catty.my.id, cattyprems.top]
+//	redis_domain_urls: {catty.my.id: "redis://eu:6379/0"}
+//
+// Lists are joined with commas and maps become domain=value pairs, so
+// every key accepts exactly what its environment variable does. An
+// environment variable always wins over the file.
+
+type source string
+
+const (
+	sourceDefault source = "default"
+	sourceFile    source = "file"
+	sourceEnv     source = "env"
+)
+
+// setting is the effective value of one key and where it came from
+type setting struct {
+	key    string
+	value  string
+	source source
+}
+
+type loader struct {
+	path     string
+	file     map[string]string
+	used     map[string]bool
+	settings []setting
+	errs     []string
+	// Vault secret, read on first use (see secrets.go)
+	vaultData map[string]string
+}
+
+func newLoader(path string) (*loader, error) {
+	l := &loader{path: path, file: map[string]string{}, used: map[string]bool{}}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	for k, v := range raw {
+		value, err := fileValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s: %w", path, k, err)
+		}
+		l.file[strings.ToLower(k)] = value
+	}
+	return l, nil
+}
+
+// fileValue flattens a YAML value into its environment variable form
+func fileValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := fileValue(v[k])
+			if err != nil {
+				return "", err
+			}
+			pairs[i] = k + "=" + s
+		}
+		return strings.Join(pairs, ","), nil
+	case map[interface{}]interface{}:
+		return "", fmt.Errorf("map keys must be strings")
+	}
+	return fmt.Sprint(v), nil
+}
+
+// lookup returns the value of key from the environment, then the file
+func (l *loader) lookup(key string) (string, source, bool) {
+	fileKey := strings.ToLower(key)
+	l.used[fileKey] = true
+	if value, ok := os.LookupEnv(key); ok {
+		return value, sourceEnv, true
+	}
+	if value, ok := l.file[fileKey]; ok {
+		return value, sourceFile, true
+	}
+	return "", sourceDefault, false
+}
+
+func (l *loader) record(key, value string, src source) {
+	l.settings = append(l.settings, setting{key: key, value: value, source: src})
+}
+
+func (l *loader) invalid(key, value string, src source, want string) {
+	name := key
+	if src == sourceFile {
+		name = strings.ToLower(key) + " in " + l.path
+	}
+	l.errs = append(l.errs, fmt.Sprintf("%s: %q is not %s", name, value, want))
+}
+
+// finish reports invalid values and file keys no setting reads
+func (l *loader) finish() error {
+	for k := range l.file {
+		if !l.used[k] {
+			l.errs = append(l.errs, fmt.Sprintf("%s in %s: unknown setting", k, l.path))
+		}
+	}
+	if len(l.errs) == 0 {
+		return nil
+	}
+	sort.Strings(l.errs)
+	return fmt.Errorf("%s", strings.Join(l.errs, "; "))
+}
+
+// FromFlags loads the configuration for a server binary. It understands
+// --config (defaulting to $CONFIG_FILE) and the "config print"
+// subcommand, which prints the effective configuration and exits.
+// Invalid or, in production, insecure configuration is fatal.
+func FromFlags() *Config {
+	args := os.Args[1:]
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	path := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	fs.Parse(args)
+
+	cfg, err := LoadFile(*path)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if printConfig {
+		cfg.Print(os.Stdout)
+		os.Exit(0)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Refusing to start: %v", err)
+	}
+	return cfg
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// secretMarkers flag keys whose values Print never shows
+var secretMarkers = []string{"PASS", "SECRET", "TOKEN", "PRIVATE_KEY", "SALT"}
+
+// Print writes the effective configuration as YAML, annotating each key
+// with where its value came from. Secrets and URL passwords are redacted.
+func (c *Config) Print(w io.Writer) {
+	for _, s := range c.settings {
+		fmt.Fprintf(w, "%s: %q # %s\n", strings.ToLower(s.key), redact(s.key, s.value), s.source)
+	}
+}
+
+func isSecret(key string) bool {
+	for _, marker := range secretMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(key, value string) string {
+	if value == "" {
+		return value
+	}
+	if isSecret(key) {
+		return "[redacted]"
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	// URLs, possibly in a comma separated list of domain=URL pairs
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		prefix, raw := "", part
+		if eq := strings.Index(part, "="); eq >= 0 && eq < strings.Index(part, "://") {
+			prefix, raw = part[:eq+1], part[eq+1:]
+		}
+		if u, err := url.Parse(strings.TrimSpace(raw)); err == nil && u.User != nil {
+			parts[i] = prefix + u.Redacted()
+		}
+	}
+	return strings.Join(parts, ",")
+}
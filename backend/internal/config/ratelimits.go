@@ -0,0 +1,93 @@
+package config
+
+import (
+	"cattymail/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RATE_LIMITS overrides the policy of any rate-limited API action with
+// comma separated action=limit/window[/burst] entries, the window in
+// seconds or as a duration:
+//
+//	RATE_LIMITS=fetch=120/1m/30,check=100/60
+//
+// Policies saved through the admin settings take precedence over both.
+
+// RatePolicies returns the configured policy of every rate-limited action:
+// the legacy per-minute knobs, overridden by RATE_LIMITS
+func (c *Config) RatePolicies() map[string]domain.RatePolicy {
+	perMin := func(limit int) domain.RatePolicy {
+		return domain.RatePolicy{Limit: limit, WindowSecs: 60}
+	}
+	policies := map[string]domain.RatePolicy{
+		"create":      perMin(c.RateLimitCreatePerMin),
+		"fetch":       perMin(c.RateLimitFetchPerMin),
+		"report":      perMin(c.RateLimitReportPerMin),
+		"unsubscribe": perMin(c.RateLimitReportPerMin),
+		"check":       perMin(c.RateLimitCreatePerMin * 4),
+		"verify":      perMin(c.RateLimitCreatePerMin),
+		"account":     perMin(c.RateLimitCreatePerMin),
+	}
+	if c.RateLimitInboxPerMin > 0 {
+		policies["inbox"] = perMin(c.RateLimitInboxPerMin)
+	}
+	for action, p := range c.RateLimits {
+		policies[action] = p
+	}
+	return policies
+}
+
+func (l *loader) getEnvRatePolicies(key string) map[string]domain.RatePolicy {
+	policies := make(map[string]domain.RatePolicy)
+	value, src, ok := l.lookup(key)
+	if !ok {
+		l.record(key, "", sourceDefault)
+		return policies
+	}
+	l.record(key, value, src)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		action, spec, _ := strings.Cut(entry, "=")
+		p, ok := ParseRatePolicy(spec)
+		if action = strings.TrimSpace(action); action == "" || !ok {
+			l.invalid(key, entry, src, "action=limit/window[/burst]")
+			continue
+		}
+		policies[action] = p
+	}
+	return policies
+}
+
+// ParseRatePolicy parses limit/window[/burst], the window in seconds or as
+// a duration such as 1m
+func ParseRatePolicy(spec string) (domain.RatePolicy, bool) {
+	parts := strings.Split(strings.TrimSpace(spec), "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return domain.RatePolicy{}, false
+	}
+	var p domain.RatePolicy
+	var err error
+	if p.Limit, err = strconv.Atoi(parts[0]); err != nil || p.Limit < 0 {
+		return p, false
+	}
+	if p.WindowSecs, err = strconv.Atoi(parts[1]); err != nil {
+		d, derr := time.ParseDuration(parts[1])
+		if derr != nil {
+			return p, false
+		}
+		p.WindowSecs = int(d / time.Second)
+	}
+	if p.WindowSecs <= 0 {
+		return p, false
+	}
+	if len(parts) == 3 {
+		if p.Burst, err = strconv.Atoi(parts[2]); err != nil || p.Burst < 0 {
+			return p, false
+		}
+	}
+	return p, true
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Secrets (IMAP_PASS, ADMIN_PASSWORD, JWT_SECRET) can also be read from
+//
+//   - <KEY>_FILE, a path to a file holding the value, as mounted by Docker
+//     and Kubernetes secrets; one trailing newline is dropped
+//   - Vault, when VAULT_ADDR and VAULT_SECRET_PATH are set: the KV secret
+//     at that path (v1 or v2) is read once at startup and its lower-case
+//     keys (imap_pass, ...) are used
+//
+// in that order after the variable itself and the config file. Values are
+// only ever held in memory, and Print and the admin config show where a
+// secret came from, never its value.
+
+const (
+	sourceSecretFile source = "secret file"
+	sourceVault      source = "vault"
+)
+
+// vaultTimeout bounds the single Vault read at startup
+const vaultTimeout = 10 * time.Second
+
+// getSecret is getEnv for secrets, see above
+func (l *loader) getSecret(key, fallback string) string {
+	if value, src, ok := l.lookup(key); ok {
+		l.record(key, value, src)
+		return value
+	}
+	if path, _, ok := l.lookup(key + "_FILE"); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Sprintf("%s_FILE: %v", key, err))
+		} else {
+			value := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+			l.record(key, value, sourceSecretFile)
+			return value
+		}
+	}
+	if value, ok := l.vault()[strings.ToLower(key)]; ok {
+		l.record(key, value, sourceVault)
+		return value
+	}
+	l.record(key, fallback, sourceDefault)
+	return fallback
+}
+
+// vault returns the Vault secret, reading it on first use. It is empty
+// when Vault is not configured or could not be read (which is an error).
+func (l *loader) vault() map[string]string {
+	if l.vaultData != nil {
+		return l.vaultData
+	}
+	l.vaultData = map[string]string{}
+
+	addr := strings.TrimRight(l.getEnv("VAULT_ADDR", ""), "/")
+	path := strings.Trim(l.getEnv("VAULT_SECRET_PATH", ""), "/")
+	if addr == "" || path == "" {
+		return l.vaultData
+	}
+	token := l.getEnv("VAULT_TOKEN", "")
+	if token == "" {
+		if file, _, ok := l.lookup("VAULT_TOKEN_FILE"); ok && file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				l.errs = append(l.errs, fmt.Sprintf("VAULT_TOKEN_FILE: %v", err))
+				return l.vaultData
+			}
+			token = strings.TrimSpace(string(data))
+		}
+	}
+
+	data, err := readVault(addr, path, token)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Sprintf("VAULT_SECRET_PATH: %v", err))
+		return l.vaultData
+	}
+	l.vaultData = data
+	return data
+}
+
+// readVault fetches a KV secret. Errors never include the token or values.
+func readVault(addr, path, token string) (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, addr+"/v1/"+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault address")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := (&http.Client{Timeout: vaultTimeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("read %s from Vault: request failed", path)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("read %s from Vault: status %d", path, resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("read %s from Vault: malformed response", path)
+	}
+	fields := body.Data
+	// KV v2 nests the secret under data.data
+	if nested, ok := fields["data"]; ok && len(nested) > 0 && nested[0] == '{' {
+		fields = nil
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return nil, fmt.Errorf("read %s from Vault: malformed response", path)
+		}
+	}
+
+	out := make(map[string]string, len(fields))
+	for k, raw := range fields {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			out[strings.ToLower(k)] = s
+		}
+	}
+	return out, nil
+}
+
+// SecretSources reports where each secret was read from (env, file,
+// secret file, vault or default), for admin output that must not carry
+// the values themselves
+func (c *Config) SecretSources() map[string]string {
+	out := make(map[string]string)
+	for _, s := range c.settings {
+		if isSecret(s.key) {
+			out[strings.ToLower(s.key)] = string(s.source)
+		}
+	}
+	return out
+}
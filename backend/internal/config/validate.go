@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minAdminPasswordLen is the shortest ADMIN_PASSWORD accepted in production
+const minAdminPasswordLen = 8
+
+// IsProduction reports whether APP_ENV is "production"
+func (c *Config) IsProduction() bool {
+	return strings.EqualFold(c.Environment, "production")
+}
+
+// Validate rejects insecure settings when running in production, listing
+// every violation with how to fix it. Outside production it always
+// succeeds.
+func (c *Config) Validate() error {
+	if !c.IsProduction() {
+		return nil
+	}
+	var problems []string
+	switch {
+	case c.DemoMode:
+		// No IMAP account is used
+	case c.IMAPUser == "" || c.IMAPPass == "":
+		problems = append(problems, "IMAP_USER or IMAP_PASS is empty: set the credentials of the IMAP mailbox")
+	}
+	if len(c.AdminPassword) < minAdminPasswordLen {
+		problems = append(problems, fmt.Sprintf("ADMIN_PASSWORD is empty or shorter than %d characters: set a strong admin password", minAdminPasswordLen))
+	}
+	if c.JWTSecret == "" {
+		problems = append(problems, "JWT_SECRET is empty: set a random secret (e.g. openssl rand -hex 32) as the initial token signing key")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			problems = append(problems, "CORS_ORIGINS allows any origin: list the frontend origins, e.g. https://catty.my.id")
+			break
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ValidationError lists every setting that failed Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "insecure configuration for APP_ENV=production:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
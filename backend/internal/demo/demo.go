@@ -0,0 +1,120 @@
+// Package demo replaces the IMAP ingestor in DEMO_MODE: it periodically
+// saves realistic fake messages into randomly picked live inboxes so the
+// product can be evaluated without a mail server.
+package demo
+
+import (
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// sampleSize bounds how many addresses are scanned to pick targets from
+const sampleSize = 500
+
+type template struct {
+	name, from, subject, text string
+}
+
+// Templates use %[1]d for a one-time code and %[2]s for the recipient
+var templates = []template{
+	{"GitHub", "noreply@github.com", "[GitHub] Please verify your device",
+		"Hey there!\n\nA sign in attempt requires further verification. To complete the sign in, enter the verification code:\n\nVerification code: %[1]d\n\nThanks,\nThe GitHub Team"},
+	{"Discord", "noreply@discord.com", "Verify Email Address for Discord",
+		"Hey %[2]s,\n\nThanks for registering for an account on Discord! Your verification code is %[1]d.\n\nNeed help? Contact our support team."},
+	{"Netflix", "info@account.netflix.com", "Complete your sign-up",
+		"Hi,\n\nYou're almost done. Enter this code to finish creating your account: %[1]d\n\n-- The Netflix team"},
+	{"Instagram", "security@mail.instagram.com", "%[1]d is your Instagram code",
+		"Hi,\n\nSomeone tried to sign up for an Instagram account with %[2]s. If it was you, enter this confirmation code in the app: %[1]d"},
+	{"Tokopedia", "noreply@tokopedia.com", "Kode OTP Tokopedia: %[1]d",
+		"Halo,\n\nKode OTP kamu adalah %[1]d. Jangan berikan kode ini kepada siapa pun, termasuk pihak Tokopedia."},
+	{"Medium Daily Digest", "noreply@medium.com", "Stories picked for you",
+		"Today's highlights\n\n- Why disposable email is good for your privacy\n- Building a Go service on a tiny VPS\n- 10 Redis patterns worth knowing\n\nYou are receiving this because you subscribed as %[2]s."},
+	{"Shopee", "info@mail.shopee.co.id", "Pesanan #%[1]d telah dikirim",
+		"Halo,\n\nPesanan #%[1]d sedang dalam perjalanan dan akan tiba dalam 2-3 hari kerja.\n\nTerima kasih telah berbelanja di Shopee."},
+}
+
+// Seeder injects fake messages on a timer
+type Seeder struct {
+	cfg   *config.Config
+	store *redisstore.Store
+}
+
+func NewSeeder(cfg *config.Config, store *redisstore.Store) *Seeder {
+	return &Seeder{cfg: cfg, store: store}
+}
+
+// Run seeds one message every DEMO_INTERVAL_SECONDS until ctx is cancelled
+func (s *Seeder) Run(ctx context.Context) {
+	interval := time.Duration(s.cfg.DemoIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	log.Printf("Demo mode: seeding a fake message every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.seed(ctx); err != nil {
+				log.Printf("Demo mode: failed to seed message: %v", err)
+			}
+		}
+	}
+}
+
+// seed saves one message into a random live inbox, if there is any
+func (s *Seeder) seed(ctx context.Context) error {
+	keys, err := s.store.GetAllAddresses(ctx, 0, sampleSize)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	// Keys have the form "addr:<domain>:<local>"
+	parts := strings.SplitN(keys[rand.Intn(len(keys))], ":", 3)
+	if len(parts) != 3 {
+		return nil
+	}
+	return s.store.SaveMessage(ctx, Message(parts[1], parts[2]))
+}
+
+// Message builds a fake message for local@emailDomain
+func Message(emailDomain, local string) *domain.Message {
+	t := templates[rand.Intn(len(templates))]
+	to := local + "@" + emailDomain
+	code := rand.Intn(900000) + 100000
+	text := fmt.Sprintf(t.text, code, to)
+	subject := t.subject
+	if strings.Contains(subject, "%") {
+		subject = fmt.Sprintf(subject, code)
+	}
+	body := fmt.Sprintf("<html><body><h2>%s</h2><p>%s</p></body></html>",
+		t.name, strings.ReplaceAll(html.EscapeString(text), "\n", "<br>"))
+
+	return &domain.Message{
+		ID:         ulid.Make().String(),
+		Domain:     emailDomain,
+		Local:      local,
+		OriginalTo: to,
+		From:       fmt.Sprintf("%q <%s>", t.name, t.from),
+		Subject:    subject,
+		Date:       time.Now(),
+		Text:       text,
+		HTML:       body,
+		Size:       len(text) + len(body),
+	}
+}
@@ -0,0 +1,162 @@
+// Package digest mails a summary of everything an inbox received, on
+// demand or shortly before the address expires, through the outbound SMTP
+// relay (SMTP_ADDR). It lets users keep a record of a one-off purchase
+// made with a throwaway address.
+package digest
+
+import (
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	// lead is how long before expiry scheduled digests are sent
+	lead = 5 * time.Minute
+	// interval is how often scheduled digests are checked
+	interval = time.Minute
+	// MaxMessages caps the messages listed in one digest
+	MaxMessages = 200
+)
+
+var (
+	ErrInvalidRecipient = errors.New("to must be an email address")
+	ErrNotConfigured    = errors.New("outbound email is not configured on this server")
+)
+
+// Sender composes and sends digests
+type Sender struct {
+	cfg   *config.Config
+	store *redisstore.Store
+}
+
+func New(cfg *config.Config, store *redisstore.Store) *Sender {
+	return &Sender{cfg: cfg, store: store}
+}
+
+// Validate checks that to can receive a digest from this server and
+// returns it without a display name
+func (s *Sender) Validate(to string) (string, error) {
+	if s.cfg.SMTPAddr == "" || s.cfg.SMTPFrom == "" {
+		return "", ErrNotConfigured
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return "", ErrInvalidRecipient
+	}
+	return addr.Address, nil
+}
+
+// Send mails the digest of an inbox to to and returns the number of
+// messages it lists
+func (s *Sender) Send(ctx context.Context, emailDomain, local, to string) (int, error) {
+	msgs, err := s.store.GetInbox(ctx, emailDomain, local, MaxMessages, 0)
+	if err != nil {
+		return 0, err
+	}
+	body := compose(s.cfg.SMTPFrom, to, local+"@"+emailDomain, msgs)
+
+	var auth smtp.Auth
+	if s.cfg.SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(s.cfg.SMTPAddr)
+		auth = smtp.PlainAuth("", s.cfg.SMTPUser, s.cfg.SMTPPass, host)
+	}
+	if err := smtp.SendMail(s.cfg.SMTPAddr, auth, s.cfg.SMTPFrom, []string{to}, body); err != nil {
+		return 0, err
+	}
+	return len(msgs), nil
+}
+
+// compose renders a plain text digest, newest message first
+func compose(from, to, inbox string, msgs []*domain.Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: [cattymail] Digest of %s (%d messages)\r\n", inbox, len(msgs))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "Messages received by %s:\r\n", inbox)
+	if len(msgs) == 0 {
+		b.WriteString("\r\n(none)\r\n")
+	}
+	for _, msg := range msgs {
+		fmt.Fprintf(&b, "\r\n%s\r\nFrom:    %s\r\nSubject: %s\r\n",
+			msg.Date.UTC().Format("2006-01-02 15:04 MST"), oneLine(msg.From), oneLine(msg.Subject))
+	}
+	if len(msgs) == MaxMessages {
+		fmt.Fprintf(&b, "\r\nOnly the latest %d messages are listed.\r\n", MaxMessages)
+	}
+	return b.Bytes()
+}
+
+// oneLine keeps header-like values from breaking the digest layout
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Run sends scheduled digests shortly before their address expires until
+// ctx is cancelled
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		due, err := s.store.DueDigests(ctx, time.Now().Add(lead), 100)
+		if err != nil {
+			log.Printf("digest: failed to list due digests: %v", err)
+			continue
+		}
+		for _, inbox := range due {
+			s.sendScheduled(ctx, inbox)
+		}
+	}
+}
+
+// sendScheduled sends the digest of a due inbox, or reschedules it when
+// the address was extended
+func (s *Sender) sendScheduled(ctx context.Context, inbox string) {
+	claimed, err := s.store.ClaimDigest(ctx, inbox)
+	if err != nil || !claimed {
+		return
+	}
+	at := strings.LastIndex(inbox, "@")
+	local, emailDomain := inbox[:at], inbox[at+1:]
+
+	to, err := s.store.DigestRecipient(ctx, emailDomain, local)
+	if err != nil {
+		log.Printf("digest: failed to read recipient of %s: %v", privacy.Redact(inbox), err)
+		return
+	}
+	if to == "" {
+		return // Cancelled, or the address is already gone
+	}
+	ttl, err := s.store.AddressTTL(ctx, emailDomain, local)
+	if err == nil && ttl > 2*lead {
+		if err := s.store.ScheduleDigest(ctx, emailDomain, local, to); err != nil {
+			log.Printf("digest: failed to reschedule %s: %v", privacy.Redact(inbox), err)
+		}
+		return
+	}
+	n, err := s.Send(ctx, emailDomain, local, to)
+	if err != nil {
+		log.Printf("digest: failed to send digest of %s: %v", privacy.Redact(inbox), err)
+		return
+	}
+	log.Printf("digest: sent digest of %s listing %d messages", privacy.Redact(inbox), n)
+}
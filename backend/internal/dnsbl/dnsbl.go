@@ -0,0 +1,166 @@
+// Package dnsbl looks up the relay that handed a message to us in DNS
+// blocklists (e.g. zen.spamhaus.org) and extracts that relay's address
+// from the Received headers. Domain blocklists (e.g. dbl.spamhaus.org)
+// are queried the same way for link targets.
+package dnsbl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheTTL is how long a verdict for an IP is reused
+const cacheTTL = 10 * time.Minute
+
+// Checker queries a fixed set of DNSBL zones
+type Checker struct {
+	zones    []string
+	timeout  time.Duration
+	resolver *net.Resolver
+
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+type cached struct {
+	lists []string
+	at    time.Time
+}
+
+// New returns a Checker for zones, or nil when zones is empty
+func New(zones []string, timeout time.Duration) *Checker {
+	if len(zones) == 0 {
+		return nil
+	}
+	return &Checker{zones: zones, timeout: timeout, resolver: net.DefaultResolver, cache: make(map[string]cached)}
+}
+
+// Check returns the zones listing ip. Zones that fail to answer in time
+// count as not listing it.
+func (c *Checker) Check(ctx context.Context, ip net.IP) []string {
+	return c.lookup(ctx, ip.String(), reverse(ip))
+}
+
+// CheckDomain returns the zones listing host, for domain blocklists such
+// as dbl.spamhaus.org. A leading "www." is dropped.
+func (c *Checker) CheckDomain(ctx context.Context, host string) []string {
+	host = strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(host), "."), "www.")
+	return c.lookup(ctx, "domain:"+host, host)
+}
+
+// lookup queries name under every zone, caching the verdict under key
+func (c *Checker) lookup(ctx context.Context, key, name string) []string {
+	c.mu.Lock()
+	if v, ok := c.cache[key]; ok && time.Since(v.at) < cacheTTL {
+		c.mu.Unlock()
+		return v.lists
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	listed := make([]bool, len(c.zones))
+	var wg sync.WaitGroup
+	for i, zone := range c.zones {
+		wg.Add(1)
+		go func(i int, zone string) {
+			defer wg.Done()
+			addrs, err := c.resolver.LookupIPAddr(ctx, name+"."+zone)
+			if err != nil {
+				return
+			}
+			for _, a := range addrs {
+				// Listings answer in 127.0.0.0/8; anything else (e.g. an
+				// ISP resolver's NXDOMAIN redirect) is ignored
+				if v4 := a.IP.To4(); v4 != nil && v4[0] == 127 {
+					listed[i] = true
+				}
+			}
+		}(i, zone)
+	}
+	wg.Wait()
+
+	var lists []string
+	for i, ok := range listed {
+		if ok {
+			lists = append(lists, c.zones[i])
+		}
+	}
+
+	c.mu.Lock()
+	if len(c.cache) > 10000 {
+		c.cache = make(map[string]cached)
+	}
+	c.cache[key] = cached{lists: lists, at: time.Now()}
+	c.mu.Unlock()
+	return lists
+}
+
+// reverse returns the DNSBL query label of ip: reversed octets for IPv4,
+// reversed nibbles for IPv6
+func reverse(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", v4[3], v4[2], v4[1], v4[0])
+	}
+	const hex = "0123456789abcdef"
+	v6 := ip.To16()
+	labels := make([]string, 0, 32)
+	for i := len(v6) - 1; i >= 0; i-- {
+		labels = append(labels, string(hex[v6[i]&0x0f]), string(hex[v6[i]>>4]))
+	}
+	return strings.Join(labels, ".")
+}
+
+// receivedFrom matches the bracketed address of the "from" clause of a
+// Received header, e.g. "from mail.example.com (mail.example.com [192.0.2.1])"
+var receivedFrom = regexp.MustCompile(`(?is)^\s*from\s.*?\[(?:ipv6:)?([0-9a-f:.]+)\]`)
+
+// SendingIP returns the first public address found in the Received
+// headers, newest first, skipping relays inside trusted (our own or our
+// mail provider's forwarding hops). It returns nil when none is found.
+func SendingIP(received []string, trusted []*net.IPNet) net.IP {
+outer:
+	for _, h := range received {
+		m := receivedFrom.FindStringSubmatch(h)
+		if m == nil {
+			continue
+		}
+		ip := net.ParseIP(m[1])
+		if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			continue
+		}
+		for _, n := range trusted {
+			if n.Contains(ip) {
+				continue outer
+			}
+		}
+		return ip
+	}
+	return nil
+}
+
+// ParseNets parses CIDRs (bare IPs are taken as single hosts)
+func ParseNets(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("dnsbl: invalid network %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
@@ -2,23 +2,440 @@ package domain
 
 import "time"
 
+// MessageSchemaVersion is the current version of the stored Message JSON.
+// Bump it together with an upgrader in redisstore when the shape changes.
+const MessageSchemaVersion = 2
+
 type Message struct {
+	SchemaVersion int       `json:"schema_version"`
+	ID            string    `json:"id"`
+	Domain        string    `json:"domain"`
+	Local         string    `json:"local"`
+	OriginalTo    string    `json:"original_to"`
+	From          string    `json:"from"` // raw header value, for display
+	FromName      string    `json:"from_name,omitempty"`
+	FromAddress   string    `json:"from_address,omitempty"` // normalized, for filtering
+	Subject       string    `json:"subject"`
+	Date          time.Time `json:"date"`        // sender-supplied Date header
+	ReceivedAt    time.Time `json:"received_at"` // when stored; zero for messages stored before it was recorded
+	Text          string    `json:"text"`
+	HTML          string    `json:"html,omitempty"`
+	Size          int       `json:"size,omitempty"`
+	IMAPUID       uint32    `json:"imap_uid,omitempty"`
+	IMAPFolder    string    `json:"imap_folder,omitempty"`
+	// Relay that handed the message to our mail provider, and the DNSBL
+	// zones listing it at ingest time
+	SenderIP string   `json:"sender_ip,omitempty"`
+	DNSBL    []string `json:"dnsbl,omitempty"`
+	// Curated headers (summarized Received chain, List-Unsubscribe,
+	// DKIM signing domains, ...), keyed by canonical header name
+	Headers map[string][]string `json:"headers,omitempty"`
+	// Antispam probability at ingest; set only when the message was also
+	// trained into the model as ham (see internal/antispam)
+	SpamScore *float64 `json:"spam_score,omitempty"`
+	// Inline images referenced from HTML as cid:<content_id>
+	Inline []InlinePart `json:"inline,omitempty"`
+	// Malware scan of the stored parts, when a scanner is configured
+	Scan *ScanReport `json:"scan,omitempty"`
+	// Set by the inbox's filter rules at ingest
+	Starred  bool   `json:"starred,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// ScanReport records a malware scan of a message's parts
+type ScanReport struct {
+	Scanner   string        `json:"scanner"`
+	ScannedAt time.Time     `json:"scanned_at"`
+	Infected  []ScanFinding `json:"infected,omitempty"`
+	Errors    int           `json:"errors,omitempty"` // parts that could not be scanned
+	Action    string        `json:"action,omitempty"` // "strip" or "quarantine" when infected
+}
+
+// ScanFinding is an infected part and the signature it matched
+type ScanFinding struct {
+	ContentID   string `json:"content_id"`
+	ContentType string `json:"content_type"`
+	Signature   string `json:"signature"`
+}
+
+// InlinePart is an image embedded in a message and referenced by Content-ID.
+// The API leaves Data out and serves it from /message/{id}/inline/{cid}.
+type InlinePart struct {
+	ContentID   string `json:"content_id"`
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data,omitempty"`
+}
+
+type Address struct {
+	Email         string    `json:"email"`
+	Local         string    `json:"local"`
+	Domain        string    `json:"domain"`
+	DisplayDomain string    `json:"display_domain,omitempty"` // Unicode form of an IDN domain
+	ExpiresAt     time.Time `json:"expires_at"`
+	// Code or link from a message that arrived right after creation
+	LatestEvent *AddressEvent `json:"latest_event,omitempty"`
+}
+
+// AddressEvent is the code or verification link extracted from a message
+// received shortly after its address was created
+type AddressEvent struct {
+	MessageID  string    `json:"message_id"`
+	From       string    `json:"from"`
+	Subject    string    `json:"subject"`
+	Code       string    `json:"code,omitempty"`
+	Link       string    `json:"link,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// IngestEvent is the metadata broadcast on the global ingest feed
+// whenever a message is stored. It never carries message bodies.
+type IngestEvent struct {
 	ID         string    `json:"id"`
-	Domain     string    `json:"domain"`
-	Local      string    `json:"local"`
-	OriginalTo string    `json:"original_to"`
 	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Inbox      string    `json:"inbox"` // canonical local@domain the message was stored under
 	Subject    string    `json:"subject"`
-	Date       time.Time `json:"date"`
-	Text       string    `json:"text"`
-	HTML       string    `json:"html,omitempty"`
-	IMAPUID    uint32    `json:"imap_uid,omitempty"`
-	IMAPFolder string    `json:"imap_folder,omitempty"`
+	Size       int       `json:"size"`
+	Folder     string    `json:"folder,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
 }
 
-type Address struct {
+// SenderProfile summarises everything stored for a single sender address.
+type SenderProfile struct {
+	Address        string     `json:"address"`
+	MessageCount   int64      `json:"message_count"`
+	FirstSeen      time.Time  `json:"first_seen"`
+	LastSeen       time.Time  `json:"last_seen"`
+	Domains        []string   `json:"domains"`
+	SpamReports    int64      `json:"spam_reports"`
+	HoneypotHits   int64      `json:"honeypot_hits,omitempty"`
+	Reputation     float64    `json:"reputation"` // 1 never reported, 0 all messages reported or honeypot mail
+	RecentMessages []*Message `json:"recent_messages"`
+}
+
+// DeadLetter is a raw message the ingestor failed to process, kept for
+// inspection. Raw is only populated when a single entry is fetched.
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	Folder   string    `json:"folder"`
+	UID      uint32    `json:"uid"`
+	Reason   string    `json:"reason"`
+	Size     int       `json:"size"`
+	FailedAt time.Time `json:"failed_at"`
+	Raw      string    `json:"raw,omitempty"`
+}
+
+// SkippedMessage is the header sample of a message the ingestor skipped
+// because no recipient matched an allowed domain
+type SkippedMessage struct {
+	Folder    string            `json:"folder"`
+	UID       uint32            `json:"uid"`
+	Reason    string            `json:"reason"`
+	Headers   map[string]string `json:"headers"`
+	Domains   []string          `json:"domains"` // recipient domains seen in the headers
+	SkippedAt time.Time         `json:"skipped_at"`
+}
+
+// LogEntry is one structured access/error log line kept for the admin
+// log viewer
+type LogEntry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Level      string    `json:"level"` // info, warn or error
+	Method     string    `json:"method"`
+	Route      string    `json:"route"` // matched route pattern
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	DurationMs int64     `json:"duration_ms"`
+	IP         string    `json:"ip"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AlertRule is an operator-defined health check evaluated by the alert
+// scheduler. Threshold means minutes for ingest_stalled, a percentage for
+// error_rate, an entry count for dlq_nonempty and a hit count for
+// honeypot_hit; redis_down ignores it.
+type AlertRule struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"` // ingest_stalled, error_rate, redis_down, dlq_nonempty or honeypot_hit
+	Threshold float64   `json:"threshold"`
+	Window    int       `json:"window_minutes,omitempty"` // error_rate and honeypot_hit
+	Channel   string    `json:"channel"`                  // email, webhook or telegram
+	Target    string    `json:"target"`                   // address, URL or chat ID
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Alert is a rule that is currently firing
+type Alert struct {
+	RuleID  string    `json:"rule_id"`
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+}
+
+// Account is a registered user. Accounts are optional: anonymous sessions
+// keep working without one.
+type Account struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Plan      string    `json:"plan"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HistoryEntry is an address an account created at some point, whether or
+// not it is still alive.
+type HistoryEntry struct {
 	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserDomain is a domain an account connected for its own addresses. It
+// only accepts mail once DNS ownership has been verified.
+type UserDomain struct {
+	Domain     string    `json:"domain"`
+	AccountID  string    `json:"-"`
+	Token      string    `json:"verification_token"`
+	Verified   bool      `json:"verified"`
+	CreatedAt  time.Time `json:"created_at"`
+	VerifiedAt time.Time `json:"verified_at,omitempty"`
+}
+
+// NotifyChannel posts new-mail summaries for one inbox or a whole domain to
+// a chat webhook.
+type NotifyChannel struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`  // "discord" or "slack"
+	Scope      string    `json:"scope"` // "inbox" or "domain"
+	Target     string    `json:"target"`
+	WebhookURL string    `json:"webhook_url"`
+	Template   string    `json:"template,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DomainMeta is the landing page and SEO metadata of a public domain, set
+// by admins. Empty fields fall back to generated defaults.
+type DomainMeta struct {
+	Domain       string    `json:"domain"`
+	Title        string    `json:"title,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	CanonicalURL string    `json:"canonical_url,omitempty"`
+	NoIndex      bool      `json:"noindex,omitempty"` // left out of sitemap.xml
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// WebAuthnCredential is a passkey or security key registered for admin
+// login. ID and PublicKey (a COSE key) are base64url encoded.
+type WebAuthnCredential struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	PublicKey  string    `json:"public_key"`
+	Algorithm  int       `json:"algorithm"`
+	SignCount  uint32    `json:"sign_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+}
+
+// APIToken is a long-lived admin token for automation, limited to Scopes
+// such as "stats:read" or "domains:write". Only a hash of the secret is
+// kept.
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	SecretHash string     `json:"secret_hash,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// RatePolicy limits how often a client IP may perform an API action:
+// Limit requests per WindowSecs on average, with up to Burst more in a
+// short spike
+type RatePolicy struct {
+	Limit      int `json:"limit"`
+	WindowSecs int `json:"window_seconds"`
+	Burst      int `json:"burst,omitempty"`
+}
+
+// RateExemption lifts or raises the rate limits of a client: requests from
+// an IP or CIDR range, or carrying a partner API key. Multiplier 0 exempts
+// the client entirely; otherwise every limit is multiplied by it.
+type RateExemption struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`            // "ip" or "key"
+	Value      string    `json:"value,omitempty"` // IP or CIDR, for "ip"
+	KeyHash    string    `json:"key_hash,omitempty"`
+	Multiplier int       `json:"multiplier"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DomainRotation is how the server picks a domain for addresses created
+// without one: "weighted" (random, proportional to Weights), "least_loaded"
+// (fewest addresses handed out lately) or "newest" (most recently added).
+// Weights default to 1; a weight of 0 takes a domain out of rotation while
+// it still accepts mail and explicit requests.
+type DomainRotation struct {
+	Strategy  string         `json:"strategy"`
+	Weights   map[string]int `json:"weights,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at,omitempty"`
+}
+
+// Domain lifecycle states. A domain without a recorded state is active.
+const (
+	DomainActive   = "active"   // new addresses allowed
+	DomainDraining = "draining" // existing inboxes keep receiving mail, no new addresses
+	DomainRetired  = "retired"  // hidden everywhere and no longer receiving mail
+)
+
+// BurnSignal is evidence that a domain may be blocked by a provider, such
+// as a bounce naming it or a site refusing it as disposable
+type BurnSignal struct {
+	Provider  string    `json:"provider"`
+	Reason    string    `json:"reason"`
+	MessageID string    `json:"message_id,omitempty"`
+	SeenAt    time.Time `json:"seen_at"`
+}
+
+// LocalPolicy constrains the local parts users may pick for custom
+// addresses on a domain. Zero values leave a rule unset; Profanity lists
+// the wordlist languages to reject (e.g. "en", "id").
+type LocalPolicy struct {
+	MinLength        int       `json:"min_length,omitempty"`
+	MaxLength        int       `json:"max_length,omitempty"`
+	BannedSubstrings []string  `json:"banned_substrings,omitempty"`
+	RequiredPrefixes []string  `json:"required_prefixes,omitempty"`
+	Profanity        []string  `json:"profanity,omitempty"`
+	UpdatedAt        time.Time `json:"updated_at,omitempty"`
+}
+
+// Honeypot is a local no one was ever given. Mail to it and API access to
+// it come from scrapers enumerating the address space. Domain "*" matches
+// the local on every domain.
+type Honeypot struct {
+	Domain    string    `json:"domain"`
 	Local     string    `json:"local"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HoneypotHit is one contact with a honeypot: inbound mail ("mail") or an
+// API request ("api")
+type HoneypotHit struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
 	Domain    string    `json:"domain"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Local     string    `json:"local"`
+	At        time.Time `json:"at"`
+	Sender    string    `json:"sender,omitempty"`     // mail: From address
+	SenderIP  string    `json:"sender_ip,omitempty"`  // mail: relay that handed it over
+	Subject   string    `json:"subject,omitempty"`    // mail
+	MessageID string    `json:"message_id,omitempty"` // mail: kept in the honeypot store
+	ClientIP  string    `json:"client_ip,omitempty"`  // api
+	Method    string    `json:"method,omitempty"`     // api
+	Path      string    `json:"path,omitempty"`       // api
+	UserAgent string    `json:"user_agent,omitempty"` // api
+}
+
+// ConsentPolicy names the current terms of service and privacy policy
+// versions. With Required set, addresses can only be created by clients
+// that accepted both.
+type ConsentPolicy struct {
+	TermsVersion   string    `json:"terms_version"`
+	PrivacyVersion string    `json:"privacy_version"`
+	Required       bool      `json:"required"`
+	UpdatedAt      time.Time `json:"updated_at,omitempty"`
+}
+
+// Consent records which versions a client accepted. IPHash is a keyed
+// hash of its IP address; the address itself is never stored.
+type Consent struct {
+	TermsVersion   string    `json:"terms_version"`
+	PrivacyVersion string    `json:"privacy_version"`
+	AcceptedAt     time.Time `json:"accepted_at"`
+	IPHash         string    `json:"ip_hash,omitempty"`
+}
+
+// AbuseReport is a complaint about a temp address, e.g. one used for
+// harassment, filed through the public report endpoint and reviewed by
+// admins. Status is "open" until an admin acts on it.
+type AbuseReport struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"` // "open", "resolved" or "dismissed"
+	Domain      string     `json:"domain"`
+	Local       string     `json:"local"`
+	MessageID   string     `json:"message_id,omitempty"`
+	Category    string     `json:"category"`
+	Description string     `json:"description,omitempty"`
+	Contact     string     `json:"contact,omitempty"` // how to reach the reporter, if they want
+	ClientIP    string     `json:"client_ip,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Actions     []string   `json:"actions,omitempty"` // taken on review: "block", "purge"
+	Note        string     `json:"note,omitempty"`    // reviewer's
+}
+
+// BlockedAddress is an address taken out of service for abuse: it cannot
+// be created again, its inbox routes refuse requests and mail to it is
+// skipped
+type BlockedAddress struct {
+	Domain    string    `json:"domain"`
+	Local     string    `json:"local"`
+	Reason    string    `json:"reason,omitempty"`
+	ReportID  string    `json:"report_id,omitempty"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// FrozenAddress is an address held for investigation: its mail is
+// quarantined and its inbox refuses reads, but nothing is deleted
+type FrozenAddress struct {
+	Domain   string    `json:"domain"`
+	Local    string    `json:"local"`
+	Reason   string    `json:"reason"`
+	FrozenBy string    `json:"frozen_by"`
+	FrozenAt time.Time `json:"frozen_at"`
+}
+
+// AuditEntry records an admin action. Actor is "admin" for interactive
+// sessions and "token:<id>" for API tokens.
+type AuditEntry struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// InboxFilters restrict what an inbox accepts. AllowSenders holds full
+// addresses ("noreply@steampowered.com") and domains ("@steampowered.com",
+// subdomains included); when set, mail from anyone else is quarantined.
+// Rules then act on the mail let in, in order.
+type InboxFilters struct {
+	AllowSenders []string     `json:"allow_senders"`
+	Rules        []FilterRule `json:"rules,omitempty"`
+	UpdatedAt    time.Time    `json:"updated_at,omitempty"`
+}
+
+// FilterRule acts on the messages its Match expression (see
+// internal/filters) selects: "drop" them, "star" them, "categorize" them
+// under Category, or post them to WebhookURL ("webhook")
+type FilterRule struct {
+	ID         string    `json:"id"`
+	Match      string    `json:"match"`
+	Action     string    `json:"action"`
+	Category   string    `json:"category,omitempty"`
+	WebhookURL string    `json:"webhook_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShadowDivergence is a message the shadow store lacks ("missing") or
+// holds with different Fields ("mismatch"), found by the shadow comparison
+type ShadowDivergence struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	Fields     []string  `json:"fields,omitempty"`
+	ComparedAt time.Time `json:"compared_at"`
 }
@@ -0,0 +1,265 @@
+// Package email parses and normalizes mailbox addresses the same way
+// everywhere: address creation in the API and recipient matching in the
+// ingestor.
+//
+// Parsing follows the RFC 5321 Mailbox grammar (Dot-string or Quoted-string
+// local part, LDH domain) with IDN domains converted to their punycode
+// A-label form. Normalization folds case, since every inbox on this service
+// is case-insensitive, and unquotes local parts that don't need quoting.
+package email
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// RFC 5321 section 4.5.3.1 size limits
+const (
+	MaxLocalLength  = 64
+	MaxDomainLength = 255
+	MaxPathLength   = 254
+)
+
+var (
+	ErrEmpty         = errors.New("address is empty")
+	ErrMissingAt     = errors.New("address must contain a single @")
+	ErrInvalidLocal  = errors.New("invalid local part")
+	ErrLocalTooLong  = errors.New("local part exceeds 64 characters")
+	ErrInvalidDomain = errors.New("invalid domain")
+	ErrTooLong       = errors.New("address exceeds 254 characters")
+)
+
+// Address is a parsed, normalized mailbox
+type Address struct {
+	Local  string
+	Domain string
+}
+
+// String returns the address in local@domain form, quoting the local part
+// if it isn't a valid Dot-string.
+func (a Address) String() string {
+	local := a.Local
+	if !isDotString(local) {
+		local = quote(local)
+	}
+	return local + "@" + a.Domain
+}
+
+// Parse parses a bare RFC 5321 address such as "user@example.com" or
+// "\"john doe\"@example.com" and returns its normalized form.
+func Parse(s string) (Address, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Address{}, ErrEmpty
+	}
+
+	at := strings.LastIndexByte(s, '@')
+	if at < 0 {
+		return Address{}, ErrMissingAt
+	}
+
+	local, err := NormalizeLocal(s[:at])
+	if err != nil {
+		return Address{}, err
+	}
+	domain, err := NormalizeDomain(s[at+1:])
+	if err != nil {
+		return Address{}, err
+	}
+
+	addr := Address{Local: local, Domain: domain}
+	if len(addr.String()) > MaxPathLength {
+		return Address{}, ErrTooLong
+	}
+	return addr, nil
+}
+
+// Extract parses the first address out of a header-style value such as
+// "Name <user@example.com>", "<user@example.com>" or a bare address.
+func Extract(s string) (Address, error) {
+	s = strings.TrimSpace(s)
+	if list, err := mail.ParseAddressList(s); err == nil && len(list) > 0 {
+		return Parse(list[0].Address)
+	}
+	if start, end := strings.IndexByte(s, '<'), strings.LastIndexByte(s, '>'); start >= 0 && start < end {
+		s = s[start+1 : end]
+	}
+	return Parse(s)
+}
+
+// SplitFrom splits a From value such as `"Name" <User@Example.com>` into
+// the display name and the normalized address. Either is "" when missing;
+// an address that does not parse is returned lower-cased as written.
+func SplitFrom(s string) (name, address string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", ""
+	}
+	if list, err := mail.ParseAddressList(s); err == nil && len(list) > 0 {
+		name = list[0].Name
+	}
+	if addr, err := Extract(s); err == nil {
+		return name, addr.String()
+	}
+	if start, end := strings.IndexByte(s, '<'), strings.LastIndexByte(s, '>'); start >= 0 && start < end {
+		if name == "" {
+			name = strings.Trim(strings.TrimSpace(s[:start]), `"`)
+		}
+		s = s[start+1 : end]
+	}
+	return name, strings.ToLower(strings.TrimSpace(s))
+}
+
+// NormalizeLocal validates a local part (Dot-string or Quoted-string) and
+// returns it case-folded and unquoted.
+func NormalizeLocal(local string) (string, error) {
+	if local == "" {
+		return "", ErrInvalidLocal
+	}
+
+	if local[0] == '"' {
+		unquoted, ok := unquote(local)
+		if !ok {
+			return "", ErrInvalidLocal
+		}
+		local = unquoted
+	} else if !isDotString(local) {
+		return "", ErrInvalidLocal
+	}
+
+	local = strings.ToLower(local)
+	if local == "" {
+		return "", ErrInvalidLocal
+	}
+	if len(local) > MaxLocalLength {
+		return "", ErrLocalTooLong
+	}
+	return local, nil
+}
+
+// NormalizeDomain validates a domain and returns its lowercase ASCII
+// (punycode) form. Unicode and xn-- inputs normalize to the same value.
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return "", ErrInvalidDomain
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", ErrInvalidDomain
+	}
+	ascii = strings.ToLower(ascii)
+
+	if len(ascii) > MaxDomainLength || !strings.Contains(ascii, ".") {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		if !isLDHLabel(label) {
+			return "", ErrInvalidDomain
+		}
+	}
+	return ascii, nil
+}
+
+// CanonicalDomain is NormalizeDomain for values that are compared rather
+// than validated: invalid input falls back to its trimmed lowercase form.
+func CanonicalDomain(domain string) string {
+	if ascii, err := NormalizeDomain(domain); err == nil {
+		return ascii
+	}
+	return strings.ToLower(strings.TrimSpace(domain))
+}
+
+// DisplayDomain returns the Unicode form of an ASCII domain for display,
+// falling back to the input if it can't be decoded.
+func DisplayDomain(domain string) string {
+	if u, err := idna.Display.ToUnicode(domain); err == nil {
+		return u
+	}
+	return domain
+}
+
+// isAtext reports whether c is an RFC 5322 atext character
+func isAtext(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-/=?^_`{|}~", c) >= 0
+}
+
+// isDotString reports whether s is a valid RFC 5321 Dot-string
+func isDotString(s string) bool {
+	if s == "" || s[0] == '.' || s[len(s)-1] == '.' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '.' {
+			if s[i-1] == '.' {
+				return false
+			}
+			continue
+		}
+		if !isAtext(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// unquote decodes an RFC 5321 Quoted-string, rejecting control characters
+func unquote(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", false
+	}
+
+	var b strings.Builder
+	body := s[1 : len(s)-1]
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == '\\' {
+			i++
+			if i == len(body) || body[i] < 32 || body[i] > 126 {
+				return "", false
+			}
+			b.WriteByte(body[i])
+			continue
+		}
+		if c == '"' || c < 32 || c > 126 {
+			return "", false
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), true
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// isLDHLabel reports whether label is a valid letter-digit-hyphen label
+func isLDHLabel(label string) bool {
+	if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
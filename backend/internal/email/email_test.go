@@ -0,0 +1,147 @@
+package email
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string // Address.String()
+	}{
+		{"user@example.com", "user@example.com"},
+		{"  User@Example.COM  ", "user@example.com"},
+		{"user@example.com.", "user@example.com"},
+		{"first.last@sub.example.com", "first.last@sub.example.com"},
+
+		// Plus-addressing is part of the local part, not stripped
+		{"budi+github@catty.my.id", "budi+github@catty.my.id"},
+		{"Budi+GitHub@Catty.My.Id", "budi+github@catty.my.id"},
+
+		// Quoted local parts unquote when they need not be quoted
+		{`"budi"@catty.my.id`, "budi@catty.my.id"},
+		{`"Budi.Santoso"@catty.my.id`, "budi.santoso@catty.my.id"},
+		{`"john doe"@example.com`, `"john doe"@example.com`},
+		{`"a@b"@example.com`, `"a@b"@example.com`},
+		{`"a\"b"@example.com`, `"a\"b"@example.com`},
+		{`"a\\b"@example.com`, `"a\\b"@example.com`},
+		{`"a..b"@example.com`, `"a..b"@example.com`},
+
+		// IDN domains normalize to the same A-label as their punycode
+		{"user@bücher.de", "user@xn--bcher-kva.de"},
+		{"user@BÜCHER.de", "user@xn--bcher-kva.de"},
+		{"user@XN--BCHER-KVA.DE", "user@xn--bcher-kva.de"},
+		{"user@münchen.example", "user@xn--mnchen-3ya.example"},
+	}
+
+	for _, tc := range cases {
+		addr, err := Parse(tc.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.in, err)
+			continue
+		}
+		if got := addr.String(); got != tc.want {
+			t.Errorf("Parse(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		in   string
+		want error
+	}{
+		{"", ErrEmpty},
+		{"   ", ErrEmpty},
+		{"user", ErrMissingAt},
+		{"user.example.com", ErrMissingAt},
+		{"@example.com", ErrInvalidLocal},
+		{".user@example.com", ErrInvalidLocal},
+		{"user.@example.com", ErrInvalidLocal},
+		{"us..er@example.com", ErrInvalidLocal},
+		{"us er@example.com", ErrInvalidLocal},
+		{"a@b@example.com", ErrInvalidLocal},
+		{"<user@example.com>", ErrInvalidLocal},
+		{`"user@example.com`, ErrInvalidLocal},
+		{`"us"er"@example.com`, ErrInvalidLocal},
+		{"\"us\ter\"@example.com", ErrInvalidLocal},
+		{`""@example.com`, ErrInvalidLocal},
+		{strings.Repeat("a", MaxLocalLength+1) + "@example.com", ErrLocalTooLong},
+		{"user@", ErrInvalidDomain},
+		{"user@localhost", ErrInvalidDomain},
+		{"user@-example.com", ErrInvalidDomain},
+		{"user@example-.com", ErrInvalidDomain},
+		{"user@exa_mple.com", ErrInvalidDomain},
+		{"user@example..com", ErrInvalidDomain},
+		{"user@[127.0.0.1]", ErrInvalidDomain},
+		{"user@" + strings.Repeat("a", 64) + ".com", ErrInvalidDomain},
+		{strings.Repeat("a", MaxLocalLength) + "@" + strings.Repeat(strings.Repeat("b", 60)+".", 4) + "com", ErrTooLong},
+	}
+
+	for _, tc := range cases {
+		addr, err := Parse(tc.in)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("Parse(%q) = %q, %v; want %v", tc.in, addr, err, tc.want)
+		}
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	cases := []struct {
+		in, want string // want "" for invalid
+	}{
+		{"catty.my.id", "catty.my.id"},
+		{" Catty.MY.id. ", "catty.my.id"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"xn--bcher-kva.de", "xn--bcher-kva.de"},
+		{"a-b.c0.io", "a-b.c0.io"},
+		{"", ""},
+		{".", ""},
+		{"com", ""},
+		{"exa mple.com", ""},
+		{"example.com/x", ""},
+		{"-a.com", ""},
+	}
+
+	for _, tc := range cases {
+		got, err := NormalizeDomain(tc.in)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("NormalizeDomain(%q) = %q, want error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("NormalizeDomain(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		in, want string // want "" for an error
+	}{
+		{"budi@catty.my.id", "budi@catty.my.id"},
+		{"<Budi@Catty.my.id>", "budi@catty.my.id"},
+		{`"Budi Santoso" <Budi+x@catty.my.id>`, "budi+x@catty.my.id"},
+		{"a@example.com, b@example.com", "a@example.com"},
+		{`Weird "<x>" <y@example.com>`, "y@example.com"},
+		{"Name <not an address>", ""},
+		{"<>", ""},
+	}
+
+	for _, tc := range cases {
+		addr, err := Extract(tc.in)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("Extract(%q) = %q, want error", tc.in, addr)
+			}
+			continue
+		}
+		if err != nil || addr.String() != tc.want {
+			t.Errorf("Extract(%q) = %q, %v; want %q", tc.in, addr, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"cattymail/internal/domain"
+)
+
+// Expressions match messages with conditions on their sender, subject and
+// body, combined with and, or, not and parentheses:
+//
+//	sender endswith "@steampowered.com" and not subject contains "newsletter"
+//
+// Operators are is, contains, startswith, endswith (all case-insensitive)
+// and matches, an RE2 regular expression. There are no loops, calls or
+// variables, and matching runs in time linear in the message size.
+const (
+	maxExprLength    = 500
+	maxPatternLength = 200
+)
+
+// Expr is a compiled match expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// String returns the source of e
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match reports whether msg satisfies e
+func (e *Expr) Match(msg *domain.Message) bool {
+	return e.root.eval(msg)
+}
+
+type node interface {
+	eval(msg *domain.Message) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+
+type condNode struct {
+	field string
+	op    string
+	value string // lowercased, except for matches
+	re    *regexp.Regexp
+}
+
+func (n andNode) eval(msg *domain.Message) bool { return n.left.eval(msg) && n.right.eval(msg) }
+func (n orNode) eval(msg *domain.Message) bool  { return n.left.eval(msg) || n.right.eval(msg) }
+func (n notNode) eval(msg *domain.Message) bool { return !n.inner.eval(msg) }
+
+func (n condNode) eval(msg *domain.Message) bool {
+	var s string
+	switch n.field {
+	case "sender":
+		s = msg.FromAddress
+		if s == "" {
+			s = msg.From
+		}
+	case "subject":
+		s = msg.Subject
+	case "body":
+		s = msg.Text
+		if strings.TrimSpace(s) == "" {
+			s = msg.HTML
+		}
+	}
+	if n.op == "matches" {
+		return n.re.MatchString(s)
+	}
+	s = strings.ToLower(s)
+	switch n.op {
+	case "is":
+		return s == n.value
+	case "contains":
+		return strings.Contains(s, n.value)
+	case "startswith":
+		return strings.HasPrefix(s, n.value)
+	case "endswith":
+		return strings.HasSuffix(s, n.value)
+	}
+	return false
+}
+
+var (
+	exprFields = map[string]bool{"sender": true, "subject": true, "body": true}
+	exprOps    = map[string]bool{"is": true, "contains": true, "startswith": true, "endswith": true, "matches": true}
+)
+
+// Compile parses an expression
+func Compile(src string) (*Expr, error) {
+	if len(src) > maxExprLength {
+		return nil, fmt.Errorf("expression longer than %d characters", maxExprLength)
+	}
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	r := []rune(src)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "("})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")"})
+			i++
+		case c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(r) && r[i] != '"'; i++ {
+				if r[i] == '\\' && i+1 < len(r) {
+					i++
+				}
+				b.WriteRune(r[i])
+			}
+			if i == len(r) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			toks = append(toks, token{tokString, b.String()})
+		case unicode.IsLetter(c):
+			start := i
+			for i < len(r) && unicode.IsLetter(r[i]) {
+				i++
+			}
+			toks = append(toks, token{tokWord, strings.ToLower(string(r[start:i]))})
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == (token{tokWord, "or"}) {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == (token{tokWord, "and"}) {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.next()
+	switch {
+	case t == token{tokWord, "not"}:
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	case t.kind == tokLParen:
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("missing )")
+		}
+		return inner, nil
+	case t.kind == tokWord && exprFields[t.text]:
+		return p.cond(t.text)
+	case t.kind == tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("expected sender, subject, body, not or (, got %q", t.text)
+}
+
+func (p *parser) cond(field string) (node, error) {
+	op := p.next()
+	if op.kind != tokWord || !exprOps[op.text] {
+		return nil, fmt.Errorf("expected is, contains, startswith, endswith or matches after %s", field)
+	}
+	val := p.next()
+	if val.kind != tokString {
+		return nil, fmt.Errorf("expected a quoted string after %s %s", field, op.text)
+	}
+	n := condNode{field: field, op: op.text, value: strings.ToLower(val.text)}
+	if op.text == "matches" {
+		if len(val.text) > maxPatternLength {
+			return nil, fmt.Errorf("pattern longer than %d characters", maxPatternLength)
+		}
+		re, err := regexp.Compile("(?i)" + val.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", val.text)
+		}
+		n.re = re
+	}
+	return n, nil
+}
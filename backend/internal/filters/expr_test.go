@@ -0,0 +1,113 @@
+package filters
+
+import (
+	"strings"
+	"testing"
+
+	"cattymail/internal/domain"
+)
+
+var steamMessage = &domain.Message{
+	FromAddress: "noreply@steampowered.com",
+	Subject:     "Your Steam account: Access from new computer",
+	Text:        "Here is the Steam Guard code you need: 4FJ9K",
+}
+
+func TestCompileMatch(t *testing.T) {
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`sender endswith "@steampowered.com"`, true},
+		{`SENDER IS "NoReply@SteamPowered.com"`, true},
+		{`subject startswith "your steam"`, true},
+		{`body contains "guard code"`, true},
+		{`body matches "code.*[0-9][a-z]+[0-9]k$"`, true},
+		{`subject contains "newsletter"`, false},
+		{`subject contains "say \"hi\""`, false},
+
+		// not binds tighter than and, and tighter than or
+		{`not subject contains "steam" and body contains "code"`, false},
+		{`not (subject contains "steam" and body contains "nope")`, true},
+		{`subject contains "nope" and body contains "nope" or sender endswith ".com"`, true},
+		{`subject contains "nope" and (body contains "nope" or sender endswith ".com")`, false},
+		{`sender endswith ".com" or subject contains "x" and body contains "nope"`, true},
+		{`(sender endswith ".com" or subject contains "x") and body contains "nope"`, false},
+		{`not not sender endswith ".com"`, true},
+		{`((sender is "noreply@steampowered.com"))`, true},
+	}
+
+	for _, tc := range cases {
+		e, err := Compile(tc.expr)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tc.expr, err)
+			continue
+		}
+		if got := e.Match(steamMessage); got != tc.want {
+			t.Errorf("Compile(%q).Match = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string // substring of the error
+	}{
+		{``, "unexpected end"},
+		{`sender`, "expected is, contains"},
+		{`sender like "x"`, "expected is, contains"},
+		{`sender is x`, "expected a quoted string"},
+		{`sender is "x`, "unterminated string"},
+		{`sender is "x" & subject is "y"`, "unexpected character"},
+		{`sender is 'x'`, "unexpected character"},
+		{`from is "x"`, "expected sender, subject, body"},
+		{`sender is "x" and`, "unexpected end"},
+		{`sender is "x" subject is "y"`, `unexpected "subject"`},
+		{`(sender is "x"`, "missing )"},
+		{`(sender is "x" or (subject is "y")`, "missing )"},
+		{`sender is "x")`, `unexpected ")"`},
+		{`()`, `got ")"`},
+		{`not`, "unexpected end"},
+		{`body matches "("`, "invalid pattern"},
+		{`body matches "` + strings.Repeat("a", maxPatternLength+1) + `"`, "pattern longer"},
+		{strings.Repeat(`sender is "x" or `, 40) + `sender is "x"`, "expression longer"},
+	}
+
+	for _, tc := range cases {
+		_, err := Compile(tc.expr)
+		if err == nil {
+			t.Errorf("Compile(%q) succeeded, want error containing %q", tc.expr, tc.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Compile(%q) = %q, want error containing %q", tc.expr, err, tc.want)
+		}
+	}
+}
+
+func FuzzCompile(f *testing.F) {
+	for _, seed := range []string{
+		`sender endswith "@steampowered.com" and not subject contains "newsletter"`,
+		`(subject is "a" or body matches "^x+$") and not (sender startswith "b")`,
+		`body contains "esc\"aped\\"`,
+		`not not not (`,
+		`sender is "`,
+		`))((`,
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		e, err := Compile(src)
+		if err != nil {
+			return
+		}
+		if e.String() != src {
+			t.Fatalf("Compile(%q).String() = %q", src, e.String())
+		}
+		e.Match(steamMessage)
+		e.Match(&domain.Message{})
+	})
+}
@@ -0,0 +1,157 @@
+// Package filters applies the per-inbox filters users set on what mail
+// their inbox accepts: a sender allowlist and rules acting on the mail it
+// lets in.
+package filters
+
+import (
+	"fmt"
+	"strings"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/notify"
+)
+
+// Limits per inbox
+const (
+	MaxAllowSenders   = 50
+	MaxRules          = 20
+	maxCategoryLength = 32
+)
+
+// Rule actions
+const (
+	ActionDrop       = "drop"
+	ActionStar       = "star"
+	ActionCategorize = "categorize"
+	ActionWebhook    = "webhook"
+)
+
+// Normalize validates f and rewrites its entries in canonical form:
+// lowercase addresses, and domains as "@domain"
+func Normalize(f *domain.InboxFilters) error {
+	if len(f.AllowSenders) > MaxAllowSenders {
+		return fmt.Errorf("at most %d allowed senders", MaxAllowSenders)
+	}
+	seen := make(map[string]bool, len(f.AllowSenders))
+	allow := make([]string, 0, len(f.AllowSenders))
+	for _, entry := range f.AllowSenders {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch i := strings.LastIndexByte(entry, '@'); {
+		case entry == "":
+			continue
+		case i <= 0:
+			d, err := email.NormalizeDomain(strings.TrimPrefix(entry, "@"))
+			if err != nil {
+				return fmt.Errorf("invalid sender domain %q", entry)
+			}
+			entry = "@" + d
+		default:
+			addr, err := email.Parse(entry)
+			if err != nil {
+				return fmt.Errorf("invalid sender address %q", entry)
+			}
+			entry = addr.String()
+		}
+		if !seen[entry] {
+			seen[entry] = true
+			allow = append(allow, entry)
+		}
+	}
+	f.AllowSenders = allow
+
+	if len(f.Rules) > MaxRules {
+		return fmt.Errorf("at most %d rules", MaxRules)
+	}
+	for i := range f.Rules {
+		if err := ValidateRule(&f.Rules[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateRule checks rule and trims what it does not use
+func ValidateRule(rule *domain.FilterRule) error {
+	if _, err := Compile(rule.Match); err != nil {
+		return fmt.Errorf("invalid match: %v", err)
+	}
+	switch rule.Action {
+	case ActionDrop, ActionStar:
+		rule.Category, rule.WebhookURL = "", ""
+	case ActionCategorize:
+		rule.Category = strings.ToLower(strings.TrimSpace(rule.Category))
+		if rule.Category == "" || len(rule.Category) > maxCategoryLength {
+			return fmt.Errorf("categorize needs a category of at most %d characters", maxCategoryLength)
+		}
+		rule.WebhookURL = ""
+	case ActionWebhook:
+		if err := notify.ValidateHook(&domain.NotifyChannel{WebhookURL: rule.WebhookURL}); err != nil {
+			return fmt.Errorf("webhook needs a public https webhook_url")
+		}
+		rule.Category = ""
+	default:
+		return fmt.Errorf(`action must be "drop", "star", "categorize" or "webhook"`)
+	}
+	return nil
+}
+
+// Outcome is what the rules of an inbox decided for a message
+type Outcome struct {
+	Drop     bool
+	Webhooks []string
+	Matched  []string // rule IDs
+}
+
+// Apply runs the rules of f on msg in order, starring and categorizing it
+// in place. A matching drop rule ends the run. Rules whose expression no
+// longer compiles are skipped.
+func Apply(f *domain.InboxFilters, msg *domain.Message) Outcome {
+	var out Outcome
+	if f == nil {
+		return out
+	}
+	for _, rule := range f.Rules {
+		expr, err := Compile(rule.Match)
+		if err != nil || !expr.Match(msg) {
+			continue
+		}
+		out.Matched = append(out.Matched, rule.ID)
+		switch rule.Action {
+		case ActionDrop:
+			out.Drop = true
+			return out
+		case ActionStar:
+			msg.Starred = true
+		case ActionCategorize:
+			msg.Category = rule.Category
+		case ActionWebhook:
+			out.Webhooks = append(out.Webhooks, rule.WebhookURL)
+		}
+	}
+	return out
+}
+
+// AllowsSender reports whether f lets mail from sender (a bare address)
+// in. Inboxes without an allowlist accept everyone.
+func AllowsSender(f *domain.InboxFilters, sender string) bool {
+	if f == nil || len(f.AllowSenders) == 0 {
+		return true
+	}
+	sender = strings.ToLower(sender)
+	i := strings.LastIndexByte(sender, '@')
+	if i < 0 {
+		return false
+	}
+	host := sender[i+1:]
+	for _, entry := range f.AllowSenders {
+		if d, ok := strings.CutPrefix(entry, "@"); ok {
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return true
+			}
+		} else if sender == entry {
+			return true
+		}
+	}
+	return false
+}
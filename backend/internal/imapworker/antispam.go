@@ -0,0 +1,37 @@
+package imapworker
+
+import (
+	"cattymail/internal/antispam"
+	"cattymail/internal/domain"
+	"cattymail/internal/redisstore"
+	"context"
+	"log"
+)
+
+// prescore sets msg.SpamScore from the antispam model and returns the
+// tokens to train it with once the message is stored. It returns nil when
+// antispam is off or the model is unreachable.
+func (w *Worker) prescore(ctx context.Context, msg *domain.Message) []string {
+	if !w.cfg.AntispamEnabled {
+		return nil
+	}
+	tokens := antispam.Tokenize(msg)
+	counts, err := w.store.AntispamCounts(ctx, tokens)
+	if err != nil {
+		log.Printf("Failed to score message %s: %v", msg.ID, err)
+		return nil
+	}
+	score := antispam.Score(counts)
+	msg.SpamScore = &score
+	return tokens
+}
+
+// trainHam counts a stored message as ham until someone reports it
+func (w *Worker) trainHam(ctx context.Context, tokens []string) {
+	if tokens == nil {
+		return
+	}
+	if err := w.store.TrainAntispam(ctx, redisstore.ClassHam, tokens, 1); err != nil {
+		log.Printf("Failed to train antispam model: %v", err)
+	}
+}
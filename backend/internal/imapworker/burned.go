@@ -0,0 +1,22 @@
+package imapworker
+
+import (
+	"context"
+	"log"
+
+	"cattymail/internal/burned"
+	"cattymail/internal/domain"
+)
+
+// checkBurned records msg as a burn signal against its domain when it is
+// a bounce or a site refusing the domain (see internal/burned)
+func (w *Worker) checkBurned(ctx context.Context, msg *domain.Message) {
+	sig, ok := burned.Match(msg)
+	if !ok {
+		return
+	}
+	log.Printf("Domain %s possibly blocked by %s: %s", msg.Domain, sig.Provider, sig.Reason)
+	if err := w.store.RecordBurnSignal(ctx, msg.Domain, sig); err != nil {
+		log.Printf("Failed to record burn signal for %s: %v", msg.Domain, err)
+	}
+}
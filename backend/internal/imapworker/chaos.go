@@ -0,0 +1,28 @@
+//go:build chaos
+
+package imapworker
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"cattymail/internal/chaos"
+
+	"github.com/emersion/go-imap/client"
+)
+
+var imapFaults = chaos.FromEnv("imap")
+
+// dialIMAP connects to the IMAP server with faults injected (see
+// internal/chaos): failed dials, latency and connection resets
+func dialIMAP(addr string, tlsConfig *tls.Config) (*client.Client, error) {
+	if chaos.Hit(imapFaults.ErrorPct) {
+		return nil, chaos.ErrInjected
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(imapFaults.WrapConn(conn))
+}
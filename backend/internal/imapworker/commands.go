@@ -0,0 +1,182 @@
+package imapworker
+
+import (
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Commands the ingestor answers on the Redis command bus
+const (
+	CommandPoll         = "poll"
+	CommandReloadConfig = "reload_config"
+	CommandResetCursor  = "reset_cursor"
+	CommandStatus       = "status"
+)
+
+// PollArgs are the arguments of CommandPoll. Folder limits the cycle to
+// one folder; Domain re-scans mail received since Since for that
+// recipient domain without moving the folder cursors.
+type PollArgs struct {
+	Folder string    `json:"folder,omitempty"`
+	Domain string    `json:"domain,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+}
+
+// ResetCursorArgs are the arguments of CommandResetCursor. The next cycle
+// fetches the folder from UID+1; already stored messages are skipped.
+type ResetCursorArgs struct {
+	Folder string `json:"folder"`
+	UID    uint32 `json:"uid"`
+}
+
+// Status is the result of CommandStatus
+type Status struct {
+	Cycling     bool              `json:"cycling"`
+	LastCycleAt *time.Time        `json:"last_cycle_at,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	Cursors     map[string]uint32 `json:"cursors"`
+	Domains     []string          `json:"domains"`
+	StorageMode string            `json:"storage_mode"`
+	// Fetched and Backlog are, per folder, the messages the last cycle
+	// took and the UIDs it left for the following ones
+	Fetched map[string]int `json:"fetched,omitempty"`
+	Backlog map[string]int `json:"backlog,omitempty"`
+}
+
+// serveCommands answers commands from the API until ctx is cancelled.
+// Each command is handled off the polling loop, so status stays
+// responsive during a long cycle while commands touching cursors or
+// domains wait for it to finish.
+func (w *Worker) serveCommands(ctx context.Context) {
+	sub := w.store.SubscribeCommands(ctx)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var cmd redisstore.Command
+			if err := json.Unmarshal([]byte(m.Payload), &cmd); err != nil {
+				continue
+			}
+			go w.answer(ctx, &cmd)
+		}
+	}
+}
+
+func (w *Worker) answer(ctx context.Context, cmd *redisstore.Command) {
+	reply := &redisstore.CommandReply{ID: cmd.ID}
+	result, err := w.handleCommand(ctx, cmd)
+	if err == nil {
+		reply.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+	if err := w.store.ReplyCommand(ctx, reply); err != nil {
+		log.Printf("Failed to reply to command %s (%s): %v", cmd.ID, cmd.Action, err)
+	}
+}
+
+func (w *Worker) handleCommand(ctx context.Context, cmd *redisstore.Command) (interface{}, error) {
+	switch cmd.Action {
+	case CommandPoll:
+		var args PollArgs
+		if err := decodeArgs(cmd, &args); err != nil {
+			return nil, err
+		}
+		log.Printf("Manual poll requested (folder=%q domain=%q)", args.Folder, args.Domain)
+		select {
+		case w.polls <- pollScope{folder: args.Folder, domain: args.Domain, since: args.Since}:
+		default:
+			return nil, fmt.Errorf("too many polls queued")
+		}
+		return map[string]string{"status": "queued"}, nil
+
+	case CommandReloadConfig:
+		w.cycleMu.Lock()
+		defer w.cycleMu.Unlock()
+		w.reloadDomains(ctx)
+		return map[string]interface{}{"domains": w.cfg.AllowedDomains}, nil
+
+	case CommandResetCursor:
+		var args ResetCursorArgs
+		if err := decodeArgs(cmd, &args); err != nil {
+			return nil, err
+		}
+		if !knownFolder(args.Folder) {
+			return nil, fmt.Errorf("unknown folder %q", args.Folder)
+		}
+		w.cycleMu.Lock()
+		defer w.cycleMu.Unlock()
+		if err := w.store.SetFolderLastUID(ctx, w.cfg.IMAPUser+":"+args.Folder, args.UID); err != nil {
+			return nil, err
+		}
+		log.Printf("Cursor for %s reset to UID %d", args.Folder, args.UID)
+		return args, nil
+
+	case CommandStatus:
+		return w.currentStatus(ctx), nil
+	}
+	return nil, fmt.Errorf("unknown command %q", cmd.Action)
+}
+
+func (w *Worker) currentStatus(ctx context.Context) *Status {
+	w.status.mu.Lock()
+	st := &Status{
+		Cycling:   w.status.cycling,
+		LastError: w.status.lastError,
+		Cursors:   make(map[string]uint32, len(Folders)),
+		Domains:   append([]string(nil), w.status.domains...),
+		Fetched:   make(map[string]int, len(w.status.fetched)),
+		Backlog:   make(map[string]int, len(w.status.backlog)),
+	}
+	for folder, n := range w.status.fetched {
+		st.Fetched[folder] = n
+	}
+	for folder, n := range w.status.backlog {
+		st.Backlog[folder] = n
+	}
+	if !w.status.lastCycleAt.IsZero() {
+		t := w.status.lastCycleAt
+		st.LastCycleAt = &t
+	}
+	w.status.mu.Unlock()
+
+	for _, folder := range Folders {
+		if uid, err := w.store.GetFolderLastUID(ctx, w.cfg.IMAPUser+":"+folder); err == nil {
+			st.Cursors[folder] = uid
+		}
+	}
+	st.StorageMode = w.store.Pressure().Mode.String()
+	return st
+}
+
+func decodeArgs(cmd *redisstore.Command, v interface{}) error {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(cmd.Args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// knownFolder reports whether folder is one of the polled Folders
+func knownFolder(folder string) bool {
+	for _, f := range Folders {
+		if f == folder {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package imapworker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// recordDelivery counts a stored message for the public stats, with the
+// time since the provider received it (arrived is the IMAP internal date)
+func (w *Worker) recordDelivery(ctx context.Context, arrived time.Time) {
+	latency := time.Duration(-1)
+	if !arrived.IsZero() {
+		if latency = time.Since(arrived); latency < 0 {
+			latency = 0 // clock skew with the provider
+		}
+	}
+	if err := w.store.RecordDelivery(ctx, latency); err != nil {
+		log.Printf("Failed to record delivery stats: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package imapworker
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"cattymail/internal/filters"
+	"cattymail/internal/notify"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ruleHookClient posts to the webhooks of filter rules. Like the
+// notification dispatcher's, it dials only public addresses.
+var ruleHookClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: notify.PublicTransport(),
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// inboxFilters returns the filters of the inbox msg is for. Lookup errors
+// count as no filters, letting the message through.
+func (w *Worker) inboxFilters(ctx context.Context, msg *domain.Message) *domain.InboxFilters {
+	f, err := w.store.GetInboxFilters(ctx, msg.Domain, msg.Local)
+	if err != nil {
+		log.Printf("Failed to load filters of %s: %v", privacy.Inbox(msg.Local, msg.Domain), err)
+	}
+	return f
+}
+
+// filtered reports whether the sender allowlist of f rejects msg
+func (w *Worker) filtered(f *domain.InboxFilters, msg *domain.Message) bool {
+	sender := redisstore.SenderAddress(msg.From)
+	if filters.AllowsSender(f, sender) {
+		return false
+	}
+	log.Printf("Message %s from %s not allowed by the filters of %s", msg.ID, privacy.Address(sender), privacy.Inbox(msg.Local, msg.Domain))
+	return true
+}
+
+// ruleHooks posts msg to the webhooks its inbox rules picked, in the
+// background and rate-limited per URL like notification channels
+func (w *Worker) ruleHooks(ctx context.Context, msg *domain.Message, urls []string) {
+	if len(urls) == 0 {
+		return
+	}
+	body, err := json.Marshal(notify.NewHookPayload(msg))
+	if err != nil {
+		return
+	}
+	for _, url := range urls {
+		allowed, err := w.store.RateLimit(ctx, url, "rulehook", w.cfg.NotifyMaxPerMin, time.Minute)
+		if err != nil || !allowed {
+			continue
+		}
+		go func(url string) {
+			if err := postRuleHook(context.Background(), url, body); err != nil {
+				log.Printf("Filter rule webhook for %s: %v", privacy.Inbox(msg.Local, msg.Domain), err)
+			}
+		}(url)
+	}
+}
+
+func postRuleHook(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := ruleHookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// applyRules runs the inbox rules of f on msg (see filters.Apply)
+func applyRules(f *domain.InboxFilters, msg *domain.Message) filters.Outcome {
+	out := filters.Apply(f, msg)
+	if out.Drop {
+		log.Printf("Message %s dropped by a filter rule of %s", msg.ID, privacy.Inbox(msg.Local, msg.Domain))
+	}
+	return out
+}
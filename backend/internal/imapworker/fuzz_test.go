@@ -0,0 +1,112 @@
+package imapworker
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+)
+
+func quietLogs(t testing.TB) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func FuzzExtractEmailFromString(f *testing.F) {
+	for _, seed := range []string{
+		"user@catty.my.id",
+		"<user@catty.my.id>",
+		"Budi <budi@catty.my.id>",
+		"\"Weird <name>\" <x@catty.my.id>",
+		"a>b<c",
+		"<>",
+		"<",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	w := benchWorker()
+	f.Fuzz(func(t *testing.T, s string) {
+		got := w.extractEmailFromString(s)
+		if !strings.Contains(s, got) {
+			t.Fatalf("extractEmailFromString(%q) = %q, not a substring of the input", s, got)
+		}
+		if got != strings.TrimSpace(got) {
+			t.Fatalf("extractEmailFromString(%q) = %q, not trimmed", s, got)
+		}
+	})
+}
+
+func FuzzExtractRecipient(f *testing.F) {
+	for _, seed := range []string{
+		"X-Forwarded-To: budi@catty.my.id\r\n\r\n",
+		"Delivered-To: other@gmail.com\r\nTo: \"Sari\" <sari@CattyPrems.top>\r\n\r\n",
+		"To: =?UTF-8?B?U2FyaQ==?= <sari@cattyprems.top>, x@y\r\n\r\n",
+		"Envelope-To: <@catty.my.id>\r\n\r\n",
+		"To: a@b@catty.my.id\r\n\r\n",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	quietLogs(f)
+	w := benchWorker()
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		hdr, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw))).ReadMIMEHeader()
+		if err != nil && len(hdr) == 0 {
+			return
+		}
+		h := mail.HeaderFromMap(hdr)
+
+		got := w.extractRecipient(h)
+		if got == "" {
+			return
+		}
+		if !w.isValidDomainEmail(got) {
+			t.Fatalf("extractRecipient returned %q outside the allowed domains", got)
+		}
+		if got != strings.ToLower(got) {
+			t.Fatalf("extractRecipient returned non-normalized %q", got)
+		}
+	})
+}
+
+func FuzzReadBodies(f *testing.F) {
+	f.Add([]byte(plainMessage))
+	f.Add([]byte(multipartMessage(256)))
+	f.Add([]byte("Content-Type: multipart/mixed; boundary=x\r\n\r\n--x\r\nContent-Type: multipart/alternative; boundary=y\r\n\r\n--y\r\n\r\nhi\r\n--y--\r\n--x--\r\n"))
+	f.Add([]byte("Content-Type: text/plain; charset=bogus\r\nContent-Transfer-Encoding: base64\r\n\r\n!!!!\r\n"))
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		mr, err := mail.CreateReader(bytes.NewReader(raw))
+		if err != nil {
+			return
+		}
+		readBodies(mr)
+	})
+}
+
+func FuzzParseMessage(f *testing.F) {
+	f.Add([]byte(plainMessage))
+	f.Add([]byte(multipartMessage(256)))
+	f.Add([]byte("To: x@catty.my.id\r\nDate: not a date\r\nSubject: =?bogus?Q?x?=\r\n\r\nbody"))
+
+	quietLogs(f)
+	w := benchWorker()
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		msg, err := w.parseMessage(1, raw, time.Now())
+		if err != nil || msg == nil {
+			return
+		}
+		if msg.Domain == "" || msg.Local == "" {
+			t.Fatalf("parsed message without recipient: %+v", msg)
+		}
+	})
+}
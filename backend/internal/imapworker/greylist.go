@@ -0,0 +1,60 @@
+package imapworker
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"context"
+	"log"
+	"time"
+)
+
+// releaseInterval is how often held messages are checked for release
+const releaseInterval = 5 * time.Second
+
+// greylist holds msg when its domain delays first contact and this is the
+// first message from its sender to the inbox. It reports whether msg was
+// held; on errors the message is delivered normally.
+func (w *Worker) greylist(ctx context.Context, msg *domain.Message) bool {
+	delay, err := w.store.GreylistDelay(ctx, msg.Domain)
+	if err != nil {
+		log.Printf("Failed to read greylist delay for %s: %v", msg.Domain, err)
+		return false
+	}
+	if delay <= 0 {
+		return false
+	}
+	first, err := w.store.FirstContact(ctx, msg.Domain, msg.Local, redisstore.SenderAddress(msg.From))
+	if err != nil || !first {
+		return false
+	}
+	if err := w.store.HoldMessage(ctx, msg, time.Now().Add(delay)); err != nil {
+		log.Printf("Failed to hold message %s: %v", msg.ID, err)
+		return false
+	}
+	log.Printf("Holding message %s for %s for %s (first contact)", msg.ID, privacy.Inbox(msg.Local, msg.Domain), delay)
+	return true
+}
+
+// releaseHeld moves held messages into their inboxes once due
+func (w *Worker) releaseHeld(ctx context.Context) {
+	ticker := time.NewTicker(releaseInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ids, err := w.store.DueHeldMessages(ctx, time.Now(), 100)
+		if err != nil {
+			log.Printf("Failed to list held messages: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			if _, err := w.store.ReleaseMessage(ctx, id); err != nil {
+				log.Printf("Failed to release held message %s: %v", id, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package imapworker
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/emersion/go-message/mail"
+)
+
+// keptHeaders are stored verbatim (decoded) for the "original headers" view
+var keptHeaders = []string{"List-Unsubscribe", "List-Unsubscribe-Post", "Auto-Submitted", "X-Mailer", "Message-Id", "Return-Path"}
+
+// maxReceivedHops bounds the Received summary of forged or looping mail
+const maxReceivedHops = 20
+
+var (
+	receivedFromHost = regexp.MustCompile(`(?i)\bfrom\s+(\S+)`)
+	receivedByHost   = regexp.MustCompile(`(?i)\bby\s+(\S+)`)
+	dkimDomain       = regexp.MustCompile(`(?i)(?:^|;)\s*d\s*=\s*([^;\s]+)`)
+)
+
+// curatedHeaders returns the headers worth keeping with a message: the
+// Received chain summarized to "from <host> by <host>; <date>" per hop,
+// newest first, the headers in keptHeaders, and DKIM-Signature as the
+// signing domains ("d=") when the message is signed.
+func curatedHeaders(h mail.Header) map[string][]string {
+	out := make(map[string][]string)
+	for i, v := range h.Values("Received") {
+		if i == maxReceivedHops {
+			break
+		}
+		out["Received"] = append(out["Received"], summarizeReceived(v))
+	}
+	for _, key := range keptHeaders {
+		if v, err := h.Text(key); err == nil && v != "" {
+			out[key] = []string{v}
+		}
+	}
+	for _, v := range h.Values("DKIM-Signature") {
+		d := "unknown"
+		if m := dkimDomain.FindStringSubmatch(v); m != nil {
+			d = strings.ToLower(m[1])
+		}
+		out["DKIM-Signature"] = append(out["DKIM-Signature"], d)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func summarizeReceived(v string) string {
+	v = strings.Join(strings.Fields(v), " ")
+	clauses, date, _ := strings.Cut(v, ";")
+	var parts []string
+	if m := receivedFromHost.FindStringSubmatch(clauses); m != nil {
+		parts = append(parts, "from "+m[1])
+	}
+	if m := receivedByHost.FindStringSubmatch(clauses); m != nil {
+		parts = append(parts, "by "+m[1])
+	}
+	if len(parts) == 0 {
+		// Not in the usual shape; keep it, trimmed
+		if len(clauses) > 200 {
+			clauses = clauses[:200]
+		}
+		parts = append(parts, clauses)
+	}
+	s := strings.Join(parts, " ")
+	if date = strings.TrimSpace(date); date != "" {
+		s += "; " + date
+	}
+	return s
+}
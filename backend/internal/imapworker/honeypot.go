@@ -0,0 +1,50 @@
+package imapworker
+
+import (
+	"cattymail/internal/antispam"
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"context"
+	"log"
+)
+
+// divertHoneypot keeps mail sent to a honeypot out of every inbox, in the
+// honeypot store instead, and holds it against its sender: the relay and
+// the sender lose reputation and the antispam model learns it as spam.
+// It reports whether msg was diverted.
+func (w *Worker) divertHoneypot(ctx context.Context, msg *domain.Message) (bool, error) {
+	if !w.store.IsHoneypot(ctx, msg.Domain, msg.Local) {
+		return false, nil
+	}
+	hit := &domain.HoneypotHit{
+		Kind:     "mail",
+		Domain:   msg.Domain,
+		Local:    msg.Local,
+		Sender:   redisstore.SenderAddress(msg.From),
+		SenderIP: msg.SenderIP,
+		Subject:  msg.Subject,
+	}
+	if err := w.store.RecordHoneypotHit(ctx, hit, msg); err != nil {
+		return true, err
+	}
+	log.Printf("Honeypot %s got mail from %s (%s)", privacy.Inbox(msg.Local, msg.Domain), privacy.Address(hit.Sender), msg.SenderIP)
+
+	if w.cfg.AntispamEnabled {
+		if err := w.store.TrainAntispam(ctx, redisstore.ClassSpam, antispam.Tokenize(msg), 1); err != nil {
+			log.Printf("Failed to train antispam model: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// honeypotRelay marks msg when its relay has mailed a honeypot before,
+// next to the DNSBL listings
+func (w *Worker) honeypotRelay(ctx context.Context, msg *domain.Message) {
+	if msg.SenderIP == "" {
+		return
+	}
+	if n, err := w.store.HoneypotHitsOf(ctx, "ip", msg.SenderIP); err == nil && n > 0 {
+		msg.DNSBL = append(msg.DNSBL, "honeypot")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !chaos
+
+package imapworker
+
+import (
+	"crypto/tls"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// dialIMAP connects to the IMAP server. Builds with -tags chaos inject
+// faults here (see chaos.go).
+func dialIMAP(addr string, tlsConfig *tls.Config) (*client.Client, error) {
+	return client.DialTLS(addr, tlsConfig)
+}
@@ -0,0 +1,37 @@
+package imapworker
+
+import (
+	"cattymail/internal/domain"
+	"cattymail/internal/otp"
+	"context"
+	"log"
+	"time"
+)
+
+// pinEvent records the code or link of a message that arrived while its
+// address's pin window is open, for address create/claim responses
+func (w *Worker) pinEvent(ctx context.Context, msg *domain.Message) {
+	if w.cfg.PinWindowSecs <= 0 {
+		return
+	}
+	open, err := w.store.PinWindowOpen(ctx, msg.Domain, msg.Local)
+	if err != nil || !open {
+		return
+	}
+	code, link := otp.Extract(msg.Subject, msg.Text, msg.HTML)
+	if code == "" && link == "" {
+		return
+	}
+	ev := &domain.AddressEvent{
+		MessageID:  msg.ID,
+		From:       msg.From,
+		Subject:    msg.Subject,
+		Code:       code,
+		Link:       link,
+		ReceivedAt: time.Now(),
+	}
+	ttl := time.Duration(w.cfg.PinWindowSecs) * time.Second
+	if err := w.store.SaveAddressEvent(ctx, msg.Domain, msg.Local, ev, ttl); err != nil {
+		log.Printf("Failed to pin event of message %s: %v", msg.ID, err)
+	}
+}
@@ -0,0 +1,17 @@
+package imapworker
+
+import (
+	"context"
+	"log"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/providers"
+)
+
+// recordProvider counts a stored message for the per-provider
+// deliverability report
+func (w *Worker) recordProvider(ctx context.Context, msg *domain.Message) {
+	if err := w.store.RecordProvider(ctx, msg.Domain, providers.Classify(msg)); err != nil {
+		log.Printf("Failed to record provider stats: %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package imapworker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// cleanInterval is how often read messages are checked for auto-clean
+const cleanInterval = 30 * time.Second
+
+// cleanRead deletes read messages of inboxes with auto-clean on once due
+func (w *Worker) cleanRead(ctx context.Context) {
+	ticker := time.NewTicker(cleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ids, err := w.store.DueReadCleanups(ctx, time.Now(), 100)
+		if err != nil {
+			log.Printf("Failed to list read messages to clean: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			if err := w.store.CleanReadMessage(ctx, id); err != nil {
+				log.Printf("Failed to clean read message %s: %v", id, err)
+			}
+		}
+	}
+}
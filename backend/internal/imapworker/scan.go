@@ -0,0 +1,48 @@
+package imapworker
+
+import (
+	"cattymail/internal/domain"
+	"context"
+	"log"
+	"time"
+)
+
+// scanParts runs the malware scanner over the parts of msg we store and
+// strips infected ones. It reports whether msg should be quarantined
+// instead of delivered (SCAN_ACTION=quarantine). Parts that fail to scan
+// are kept: a scanner outage must not stop ingestion.
+func (w *Worker) scanParts(ctx context.Context, msg *domain.Message) bool {
+	if w.scanner == nil || len(msg.Inline) == 0 {
+		return false
+	}
+	report := &domain.ScanReport{Scanner: w.scanner.Name(), ScannedAt: time.Now()}
+	kept := msg.Inline[:0]
+	for _, part := range msg.Inline {
+		sig, err := w.scanner.Scan(ctx, part.Data)
+		if err != nil {
+			log.Printf("Failed to scan part %s of message %s: %v", part.ContentID, msg.ID, err)
+			report.Errors++
+		}
+		if sig == "" {
+			kept = append(kept, part)
+			continue
+		}
+		log.Printf("Message %s part %s infected: %s", msg.ID, part.ContentID, sig)
+		report.Infected = append(report.Infected, domain.ScanFinding{
+			ContentID:   part.ContentID,
+			ContentType: part.ContentType,
+			Signature:   sig,
+		})
+	}
+	msg.Inline = kept
+	msg.Scan = report
+	if len(report.Infected) == 0 {
+		return false
+	}
+	if w.cfg.ScanAction == "quarantine" {
+		report.Action = "quarantine"
+		return true
+	}
+	report.Action = "strip"
+	return false
+}
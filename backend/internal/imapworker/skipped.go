@@ -0,0 +1,74 @@
+package imapworker
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-message/mail"
+)
+
+// sampledHeaders are kept for skipped messages; bodies never are
+var sampledHeaders = []string{
+	"From", "To", "Cc", "Subject", "Date", "Message-Id",
+	"X-Forwarded-To", "Envelope-To", "X-Envelope-To", "X-Original-To", "Delivered-To",
+}
+
+// recipientHeaders are searched for recipient domains, as in extractRecipient
+var recipientHeaders = []string{"X-Forwarded-To", "Envelope-To", "X-Envelope-To", "X-Original-To", "Delivered-To", "To", "Cc"}
+
+// captureSkipped adds the headers of a message without an allowed
+// recipient to the rolling sample shown in the admin panel
+func (w *Worker) captureSkipped(ctx context.Context, folder string, uid uint32, raw []byte) {
+	if w.cfg.SkippedSampleMax <= 0 {
+		return
+	}
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+	header := mr.Header
+
+	sm := &domain.SkippedMessage{
+		Folder:    folder,
+		UID:       uid,
+		Headers:   make(map[string]string),
+		SkippedAt: time.Now(),
+	}
+	for _, key := range sampledHeaders {
+		if v := header.Get(key); v != "" {
+			sm.Headers[key] = v
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range recipientHeaders {
+		addrs, err := header.AddressList(key)
+		if err != nil || len(addrs) == 0 {
+			// Envelope headers often hold a bare address
+			if v := header.Get(key); v != "" {
+				addrs = []*mail.Address{{Address: w.extractEmailFromString(v)}}
+			}
+		}
+		for _, a := range addrs {
+			if addr, err := email.Parse(a.Address); err == nil && !seen[addr.Domain] {
+				seen[addr.Domain] = true
+				sm.Domains = append(sm.Domains, addr.Domain)
+			}
+		}
+	}
+	sort.Strings(sm.Domains)
+
+	sm.Reason = "no recipient on an allowed domain"
+	if len(sm.Domains) == 0 {
+		sm.Reason = "no parseable recipient address"
+	}
+
+	if err := w.store.SaveSkipped(ctx, sm, w.cfg.SkippedSampleMax); err != nil {
+		log.Printf("Failed to sample skipped message %d (%s): %v", uid, folder, err)
+	}
+}
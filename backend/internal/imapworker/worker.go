@@ -1,15 +1,25 @@
 package imapworker
 
 import (
+	"bytes"
 	"cattymail/internal/config"
+	"cattymail/internal/dnsbl"
 	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/privacy"
 	"cattymail/internal/redisstore"
+	"cattymail/internal/scan"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/emersion/go-imap"
@@ -21,22 +31,87 @@ import (
 type Worker struct {
 	cfg   *config.Config
 	store *redisstore.Store
+	// systemDomains is the env allowlist, kept apart from cfg.AllowedDomains
+	// so domains removed from Redis stop being accepted
+	systemDomains []string
+
+	// polls queues admin-requested cycles (see commands.go)
+	polls chan pollScope
+	// cycleMu serializes cycles with commands touching cursors or domains
+	cycleMu sync.Mutex
+	status  cycleStatus
+	// firstFolder rotates which folder a regular cycle starts with, so a
+	// capped backlog in one folder never keeps the others waiting
+	firstFolder int
+
+	// dnsbl is nil unless DNSBL_ZONES is set
+	dnsbl         *dnsbl.Checker
+	trustedRelays []*net.IPNet
+	// scanner is nil unless CLAMD_ADDR is set
+	scanner scan.Scanner
+}
+
+// cycleStatus is what the status command reports
+type cycleStatus struct {
+	mu          sync.Mutex
+	startedAt   time.Time
+	cycling     bool
+	lastCycleAt time.Time
+	lastError   string
+	domains     []string
+	// Per folder, messages fetched by the last cycle and UIDs it left for
+	// the next one (see POLL_BATCH_SIZE)
+	fetched map[string]int
+	backlog map[string]int
 }
 
 func New(cfg *config.Config, store *redisstore.Store) *Worker {
-	return &Worker{cfg: cfg, store: store}
+	trusted, err := dnsbl.ParseNets(cfg.DNSBLTrustedRelays)
+	if err != nil {
+		log.Printf("Ignoring DNSBL_TRUSTED_RELAYS: %v", err)
+	}
+	privacy.Configure(cfg.PrivacyMode, cfg.PrivacySalt)
+	w := &Worker{
+		cfg:           cfg,
+		store:         store,
+		systemDomains: cfg.AllowedDomains,
+		polls:         make(chan pollScope, 8),
+		dnsbl:         dnsbl.New(cfg.DNSBLZones, time.Duration(cfg.DNSBLTimeoutMs)*time.Millisecond),
+		trustedRelays: trusted,
+	}
+	if cfg.ClamdAddr != "" {
+		w.scanner = scan.NewClamd(cfg.ClamdAddr, time.Duration(cfg.ScanTimeoutMs)*time.Millisecond)
+	}
+	return w
+}
+
+// Folders are the IMAP folders polled each cycle
+var Folders = []string{"INBOX", "INBOX.spam", "INBOX.Junk"}
+
+// pollScope narrows a cycle triggered by an admin command; the zero value
+// is a regular cycle over every folder
+type pollScope struct {
+	folder string
+	// domain re-scans messages since since for one recipient domain
+	domain string
+	since  time.Time
 }
 
 func (w *Worker) Start(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(w.cfg.PollSeconds) * time.Second)
 	defer ticker.Stop()
 
+	go w.serveCommands(ctx)
+	go w.releaseHeld(ctx)
+	go w.cleanRead(ctx)
+
+	w.status.mu.Lock()
+	w.status.startedAt = time.Now()
+	w.status.mu.Unlock()
 	log.Println("IMAP Worker started")
 
 	// Initial run
-	if err := w.process(ctx); err != nil {
-		log.Printf("Error in IMAP process: %v", err)
-	}
+	w.runCycle(ctx, pollScope{})
 
 	for {
 		select {
@@ -44,46 +119,123 @@ func (w *Worker) Start(ctx context.Context) {
 			log.Println("IMAP Worker stopping...")
 			return
 		case <-ticker.C:
-			if err := w.process(ctx); err != nil {
-				log.Printf("Error in IMAP process: %v", err)
-			}
+			w.runCycle(ctx, pollScope{})
+		case scope := <-w.polls:
+			w.runCycle(ctx, scope)
 		}
 	}
 }
 
-func (w *Worker) process(ctx context.Context) error {
-	// We no longer refresh IMAP config from Redis.
-	// We will use the hardcoded/env config directly as requested by the user.
+// Health reports why the worker is unhealthy: its last cycle failed, or
+// none has completed for three poll intervals (at least five minutes)
+func (w *Worker) Health() error {
+	w.status.mu.Lock()
+	defer w.status.mu.Unlock()
 
-	// Refresh domains from Redis and merge with system domains
-	if customDomains, err := w.store.GetDomains(ctx); err == nil && len(customDomains) > 0 {
-		// Create a map to track unique domains
-		domainMap := make(map[string]bool)
+	if w.status.startedAt.IsZero() {
+		return errors.New("not started")
+	}
+	if w.status.lastError != "" {
+		return errors.New(w.status.lastError)
+	}
+	stale := 3 * time.Duration(w.cfg.PollSeconds) * time.Second
+	if stale < 5*time.Minute {
+		stale = 5 * time.Minute
+	}
+	last := w.status.lastCycleAt
+	if last.IsZero() {
+		last = w.status.startedAt
+	}
+	if since := time.Since(last); since > stale {
+		return fmt.Errorf("no completed cycle for %s", since.Round(time.Second))
+	}
+	return nil
+}
 
-		// Add system domains from ENV
-		for _, d := range w.cfg.AllowedDomains {
-			domainMap[d] = true
+// runCycle runs one poll cycle; a panic aborts the cycle, never the worker
+func (w *Worker) runCycle(ctx context.Context, scope pollScope) {
+	w.cycleMu.Lock()
+	defer w.cycleMu.Unlock()
+
+	w.status.mu.Lock()
+	w.status.cycling = true
+	w.status.mu.Unlock()
+
+	var err error
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Panic in IMAP process: %v\n%s", rec, debug.Stack())
+			err = fmt.Errorf("panic: %v", rec)
+		}
+		w.status.mu.Lock()
+		w.status.cycling = false
+		w.status.lastCycleAt = time.Now()
+		w.status.lastError = ""
+		if err != nil {
+			w.status.lastError = err.Error()
 		}
+		w.status.mu.Unlock()
+	}()
 
-		// Add custom domains from Redis
-		for _, d := range customDomains {
-			domainMap[d] = true
+	if err = w.process(ctx, scope); err != nil {
+		log.Printf("Error in IMAP process: %v", err)
+		return
+	}
+	if err := w.store.MarkIngestCycle(ctx); err != nil {
+		log.Printf("Failed to record ingest heartbeat: %v", err)
+	}
+	if scope == (pollScope{}) && w.hasBacklog() {
+		// Carry on with the remainder without waiting for the ticker
+		select {
+		case w.polls <- pollScope{}:
+		default:
 		}
+	}
+}
 
-		// Convert back to slice
-		var mergedDomains []string
-		for d := range domainMap {
-			mergedDomains = append(mergedDomains, d)
+// hasBacklog reports whether the last cycle left messages behind
+func (w *Worker) hasBacklog() bool {
+	w.status.mu.Lock()
+	defer w.status.mu.Unlock()
+	for _, n := range w.status.backlog {
+		if n > 0 {
+			return true
 		}
+	}
+	return false
+}
 
-		w.cfg.AllowedDomains = mergedDomains
-		log.Printf("Loaded domains: %v (system + custom from Redis)", w.cfg.AllowedDomains)
-	} else {
-		log.Printf("Using system domains only: %v", w.cfg.AllowedDomains)
+// recordProgress stores how far a cycle got through folder
+func (w *Worker) recordProgress(folder string, fetched, backlog int) {
+	w.status.mu.Lock()
+	defer w.status.mu.Unlock()
+	if w.status.fetched == nil {
+		w.status.fetched = make(map[string]int)
+		w.status.backlog = make(map[string]int)
+	}
+	w.status.fetched[folder] = fetched
+	w.status.backlog[folder] = backlog
+}
+
+func (w *Worker) process(ctx context.Context, scope pollScope) error {
+	// Leave mail on the server while Redis is too full to store it; the
+	// folder cursors stay put so nothing is skipped once memory frees up
+	if w.store.Pressure().Mode == redisstore.PressurePaused {
+		log.Println("Redis memory critical, ingestion paused")
+		return nil
 	}
 
+	// We no longer refresh IMAP config from Redis.
+	// We will use the hardcoded/env config directly as requested by the user.
+
+	if w.cfg.IMAPUser == "" || w.cfg.IMAPPass == "" {
+		return errors.New("IMAP_USER and IMAP_PASS are not set")
+	}
+
+	w.reloadDomains(ctx)
+
 	connStr := fmt.Sprintf("%s:%d", w.cfg.IMAPHost, w.cfg.IMAPPort)
-	c, err := client.DialTLS(connStr, &tls.Config{InsecureSkipVerify: true})
+	c, err := dialIMAP(connStr, &tls.Config{InsecureSkipVerify: true})
 	if err != nil {
 		return fmt.Errorf("failed to dial IMAP: %w", err)
 	}
@@ -93,10 +245,19 @@ func (w *Worker) process(ctx context.Context) error {
 		return fmt.Errorf("failed to login: %w", err)
 	}
 
-	// Process multiple folders: INBOX + spam folders
-	folders := []string{"INBOX", "INBOX.spam", "INBOX.Junk"}
+	// Process multiple folders: INBOX + spam folders, starting with a
+	// different one each regular cycle
+	folders := Folders
+	if scope == (pollScope{}) {
+		start := w.firstFolder % len(Folders)
+		folders = append(append([]string(nil), Folders[start:]...), Folders[:start]...)
+		w.firstFolder = start + 1
+	}
 	for _, folder := range folders {
-		if err := w.processFolder(ctx, c, folder); err != nil {
+		if scope.folder != "" && folder != scope.folder {
+			continue
+		}
+		if err := w.processFolder(ctx, c, folder, scope); err != nil {
 			log.Printf("Error processing folder %s: %v", folder, err)
 		}
 	}
@@ -104,7 +265,50 @@ func (w *Worker) process(ctx context.Context) error {
 	return nil
 }
 
-func (w *Worker) processFolder(ctx context.Context, c *client.Client, folder string) error {
+// reloadDomains refreshes cfg.AllowedDomains from Redis merged with the
+// system domains. Callers hold cycleMu.
+func (w *Worker) reloadDomains(ctx context.Context) {
+	customDomains, err := w.store.GetDomains(ctx)
+	if err != nil {
+		customDomains = nil
+	}
+	// Verified bring-your-own domains receive mail like any other
+	if userDomains, err := w.store.GetVerifiedUserDomains(ctx); err == nil {
+		customDomains = append(customDomains, userDomains...)
+	}
+	if len(customDomains) > 0 {
+		// Create a map to track unique domains
+		domainMap := make(map[string]bool)
+
+		// Add system domains from ENV
+		for _, d := range w.systemDomains {
+			domainMap[d] = true
+		}
+
+		// Add custom domains from Redis
+		for _, d := range customDomains {
+			domainMap[email.CanonicalDomain(d)] = true
+		}
+
+		// Convert back to slice
+		var mergedDomains []string
+		for d := range domainMap {
+			mergedDomains = append(mergedDomains, d)
+		}
+
+		w.cfg.AllowedDomains = mergedDomains
+		log.Printf("Loaded domains: %v (system + custom from Redis)", w.cfg.AllowedDomains)
+	} else {
+		w.cfg.AllowedDomains = w.systemDomains
+		log.Printf("Using system domains only: %v", w.cfg.AllowedDomains)
+	}
+
+	w.status.mu.Lock()
+	w.status.domains = w.cfg.AllowedDomains
+	w.status.mu.Unlock()
+}
+
+func (w *Worker) processFolder(ctx context.Context, c *client.Client, folder string, scope pollScope) error {
 	mbox, err := c.Select(folder, false)
 	if err != nil {
 		// Folder might not exist, that's OK — but log it
@@ -132,6 +336,11 @@ func (w *Worker) processFolder(ctx context.Context, c *client.Client, folder str
 
 	// Default to lastUID mapping
 	from := lastUID + 1
+	if scope.domain != "" {
+		// Re-scan: look behind the cursor for mail that was skipped
+		searchCrit.Since = scope.since
+		from = 1
+	}
 	var uids []uint32
 
 	// Search the folder for matching UIDs
@@ -148,9 +357,58 @@ func (w *Worker) processFolder(ctx context.Context, c *client.Client, folder str
 	}
 
 	if len(uids) == 0 {
+		w.recordProgress(folder, 0, 0)
 		return nil // No new messages to process
 	}
 
+	// Take the oldest batch; the cursor stops at the last one taken so the
+	// rest is picked up next cycle. Re-scans are admin-driven and not capped.
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	backlog := 0
+	if batch := w.cfg.PollBatchSize; batch > 0 && len(uids) > batch && scope.domain == "" {
+		backlog = len(uids) - batch
+		uids = uids[:batch]
+		log.Printf("Folder %s: taking %d messages this cycle, %d left for later", folder, batch, backlog)
+	}
+	w.recordProgress(folder, len(uids), backlog)
+
+	// Fetch in chunks so huge UID sets stay within server limits. The
+	// cursor is committed after every chunk, but never past one that
+	// failed: later chunks are still ingested, and the next cycle re-fetches
+	// from the failed one, skipping what was stored meanwhile.
+	chunk := w.cfg.IMAPFetchChunk
+	if chunk <= 0 {
+		chunk = len(uids)
+	}
+	cursor := lastUID
+	var failed int
+	var lastErr error
+	for i := 0; i < len(uids); i += chunk {
+		part := uids[i:min(i+chunk, len(uids))]
+		maxUID, err := w.fetchChunk(ctx, c, folder, part, scope)
+		if err != nil {
+			failed++
+			lastErr = err
+			log.Printf("Fetch of UIDs %d-%d in %s failed: %v", part[0], part[len(part)-1], folder, err)
+			continue
+		}
+		if failed > 0 || scope.domain != "" || maxUID <= cursor {
+			continue
+		}
+		cursor = maxUID
+		if err := w.store.SetFolderLastUID(ctx, uidKey, cursor); err != nil {
+			log.Printf("Failed to update last UID for %s: %v", folder, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("fetch %s: %d of %d chunks failed, last: %w", folder, failed, (len(uids)+chunk-1)/chunk, lastErr)
+	}
+	return nil
+}
+
+// fetchChunk fetches and ingests one set of UIDs, returning the highest
+// UID fetched
+func (w *Worker) fetchChunk(ctx context.Context, c *client.Client, folder string, uids []uint32, scope pollScope) (uint32, error) {
 	seqSet := new(imap.SeqSet)
 	seqSet.AddNum(uids...)
 
@@ -158,17 +416,16 @@ func (w *Worker) processFolder(ctx context.Context, c *client.Client, folder str
 	done := make(chan error, 1)
 
 	section := &imap.BodySectionName{}
-	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchUid, imap.FetchInternalDate, section.FetchItem()}
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchUid, imap.FetchInternalDate, imap.FetchRFC822Size, section.FetchItem()}
 
 	go func() {
 		done <- c.UidFetch(seqSet, items, messages)
 	}()
 
-	var newMaxUID uint32 = lastUID
-
+	var maxUID uint32
 	for msg := range messages {
-		if msg.Uid > newMaxUID {
-			newMaxUID = msg.Uid
+		if msg.Uid > maxUID {
+			maxUID = msg.Uid
 		}
 
 		processed, err := w.store.IsUIDProcessed(ctx, folder, msg.Uid)
@@ -180,70 +437,189 @@ func (w *Worker) processFolder(ctx context.Context, c *client.Client, folder str
 			continue
 		}
 
-		if err := w.ingestMessage(ctx, msg, section, folder); err != nil {
+		if err := w.ingestMessage(ctx, msg, section, folder, scope.domain); err != nil {
 			log.Printf("Failed to ingest message %d (%s): %v", msg.Uid, folder, err)
 		}
 	}
 
-	if err := <-done; err != nil {
-		return fmt.Errorf("fetch %s failed: %w", folder, err)
-	}
+	return maxUID, <-done
+}
 
-	if newMaxUID > lastUID {
-		if err := w.store.SetFolderLastUID(ctx, uidKey, newMaxUID); err != nil {
-			log.Printf("Failed to update last UID for %s: %v", folder, err)
+// ingestMessage parses and stores one fetched message. Panics are recovered
+// here so a single malformed email can't take down the cycle; the offending
+// raw message goes to the dead-letter store instead. Every outcome is
+// counted in the ingestion statistics. With onlyDomain set (a re-scan),
+// messages for other domains are passed over without being counted.
+func (w *Worker) ingestMessage(ctx context.Context, msg *imap.Message, section *imap.BodySectionName, folder, onlyDomain string) (err error) {
+	var bodyBytes []byte
+	outcome, recipDomain := redisstore.IngestErrored, ""
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Panic ingesting message %d (%s): %v\n%s", msg.Uid, folder, rec, debug.Stack())
+			err = fmt.Errorf("panic: %v", rec)
+			w.deadLetter(ctx, folder, msg.Uid, err.Error(), bodyBytes)
+			outcome = redisstore.IngestErrored
 		}
-	}
+		if outcome == "" {
+			return
+		}
+		if serr := w.store.RecordIngest(ctx, folder, recipDomain, outcome); serr != nil {
+			log.Printf("Failed to record ingest stats for %d (%s): %v", msg.Uid, folder, serr)
+		}
+		if outcome == redisstore.IngestIngested && recipDomain != "" {
+			if serr := w.store.RecordDomainHourly(ctx, recipDomain); serr != nil {
+				log.Printf("Failed to record hourly volume of %s: %v", recipDomain, serr)
+			}
+		}
+	}()
 
-	return nil
-}
+	// The server reports the size up front; skip oversized mail without
+	// reading it at all
+	if msg.Size > 0 && int64(msg.Size) > int64(w.cfg.MaxEmailBytes) {
+		log.Printf("Message %d too large: %d bytes", msg.Uid, msg.Size)
+		outcome = redisstore.IngestDropped
+		return nil
+	}
 
-func (w *Worker) ingestMessage(ctx context.Context, msg *imap.Message, section *imap.BodySectionName, folder string) error {
 	r := msg.GetBody(section)
 	if r == nil {
 		return fmt.Errorf("server didn't return message body")
 	}
 
-	// Create a buffered reader to check size without reading everything if possible,
-	// or just read all. `go-message` parses from reader.
-	// To check size, we can read all bytes.
-	bodyBytes, err := io.ReadAll(r)
+	// Read at most one byte past the limit, so a server that reported no
+	// (or a wrong) size still cannot make us buffer more than that
+	bodyBytes, err = io.ReadAll(io.LimitReader(r, int64(w.cfg.MaxEmailBytes)+1))
 	if err != nil {
 		return fmt.Errorf("failed to read body: %w", err)
 	}
 
 	if len(bodyBytes) > w.cfg.MaxEmailBytes {
-		log.Printf("Message %d too large: %d bytes", msg.Uid, len(bodyBytes))
+		log.Printf("Message %d too large: more than %d bytes", msg.Uid, w.cfg.MaxEmailBytes)
+		bodyBytes = nil
+		outcome = redisstore.IngestDropped
 		return nil
 	}
 
-	mr, err := mail.CreateReader(strings.NewReader(string(bodyBytes)))
+	dbMsg, err := w.parseMessage(msg.Uid, bodyBytes, msg.InternalDate)
 	if err != nil {
-		return fmt.Errorf("failed to create mail reader: %w", err)
+		w.deadLetter(ctx, folder, msg.Uid, err.Error(), bodyBytes)
+		return err
+	}
+	if onlyDomain != "" && (dbMsg == nil || dbMsg.Domain != onlyDomain) {
+		outcome = ""
+		return nil
+	}
+	if dbMsg == nil {
+		outcome = redisstore.IngestSkipped
+		w.captureSkipped(ctx, folder, msg.Uid, bodyBytes)
+		return nil // Skipped
+	}
+	dbMsg.IMAPUID = msg.Uid
+	dbMsg.IMAPFolder = folder
+	recipDomain = dbMsg.Domain
+	if w.store.DomainState(ctx, dbMsg.Domain) == domain.DomainRetired {
+		outcome = redisstore.IngestSkipped
+		return nil
+	}
+	if w.store.IsBlocked(ctx, dbMsg.Domain, dbMsg.Local) {
+		outcome = redisstore.IngestSkipped
+		return nil
+	}
+	if _, frozen := w.store.FrozenReason(ctx, dbMsg.Domain, dbMsg.Local); frozen {
+		// Kept as evidence where only admins can reach it
+		if err := w.store.QuarantineIncoming(ctx, dbMsg); err != nil {
+			return err
+		}
+		outcome = redisstore.IngestIngested
+		return nil
+	}
+	if w.dnsbl != nil && dbMsg.SenderIP != "" {
+		dbMsg.DNSBL = w.dnsbl.Check(ctx, net.ParseIP(dbMsg.SenderIP))
+	}
+	if diverted, err := w.divertHoneypot(ctx, dbMsg); diverted {
+		if err != nil {
+			return err
+		}
+		outcome = redisstore.IngestIngested
+		return nil
+	}
+	w.honeypotRelay(ctx, dbMsg)
+
+	inboxFilters := w.inboxFilters(ctx, dbMsg)
+	if w.scanParts(ctx, dbMsg) || w.filtered(inboxFilters, dbMsg) {
+		if err := w.store.QuarantineIncoming(ctx, dbMsg); err != nil {
+			return err
+		}
+		outcome = redisstore.IngestIngested
+		return nil
+	}
+	rules := applyRules(inboxFilters, dbMsg)
+	if rules.Drop {
+		outcome = redisstore.IngestDropped
+		return w.store.MarkUIDProcessed(ctx, folder, msg.Uid)
+	}
+
+	tokens := w.prescore(ctx, dbMsg)
+
+	if w.greylist(ctx, dbMsg) {
+		w.trainHam(ctx, tokens)
+		outcome = redisstore.IngestIngested
+		return nil
+	}
+
+	err = w.store.SaveMessage(ctx, dbMsg)
+	switch {
+	case err == nil:
+		w.trainHam(ctx, tokens)
+		w.pinEvent(ctx, dbMsg)
+		w.recordDelivery(ctx, msg.InternalDate)
+		w.recordProvider(ctx, dbMsg)
+		w.checkBurned(ctx, dbMsg)
+		w.ruleHooks(ctx, dbMsg, rules.Webhooks)
+		outcome = redisstore.IngestIngested
+	case errors.Is(err, redisstore.ErrMessageTooLarge), errors.Is(err, redisstore.ErrQuotaExceeded):
+		outcome = redisstore.IngestDropped
+	}
+	return err
+}
+
+// Parse runs raw through the same parser the ingestor uses, for callers
+// that receive messages outside IMAP (e.g. the admin injection API).
+// It returns nil if no recipient matches cfg.AllowedDomains.
+func Parse(cfg *config.Config, raw []byte, received time.Time) (*domain.Message, error) {
+	return New(cfg, nil).parseMessage(0, raw, received)
+}
+
+// parseMessage turns a raw RFC 822 message into a domain.Message addressed to
+// one of the allowed domains. It returns nil if no valid recipient is found.
+func (w *Worker) parseMessage(uid uint32, bodyBytes []byte, internalDate time.Time) (*domain.Message, error) {
+	mr, err := mail.CreateReader(bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail reader: %w", err)
 	}
 
 	header := mr.Header
 
 	// Debug: Log all headers to understand what we're receiving
-	log.Printf("Processing message %d - Headers available:", msg.Uid)
+	log.Printf("Processing message %d - Headers available:", uid)
 	for key := range header.Map() {
-		log.Printf("  %s: %s", key, header.Get(key))
+		log.Printf("  %s: %s", key, privacy.Text(header.Get(key)))
 	}
 
 	// Header parsing
 	originalTo := w.extractRecipient(header)
 	if originalTo == "" {
-		log.Printf("Message %d skipped: No valid recipient found in headers (allowed domains: %v)", msg.Uid, w.cfg.AllowedDomains)
-		return nil
+		log.Printf("Message %d skipped: No valid recipient found in headers (allowed domains: %v)", uid, w.cfg.AllowedDomains)
+		return nil, nil
 	}
-	log.Printf("Message %d - Extracted recipient: %s", msg.Uid, originalTo)
+	log.Printf("Message %d - Extracted recipient: %s", uid, privacy.Address(originalTo))
 
-	recipParts := strings.Split(originalTo, "@")
-	if len(recipParts) != 2 {
-		return nil
+	recipient, err := email.Parse(originalTo)
+	if err != nil {
+		return nil, nil
 	}
-	recipLocal := recipParts[0]
-	recipDomain := recipParts[1]
+	recipLocal := recipient.Local
+	recipDomain := recipient.Domain
 
 	// We blindly reserve/create if getting email (Catch-All logic)
 	// But per requirements, check if specific handling needed.
@@ -251,9 +627,11 @@ func (w *Worker) ingestMessage(ctx context.Context, msg *imap.Message, section *
 	// We'll create the inbox implicitly by storing.
 
 	fromList, err := header.AddressList("From")
-	from := ""
+	from, fromName, fromAddress := "", "", ""
 	if err == nil && len(fromList) > 0 {
 		from = fromList[0].String()
+		fromName = fromList[0].Name
+		_, fromAddress = email.SplitFrom(fromList[0].Address)
 	}
 
 	subject, err := header.Subject()
@@ -263,12 +641,64 @@ func (w *Worker) ingestMessage(ctx context.Context, msg *imap.Message, section *
 
 	date, err := header.Date()
 	if err != nil {
-		date = msg.InternalDate
+		date = internalDate
+	}
+
+	senderIP := ""
+	if ip := dnsbl.SendingIP(header.Values("Received"), w.trustedRelays); ip != nil {
+		senderIP = ip.String()
+	}
+
+	textBody, htmlBody, inline := readBodies(mr)
+
+	messageID := ulid.Make().String()
+
+	dbMsg := &domain.Message{
+		ID:          messageID,
+		Domain:      recipDomain,
+		Local:       recipLocal,
+		OriginalTo:  originalTo,
+		From:        from,
+		FromName:    fromName,
+		FromAddress: fromAddress,
+		Subject:     subject,
+		Date:        date,
+		Text:        textBody,
+		HTML:        htmlBody,
+		Size:        len(bodyBytes),
+		Inline:      inline,
+		SenderIP:    senderIP,
+		Headers:     curatedHeaders(header),
+	}
+
+	return dbMsg, nil
+}
+
+// deadLetter records a raw message that could not be ingested
+func (w *Worker) deadLetter(ctx context.Context, folder string, uid uint32, reason string, raw []byte) {
+	dl := &domain.DeadLetter{
+		ID:       ulid.Make().String(),
+		Folder:   folder,
+		UID:      uid,
+		Reason:   reason,
+		Size:     len(raw),
+		FailedAt: time.Now(),
+		Raw:      string(raw),
+	}
+	if err := w.store.SaveDeadLetter(ctx, dl); err != nil {
+		log.Printf("Failed to dead-letter message %d (%s): %v", uid, folder, err)
 	}
+}
 
-	var textBody, htmlBody string
+// maxInlineBytes caps the total size of inline images kept per message
+const maxInlineBytes = 2 << 20
 
-	// Process parts
+// readBodies walks the MIME parts of mr and concatenates the inline
+// text/plain and text/html bodies. Images with a Content-ID are kept so the
+// HTML can reference them as cid: URLs. Malformed parts end the walk early.
+func readBodies(mr *mail.Reader) (textBody, htmlBody string, inline []domain.InlinePart) {
+	var text, html strings.Builder
+	inlineBytes := 0
 	for {
 		p, err := mr.NextPart()
 		if err == io.EOF {
@@ -278,37 +708,39 @@ func (w *Worker) ingestMessage(ctx context.Context, msg *imap.Message, section *
 			break
 		}
 
+		var t string
 		switch h := p.Header.(type) {
 		case *mail.InlineHeader:
+			t, _, _ = h.ContentType()
+		case *mail.AttachmentHeader:
+			t, _, _ = h.ContentType()
+		}
+
+		if strings.HasPrefix(t, "image/") {
+			cid := strings.Trim(p.Header.Get("Content-Id"), "<> ")
+			if cid == "" {
+				continue
+			}
+			b, _ := io.ReadAll(io.LimitReader(p.Body, maxInlineBytes+1))
+			if inlineBytes+len(b) > maxInlineBytes {
+				continue
+			}
+			inlineBytes += len(b)
+			inline = append(inline, domain.InlinePart{ContentID: cid, ContentType: t, Data: b})
+			continue
+		}
+
+		if _, ok := p.Header.(*mail.InlineHeader); ok {
 			// This is the header for this part
 			// We can read the body
-			b, _ := io.ReadAll(p.Body)
-			t, _, _ := h.ContentType()
 			if t == "text/plain" {
-				textBody += string(b)
+				io.Copy(&text, p.Body)
 			} else if t == "text/html" {
-				htmlBody += string(b)
+				io.Copy(&html, p.Body)
 			}
 		}
 	}
-
-	messageID := ulid.Make().String()
-
-	dbMsg := &domain.Message{
-		ID:         messageID,
-		Domain:     recipDomain,
-		Local:      recipLocal,
-		OriginalTo: originalTo,
-		From:       from,
-		Subject:    subject,
-		Date:       date,
-		Text:       textBody,
-		HTML:       htmlBody,
-		IMAPUID:    msg.Uid,
-		IMAPFolder: folder,
-	}
-
-	return w.store.SaveMessage(ctx, dbMsg)
+	return text.String(), html.String(), inline
 }
 
 func (w *Worker) extractRecipient(h mail.Header) string {
@@ -318,11 +750,11 @@ func (w *Worker) extractRecipient(h mail.Header) string {
 	sysHeaders := []string{"X-Forwarded-To", "Envelope-To", "X-Envelope-To", "X-Original-To", "Delivered-To", "To"}
 	for _, key := range sysHeaders {
 		if val := h.Get(key); val != "" {
-			log.Printf("  Checking header %s: %s", key, val)
-			email := w.extractEmailFromString(val)
-			if email != "" && w.isValidDomainEmail(email) {
-				log.Printf("  ✓ Found valid recipient in %s: %s", key, email)
-				return w.normalizeEmail(email)
+			log.Printf("  Checking header %s: %s", key, privacy.Text(val))
+			candidate := w.extractEmailFromString(val)
+			if candidate != "" && w.isValidDomainEmail(candidate) {
+				log.Printf("  ✓ Found valid recipient in %s: %s", key, privacy.Address(candidate))
+				return w.normalizeEmail(candidate)
 			}
 		}
 	}
@@ -330,9 +762,9 @@ func (w *Worker) extractRecipient(h mail.Header) string {
 	// Try To header as fallback
 	toList, _ := h.AddressList("To")
 	for _, addr := range toList {
-		log.Printf("  Checking To address: %s", addr.Address)
+		log.Printf("  Checking To address: %s", privacy.Address(addr.Address))
 		if w.isValidDomainEmail(addr.Address) {
-			log.Printf("  ✓ Found valid recipient in To: %s", addr.Address)
+			log.Printf("  ✓ Found valid recipient in To: %s", privacy.Address(addr.Address))
 			return w.normalizeEmail(addr.Address)
 		}
 	}
@@ -359,20 +791,23 @@ func (w *Worker) extractEmailFromString(s string) string {
 	return s
 }
 
-func (w *Worker) isValidDomainEmail(email string) bool {
-	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
+func (w *Worker) isValidDomainEmail(address string) bool {
+	addr, err := email.Parse(address)
+	if err != nil {
 		return false
 	}
-	domain := strings.ToLower(strings.TrimSpace(parts[1]))
 	for _, d := range w.cfg.AllowedDomains {
-		if domain == d {
+		if allowed, err := email.NormalizeDomain(d); err == nil && addr.Domain == allowed {
 			return true
 		}
 	}
 	return false
 }
 
-func (w *Worker) normalizeEmail(email string) string {
-	return strings.ToLower(strings.TrimSpace(email))
+func (w *Worker) normalizeEmail(address string) string {
+	addr, err := email.Parse(address)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(address))
+	}
+	return addr.String()
 }
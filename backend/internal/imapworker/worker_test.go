@@ -0,0 +1,160 @@
+package imapworker
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cattymail/internal/config"
+
+	"github.com/emersion/go-message/mail"
+)
+
+const plainMessage = "From: GitHub <noreply@github.com>\r\n" +
+	"X-Forwarded-To: budi12345@catty.my.id\r\n" +
+	"To: budi12345@catty.my.id\r\n" +
+	"Subject: [GitHub] Please verify your device\r\n" +
+	"Date: Mon, 02 Feb 2026 10:00:00 +0000\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Verification code: 482913\r\n"
+
+func multipartMessage(htmlSize int) string {
+	html := "<html><body><p>Your code is <b>482913</b></p>" +
+		strings.Repeat("<p>Lorem ipsum dolor sit amet, consectetur adipiscing elit.</p>", htmlSize/64+1) +
+		"</body></html>"
+
+	return "From: \"Discord\" <noreply@discord.com>\r\n" +
+		"Delivered-To: catchall@gmail.com\r\n" +
+		"X-Original-To: sari77821@cattyprems.top\r\n" +
+		"To: sari77821@cattyprems.top\r\n" +
+		"Subject: =?UTF-8?B?VmVyaWZ5IEVtYWlsIEFkZHJlc3M=?=\r\n" +
+		"Date: Mon, 02 Feb 2026 10:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Your code is 482913\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		html + "\r\n" +
+		"--b1--\r\n"
+}
+
+func benchWorker() *Worker {
+	return New(&config.Config{
+		AllowedDomains: []string{"catty.my.id", "cattyprems.top"},
+		MaxEmailBytes:  5242880,
+	}, nil)
+}
+
+func BenchmarkParseMessage(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	w := benchWorker()
+	cases := []struct {
+		name string
+		raw  []byte
+	}{
+		{"plain", []byte(plainMessage)},
+		{"multipart-4KB", []byte(multipartMessage(4 << 10))},
+		{"multipart-512KB", []byte(multipartMessage(512 << 10))},
+	}
+
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tc.raw)))
+			for i := 0; i < b.N; i++ {
+				msg, err := w.parseMessage(uint32(i), tc.raw, time.Now())
+				if err != nil || msg == nil {
+					b.Fatalf("parse failed: msg=%v err=%v", msg, err)
+				}
+			}
+		})
+	}
+}
+
+func TestIsValidDomainEmail(t *testing.T) {
+	w := New(&config.Config{AllowedDomains: []string{"Catty.My.Id", "bücher.de"}}, nil)
+	cases := []struct {
+		address string
+		want    bool
+	}{
+		{"budi12345@catty.my.id", true},
+		{"Budi12345@CATTY.MY.ID", true},
+		{"budi+github@catty.my.id", true},
+		{`"budi santoso"@catty.my.id`, true},
+		{"sari@bücher.de", true},
+		{"sari@xn--bcher-kva.de", true},
+		{"budi@catty.my.id.", true},
+		{"budi@sub.catty.my.id", false},
+		{"budi@catty.my.id.evil.com", false},
+		{"budi@evilcatty.my.id", false},
+		{"catty.my.id", false},
+		{"@catty.my.id", false},
+		{"a@b@catty.my.id", false},
+		{"bu di@catty.my.id", false},
+		{"<budi@catty.my.id>", false},
+		{"", false},
+	}
+
+	for _, tc := range cases {
+		if got := w.isValidDomainEmail(tc.address); got != tc.want {
+			t.Errorf("isValidDomainEmail(%q) = %v, want %v", tc.address, got, tc.want)
+		}
+	}
+}
+
+func TestExtractRecipient(t *testing.T) {
+	quietLogs(t)
+	w := New(&config.Config{AllowedDomains: []string{"catty.my.id", "bücher.de"}}, nil)
+	cases := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{"forwarded-to wins", map[string]string{
+			"X-Forwarded-To": "budi@catty.my.id",
+			"To":             "sari@catty.my.id",
+		}, "budi@catty.my.id"},
+		{"skips foreign delivered-to", map[string]string{
+			"Delivered-To": "catchall@gmail.com",
+			"To":           `"Sari" <Sari+Shop@Catty.My.Id>`,
+		}, "sari+shop@catty.my.id"},
+		{"idn domain", map[string]string{
+			"X-Original-To": "<Sari@BÜCHER.de>",
+		}, "sari@xn--bcher-kva.de"},
+		{"quoted local", map[string]string{
+			"Envelope-To": `"john doe"@catty.my.id`,
+		}, `"john doe"@catty.my.id`},
+		{"second to address", map[string]string{
+			"To": "other@gmail.com, budi@catty.my.id",
+		}, "budi@catty.my.id"},
+		{"only foreign", map[string]string{
+			"To": "budi@catty.my.id.evil.com",
+		}, ""},
+		{"malformed", map[string]string{
+			"X-Forwarded-To": "a@b@catty.my.id",
+			"To":             "<@catty.my.id>",
+		}, ""},
+	}
+
+	for _, tc := range cases {
+		var h mail.Header
+		for k, v := range tc.header {
+			h.Set(k, v)
+		}
+		if got := w.extractRecipient(h); got != tc.want {
+			t.Errorf("%s: extractRecipient = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
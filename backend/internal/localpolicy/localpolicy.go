@@ -0,0 +1,175 @@
+// Package localpolicy applies the per-domain rules on the local parts users
+// pick for custom addresses: length bounds, banned substrings, required
+// prefixes and localized profanity lists.
+package localpolicy
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cattymail/internal/domain"
+)
+
+// Default length bounds, used when a policy leaves them unset
+const (
+	DefaultMinLength = 3
+	DefaultMaxLength = 31
+	// MaxLength is the longest local part RFC 5321 allows
+	MaxLength = 64
+)
+
+// charset is what every local must look like regardless of policy: short,
+// URL-safe, starting with a letter or digit
+var charset = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Violation describes why a local cannot be used
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+//go:embed wordlists/*.txt
+var wordlistFiles embed.FS
+
+// wordlists are the profanity lists by language, loaded once
+var wordlists = loadWordlists()
+
+func loadWordlists() map[string][]string {
+	lists := make(map[string][]string)
+	entries, _ := wordlistFiles.ReadDir("wordlists")
+	for _, e := range entries {
+		f, err := wordlistFiles.Open("wordlists/" + e.Name())
+		if err != nil {
+			continue
+		}
+		lang := strings.TrimSuffix(e.Name(), ".txt")
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if w := strings.TrimSpace(sc.Text()); w != "" && !strings.HasPrefix(w, "#") {
+				lists[lang] = append(lists[lang], strings.ToLower(w))
+			}
+		}
+		f.Close()
+	}
+	return lists
+}
+
+// Languages returns the languages with a profanity wordlist
+func Languages() []string {
+	langs := make([]string, 0, len(wordlists))
+	for lang := range wordlists {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Validate checks that p is a usable policy
+func Validate(p *domain.LocalPolicy) error {
+	if p.MinLength < 0 || p.MaxLength < 0 || p.MinLength > MaxLength || p.MaxLength > MaxLength {
+		return fmt.Errorf("lengths must be between 1 and %d", MaxLength)
+	}
+	if p.MinLength > 0 && p.MaxLength > 0 && p.MinLength > p.MaxLength {
+		return fmt.Errorf("min_length must not exceed max_length")
+	}
+	for _, lang := range p.Profanity {
+		if _, ok := wordlists[lang]; !ok {
+			return fmt.Errorf("no profanity wordlist for %q (have %s)", lang, strings.Join(Languages(), ", "))
+		}
+	}
+	for _, prefix := range p.RequiredPrefixes {
+		if prefix == "" || !charset.MatchString(prefix+"x") {
+			return fmt.Errorf("invalid required prefix %q", prefix)
+		}
+	}
+	return nil
+}
+
+// Check returns the rules of p that local (already normalized) breaks. A
+// nil policy applies the defaults only.
+func Check(local string, p *domain.LocalPolicy) []Violation {
+	var violations []Violation
+	if !charset.MatchString(local) {
+		violations = append(violations, Violation{"invalid_format", "Username may only contain letters, digits, dots, dashes and underscores, and must start with a letter or digit."})
+	}
+
+	min, max := DefaultMinLength, DefaultMaxLength
+	if p != nil && p.MinLength > 0 {
+		min = p.MinLength
+	}
+	if p != nil && p.MaxLength > 0 {
+		max = p.MaxLength
+	}
+	if n := len(local); n < min {
+		violations = append(violations, Violation{"too_short", fmt.Sprintf("Username must be at least %d characters.", min)})
+	} else if n > max {
+		violations = append(violations, Violation{"too_long", fmt.Sprintf("Username must be at most %d characters.", max)})
+	}
+	if p == nil {
+		return violations
+	}
+
+	for _, banned := range p.BannedSubstrings {
+		if banned != "" && strings.Contains(local, strings.ToLower(banned)) {
+			violations = append(violations, Violation{"banned_substring", fmt.Sprintf("Username must not contain %q.", banned)})
+			break
+		}
+	}
+	if len(p.RequiredPrefixes) > 0 {
+		ok := false
+		for _, prefix := range p.RequiredPrefixes {
+			if strings.HasPrefix(local, strings.ToLower(prefix)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			violations = append(violations, Violation{"missing_prefix", "Username must start with " + strings.Join(quoted(p.RequiredPrefixes), " or ") + "."})
+		}
+	}
+	if profane(local, p.Profanity) {
+		violations = append(violations, Violation{"profanity", "Username contains a word that is not allowed."})
+	}
+	return violations
+}
+
+func quoted(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = fmt.Sprintf("%q", s)
+	}
+	return out
+}
+
+// leet folds common look-alike digits and symbols back to letters
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "$", "s", "@", "a")
+
+// profane reports whether local contains a word of the wordlists of langs.
+// Words of five letters or more match anywhere, after separators are
+// dropped; shorter ones only as a whole dot, dash or underscore separated
+// token, so "tai" does not flag "detail".
+func profane(local string, langs []string) bool {
+	folded := leet.Replace(local)
+	tokens := strings.FieldsFunc(folded, func(r rune) bool { return r == '.' || r == '-' || r == '_' })
+	joined := strings.Join(tokens, "")
+	for _, lang := range langs {
+		for _, word := range wordlists[lang] {
+			if len(word) >= 5 {
+				if strings.Contains(joined, word) {
+					return true
+				}
+				continue
+			}
+			for _, t := range tokens {
+				if t == word {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
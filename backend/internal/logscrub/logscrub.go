@@ -0,0 +1,66 @@
+// Package logscrub keeps secrets and personal data out of the process logs.
+// Install routes the standard logger through Scrub, which masks addresses
+// and blanks credentials, mail subjects and one-time codes, so a forgotten
+// debug line cannot leak them.
+package logscrub
+
+import (
+	"io"
+	"log"
+	"regexp"
+
+	"cattymail/internal/privacy"
+)
+
+// Redacted replaces scrubbed values
+const Redacted = "[redacted]"
+
+var (
+	// headerField matches headers whose whole value is sensitive, as dumped
+	// by "Name: value" log lines
+	headerField = regexp.MustCompile(`(?i)\b(authorization|proxy-authorization|cookie|set-cookie|x-api-key|subject)(:\s*)[^\r\n]+`)
+	// keyValue matches sensitive keys in query strings, JSON and key=value
+	// pairs
+	keyValue = regexp.MustCompile(`(?i)("?\b(?:password|passwd|secret|token|access_token|refresh_token|api_key|apikey|otp)"?\s*[=:]\s*"?)([^\s"&,;}]+)`)
+	// bearer matches credentials after an auth scheme
+	bearer = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+	// jwt matches JSON web tokens wherever they appear
+	jwt = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// codeNear matches a one-time code shortly after a word announcing
+	// one, as the otp package finds them
+	codeNear = regexp.MustCompile(`(?i)(\b(?:code|kode|otp|pin|passcode|verification|verifikasi|one-time)[^0-9\n]{0,40}?)\b([0-9]{4,8}|[0-9]{3}[- ][0-9]{3})\b`)
+)
+
+// Scrub returns line with its sensitive parts masked
+func Scrub(line string) string {
+	line = headerField.ReplaceAllString(line, "${1}${2}"+Redacted)
+	line = bearer.ReplaceAllString(line, "${1} "+Redacted)
+	line = jwt.ReplaceAllString(line, Redacted)
+	line = keyValue.ReplaceAllString(line, "${1}"+Redacted)
+	line = codeNear.ReplaceAllString(line, "${1}"+Redacted)
+	return privacy.Redact(line)
+}
+
+type writer struct {
+	w io.Writer
+}
+
+// Writer scrubs everything written to w. The standard logger writes a
+// line at a time.
+func Writer(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+func (s *writer) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(s.w, Scrub(string(b))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Install scrubs the output of the standard logger when enabled (LOG_REDACT)
+func Install(enabled bool) {
+	if enabled {
+		log.SetOutput(Writer(log.Writer()))
+	}
+}
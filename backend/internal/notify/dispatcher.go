@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Dispatcher follows the ingest feed and posts summaries to every channel
+// configured for the receiving inbox or its domain, and sends Web Push
+// notifications to browsers subscribed to the inbox. Run it in one process
+// only (the ingestor), otherwise each message is announced once per
+// subscriber.
+type Dispatcher struct {
+	cfg    *config.Config
+	store  *redisstore.Store
+	client *http.Client
+}
+
+// NewDispatcher returns a dispatcher posting through a short-timeout client
+func NewDispatcher(cfg *config.Config, store *redisstore.Store) *Dispatcher {
+	return &Dispatcher{
+		cfg:   cfg,
+		store: store,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: PublicTransport(),
+			// Webhook hosts never redirect legitimately
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run blocks until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	sub := d.store.SubscribeIngestFeed(ctx)
+	defer sub.Close()
+
+	log.Println("Notification dispatcher started")
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var ev domain.IngestEvent
+			if err := json.Unmarshal([]byte(m.Payload), &ev); err != nil || ev.Inbox == "" {
+				continue
+			}
+			go d.dispatch(ctx, &ev)
+			go d.push(ctx, &ev)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, ev *domain.IngestEvent) {
+	_, emailDomain, _ := strings.Cut(ev.Inbox, "@")
+
+	var channels []*domain.NotifyChannel
+	for _, t := range []struct{ scope, target string }{
+		{ScopeInbox, ev.Inbox},
+		{ScopeDomain, emailDomain},
+	} {
+		found, err := d.store.ListNotifyChannels(ctx, t.scope, t.target)
+		if err != nil {
+			log.Printf("notify: failed to load %s channels for %s: %v", t.scope, privacy.Address(t.target), err)
+			continue
+		}
+		channels = append(channels, found...)
+	}
+
+	for _, ch := range channels {
+		allowed, err := d.store.RateLimit(ctx, ch.ID, "notify", d.cfg.NotifyMaxPerMin, time.Minute)
+		if err != nil || !allowed {
+			continue
+		}
+		post := d.post
+		if ch.Kind == KindWebhook {
+			post = d.deliverHook
+		}
+		if err := post(ctx, ch, ev); err != nil {
+			log.Printf("notify: channel %s (%s): %v", ch.ID, ch.Kind, err)
+		}
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, ch *domain.NotifyChannel, ev *domain.IngestEvent) error {
+	text, err := Render(ch, ev)
+	if err != nil {
+		return err
+	}
+	body, err := payload(ch.Kind, text)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
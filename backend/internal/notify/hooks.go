@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// EventNewEmail is the only REST Hooks event
+const EventNewEmail = "new_email"
+
+// ErrGone is returned when a hook target answered 410 and was unsubscribed
+var ErrGone = errors.New("hook target gone")
+
+// HookPayload is the body POSTed to REST Hooks targets and returned by the
+// polling fallback, so both trigger styles see identical records. For
+// burn-after-reading inboxes Text holds a short preview and HTML is empty:
+// the body is only ever served, once, by /message/{id}.
+type HookPayload struct {
+	ID      string    `json:"id"`
+	Event   string    `json:"event"`
+	Inbox   string    `json:"inbox"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Text    string    `json:"text"`
+	HTML    string    `json:"html,omitempty"`
+}
+
+// NewHookPayload builds the hook record of msg
+func NewHookPayload(msg *domain.Message) HookPayload {
+	return HookPayload{
+		ID:      msg.ID,
+		Event:   EventNewEmail,
+		Inbox:   msg.Local + "@" + msg.Domain,
+		From:    msg.From,
+		To:      msg.OriginalTo,
+		Subject: msg.Subject,
+		Date:    msg.Date,
+		Text:    msg.Text,
+		HTML:    msg.HTML,
+	}
+}
+
+// ValidateHook checks a REST Hooks subscription target. Addresses are
+// checked again at dial time, this only rejects obviously internal URLs
+// early.
+func ValidateHook(ch *domain.NotifyChannel) error {
+	u, err := url.Parse(ch.WebhookURL)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// publicOnlyDialer refuses connections to non-public addresses, closing
+// DNS-rebinding tricks that ValidateHook can't see
+func publicOnlyDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		},
+	}
+}
+
+// PublicTransport dials only public addresses, for requests to URLs taken
+// from subscribers or incoming mail
+func PublicTransport() *http.Transport {
+	return &http.Transport{DialContext: publicOnlyDialer().DialContext}
+}
+
+// summaryPayload reduces p to what an inbox summary shows, matching the
+// records the polling fallback returns for burn-after-reading inboxes
+func summaryPayload(p HookPayload) HookPayload {
+	text := strings.Join(strings.Fields(p.Text), " ")
+	if runes := []rune(text); len(runes) > 140 {
+		text = string(runes[:140]) + "…"
+	}
+	p.Text = text
+	p.HTML = ""
+	return p
+}
+
+// deliverHook POSTs the full message to a REST Hooks target. Delivery
+// doesn't count as the single retrieval of a burn-after-reading inbox, so
+// those get the summary only.
+func (d *Dispatcher) deliverHook(ctx context.Context, ch *domain.NotifyChannel, ev *domain.IngestEvent) error {
+	msg, err := d.store.GetMessage(ctx, ev.ID)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		return nil
+	}
+	burn, err := d.store.BurnsAfterReading(ctx, msg.Domain, msg.Local)
+	if err != nil {
+		return err
+	}
+
+	payload := NewHookPayload(msg)
+	if burn {
+		payload = summaryPayload(payload)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	// REST Hooks: 410 means the subscriber is gone for good
+	if resp.StatusCode == http.StatusGone {
+		d.store.DeleteNotifyChannel(ctx, ch.Scope, ch.Target, ch.ID)
+		return ErrGone
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("hook target returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Channel kinds
+const (
+	KindDiscord = "discord"
+	KindSlack   = "slack"
+	// KindWebhook is a REST Hooks subscription receiving full message JSON
+	KindWebhook = "webhook"
+)
+
+// Channel scopes
+const (
+	ScopeInbox  = "inbox"
+	ScopeDomain = "domain"
+)
+
+// DefaultTemplate is used when a channel has no template of its own
+const DefaultTemplate = `New mail for {{.Inbox}} from {{.From}}: {{.Subject}}`
+
+// maxMessageLen keeps rendered summaries within both services' limits
+const maxMessageLen = 1800
+
+// webhookHosts lists the hosts each kind may post to, so channels can't be
+// used to make the ingestor call arbitrary URLs
+var webhookHosts = map[string][]string{
+	KindDiscord: {"discord.com", "discordapp.com", "canary.discord.com", "ptb.discord.com"},
+	KindSlack:   {"hooks.slack.com"},
+}
+
+var (
+	ErrInvalidKind     = errors.New("kind must be discord or slack")
+	ErrInvalidURL      = errors.New("webhook URL is not a valid Discord or Slack webhook")
+	ErrInvalidTemplate = errors.New("template does not parse")
+)
+
+// Validate checks that ch can be delivered
+func Validate(ch *domain.NotifyChannel) error {
+	hosts, ok := webhookHosts[ch.Kind]
+	if !ok {
+		return ErrInvalidKind
+	}
+
+	u, err := url.Parse(ch.WebhookURL)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return ErrInvalidURL
+	}
+	allowed := false
+	for _, h := range hosts {
+		if strings.EqualFold(u.Hostname(), h) {
+			allowed = true
+		}
+	}
+	if !allowed || u.Port() != "" {
+		return ErrInvalidURL
+	}
+
+	if ch.Template != "" {
+		if _, err := template.New("notify").Parse(ch.Template); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	return nil
+}
+
+// Masked returns a copy of ch safe to show to anyone who knows the inbox:
+// the webhook token is replaced by its last four characters
+func Masked(ch *domain.NotifyChannel) *domain.NotifyChannel {
+	out := *ch
+	if u, err := url.Parse(ch.WebhookURL); err == nil {
+		tail := u.Path
+		if len(tail) > 4 {
+			tail = tail[len(tail)-4:]
+		}
+		out.WebhookURL = fmt.Sprintf("https://%s/…%s", u.Host, tail)
+	}
+	return &out
+}
+
+// templateData is what templates can reference
+type templateData struct {
+	ID         string
+	Inbox      string
+	To         string
+	From       string
+	Subject    string
+	Size       int
+	ReceivedAt time.Time
+}
+
+// Render formats ev for ch
+func Render(ch *domain.NotifyChannel, ev *domain.IngestEvent) (string, error) {
+	text := ch.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("notify").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, templateData{
+		ID:         ev.ID,
+		Inbox:      ev.Inbox,
+		To:         ev.To,
+		From:       ev.From,
+		Subject:    ev.Subject,
+		Size:       ev.Size,
+		ReceivedAt: ev.ReceivedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	out := buf.String()
+	if len(out) > maxMessageLen {
+		out = out[:maxMessageLen] + "…"
+	}
+	return out, nil
+}
+
+// payload builds the webhook body for ch's service
+func payload(kind, text string) ([]byte, error) {
+	switch kind {
+	case KindDiscord:
+		return json.Marshal(map[string]interface{}{
+			"content": text,
+			// Never let mail content ping @everyone or roles
+			"allowed_mentions": map[string]interface{}{"parse": []string{}},
+		})
+	case KindSlack:
+		return json.Marshal(map[string]string{"text": text})
+	default:
+		return nil, ErrInvalidKind
+	}
+}
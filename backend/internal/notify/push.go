@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/privacy"
+	"cattymail/internal/redisstore"
+	"cattymail/internal/webpush"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// pushTTL is how long push services hold a notification for offline browsers
+const pushTTL = 6 * time.Hour
+
+// VAPID returns the application server keys: VAPID_* from the environment
+// when set, otherwise a pair generated once and shared through Redis
+func VAPID(ctx context.Context, cfg *config.Config, store *redisstore.Store) (*webpush.VAPID, error) {
+	v := &webpush.VAPID{
+		PrivateKey: cfg.VAPIDPrivateKey,
+		PublicKey:  cfg.VAPIDPublicKey,
+		Subject:    cfg.VAPIDSubject,
+	}
+	if v.PrivateKey == "" || v.PublicKey == "" {
+		priv, pub, err := store.VAPIDKeys(ctx, webpush.GenerateVAPIDKeys)
+		if err != nil {
+			return nil, err
+		}
+		v.PrivateKey, v.PublicKey = priv, pub
+	}
+	if v.Subject == "" && len(cfg.AllowedDomains) > 0 {
+		v.Subject = "mailto:postmaster@" + cfg.AllowedDomains[0]
+	}
+	return v, nil
+}
+
+// pushPayload is what the service worker receives
+type pushPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Inbox string `json:"inbox"`
+	ID    string `json:"id"`
+}
+
+// push notifies every browser subscribed to the receiving inbox
+func (d *Dispatcher) push(ctx context.Context, ev *domain.IngestEvent) {
+	local, emailDomain, _ := strings.Cut(ev.Inbox, "@")
+	subs, err := d.store.ListPushSubscriptions(ctx, emailDomain, local)
+	if err != nil || len(subs) == 0 {
+		return
+	}
+
+	vapid, err := VAPID(ctx, d.cfg, d.store)
+	if err != nil {
+		log.Printf("notify: failed to load VAPID keys: %v", err)
+		return
+	}
+
+	subject := ev.Subject
+	if len(subject) > 200 {
+		subject = subject[:200] + "…"
+	}
+	payload, _ := json.Marshal(pushPayload{
+		Title: "New mail for " + ev.Inbox,
+		Body:  ev.From + "\n" + subject,
+		Inbox: ev.Inbox,
+		ID:    ev.ID,
+	})
+
+	for endpoint, raw := range subs {
+		var sub webpush.Subscription
+		if err := json.Unmarshal([]byte(raw), &sub); err != nil {
+			continue
+		}
+
+		err := webpush.Send(ctx, d.client, &sub, payload, vapid, pushTTL)
+		if errors.Is(err, webpush.ErrGone) {
+			d.store.DeletePushSubscription(ctx, emailDomain, local, endpoint)
+			continue
+		}
+		if err != nil {
+			log.Printf("notify: push to %s failed: %v", privacy.Address(ev.Inbox), err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Package otp pulls the one-time code or verification link out of a
+// signup message, so clients waiting on one do not have to parse it.
+package otp
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// codeNear matches a 4-8 digit code (or two groups of three) shortly
+	// after a word that announces one, in English or Indonesian
+	codeNear = regexp.MustCompile(`(?i)\b(?:code|kode|otp|pin|passcode|verification|verifikasi|one-time)[^0-9]{0,40}?\b([0-9]{4,8}|[0-9]{3}[- ][0-9]{3})\b`)
+	// bareCode is a lone 6 digit number, used for subjects only
+	bareCode = regexp.MustCompile(`\b([0-9]{6})\b`)
+	urlRe    = regexp.MustCompile(`https?://[^\s"'<>)\]]+`)
+	tagRe    = regexp.MustCompile(`(?s)<[^>]*>`)
+	// linkWords mark a URL as a verification or magic sign-in link
+	linkWords = []string{"verif", "confirm", "activat", "magic", "signin", "sign-in", "login", "token=", "code=", "otp"}
+)
+
+// Extract returns the code and link found in a message, either empty when
+// there is none. The subject is tried first since senders often put the
+// code there.
+func Extract(subject, text, htmlBody string) (code, link string) {
+	body := text
+	if strings.TrimSpace(body) == "" {
+		body = html.UnescapeString(tagRe.ReplaceAllString(htmlBody, " "))
+	}
+	for _, s := range []string{subject, body} {
+		if m := codeNear.FindStringSubmatch(s); m != nil {
+			code = m[1]
+			break
+		}
+	}
+	if code == "" {
+		if m := bareCode.FindStringSubmatch(subject); m != nil {
+			code = m[1]
+		}
+	}
+
+	// Links in the HTML are the ones the sender meant to be clicked
+	for _, s := range []string{htmlBody, text} {
+		for _, u := range urlRe.FindAllString(s, -1) {
+			u = html.UnescapeString(strings.TrimRight(u, ".,;"))
+			lower := strings.ToLower(u)
+			for _, w := range linkWords {
+				if strings.Contains(lower, w) {
+					return code, u
+				}
+			}
+		}
+	}
+	return code, ""
+}
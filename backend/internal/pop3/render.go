@@ -0,0 +1,74 @@
+package pop3
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// render rebuilds an RFC 5322 message from a stored one. Only the parsed
+// text and HTML bodies are kept by the ingestor, so the result is a
+// multipart/alternative (or plain text) message with CRLF line endings.
+func render(msg *domain.Message) []byte {
+	var buf bytes.Buffer
+
+	to := msg.OriginalTo
+	if to == "" {
+		to = msg.Local + "@" + msg.Domain
+	}
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	header("From", sanitize(msg.From))
+	header("To", sanitize(to))
+	header("Subject", mime.QEncoding.Encode("utf-8", sanitize(msg.Subject)))
+	header("Date", msg.Date.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", msg.ID, msg.Domain))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", `text/plain; charset="utf-8"`)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		writeQP(&buf, msg.Text)
+		return buf.Bytes()
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ ctype, content string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ctype + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQP(pw, part.content)
+	}
+	mw.Close()
+
+	header("Content-Type", `multipart/alternative; boundary="`+mw.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
+
+// writeQP writes s quoted-printable encoded; the encoder emits CRLF
+func writeQP(w interface{ Write([]byte) (int, error) }, s string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(strings.ReplaceAll(s, "\r\n", "\n")))
+	qp.Close()
+	w.Write([]byte("\r\n"))
+}
+
+// sanitize keeps header values on one line
+func sanitize(v string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+}
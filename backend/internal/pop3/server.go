@@ -0,0 +1,384 @@
+// Package pop3 serves temp inboxes read-only over POP3 (RFC 1939) so they
+// can be added to a regular mail client. Users log in with the address and
+// an inbox access token; DELE marks messages read instead of deleting them.
+package pop3
+
+import (
+	"bufio"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+	"cattymail/internal/redisstore"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maildropSize caps how many of the newest messages are exposed
+	maildropSize = 100
+	idleTimeout  = 10 * time.Minute
+	maxLineLen   = 512
+)
+
+// Server is a POP3 listener backed by redisstore
+type Server struct {
+	cfg   *config.Config
+	store *redisstore.Store
+	tls   *tls.Config
+}
+
+// New returns a server. When certFile and keyFile are set the listener
+// speaks implicit TLS (POP3S).
+func New(cfg *config.Config, store *redisstore.Store) (*Server, error) {
+	s := &Server{cfg: cfg, store: store}
+	if cfg.POP3TLSCert != "" && cfg.POP3TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.POP3TLSCert, cfg.POP3TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("pop3: load TLS certificate: %w", err)
+		}
+		s.tls = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	return s, nil
+}
+
+// ListenAndServe accepts connections on addr until ctx is cancelled
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	var ln net.Listener
+	var err error
+	if s.tls != nil {
+		ln, err = tls.Listen("tcp", addr, s.tls)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("pop3: accept: %v", err)
+			continue
+		}
+		go s.serve(ctx, conn)
+	}
+}
+
+// session is the state of one POP3 connection
+type session struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	user   string
+	domain string
+	local  string
+	// Maildrop snapshot taken at login
+	msgs    []*domain.Message
+	deleted map[int]bool
+	// The inbox burns messages after reading: RETR deletes them and TOP
+	// only shows headers
+	burn bool
+}
+
+func (s *Server) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("pop3: panic serving %s: %v", conn.RemoteAddr(), rec)
+		}
+	}()
+
+	sess := &session{
+		conn:    conn,
+		r:       bufio.NewReaderSize(conn, maxLineLen),
+		w:       bufio.NewWriter(conn),
+		deleted: make(map[int]bool),
+	}
+	sess.ok("CattyMail POP3 ready")
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		line, err := sess.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		cmd = strings.ToUpper(cmd)
+
+		if sess.msgs == nil {
+			if quit := s.authorization(ctx, sess, cmd, arg); quit {
+				return
+			}
+		} else if quit := s.transaction(ctx, sess, cmd, arg); quit {
+			return
+		}
+	}
+}
+
+// authorization handles the AUTHORIZATION state. It returns true when the
+// connection should be closed.
+func (s *Server) authorization(ctx context.Context, sess *session, cmd, arg string) bool {
+	switch cmd {
+	case "CAPA":
+		sess.multiline("Capability list follows", []string{"USER", "UIDL", "TOP", "RESP-CODES", "IMPLEMENTATION CattyMail"})
+	case "USER":
+		addr, err := email.Parse(arg)
+		if err != nil {
+			sess.err("invalid address")
+			return false
+		}
+		sess.user, sess.domain, sess.local = addr.String(), addr.Domain, addr.Local
+		sess.ok("send access token")
+	case "PASS":
+		if sess.user == "" {
+			sess.err("USER first")
+			return false
+		}
+		host, _, _ := net.SplitHostPort(sess.conn.RemoteAddr().String())
+		if allowed, _ := s.store.RateLimit(ctx, host, "pop3auth", 10, time.Minute); !allowed {
+			sess.err("[SYS/TEMP] too many login attempts")
+			return true
+		}
+
+		ok, err := s.store.CheckInboxToken(ctx, sess.domain, sess.local, arg)
+		if err != nil {
+			sess.err("[SYS/TEMP] try again later")
+			return false
+		}
+		if !ok {
+			sess.user = ""
+			sess.err("[AUTH] invalid address or token")
+			return false
+		}
+		if refusal := s.refusal(ctx, sess); refusal != "" {
+			sess.err("[AUTH] " + refusal)
+			return true
+		}
+		if err := s.loadMaildrop(ctx, sess); err != nil {
+			sess.err("[SYS/TEMP] failed to open maildrop")
+			return true
+		}
+		sess.ok(fmt.Sprintf("%s has %d messages", sess.user, len(sess.msgs)))
+	case "QUIT":
+		sess.ok("bye")
+		return true
+	default:
+		sess.err("unknown command")
+	}
+	return false
+}
+
+// refusal returns why the session's inbox may not be read (blocked for
+// abuse or frozen), or ""
+func (s *Server) refusal(ctx context.Context, sess *session) string {
+	if s.store.IsBlocked(ctx, sess.domain, sess.local) {
+		return "address blocked for abuse"
+	}
+	if reason, frozen := s.store.FrozenReason(ctx, sess.domain, sess.local); frozen {
+		return "address frozen: " + reason
+	}
+	return ""
+}
+
+// loadMaildrop snapshots the unread messages of the inbox, oldest first as
+// clients expect
+func (s *Server) loadMaildrop(ctx context.Context, sess *session) error {
+	msgs, err := s.store.GetInbox(ctx, sess.domain, sess.local, maildropSize, 0)
+	if err != nil {
+		return err
+	}
+	read, err := s.store.ReadIDs(ctx, sess.domain, sess.local)
+	if err != nil {
+		return err
+	}
+	if sess.burn, err = s.store.BurnsAfterReading(ctx, sess.domain, sess.local); err != nil {
+		return err
+	}
+
+	sess.msgs = make([]*domain.Message, 0, len(msgs))
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if !read[msgs[i].ID] {
+			sess.msgs = append(sess.msgs, msgs[i])
+		}
+	}
+	return nil
+}
+
+// transaction handles the TRANSACTION state
+func (s *Server) transaction(ctx context.Context, sess *session, cmd, arg string) bool {
+	switch cmd {
+	case "CAPA":
+		sess.multiline("Capability list follows", []string{"UIDL", "TOP", "RESP-CODES", "IMPLEMENTATION CattyMail"})
+	case "NOOP":
+		sess.ok("")
+	case "STAT":
+		count, size := 0, 0
+		for i, m := range sess.msgs {
+			if !sess.deleted[i] {
+				count++
+				size += len(render(m))
+			}
+		}
+		sess.ok(fmt.Sprintf("%d %d", count, size))
+	case "LIST", "UIDL":
+		value := func(i int, m *domain.Message) string {
+			if cmd == "UIDL" {
+				return m.ID
+			}
+			return strconv.Itoa(len(render(m)))
+		}
+		if arg != "" {
+			i, ok := sess.index(arg)
+			if !ok {
+				sess.err("no such message")
+				return false
+			}
+			sess.ok(fmt.Sprintf("%d %s", i+1, value(i, sess.msgs[i])))
+			return false
+		}
+		var lines []string
+		for i, m := range sess.msgs {
+			if !sess.deleted[i] {
+				lines = append(lines, fmt.Sprintf("%d %s", i+1, value(i, m)))
+			}
+		}
+		sess.multiline("listing follows", lines)
+	case "RETR":
+		i, ok := sess.index(arg)
+		if !ok {
+			sess.err("no such message")
+			return false
+		}
+		if refusal := s.refusal(ctx, sess); refusal != "" {
+			sess.err(refusal)
+			return false
+		}
+		msg := sess.msgs[i]
+		if sess.burn {
+			burned, err := s.store.BurnMessage(ctx, msg.ID)
+			if err != nil {
+				sess.err("[SYS/TEMP] try again later")
+				return false
+			}
+			if burned == nil {
+				sess.err("message already read")
+				return false
+			}
+			msg = burned
+		}
+		sess.message(render(msg), -1)
+	case "TOP":
+		n, lines, _ := strings.Cut(arg, " ")
+		i, ok := sess.index(n)
+		count, err := strconv.Atoi(strings.TrimSpace(lines))
+		if !ok || err != nil || count < 0 {
+			sess.err("usage: TOP msg n")
+			return false
+		}
+		if refusal := s.refusal(ctx, sess); refusal != "" {
+			sess.err(refusal)
+			return false
+		}
+		if sess.burn {
+			count = 0
+		}
+		sess.message(render(sess.msgs[i]), count)
+	case "DELE":
+		i, ok := sess.index(arg)
+		if !ok {
+			sess.err("no such message")
+			return false
+		}
+		sess.deleted[i] = true
+		sess.ok("message marked read")
+	case "RSET":
+		sess.deleted = make(map[int]bool)
+		sess.ok("")
+	case "QUIT":
+		// UPDATE state: deletions become read flags
+		var ids []string
+		for i := range sess.deleted {
+			ids = append(ids, sess.msgs[i].ID)
+		}
+		if err := s.store.MarkRead(ctx, sess.domain, sess.local, ids...); err != nil {
+			sess.err("[SYS/TEMP] failed to update maildrop")
+			return true
+		}
+		sess.ok("bye")
+		return true
+	default:
+		sess.err("unknown command")
+	}
+	return false
+}
+
+// index resolves a 1-based message number that has not been deleted
+func (sess *session) index(arg string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n < 1 || n > len(sess.msgs) || sess.deleted[n-1] {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func (sess *session) ok(text string) {
+	if text == "" {
+		sess.w.WriteString("+OK\r\n")
+	} else {
+		sess.w.WriteString("+OK " + text + "\r\n")
+	}
+	sess.w.Flush()
+}
+
+func (sess *session) err(text string) {
+	sess.w.WriteString("-ERR " + text + "\r\n")
+	sess.w.Flush()
+}
+
+func (sess *session) multiline(status string, lines []string) {
+	sess.w.WriteString("+OK " + status + "\r\n")
+	for _, l := range lines {
+		sess.w.WriteString(l + "\r\n")
+	}
+	sess.w.WriteString(".\r\n")
+	sess.w.Flush()
+}
+
+// message writes a dot-stuffed message. With bodyLines >= 0 only the
+// headers and that many body lines are sent (TOP).
+func (sess *session) message(raw []byte, bodyLines int) {
+	sess.w.WriteString("+OK message follows\r\n")
+	inBody := false
+	for _, line := range strings.Split(strings.TrimSuffix(string(raw), "\r\n"), "\r\n") {
+		if inBody {
+			if bodyLines == 0 {
+				break
+			}
+			if bodyLines > 0 {
+				bodyLines--
+			}
+		} else if line == "" {
+			inBody = true
+		}
+		if strings.HasPrefix(line, ".") {
+			line = "." + line
+		}
+		sess.w.WriteString(line + "\r\n")
+	}
+	sess.w.WriteString(".\r\n")
+	sess.w.Flush()
+}
@@ -0,0 +1,276 @@
+// Package preview renders a stored message as a sanitized, self-contained
+// HTML document that can be embedded in a sandboxed iframe as-is. Scripts
+// and active content are dropped, cid: images are inlined as data URIs,
+// remote images and stylesheets are blocked, and every link opens in a new
+// tab.
+package preview
+
+import (
+	"bytes"
+	"cattymail/internal/domain"
+	"encoding/base64"
+	"html"
+	"regexp"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// CSP is the Content-Security-Policy the document is written for. Serve it
+// as a header as well so browsers enforce it even if the markup is altered.
+const CSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline'; base-uri 'none'; form-action 'none'"
+
+// allowedTags are emitted as-is (minus disallowed attributes)
+var allowedTags = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.Address: true, atom.B: true, atom.Big: true,
+	atom.Blockquote: true, atom.Br: true, atom.Caption: true, atom.Center: true,
+	atom.Cite: true, atom.Code: true, atom.Col: true, atom.Colgroup: true,
+	atom.Dd: true, atom.Del: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Em: true, atom.Font: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true, atom.I: true,
+	atom.Img: true, atom.Ins: true, atom.Li: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.S: true, atom.Small: true, atom.Span: true, atom.Strike: true,
+	atom.Strong: true, atom.Sub: true, atom.Sup: true, atom.Table: true,
+	atom.Tbody: true, atom.Td: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true,
+	atom.Tr: true, atom.Tt: true, atom.U: true, atom.Ul: true, atom.Style: true,
+}
+
+// droppedTags are removed together with everything inside them
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Noscript: true, atom.Iframe: true, atom.Frame: true,
+	atom.Frameset: true, atom.Object: true, atom.Embed: true, atom.Applet: true,
+	atom.Title: true, atom.Template: true, atom.Svg: true, atom.Math: true,
+	atom.Form: true, atom.Textarea: true, atom.Select: true, atom.Video: true,
+	atom.Audio: true,
+}
+
+var allowedAttrs = map[string]bool{
+	"align": true, "alt": true, "bgcolor": true, "border": true, "cellpadding": true,
+	"cellspacing": true, "class": true, "color": true, "colspan": true, "dir": true,
+	"face": true, "height": true, "href": true, "lang": true, "rowspan": true,
+	"size": true, "src": true, "style": true, "title": true, "valign": true,
+	"width": true,
+}
+
+var (
+	cssImport = regexp.MustCompile(`(?i)@import[^;]*;?`)
+	cssURL    = regexp.MustCompile(`(?i)url\s*\(\s*(['"]?)([^'")]*)(['"]?)\s*\)`)
+	cssActive = regexp.MustCompile(`(?i)expression\s*\(|behavior\s*:|-moz-binding`)
+)
+
+// Result is a rendered preview
+type Result struct {
+	HTML []byte
+	// RemoteBlocked counts remote images and stylesheets that were removed
+	RemoteBlocked int
+}
+
+// Options adjust rendering
+type Options struct {
+	// Link, when set, rewrites the href of every web link (e.g. through a
+	// click proxy); mailto: links are left alone
+	Link func(href string) string
+	// Print adds a block with the sender, recipient, date and subject
+	// above the body and styles the page for printing
+	Print bool
+}
+
+// Render turns msg into a standalone HTML document. Plain-text messages are
+// escaped and wrapped in a pre-formatted block.
+func Render(msg *domain.Message) *Result {
+	return RenderWith(msg, Options{})
+}
+
+// RenderWith is Render with options
+func RenderWith(msg *domain.Message, opts Options) *Result {
+	r := &renderer{inline: make(map[string]string, len(msg.Inline)), link: opts.Link}
+	for _, part := range msg.Inline {
+		r.inline[strings.ToLower(part.ContentID)] = "data:" + part.ContentType + ";base64," + base64.StdEncoding.EncodeToString(part.Data)
+	}
+
+	var body bytes.Buffer
+	if msg.HTML != "" {
+		r.sanitize(&body, msg.HTML)
+	} else {
+		body.WriteString(`<pre style="white-space:pre-wrap;word-wrap:break-word;font-family:inherit">`)
+		body.WriteString(html.EscapeString(msg.Text))
+		body.WriteString(`</pre>`)
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">")
+	doc.WriteString(`<meta http-equiv="Content-Security-Policy" content="` + html.EscapeString(CSP) + `">`)
+	doc.WriteString(`<meta name="referrer" content="no-referrer"><base target="_blank">`)
+	doc.WriteString(`<title>` + html.EscapeString(msg.Subject) + `</title>`)
+	if opts.Print {
+		doc.WriteString(printCSS)
+	}
+	doc.WriteString("</head><body>")
+	if opts.Print {
+		writePrintHeader(&doc, msg)
+	}
+	doc.Write(body.Bytes())
+	doc.WriteString("</body></html>\n")
+	return &Result{HTML: doc.Bytes(), RemoteBlocked: r.blocked}
+}
+
+// printCSS lays the printable document out on A4 paper
+const printCSS = `<style>@page{size:A4;margin:15mm}` +
+	`.cattymail-header{font:13px sans-serif;border-bottom:1px solid #999;margin-bottom:12px;padding-bottom:8px}` +
+	`.cattymail-header th{text-align:left;padding-right:12px;vertical-align:top}</style>`
+
+// writePrintHeader writes the envelope of msg as a small table
+func writePrintHeader(doc *bytes.Buffer, msg *domain.Message) {
+	doc.WriteString(`<table class="cattymail-header">`)
+	for _, row := range [][2]string{
+		{"From", msg.From},
+		{"To", msg.OriginalTo},
+		{"Date", msg.Date.UTC().Format("2006-01-02 15:04 MST")},
+		{"Subject", msg.Subject},
+	} {
+		doc.WriteString("<tr><th>" + row[0] + "</th><td>" + html.EscapeString(row[1]) + "</td></tr>")
+	}
+	doc.WriteString("</table>")
+}
+
+type renderer struct {
+	inline  map[string]string
+	link    func(string) string
+	blocked int
+}
+
+// sanitize writes the allowed subset of src to out
+func (r *renderer) sanitize(out *bytes.Buffer, src string) {
+	z := xhtml.NewTokenizer(strings.NewReader(src))
+	skip := 0 // depth inside a dropped element
+	inStyle := false
+
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			return
+		}
+		tok := z.Token()
+
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if droppedTags[tok.DataAtom] {
+				if tt == xhtml.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 || !allowedTags[tok.DataAtom] {
+				continue
+			}
+			if tok.DataAtom == atom.Style {
+				inStyle = tt == xhtml.StartTagToken
+			}
+			r.writeTag(out, tok, tt == xhtml.SelfClosingTagToken)
+		case xhtml.EndTagToken:
+			if droppedTags[tok.DataAtom] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 || !allowedTags[tok.DataAtom] {
+				continue
+			}
+			if tok.DataAtom == atom.Style {
+				inStyle = false
+			}
+			out.WriteString("</" + tok.DataAtom.String() + ">")
+		case xhtml.TextToken:
+			if skip > 0 {
+				continue
+			}
+			if inStyle {
+				// Raw text: keep it unescaped but never let it close the element
+				css := strings.ReplaceAll(r.sanitizeCSS(tok.Data), "<", "\\3c ")
+				out.WriteString(css)
+				continue
+			}
+			out.WriteString(html.EscapeString(tok.Data))
+		}
+	}
+}
+
+func (r *renderer) writeTag(out *bytes.Buffer, tok xhtml.Token, selfClosing bool) {
+	out.WriteString("<" + tok.DataAtom.String())
+	for _, a := range tok.Attr {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" || !allowedAttrs[key] {
+			continue
+		}
+		val := a.Val
+		switch key {
+		case "href":
+			if tok.DataAtom != atom.A || !safeLink(val) {
+				continue
+			}
+			if r.link != nil && !strings.HasPrefix(strings.ToLower(strings.TrimSpace(val)), "mailto:") {
+				val = r.link(strings.TrimSpace(val))
+			}
+		case "src":
+			if tok.DataAtom != atom.Img {
+				continue
+			}
+			var ok bool
+			if val, ok = r.imageSource(val); !ok {
+				continue
+			}
+		case "style":
+			val = r.sanitizeCSS(val)
+		}
+		out.WriteString(" " + key + `="` + html.EscapeString(val) + `"`)
+	}
+	if tok.DataAtom == atom.A {
+		out.WriteString(` target="_blank" rel="noopener noreferrer nofollow"`)
+	}
+	if selfClosing {
+		out.WriteString(" /")
+	}
+	out.WriteString(">")
+}
+
+// safeLink allows web and mail links only
+func safeLink(href string) bool {
+	h := strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(h, "http://") || strings.HasPrefix(h, "https://") || strings.HasPrefix(h, "mailto:")
+}
+
+// imageSource resolves cid: references and keeps embedded data images.
+// Anything else is remote content and is blocked.
+func (r *renderer) imageSource(src string) (string, bool) {
+	s := strings.TrimSpace(src)
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(lower, "cid:"):
+		uri, ok := r.inline[strings.Trim(lower[4:], "<>")]
+		return uri, ok
+	case strings.HasPrefix(lower, "data:image/"):
+		return s, true
+	}
+	if s != "" {
+		r.blocked++
+	}
+	return "", false
+}
+
+// sanitizeCSS strips imports, script-capable constructs and remote urls
+func (r *renderer) sanitizeCSS(css string) string {
+	css = cssImport.ReplaceAllStringFunc(css, func(string) string {
+		r.blocked++
+		return ""
+	})
+	css = cssActive.ReplaceAllString(css, "")
+	return cssURL.ReplaceAllStringFunc(css, func(m string) string {
+		src := cssURL.FindStringSubmatch(m)[2]
+		if uri, ok := r.imageSource(src); ok {
+			return `url("` + uri + `")`
+		}
+		return "none"
+	})
+}
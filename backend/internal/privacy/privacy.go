@@ -0,0 +1,180 @@
+// Package privacy masks email addresses in logs and in admin views for
+// deployments with data-minimization requirements (PRIVACY_MODE). With the
+// mode off every function returns its input unchanged.
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Modes
+const (
+	ModeOff  = "off"
+	ModeMask = "mask" // "j***e@example.com"
+	ModeHash = "hash" // "h-3f9a1c07d2@example.com", stable for a given salt
+)
+
+type masker struct {
+	mode string
+	salt []byte
+}
+
+var current atomic.Pointer[masker]
+
+var (
+	// addressRe finds addresses in free text (log lines, headers, JSON)
+	addressRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// localFieldRe finds the local parts JSON views carry on their own
+	localFieldRe = regexp.MustCompile(`"local":"([^"\\]*)"`)
+)
+
+// Configure sets the process-wide mode. The salt keys the hashes of
+// ModeHash so they cannot be reversed by hashing guessed addresses.
+func Configure(mode, salt string) {
+	if mode == "" || mode == ModeOff {
+		current.Store(nil)
+		return
+	}
+	current.Store(&masker{mode: mode, salt: []byte(salt)})
+}
+
+// Enabled reports whether addresses are being masked
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Local masks a local part
+func Local(local string) string {
+	m := current.Load()
+	if m == nil || local == "" {
+		return local
+	}
+	if m.mode == ModeHash {
+		mac := hmac.New(sha256.New, m.salt)
+		mac.Write([]byte(strings.ToLower(local)))
+		return "h-" + hex.EncodeToString(mac.Sum(nil))[:10]
+	}
+	return mask(local)
+}
+
+func mask(local string) string {
+	r := []rune(local)
+	if len(r) <= 2 {
+		return strings.Repeat("*", len(r))
+	}
+	return string(r[0]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1])
+}
+
+// Address masks the local part of addr, keeping the domain
+func Address(addr string) string {
+	if !Enabled() {
+		return addr
+	}
+	i := strings.LastIndexByte(addr, '@')
+	if i < 0 {
+		return Local(addr)
+	}
+	return Local(addr[:i]) + addr[i:]
+}
+
+// Inbox masks local@d given apart
+func Inbox(local, d string) string {
+	return Local(local) + "@" + d
+}
+
+// Text masks every address found in s
+func Text(s string) string {
+	if !Enabled() {
+		return s
+	}
+	return addressRe.ReplaceAllStringFunc(s, Address)
+}
+
+// Redact masks every address found in s like Text, in ModeMask style
+// when the mode is off, for output that must never carry addresses
+func Redact(s string) string {
+	if Enabled() {
+		return Text(s)
+	}
+	return addressRe.ReplaceAllStringFunc(s, func(addr string) string {
+		i := strings.LastIndexByte(addr, '@')
+		return mask(addr[:i]) + addr[i:]
+	})
+}
+
+type ctxKey struct{}
+
+// Restrict marks r as a view that must not show addresses in the clear and
+// returns w masking the addresses (and JSON "local" fields) in everything
+// written to it. Output is masked a line at a time, so an address split
+// across writes (as csv.Writer and bufio do) is still caught; call done
+// once the handler returns to send an unterminated last line. Handlers
+// writing locals in other shapes check Masked.
+func Restrict(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func() error) {
+	if !Enabled() {
+		return w, r, func() error { return nil }
+	}
+	m := &maskingWriter{ResponseWriter: w}
+	return m, r.WithContext(context.WithValue(r.Context(), ctxKey{}, true)), m.Close
+}
+
+// Masked reports whether ctx is a restricted view (see Restrict)
+func Masked(ctx context.Context) bool {
+	masked, _ := ctx.Value(ctxKey{}).(bool)
+	return masked && Enabled()
+}
+
+type maskingWriter struct {
+	http.ResponseWriter
+	pending []byte // written after the last newline, not sent yet
+}
+
+// Write sends every complete line of what was written so far, masked, and
+// holds back the rest
+func (m *maskingWriter) Write(b []byte) (int, error) {
+	m.pending = append(m.pending, b...)
+	i := bytes.LastIndexByte(m.pending, '\n')
+	if i < 0 {
+		return len(b), nil
+	}
+	lines := m.pending[:i+1]
+	m.pending = append([]byte(nil), m.pending[i+1:]...)
+	if err := m.send(lines); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close sends what is left after the last newline
+func (m *maskingWriter) Close() error {
+	if len(m.pending) == 0 {
+		return nil
+	}
+	rest := m.pending
+	m.pending = nil
+	return m.send(rest)
+}
+
+func (m *maskingWriter) send(b []byte) error {
+	s := localFieldRe.ReplaceAllStringFunc(Text(string(b)), func(field string) string {
+		return `"local":"` + Local(localFieldRe.FindStringSubmatch(field)[1]) + `"`
+	})
+	_, err := m.ResponseWriter.Write([]byte(s))
+	return err
+}
+
+// Flush flushes the complete lines already sent; a partial line stays
+// held back so it is never sent half masked
+func (m *maskingWriter) Flush() {
+	if f, ok := m.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,34 @@
+package privacy
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRestrictMasksAcrossWrites(t *testing.T) {
+	Configure(ModeMask, "")
+	defer Configure(ModeOff, "")
+
+	rec := httptest.NewRecorder()
+	w, _, done := Restrict(rec, httptest.NewRequest("GET", "/admin/stats", nil))
+
+	// An address split at an arbitrary byte, as a buffered CSV writer does
+	for _, chunk := range []string{"inbox,messages\nbudi12", "345@catty.my.id,3\n", `{"local":"sari`, `77821"}`} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := rec.Body.String(); strings.Contains(got, "sari") {
+		t.Fatalf("partial line sent before done: %q", got)
+	}
+	if err := done(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := rec.Body.String()
+	want := "inbox,messages\nb*******5@catty.my.id,3\n" + `{"local":"s*******1"}`
+	if got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
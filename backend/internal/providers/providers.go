@@ -0,0 +1,116 @@
+// Package providers tells which service sent a message (Google, Discord,
+// Steam, ...), from the sender's domain or the domains that signed it, for
+// the per-provider deliverability report.
+package providers
+
+import (
+	"strings"
+
+	"cattymail/internal/domain"
+)
+
+// Other is the provider of mail from services not in the table
+const Other = "other"
+
+// table maps sender and DKIM domains (and their subdomains) to a provider.
+// Bulk mail platforms (SES, SendGrid, ...) sign for many senders and are
+// deliberately absent, so the sender's own domain decides.
+var table = map[string]string{
+	"google.com":          "Google",
+	"gmail.com":           "Google",
+	"googlemail.com":      "Google",
+	"youtube.com":         "Google",
+	"microsoft.com":       "Microsoft",
+	"microsoftonline.com": "Microsoft",
+	"outlook.com":         "Microsoft",
+	"hotmail.com":         "Microsoft",
+	"live.com":            "Microsoft",
+	"xbox.com":            "Microsoft",
+	"apple.com":           "Apple",
+	"icloud.com":          "Apple",
+	"discord.com":         "Discord",
+	"discordapp.com":      "Discord",
+	"steampowered.com":    "Steam",
+	"steamcommunity.com":  "Steam",
+	"github.com":          "GitHub",
+	"facebookmail.com":    "Meta",
+	"facebook.com":        "Meta",
+	"instagram.com":       "Meta",
+	"whatsapp.com":        "Meta",
+	"x.com":               "X",
+	"twitter.com":         "X",
+	"amazon.com":          "Amazon",
+	"tiktok.com":          "TikTok",
+	"telegram.org":        "Telegram",
+	"redditmail.com":      "Reddit",
+	"reddit.com":          "Reddit",
+	"twitch.tv":           "Twitch",
+	"epicgames.com":       "Epic Games",
+	"openai.com":          "OpenAI",
+	"spotify.com":         "Spotify",
+	"netflix.com":         "Netflix",
+	"paypal.com":          "PayPal",
+	"linkedin.com":        "LinkedIn",
+}
+
+// Classify returns the provider that sent msg, or Other
+func Classify(msg *domain.Message) string {
+	if i := strings.LastIndexByte(msg.FromAddress, '@'); i >= 0 {
+		if p := lookup(msg.FromAddress[i+1:]); p != "" {
+			return p
+		}
+	}
+	for _, d := range msg.Headers["DKIM-Signature"] {
+		if p := lookup(d); p != "" {
+			return p
+		}
+	}
+	return Other
+}
+
+// lookup matches host and its parent domains against the table
+func lookup(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for host != "" {
+		if p, ok := table[host]; ok {
+			return p
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return ""
+}
+
+// Trend compares the last week of daily counts (oldest first) with the
+// week before: "new", "stopped", "up", "down" or "steady". Shorter
+// series compare their two halves.
+func Trend(daily []int64) string {
+	span := 7
+	if len(daily) < 2*span {
+		span = len(daily) / 2
+	}
+	if span == 0 {
+		return "steady"
+	}
+	var prev, last int64
+	for _, n := range daily[len(daily)-2*span : len(daily)-span] {
+		prev += n
+	}
+	for _, n := range daily[len(daily)-span:] {
+		last += n
+	}
+	switch {
+	case prev == 0 && last > 0:
+		return "new"
+	case prev > 0 && last == 0:
+		return "stopped"
+	case float64(last) > float64(prev)*1.25:
+		return "up"
+	case float64(last) < float64(prev)*0.75:
+		return "down"
+	}
+	return "steady"
+}
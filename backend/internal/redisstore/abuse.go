@@ -0,0 +1,208 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Abuse report layout (on the primary):
+//
+//	abuse:reports      ZSET report IDs scored by filing time (unix ms)
+//	abuse:open         ZSET the IDs of reports still awaiting review, same scores
+//	abuse:report:<id>  STRING JSON domain.AbuseReport
+//	blocked_addresses  HASH "<domain>:<local>" -> JSON domain.BlockedAddress
+//
+// Reports expire after AbuseRetention; blocks stay until lifted.
+const (
+	KeyAbuseReports     = "abuse:reports"
+	KeyAbuseOpen        = "abuse:open"
+	KeyBlockedAddresses = "blocked_addresses"
+	AbuseRetention      = 90 * 24 * time.Hour
+)
+
+// Abuse report statuses
+const (
+	AbuseOpen      = "open"
+	AbuseResolved  = "resolved"
+	AbuseDismissed = "dismissed"
+)
+
+func abuseReportKey(id string) string {
+	return "abuse:report:" + id
+}
+
+// SaveAbuseReport files a new report or updates a reviewed one
+func (s *Store) SaveAbuseReport(ctx context.Context, rep *domain.AbuseReport) error {
+	if rep.ID == "" {
+		rep.ID = ulid.Make().String()
+	}
+	if rep.CreatedAt.IsZero() {
+		rep.CreatedAt = time.Now().UTC()
+	}
+	if rep.Status == "" {
+		rep.Status = AbuseOpen
+	}
+	data, err := json.Marshal(rep)
+	if err != nil {
+		return err
+	}
+	score := float64(rep.CreatedAt.UnixMilli())
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, abuseReportKey(rep.ID), data, AbuseRetention)
+	pipe.ZAdd(ctx, KeyAbuseReports, redis.Z{Score: score, Member: rep.ID})
+	if rep.Status == AbuseOpen {
+		pipe.ZAdd(ctx, KeyAbuseOpen, redis.Z{Score: score, Member: rep.ID})
+	} else {
+		pipe.ZRem(ctx, KeyAbuseOpen, rep.ID)
+	}
+	cutoff := strconv.FormatInt(time.Now().Add(-AbuseRetention).UnixMilli(), 10)
+	pipe.ZRemRangeByScore(ctx, KeyAbuseReports, "-inf", cutoff)
+	pipe.ZRemRangeByScore(ctx, KeyAbuseOpen, "-inf", cutoff)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetAbuseReport returns a report, or nil
+func (s *Store) GetAbuseReport(ctx context.Context, id string) (*domain.AbuseReport, error) {
+	data, err := s.client.Get(ctx, abuseReportKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rep domain.AbuseReport
+	if err := json.Unmarshal(data, &rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// ListAbuseReports returns up to limit reports, oldest first when only
+// open ones are asked for (the review queue), newest first otherwise
+func (s *Store) ListAbuseReports(ctx context.Context, openOnly bool, limit int64) ([]*domain.AbuseReport, error) {
+	var ids []string
+	var err error
+	if openOnly {
+		ids, err = s.client.ZRange(ctx, KeyAbuseOpen, 0, limit-1).Result()
+	} else {
+		ids, err = s.client.ZRevRange(ctx, KeyAbuseReports, 0, limit-1).Result()
+	}
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = abuseReportKey(id)
+	}
+	vals, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]*domain.AbuseReport, 0, len(ids))
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue // Expired
+		}
+		var rep domain.AbuseReport
+		if err := json.Unmarshal([]byte(str), &rep); err == nil {
+			reports = append(reports, &rep)
+		}
+	}
+	return reports, nil
+}
+
+// CountOpenAbuseReports returns the length of the review queue
+func (s *Store) CountOpenAbuseReports(ctx context.Context) (int64, error) {
+	return s.client.ZCard(ctx, KeyAbuseOpen).Result()
+}
+
+func blockedField(emailDomain, local string) string {
+	return email.CanonicalDomain(emailDomain) + ":" + local
+}
+
+// BlockAddress takes an address out of service (see domain.BlockedAddress)
+func (s *Store) BlockAddress(ctx context.Context, b *domain.BlockedAddress) error {
+	if b.BlockedAt.IsZero() {
+		b.BlockedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if err := s.client.HSet(ctx, KeyBlockedAddresses, blockedField(b.Domain, b.Local), data).Err(); err != nil {
+		return err
+	}
+	s.invalidate(ctx, "blocked")
+	return nil
+}
+
+// UnblockAddress lifts a block. Reports whether there was one.
+func (s *Store) UnblockAddress(ctx context.Context, emailDomain, local string) (bool, error) {
+	n, err := s.client.HDel(ctx, KeyBlockedAddresses, blockedField(emailDomain, local)).Result()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	s.invalidate(ctx, "blocked")
+	return true, nil
+}
+
+// ListBlockedAddresses returns every blocked address
+func (s *Store) ListBlockedAddresses(ctx context.Context) ([]*domain.BlockedAddress, error) {
+	vals, err := s.client.HGetAll(ctx, KeyBlockedAddresses).Result()
+	if err != nil {
+		return nil, err
+	}
+	blocked := make([]*domain.BlockedAddress, 0, len(vals))
+	for _, v := range vals {
+		var b domain.BlockedAddress
+		if err := json.Unmarshal([]byte(v), &b); err == nil {
+			blocked = append(blocked, &b)
+		}
+	}
+	return blocked, nil
+}
+
+// IsBlocked reports whether local@emailDomain is blocked. The set is
+// cached like the honeypots; lookup errors count as no.
+func (s *Store) IsBlocked(ctx context.Context, emailDomain, local string) bool {
+	blocked, ok := s.caches.blocked.Get(KeyBlockedAddresses)
+	if !ok {
+		fields, err := s.client.HKeys(ctx, KeyBlockedAddresses).Result()
+		if err != nil {
+			return false
+		}
+		blocked = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			blocked[f] = true
+		}
+		s.caches.blocked.Set(KeyBlockedAddresses, blocked)
+	}
+	return blocked[blockedField(emailDomain, local)]
+}
+
+// PurgeInbox deletes every message of an inbox and returns how many
+func (s *Store) PurgeInbox(ctx context.Context, emailDomain, local string) (int, error) {
+	ids, err := s.clientFor(emailDomain).ZRange(ctx, fmt.Sprintf("inbox:%s:%s", emailDomain, local), 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		if err := s.DeleteMessage(ctx, id); err != nil && err != redis.Nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,144 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Account store layout:
+//
+//	account:<id>            HASH with email, password, plan, created_at
+//	account:email:<email>   STRING account ID, enforces unique emails
+//	account:<id>:history    ZSET of local@domain scored by creation time
+//	session:<sid>:account   STRING account ID a login session belongs to
+const accountHistoryMax = 500
+
+// ErrAccountExists is returned when registering an email that is taken
+var ErrAccountExists = errors.New("account already exists")
+
+func accountKey(id string) string {
+	return fmt.Sprintf("account:%s", id)
+}
+
+// AccountSession is the session ID under which an account's addresses are
+// grouped, shared by every login of that account.
+func AccountSession(accountID string) string {
+	return "acct:" + accountID
+}
+
+// CreateAccount stores acct with a bcrypt password hash
+func (s *Store) CreateAccount(ctx context.Context, acct *domain.Account, passwordHash string) error {
+	ok, err := s.client.SetNX(ctx, fmt.Sprintf("account:email:%s", acct.Email), acct.ID, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAccountExists
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, accountKey(acct.ID), map[string]interface{}{
+		"email":      acct.Email,
+		"password":   passwordHash,
+		"plan":       acct.Plan,
+		"created_at": acct.CreatedAt.Unix(),
+	})
+	pipe.SAdd(ctx, planMembersKey(acct.Plan), acct.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetAccount returns the account and its password hash, or nil if absent
+func (s *Store) GetAccount(ctx context.Context, id string) (*domain.Account, string, error) {
+	vals, err := s.client.HMGet(ctx, accountKey(id), "email", "password", "created_at", "plan").Result()
+	if err != nil {
+		return nil, "", err
+	}
+	if vals[0] == nil {
+		return nil, "", nil
+	}
+
+	acct := &domain.Account{ID: id}
+	acct.Email, _ = vals[0].(string)
+	hash, _ := vals[1].(string)
+	if v, ok := vals[2].(string); ok {
+		ts, _ := strconv.ParseInt(v, 10, 64)
+		acct.CreatedAt = time.Unix(ts, 0)
+	}
+	acct.Plan, _ = vals[3].(string)
+	return acct, hash, nil
+}
+
+// GetAccountByEmail resolves an account by its login email
+func (s *Store) GetAccountByEmail(ctx context.Context, addr string) (*domain.Account, string, error) {
+	id, err := s.client.Get(ctx, fmt.Sprintf("account:email:%s", addr)).Result()
+	if err == redis.Nil {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return s.GetAccount(ctx, id)
+}
+
+// BindSessionAccount logs session sid into accountID for ttl
+func (s *Store) BindSessionAccount(ctx context.Context, sid, accountID string, ttl time.Duration) error {
+	return s.client.Set(ctx, sessionKey(sid)+":account", accountID, ttl).Err()
+}
+
+// UnbindSessionAccount logs session sid out
+func (s *Store) UnbindSessionAccount(ctx context.Context, sid string) error {
+	return s.client.Del(ctx, sessionKey(sid)+":account").Err()
+}
+
+// SessionAccount returns the account session sid is logged into, if any
+func (s *Store) SessionAccount(ctx context.Context, sid string) (string, error) {
+	id, err := s.client.Get(ctx, sessionKey(sid)+":account").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return id, err
+}
+
+// RecordAddressHistory appends local@emailDomain to the account's history
+func (s *Store) RecordAddressHistory(ctx context.Context, accountID, emailDomain, local string) error {
+	key := accountKey(accountID) + ":history"
+	pipe := s.client.Pipeline()
+	pipe.ZAddNX(ctx, key, redis.Z{Score: float64(time.Now().Unix()), Member: local + "@" + emailDomain})
+	pipe.ZRemRangeByRank(ctx, key, 0, -accountHistoryMax-1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AddressHistory returns the account's most recently created addresses
+func (s *Store) AddressHistory(ctx context.Context, accountID string, limit int) ([]domain.HistoryEntry, error) {
+	zs, err := s.client.ZRevRangeWithScores(ctx, accountKey(accountID)+":history", 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]domain.HistoryEntry, 0, len(zs))
+	for _, z := range zs {
+		entries = append(entries, domain.HistoryEntry{
+			Email:     fmt.Sprint(z.Member),
+			CreatedAt: time.Unix(int64(z.Score), 0),
+		})
+	}
+	return entries, nil
+}
+
+// ExtendAddress sets the lifetime of an existing address to ttl
+func (s *Store) ExtendAddress(ctx context.Context, emailDomain, local string, ttl time.Duration) error {
+	pipe := s.clientFor(emailDomain).Pipeline()
+	pipe.ExpireXX(ctx, fmt.Sprintf("addr:%s:%s", emailDomain, local), ttl)
+	pipe.ExpireXX(ctx, fmt.Sprintf("inbox:%s:%s", emailDomain, local), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
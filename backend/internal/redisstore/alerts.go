@@ -0,0 +1,90 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"cattymail/internal/domain"
+)
+
+// Alerting layout:
+//
+//	alert:rules   HASH of rule ID -> AlertRule JSON
+//	alert:firing  HASH of rule ID -> Alert JSON, maintained by the scheduler
+const (
+	KeyAlertRules  = "alert:rules"
+	KeyAlertFiring = "alert:firing"
+)
+
+// SaveAlertRule creates or replaces a rule
+func (s *Store) SaveAlertRule(ctx context.Context, rule *domain.AlertRule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyAlertRules, rule.ID, data).Err()
+}
+
+// ListAlertRules returns every rule
+func (s *Store) ListAlertRules(ctx context.Context) ([]*domain.AlertRule, error) {
+	vals, err := s.client.HGetAll(ctx, KeyAlertRules).Result()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*domain.AlertRule, 0, len(vals))
+	for _, v := range vals {
+		var rule domain.AlertRule
+		if err := json.Unmarshal([]byte(v), &rule); err != nil {
+			continue
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, nil
+}
+
+// DeleteAlertRule removes a rule and any alert it has firing. It reports
+// false when the rule does not exist.
+func (s *Store) DeleteAlertRule(ctx context.Context, id string) (bool, error) {
+	removed, err := s.client.HDel(ctx, KeyAlertRules, id).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+	return true, s.client.HDel(ctx, KeyAlertFiring, id).Err()
+}
+
+// SetFiringAlerts replaces the set of firing alerts
+func (s *Store) SetFiringAlerts(ctx context.Context, alerts []*domain.Alert) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, KeyAlertFiring)
+	for _, a := range alerts {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(ctx, KeyAlertFiring, a.RuleID, data)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ListFiringAlerts returns the alerts currently firing
+func (s *Store) ListFiringAlerts(ctx context.Context) ([]*domain.Alert, error) {
+	vals, err := s.client.HGetAll(ctx, KeyAlertFiring).Result()
+	if err != nil {
+		return nil, err
+	}
+	alerts := make([]*domain.Alert, 0, len(vals))
+	for _, v := range vals {
+		var a domain.Alert
+		if err := json.Unmarshal([]byte(v), &a); err != nil {
+			continue
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, nil
+}
+
+// Ping checks that the primary Redis answers
+func (s *Store) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
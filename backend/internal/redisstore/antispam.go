@@ -0,0 +1,109 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+
+	"cattymail/internal/antispam"
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Antispam model layout:
+//
+//	antispam:docs  HASH of class ("spam", "ham") -> messages trained
+//	antispam:spam  HASH of token -> spam messages containing it
+//	antispam:ham   HASH of token -> ham messages containing it
+const (
+	KeyAntispamDocs = "antispam:docs"
+	KeyAntispamSpam = "antispam:spam"
+	KeyAntispamHam  = "antispam:ham"
+)
+
+// Antispam classes
+const (
+	ClassSpam = "spam"
+	ClassHam  = "ham"
+)
+
+func antispamKey(class string) string {
+	if class == ClassSpam {
+		return KeyAntispamSpam
+	}
+	return KeyAntispamHam
+}
+
+// AntispamCounts returns the model counts for tokens
+func (s *Store) AntispamCounts(ctx context.Context, tokens []string) (antispam.Counts, error) {
+	var c antispam.Counts
+	pipe := s.client.Pipeline()
+	docs := pipe.HMGet(ctx, KeyAntispamDocs, ClassSpam, ClassHam)
+	var spam, ham *redis.SliceCmd
+	if len(tokens) > 0 {
+		spam = pipe.HMGet(ctx, KeyAntispamSpam, tokens...)
+		ham = pipe.HMGet(ctx, KeyAntispamHam, tokens...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return c, err
+	}
+	d := toInts(docs.Val())
+	c.SpamDocs, c.HamDocs = d[0], d[1]
+	if spam != nil {
+		c.Spam, c.Ham = toInts(spam.Val()), toInts(ham.Val())
+	}
+	return c, nil
+}
+
+// TrainAntispam adds (delta 1) or removes (delta -1) a message with tokens
+// to or from class
+func (s *Store) TrainAntispam(ctx context.Context, class string, tokens []string, delta int64) error {
+	pipe := s.client.Pipeline()
+	trainAntispam(ctx, pipe, class, tokens, delta)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// LearnSpam trains a reported message as spam, taking it out of ham when it
+// was trained as ham at ingest (msg.SpamScore set)
+func (s *Store) LearnSpam(ctx context.Context, msg *domain.Message) error {
+	return s.moveAntispam(ctx, msg, 1)
+}
+
+// UnlearnSpam reverses LearnSpam for a message found not to be spam
+func (s *Store) UnlearnSpam(ctx context.Context, msg *domain.Message) error {
+	return s.moveAntispam(ctx, msg, -1)
+}
+
+func (s *Store) moveAntispam(ctx context.Context, msg *domain.Message, delta int64) error {
+	tokens := antispam.Tokenize(msg)
+	pipe := s.client.Pipeline()
+	trainAntispam(ctx, pipe, ClassSpam, tokens, delta)
+	if msg.SpamScore != nil {
+		trainAntispam(ctx, pipe, ClassHam, tokens, -delta)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func trainAntispam(ctx context.Context, pipe redis.Pipeliner, class string, tokens []string, delta int64) {
+	key := antispamKey(class)
+	pipe.HIncrBy(ctx, KeyAntispamDocs, class, delta)
+	for _, t := range tokens {
+		pipe.HIncrBy(ctx, key, t, delta)
+	}
+}
+
+// toInts converts HMGET replies to counts, missing fields and negative
+// counts (from untraining races) read as 0
+func toInts(vals []interface{}) []int64 {
+	out := make([]int64, len(vals))
+	for i, v := range vals {
+		if str, ok := v.(string); ok {
+			if n, err := strconv.ParseInt(str, 10, 64); err == nil && n > 0 {
+				out[i] = n
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cattymail/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Admin API tokens layout:
+//
+//	admin:tokens  HASH of token ID -> APIToken JSON
+const KeyAdminTokens = "admin:tokens"
+
+// SaveAPIToken creates or replaces a token
+func (s *Store) SaveAPIToken(ctx context.Context, tok *domain.APIToken) error {
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyAdminTokens, tok.ID, data).Err()
+}
+
+// GetAPIToken returns a token, or nil when it does not exist
+func (s *Store) GetAPIToken(ctx context.Context, id string) (*domain.APIToken, error) {
+	data, err := s.client.HGet(ctx, KeyAdminTokens, id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tok domain.APIToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+// ListAPITokens returns every token
+func (s *Store) ListAPITokens(ctx context.Context) ([]*domain.APIToken, error) {
+	vals, err := s.client.HGetAll(ctx, KeyAdminTokens).Result()
+	if err != nil {
+		return nil, err
+	}
+	toks := make([]*domain.APIToken, 0, len(vals))
+	for _, v := range vals {
+		var tok domain.APIToken
+		if err := json.Unmarshal([]byte(v), &tok); err != nil {
+			continue
+		}
+		toks = append(toks, &tok)
+	}
+	return toks, nil
+}
+
+// DeleteAPIToken revokes a token. It reports false when the token does
+// not exist.
+func (s *Store) DeleteAPIToken(ctx context.Context, id string) (bool, error) {
+	removed, err := s.client.HDel(ctx, KeyAdminTokens, id).Result()
+	return removed > 0, err
+}
+
+// TouchAPIToken records that a token was used at t, unless it was revoked
+// in the meantime
+func (s *Store) TouchAPIToken(ctx context.Context, tok *domain.APIToken, t time.Time) error {
+	tok.LastUsedAt = &t
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	return touchAPITokenScript.Run(ctx, s.client, []string{KeyAdminTokens}, tok.ID, data).Err()
+}
+
+var touchAPITokenScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+end
+return 1
+`)
@@ -0,0 +1,82 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Archiver state (on the primary):
+//
+//	archive:cursor  STRING unix time up to which expiring messages are archived
+//	archive:lock    STRING held by the process running an archive pass
+//
+// Messages due to expire are found through storage:expiry (see storage.go).
+const (
+	KeyArchiveCursor = "archive:cursor"
+	KeyArchiveLock   = "archive:lock"
+)
+
+// ExpiringMessages returns the messages on every backend that expire in
+// (from, until]
+func (s *Store) ExpiringMessages(ctx context.Context, from, until time.Time) ([]*domain.Message, error) {
+	var msgs []*domain.Message
+	for _, c := range s.clients() {
+		ids, err := c.ZRangeByScore(ctx, KeyStorageExpiry, &redis.ZRangeBy{
+			Min: "(" + strconv.FormatInt(from.Unix(), 10),
+			Max: strconv.FormatInt(until.Unix(), 10),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = "msg:" + id
+		}
+		vals, err := c.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			str, ok := v.(string)
+			if !ok {
+				continue // Already gone
+			}
+			if msg, err := decodeMessage([]byte(str)); err == nil {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// ArchiveCursor returns how far expiring messages have been archived, or
+// the zero time before the first pass
+func (s *Store) ArchiveCursor(ctx context.Context) (time.Time, error) {
+	secs, err := s.client.Get(ctx, KeyArchiveCursor).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// SetArchiveCursor records that messages expiring up to t are archived
+func (s *Store) SetArchiveCursor(ctx context.Context, t time.Time) error {
+	return s.client.Set(ctx, KeyArchiveCursor, t.Unix(), 0).Err()
+}
+
+// AcquireArchiveLock reports whether this process may run an archive pass;
+// the lock lapses after ttl
+func (s *Store) AcquireArchiveLock(ctx context.Context, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, KeyArchiveLock, time.Now().Unix(), ttl).Result()
+}
@@ -0,0 +1,86 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Audit log layout (on the primary):
+//
+//	audit:log  STREAM of admin actions (actor, action, target, detail),
+//	           capped (approximately) at AuditLogMax entries
+const (
+	KeyAuditLog = "audit:log"
+	AuditLogMax = 100000
+)
+
+// AppendAudit records an admin action
+func (s *Store) AppendAudit(ctx context.Context, e *domain.AuditEntry) error {
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: KeyAuditLog,
+		MaxLen: AuditLogMax,
+		Approx: true,
+		Values: map[string]interface{}{
+			"actor":  e.Actor,
+			"action": e.Action,
+			"target": e.Target,
+			"detail": e.Detail,
+		},
+	}).Err()
+}
+
+// ListAudit returns up to limit entries, newest first, optionally only
+// those whose target starts with target
+func (s *Store) ListAudit(ctx context.Context, target string, limit int) ([]*domain.AuditEntry, error) {
+	const batch = 500
+
+	end := "+"
+	entries := []*domain.AuditEntry{}
+	for len(entries) < limit {
+		msgs, err := s.client.XRevRangeN(ctx, KeyAuditLog, end, "-", batch).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			e := parseAuditEntry(m)
+			if target != "" && !strings.HasPrefix(e.Target, target) {
+				continue
+			}
+			entries = append(entries, e)
+			if len(entries) == limit {
+				break
+			}
+		}
+		if len(msgs) < batch {
+			break
+		}
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+	return entries, nil
+}
+
+func parseAuditEntry(m redis.XMessage) *domain.AuditEntry {
+	str := func(k string) string {
+		v, _ := m.Values[k].(string)
+		return v
+	}
+	e := &domain.AuditEntry{
+		ID:     m.ID,
+		Actor:  str("actor"),
+		Action: str("action"),
+		Target: str("target"),
+		Detail: str("detail"),
+	}
+	if ts, _, ok := strings.Cut(m.ID, "-"); ok {
+		if millis, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			e.Time = time.UnixMilli(millis)
+		}
+	}
+	return e
+}
@@ -0,0 +1,299 @@
+package redisstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BackupVersion is the archive format version written by ExportBackup.
+// Version 2 added hashes and the backend domain of each key.
+const BackupVersion = 2
+
+// backupPatterns lists the key namespaces included in a backup
+var backupPatterns = []string{"config:*", "addr:*", "inbox:*", "msg:*", KeyMessageIndex, "storage:*", "antispam:*"}
+
+// BackupRecord is a single line of a backup archive. The first line of
+// every archive is a record of type "header".
+type BackupRecord struct {
+	Type      string            `json:"type"`
+	Key       string            `json:"key,omitempty"`
+	Domain    string            `json:"domain,omitempty"` // a domain routed to the key's backend, empty for the primary
+	TTLMs     int64             `json:"ttl_ms,omitempty"` // 0 means no expiry
+	Value     string            `json:"value,omitempty"`
+	Binary    []byte            `json:"binary,omitempty"` // string values that are not UTF-8 (encoded messages)
+	Members   []string          `json:"members,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Scored    []BackupMember    `json:"scored,omitempty"`
+	Version   int               `json:"version,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitempty"`
+}
+
+type BackupMember struct {
+	Member string  `json:"m"`
+	Score  float64 `json:"s"`
+}
+
+// BackupStats summarises an export or restore run
+type BackupStats struct {
+	Written  int `json:"written"`
+	Restored int `json:"restored"`
+	Skipped  int `json:"skipped"`
+}
+
+// ExportBackup writes every address, inbox index, message, message index,
+// storage accounting, antispam model and dynamic config key of every
+// backend to w as gzip-compressed JSONL, preserving remaining TTLs.
+func (s *Store) ExportBackup(ctx context.Context, w io.Writer) (*BackupStats, error) {
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	stats := &BackupStats{}
+
+	header := BackupRecord{Type: "header", Version: BackupVersion, CreatedAt: time.Now().UTC()}
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+
+	for _, c := range s.clients() {
+		backend := s.backendDomain(c)
+		for _, pattern := range backupPatterns {
+			var cursor uint64
+			for {
+				keys, nextCursor, err := c.Scan(ctx, cursor, pattern, 100).Result()
+				if err != nil {
+					return nil, err
+				}
+
+				for _, key := range keys {
+					rec, err := dumpKey(ctx, c, key)
+					if err != nil {
+						return nil, fmt.Errorf("dump %s: %w", key, err)
+					}
+					if rec == nil {
+						continue // Expired while scanning or unsupported type
+					}
+					rec.Domain = backend
+					if err := enc.Encode(rec); err != nil {
+						return nil, err
+					}
+					stats.Written++
+				}
+
+				cursor = nextCursor
+				if cursor == 0 {
+					break
+				}
+			}
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// backendDomain names a domain routed to c so a restore can find the same
+// backend again, or "" for the primary
+func (s *Store) backendDomain(c *redis.Client) string {
+	if c == s.client {
+		return ""
+	}
+	var domains []string
+	for d, b := range s.backends {
+		if b == c {
+			domains = append(domains, d)
+		}
+	}
+	sort.Strings(domains)
+	return domains[0]
+}
+
+func dumpKey(ctx context.Context, c *redis.Client, key string) (*BackupRecord, error) {
+	keyType, err := c.Type(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &BackupRecord{Type: keyType, Key: key}
+	switch keyType {
+	case "string":
+		rec.Value, err = c.Get(ctx, key).Result()
+		if !utf8.ValidString(rec.Value) {
+			rec.Binary, rec.Value = []byte(rec.Value), ""
+		}
+	case "set":
+		rec.Members, err = c.SMembers(ctx, key).Result()
+	case "hash":
+		rec.Fields, err = c.HGetAll(ctx, key).Result()
+	case "zset":
+		var zs []redis.Z
+		zs, err = c.ZRangeWithScores(ctx, key, 0, -1).Result()
+		for _, z := range zs {
+			rec.Scored = append(rec.Scored, BackupMember{Member: fmt.Sprint(z.Member), Score: z.Score})
+		}
+	default:
+		return nil, nil
+	}
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, err := c.PTTL(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if ttl == -2 {
+		return nil, nil // Key vanished
+	}
+	if ttl > 0 {
+		rec.TTLMs = ttl.Milliseconds()
+	}
+	return rec, nil
+}
+
+// ImportBackup restores an archive produced by ExportBackup. TTLs are
+// shortened by the time elapsed since the archive was written and keys that
+// would already have expired are skipped. Keys go back to the backend of
+// their domain. Existing string and hash keys are only replaced when
+// overwrite is set (hashes otherwise only gain missing fields); sets and
+// zsets are merged.
+func (s *Store) ImportBackup(ctx context.Context, r io.Reader, overwrite bool) (*BackupStats, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	// Messages may hold large HTML bodies
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	stats := &BackupStats{}
+	var elapsed time.Duration
+	first := true
+
+	for scanner.Scan() {
+		var rec BackupRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return stats, fmt.Errorf("invalid backup record: %w", err)
+		}
+
+		if first {
+			first = false
+			if rec.Type != "header" {
+				return stats, fmt.Errorf("missing backup header")
+			}
+			if rec.Version > BackupVersion {
+				return stats, fmt.Errorf("unsupported backup version %d", rec.Version)
+			}
+			elapsed = time.Since(rec.CreatedAt)
+			continue
+		}
+
+		var ttl time.Duration
+		if rec.TTLMs > 0 {
+			ttl = time.Duration(rec.TTLMs)*time.Millisecond - elapsed
+			if ttl <= 0 {
+				stats.Skipped++
+				continue
+			}
+		}
+
+		restored, err := s.restoreKey(ctx, &rec, ttl, overwrite)
+		if err != nil {
+			return stats, fmt.Errorf("restore %s: %w", rec.Key, err)
+		}
+		if restored {
+			stats.Restored++
+		} else {
+			stats.Skipped++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+	if first {
+		return stats, fmt.Errorf("empty backup archive")
+	}
+	return stats, nil
+}
+
+func (s *Store) restoreKey(ctx context.Context, rec *BackupRecord, ttl time.Duration, overwrite bool) (bool, error) {
+	c := s.clientFor(rec.Domain)
+	switch rec.Type {
+	case "string":
+		var value interface{} = rec.Value
+		if rec.Binary != nil {
+			value = rec.Binary
+		}
+		if overwrite {
+			return true, c.Set(ctx, rec.Key, value, ttl).Err()
+		}
+		return c.SetNX(ctx, rec.Key, value, ttl).Result()
+	case "set":
+		if len(rec.Members) == 0 {
+			return false, nil
+		}
+		pipe := c.Pipeline()
+		pipe.SAdd(ctx, rec.Key, toInterfaces(rec.Members)...)
+		if ttl > 0 {
+			pipe.Expire(ctx, rec.Key, ttl)
+		}
+		_, err := pipe.Exec(ctx)
+		return err == nil, err
+	case "zset":
+		if len(rec.Scored) == 0 {
+			return false, nil
+		}
+		zs := make([]redis.Z, 0, len(rec.Scored))
+		for _, m := range rec.Scored {
+			zs = append(zs, redis.Z{Score: m.Score, Member: m.Member})
+		}
+		pipe := c.Pipeline()
+		pipe.ZAdd(ctx, rec.Key, zs...)
+		if ttl > 0 {
+			pipe.Expire(ctx, rec.Key, ttl)
+		}
+		_, err := pipe.Exec(ctx)
+		return err == nil, err
+	case "hash":
+		if len(rec.Fields) == 0 {
+			return false, nil
+		}
+		pipe := c.TxPipeline()
+		if overwrite {
+			pipe.Del(ctx, rec.Key)
+			pipe.HSet(ctx, rec.Key, rec.Fields)
+		} else {
+			for field, value := range rec.Fields {
+				pipe.HSetNX(ctx, rec.Key, field, value)
+			}
+		}
+		if ttl > 0 {
+			pipe.Expire(ctx, rec.Key, ttl)
+		}
+		_, err := pipe.Exec(ctx)
+		return err == nil, err
+	}
+	return false, nil
+}
+
+func toInterfaces(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
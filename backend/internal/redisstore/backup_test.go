@@ -0,0 +1,67 @@
+package redisstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	s := benchStore(t)
+	ctx := context.Background()
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	str, set, zset, hash := "config:backup-str:"+suffix, "config:backup-set:"+suffix, "inbox:backup.test:"+suffix, "antispam:backup-"+suffix
+	pipe := s.client.Pipeline()
+	pipe.Set(ctx, str, "value", time.Minute)
+	pipe.Set(ctx, "msg:"+suffix, []byte{0xff, 0x00, 0x01}, time.Minute)
+	pipe.SAdd(ctx, set, "a", "b")
+	pipe.Expire(ctx, set, time.Minute)
+	pipe.ZAdd(ctx, zset, redis.Z{Score: 1, Member: "m1"}, redis.Z{Score: 2, Member: "m2"})
+	pipe.Expire(ctx, zset, time.Minute)
+	pipe.HSet(ctx, hash, map[string]interface{}{"tok": 3, "other": 7})
+	pipe.Expire(ctx, hash, time.Minute)
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if _, err := s.ExportBackup(ctx, &archive); err != nil {
+		t.Fatalf("ExportBackup: %v", err)
+	}
+	keys := []string{str, "msg:" + suffix, set, zset, hash}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ImportBackup(ctx, &archive, false); err != nil {
+		t.Fatalf("ImportBackup: %v", err)
+	}
+
+	if got := s.client.Get(ctx, str).Val(); got != "value" {
+		t.Errorf("string = %q, want %q", got, "value")
+	}
+	if got := s.client.Get(ctx, "msg:"+suffix).Val(); got != string([]byte{0xff, 0x00, 0x01}) {
+		t.Errorf("binary string = %x, want ff0001", got)
+	}
+	if got := s.client.SMembers(ctx, set).Val(); len(got) != 2 {
+		t.Errorf("set = %v, want 2 members", got)
+	}
+	if got := s.client.ZRangeWithScores(ctx, zset, 0, -1).Val(); len(got) != 2 || got[1].Score != 2 {
+		t.Errorf("zset = %v, want m1:1 m2:2", got)
+	}
+	want := map[string]string{"tok": "3", "other": "7"}
+	if got := s.client.HGetAll(ctx, hash).Val(); !reflect.DeepEqual(got, want) {
+		t.Errorf("hash = %v, want %v", got, want)
+	}
+	for _, key := range keys {
+		if ttl := s.client.PTTL(ctx, key).Val(); ttl <= 0 || ttl > time.Minute {
+			t.Errorf("TTL of %s = %v, want within a minute", key, ttl)
+		}
+	}
+	s.client.Del(ctx, keys...)
+}
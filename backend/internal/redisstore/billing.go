@@ -0,0 +1,76 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Billing store layout:
+//
+//	billing:plan:<plan>     SET of account IDs on that plan
+//	billing:event:<id>      marker for processed webhook events
+const billingEventTTL = 7 * 24 * time.Hour
+
+func planMembersKey(plan string) string {
+	return fmt.Sprintf("billing:plan:%s", plan)
+}
+
+// AccountPlan returns the plan ID stored for accountID
+func (s *Store) AccountPlan(ctx context.Context, accountID string) (string, error) {
+	plan, err := s.client.HGet(ctx, accountKey(accountID), "plan").Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return plan, err
+}
+
+// SetAccountPlan moves accountID onto plan, recording the subscription
+// that paid for it
+func (s *Store) SetAccountPlan(ctx context.Context, accountID, plan, subscriptionID string) error {
+	old, err := s.AccountPlan(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, accountKey(accountID), "plan", plan, "subscription", subscriptionID)
+	if old != "" && old != plan {
+		pipe.SRem(ctx, planMembersKey(old), accountID)
+	}
+	pipe.SAdd(ctx, planMembersKey(plan), accountID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// MarkBillingEvent records a webhook event ID and reports whether it was
+// seen for the first time, making webhook handling idempotent
+func (s *Store) MarkBillingEvent(ctx context.Context, eventID string) (bool, error) {
+	return s.client.SetNX(ctx, fmt.Sprintf("billing:event:%s", eventID), "1", billingEventTTL).Result()
+}
+
+// ForgetBillingEvent removes a webhook event ID recorded by
+// MarkBillingEvent, so a retried delivery is applied again
+func (s *Store) ForgetBillingEvent(ctx context.Context, eventID string) error {
+	return s.client.Del(ctx, fmt.Sprintf("billing:event:%s", eventID)).Err()
+}
+
+// PlanMemberCounts returns how many accounts are on each of plans
+func (s *Store) PlanMemberCounts(ctx context.Context, plans []string) (map[string]int64, error) {
+	pipe := s.client.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(plans))
+	for _, p := range plans {
+		cmds[p] = pipe.SCard(ctx, planMembersKey(p))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(plans))
+	for p, cmd := range cmds {
+		counts[p] = cmd.Val()
+	}
+	return counts, nil
+}
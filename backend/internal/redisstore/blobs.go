@@ -0,0 +1,78 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The blob store holds derived binary artifacts of a message (thumbnails)
+// and expires them together with the message:
+//
+//	blob:<message id>:<name>   STRING raw bytes
+//	blobs:<message id>         SET of the names stored for the message
+func blobKey(id, name string) string {
+	return fmt.Sprintf("blob:%s:%s", id, name)
+}
+
+func blobNamesKey(id string) string {
+	return "blobs:" + id
+}
+
+// maxBlobSize keeps a single artifact from bloating Redis
+const maxBlobSize = 1 << 20
+
+// PutMessageBlob stores data under name for message id. It is a no-op
+// when the message has already expired.
+func (s *Store) PutMessageBlob(ctx context.Context, id, name string, data []byte) error {
+	if len(data) > maxBlobSize {
+		return fmt.Errorf("blob %s of %s too large: %d bytes", name, id, len(data))
+	}
+	client, _, err := s.messageClient(ctx, id)
+	if err != nil || client == nil {
+		return err
+	}
+	ttl, err := client.PTTL(ctx, fmt.Sprintf("msg:%s", id)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl == -2 {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	pipe := client.TxPipeline()
+	pipe.Set(ctx, blobKey(id, name), data, ttl)
+	pipe.SAdd(ctx, blobNamesKey(id), name)
+	pipe.PExpire(ctx, blobNamesKey(id), ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// deleteMessageBlobs queues the removal of every artifact of message id
+// stored on client
+func (s *Store) deleteMessageBlobs(ctx context.Context, client *redis.Client, pipe redis.Pipeliner, id string) error {
+	names, err := client.SMembers(ctx, blobNamesKey(id)).Result()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		pipe.Del(ctx, blobKey(id, name))
+	}
+	pipe.Del(ctx, blobNamesKey(id))
+	return nil
+}
+
+// GetMessageBlob returns a stored artifact, or nil if there is none
+func (s *Store) GetMessageBlob(ctx context.Context, id, name string) ([]byte, error) {
+	for _, c := range s.clients() {
+		data, err := c.Get(ctx, blobKey(id, name)).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		return data, err
+	}
+	return nil, nil
+}
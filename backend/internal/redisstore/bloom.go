@@ -0,0 +1,93 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bloom filter of recently issued locals, kept as plain Redis bitmaps so no
+// module is required. Filters rotate every TTL period; membership checks
+// consult the current and previous period, which covers every address that
+// can still be alive.
+const (
+	bloomBits   = 1 << 22 // 512KB per domain per period
+	bloomHashes = 7
+)
+
+func (s *Store) bloomKey(emailDomain string, period int64) string {
+	return fmt.Sprintf("addrgen:bloom:%s:%d", emailDomain, period)
+}
+
+func (s *Store) bloomPeriod(t time.Time) int64 {
+	ttl := int64(s.ttl / time.Second)
+	if ttl <= 0 {
+		ttl = 86400
+	}
+	return t.Unix() / ttl
+}
+
+// bloomOffsets derives bloomHashes bit positions via double hashing
+func bloomOffsets(local string) []int64 {
+	h1 := fnv.New64a()
+	h1.Write([]byte(local))
+	a := h1.Sum64()
+
+	h2 := fnv.New64()
+	h2.Write([]byte(local))
+	b := h2.Sum64() | 1
+
+	offsets := make([]int64, bloomHashes)
+	for i := range offsets {
+		offsets[i] = int64((a + uint64(i)*b) % bloomBits)
+	}
+	return offsets
+}
+
+// RecentlyIssued reports whether local@emailDomain may have been issued
+// within the last TTL period. False positives are possible, false negatives
+// are not.
+func (s *Store) RecentlyIssued(ctx context.Context, emailDomain, local string) (bool, error) {
+	period := s.bloomPeriod(time.Now())
+	offsets := bloomOffsets(local)
+
+	for _, p := range []int64{period, period - 1} {
+		key := s.bloomKey(emailDomain, p)
+		pipe := s.client.Pipeline()
+		cmds := make([]*redis.IntCmd, len(offsets))
+		for i, off := range offsets {
+			cmds[i] = pipe.GetBit(ctx, key, off)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return false, err
+		}
+
+		all := true
+		for _, cmd := range cmds {
+			if cmd.Val() == 0 {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MarkIssued records local@emailDomain in the current period's filter
+func (s *Store) MarkIssued(ctx context.Context, emailDomain, local string) error {
+	key := s.bloomKey(emailDomain, s.bloomPeriod(time.Now()))
+
+	pipe := s.client.Pipeline()
+	for _, off := range bloomOffsets(local) {
+		pipe.SetBit(ctx, key, off, 1)
+	}
+	pipe.Expire(ctx, key, 2*s.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
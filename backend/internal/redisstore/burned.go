@@ -0,0 +1,117 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Burned domain detection layout:
+//
+//	ingest:hourly:<YYYYMMDDHH>  HASH domain -> messages ingested that hour (UTC)
+//	domain:burn:<domain>        HASH provider -> JSON domain.BurnSignal (latest)
+//
+// Hourly counts are kept for BurnHistoryHours; signals expire BurnSignalTTL
+// after the last one, so a domain that stopped bouncing clears itself.
+const (
+	BurnHistoryHours = 7 * 24
+	BurnSignalTTL    = 7 * 24 * time.Hour
+)
+
+func hourlyKey(t time.Time) string {
+	return "ingest:hourly:" + t.UTC().Format("2006010215")
+}
+
+// RecordDomainHourly counts one message ingested for emailDomain this hour
+func (s *Store) RecordDomainHourly(ctx context.Context, emailDomain string) error {
+	key := hourlyKey(time.Now())
+	pipe := s.client.Pipeline()
+	pipe.HIncrBy(ctx, key, email.CanonicalDomain(emailDomain), 1)
+	pipe.Expire(ctx, key, (BurnHistoryHours+1)*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DomainHourly returns the hourly ingest counts of each domain over the
+// last hours, oldest first and ending with the current hour
+func (s *Store) DomainHourly(ctx context.Context, domains []string, hours int) (map[string][]int64, error) {
+	if len(domains) == 0 {
+		return map[string][]int64{}, nil
+	}
+	fields := make([]string, len(domains))
+	for i, d := range domains {
+		fields[i] = email.CanonicalDomain(d)
+	}
+	now := time.Now()
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, hours)
+	for i := 0; i < hours; i++ {
+		cmds[i] = pipe.HMGet(ctx, hourlyKey(now.Add(-time.Duration(hours-1-i)*time.Hour)), fields...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string][]int64, len(domains))
+	for _, d := range domains {
+		counts[d] = make([]int64, hours)
+	}
+	for i, cmd := range cmds {
+		for j, n := range toInts(cmd.Val()) {
+			counts[domains[j]][i] = n
+		}
+	}
+	return counts, nil
+}
+
+// RecordBurnSignal keeps sig as the latest signal of its provider against
+// emailDomain
+func (s *Store) RecordBurnSignal(ctx context.Context, emailDomain string, sig domain.BurnSignal) error {
+	if sig.SeenAt.IsZero() {
+		sig.SeenAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	key := "domain:burn:" + email.CanonicalDomain(emailDomain)
+	pipe := s.client.Pipeline()
+	pipe.HSet(ctx, key, sig.Provider, data)
+	pipe.Expire(ctx, key, BurnSignalTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// BurnSignals returns the recent signals of each domain that has any
+func (s *Store) BurnSignals(ctx context.Context, domains []string) (map[string][]domain.BurnSignal, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(domains))
+	for i, d := range domains {
+		cmds[i] = pipe.HGetAll(ctx, "domain:burn:"+email.CanonicalDomain(d))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	signals := make(map[string][]domain.BurnSignal)
+	for i, cmd := range cmds {
+		for _, v := range cmd.Val() {
+			var sig domain.BurnSignal
+			if json.Unmarshal([]byte(v), &sig) == nil {
+				signals[domains[i]] = append(signals[domains[i]], sig)
+			}
+		}
+	}
+	return signals, nil
+}
+
+// ClearBurnSignals forgets the signals against emailDomain, once an
+// operator has dealt with them
+func (s *Store) ClearBurnSignals(ctx context.Context, emailDomain string) error {
+	return s.client.Del(ctx, "domain:burn:"+email.CanonicalDomain(emailDomain)).Err()
+}
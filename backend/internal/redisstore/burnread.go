@@ -0,0 +1,100 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Burn-after-reading layout:
+//
+//	burnread:<domain>:<local>  STRING set while the inbox burns messages on read
+//
+// A burned message is deleted by the same script that returns it, so at
+// most one reader ever gets it.
+func burnReadKey(emailDomain, local string) string {
+	return fmt.Sprintf("burnread:%s:%s", emailDomain, local)
+}
+
+// burnScript returns a message and removes it from its inbox and the
+// message index in one step
+var burnScript = redis.NewScript(`
+local data = redis.call('GET', KEYS[1])
+if not data then
+	return false
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('ZREM', KEYS[3], ARGV[1])
+return data
+`)
+
+// SetBurnAfterReading turns burn-after-reading on or off for an inbox. The
+// setting expires with the address.
+func (s *Store) SetBurnAfterReading(ctx context.Context, emailDomain, local string, on bool) error {
+	client := s.clientFor(emailDomain)
+	if !on {
+		return client.Del(ctx, burnReadKey(emailDomain, local)).Err()
+	}
+	ttl, err := client.PTTL(ctx, fmt.Sprintf("addr:%s:%s", emailDomain, local)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	return client.Set(ctx, burnReadKey(emailDomain, local), "1", ttl).Err()
+}
+
+// BurnsAfterReading reports whether an inbox burns messages on read
+func (s *Store) BurnsAfterReading(ctx context.Context, emailDomain, local string) (bool, error) {
+	n, err := s.clientFor(emailDomain).Exists(ctx, burnReadKey(emailDomain, local)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// BurnMessage returns a message and deletes it atomically. It returns nil
+// when the message does not exist or another reader burned it first.
+func (s *Store) BurnMessage(ctx context.Context, id string) (*domain.Message, error) {
+	client, val, err := s.messageClient(ctx, id)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	msg, err := decodeMessage([]byte(val))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{
+		fmt.Sprintf("msg:%s", id),
+		fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local),
+		KeyMessageIndex,
+	}
+	data, err := burnScript.Run(ctx, client, keys, id).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Decode what the script returned, not the earlier read
+	if msg, err = decodeMessage([]byte(data)); err != nil {
+		return nil, err
+	}
+
+	pipe := client.Pipeline()
+	s.addRemoved(ctx, pipe, msg.Domain, msg.Local, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.unaccount(ctx, client, id); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, "msg:"+id)
+	return msg, nil
+}
@@ -0,0 +1,135 @@
+package redisstore
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cattymail/internal/domain"
+)
+
+// In-process cache tuning. Domains change rarely and are invalidated over
+// pub/sub; messages are immutable once stored, so only deletes invalidate.
+const (
+	domainCacheTTL   = 30 * time.Second
+	messageCacheTTL  = 60 * time.Second
+	messageCacheSize = 1000
+
+	// ChannelCacheInvalidate carries "domains" (list and states) or
+	// "msg:<id>" payloads so every process sharing the Redis instance drops
+	// stale entries.
+	ChannelCacheInvalidate = "cache:invalidate"
+)
+
+type cacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// ttlCache is a small size-bounded map with per-entry expiry
+type ttlCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]cacheEntry[V]
+	ttl     time.Duration
+	max     int
+}
+
+func newTTLCache[K comparable, V any](ttl time.Duration, max int) *ttlCache[K, V] {
+	return &ttlCache[K, V]{entries: make(map[K]cacheEntry[V]), ttl: ttl, max: max}
+}
+
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *ttlCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.max {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: drop an arbitrary entry
+		for k := range c.entries {
+			if len(c.entries) < c.max {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry[V]{value: value, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *ttlCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// storeCaches groups the read-through caches owned by a Store
+type storeCaches struct {
+	domains      *ttlCache[string, []string]
+	domainStates *ttlCache[string, map[string]string] // treated as read-only
+	messages     *ttlCache[string, *domain.Message]
+	honeypots    *ttlCache[string, map[string]bool]   // treated as read-only
+	blocked      *ttlCache[string, map[string]bool]   // treated as read-only
+	frozen       *ttlCache[string, map[string]string] // treated as read-only
+}
+
+func newStoreCaches() *storeCaches {
+	return &storeCaches{
+		domains:      newTTLCache[string, []string](domainCacheTTL, 1),
+		domainStates: newTTLCache[string, map[string]string](domainCacheTTL, 1),
+		messages:     newTTLCache[string, *domain.Message](messageCacheTTL, messageCacheSize),
+		honeypots:    newTTLCache[string, map[string]bool](domainCacheTTL, 1),
+		blocked:      newTTLCache[string, map[string]bool](domainCacheTTL, 1),
+		frozen:       newTTLCache[string, map[string]string](domainCacheTTL, 1),
+	}
+}
+
+// invalidate drops local entries and tells other processes to do the same
+func (s *Store) invalidate(ctx context.Context, target string) {
+	s.applyInvalidation(target)
+	_ = s.client.Publish(ctx, ChannelCacheInvalidate, target).Err()
+}
+
+func (s *Store) applyInvalidation(target string) {
+	switch {
+	case target == "domains":
+		s.caches.domains.Delete(KeyConfigDomains)
+		s.caches.domainStates.Delete(KeyDomainStates)
+	case target == "honeypots":
+		s.caches.honeypots.Delete(KeyHoneypots)
+	case target == "blocked":
+		s.caches.blocked.Delete(KeyBlockedAddresses)
+	case target == "frozen":
+		s.caches.frozen.Delete(KeyFrozenAddresses)
+	case strings.HasPrefix(target, "msg:"):
+		s.caches.messages.Delete(strings.TrimPrefix(target, "msg:"))
+	}
+}
+
+// watchInvalidations applies invalidations published by other processes
+func (s *Store) watchInvalidations(ctx context.Context) {
+	pubsub := s.client.Subscribe(ctx, ChannelCacheInvalidate)
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		s.applyInvalidation(msg.Payload)
+	}
+	log.Println("Cache invalidation subscription closed")
+}
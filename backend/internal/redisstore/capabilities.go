@@ -0,0 +1,156 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BackendCapabilities is what a Redis backend offers of the optional
+// server features CattyMail can use
+type BackendCapabilities struct {
+	Addr    string   `json:"addr"`
+	Version string   `json:"version"`
+	Modules []string `json:"modules"`
+	// Streams (XADD), Redis 5.0+
+	Streams bool `json:"streams"`
+	// MEMORY USAGE, Redis 4.0+
+	MemoryUsage bool `json:"memory_usage"`
+	// EXPIRE NX/XX/GT, Redis 7.0+
+	ExpireOptions bool `json:"expire_options"`
+	// RediSearch module loaded
+	Search bool `json:"search"`
+	// notify-keyspace-events; "" when off, "unknown" when CONFIG is not
+	// allowed (common on managed Redis)
+	KeyspaceEvents string `json:"keyspace_events"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Feature is an optional CattyMail feature and whether every backend
+// supports it
+type Feature struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// CapabilityReport is the capability matrix found by CheckCapabilities
+type CapabilityReport struct {
+	Backends  []BackendCapabilities `json:"backends"`
+	Features  []Feature             `json:"features"`
+	CheckedAt time.Time             `json:"checked_at"`
+}
+
+// features maps CattyMail features to the backend capability they need
+var features = []struct {
+	name  string
+	needs string
+	has   func(b BackendCapabilities) bool
+}{
+	{"audit_log", "streams (Redis 5.0+)", func(b BackendCapabilities) bool { return b.Streams }},
+	{"log_viewer", "streams (Redis 5.0+)", func(b BackendCapabilities) bool { return b.Streams }},
+	{"memory_report", "MEMORY USAGE (Redis 4.0+)", func(b BackendCapabilities) bool { return b.MemoryUsage }},
+	{"address_extension", "EXPIRE XX (Redis 7.0+)", func(b BackendCapabilities) bool { return b.ExpireOptions }},
+	{"consistency_repair", "EXPIRE NX (Redis 7.0+)", func(b BackendCapabilities) bool { return b.ExpireOptions }},
+}
+
+// CheckCapabilities detects the version, modules and settings of every
+// backend, logs which optional features work and keeps the result for
+// Capabilities. Nothing CattyMail needs depends on RediSearch or keyspace
+// notifications yet; they are reported so support can see them.
+func (s *Store) CheckCapabilities(ctx context.Context) *CapabilityReport {
+	rep := &CapabilityReport{CheckedAt: time.Now()}
+	for _, c := range s.clients() {
+		b := checkBackend(ctx, c)
+		if b.Error != "" {
+			log.Printf("Redis %s: capability check failed: %s", b.Addr, b.Error)
+		} else {
+			modules := "none"
+			if len(b.Modules) > 0 {
+				modules = strings.Join(b.Modules, ", ")
+			}
+			events := b.KeyspaceEvents
+			if events == "" {
+				events = "off"
+			}
+			log.Printf("Redis %s: version %s, modules: %s, keyspace notifications: %s", b.Addr, b.Version, modules, events)
+		}
+		rep.Backends = append(rep.Backends, b)
+	}
+
+	for _, f := range features {
+		feature := Feature{Name: f.name, Enabled: true}
+		for _, b := range rep.Backends {
+			if b.Error == "" && !f.has(b) {
+				feature.Enabled = false
+				feature.Detail = fmt.Sprintf("needs %s, %s has %s", f.needs, b.Addr, b.Version)
+				break
+			}
+		}
+		if !feature.Enabled {
+			log.Printf("Redis self-check: %s degraded: %s", f.name, feature.Detail)
+		}
+		rep.Features = append(rep.Features, feature)
+	}
+
+	s.capabilities.Store(rep)
+	return rep
+}
+
+// Capabilities returns the result of the last CheckCapabilities, or nil
+// if it has not run
+func (s *Store) Capabilities() *CapabilityReport {
+	return s.capabilities.Load()
+}
+
+func checkBackend(ctx context.Context, c *redis.Client) BackendCapabilities {
+	b := BackendCapabilities{Addr: c.Options().Addr, Modules: []string{}}
+
+	server, err := c.InfoMap(ctx, "server").Result()
+	if err != nil {
+		b.Error = err.Error()
+		return b
+	}
+	b.Version = server["Server"]["redis_version"]
+	major := majorVersion(b.Version)
+	b.MemoryUsage = major >= 4
+	b.Streams = major >= 5
+	b.ExpireOptions = major >= 7
+
+	// INFO modules lists one "module:name=<name>,ver=..." line per module;
+	// InfoMap would keep only the last one
+	if info, err := c.Info(ctx, "modules").Result(); err == nil {
+		for _, line := range strings.Split(info, "\n") {
+			fields, ok := strings.CutPrefix(strings.TrimSpace(line), "module:")
+			if !ok {
+				continue
+			}
+			for _, field := range strings.Split(fields, ",") {
+				if name, ok := strings.CutPrefix(field, "name="); ok {
+					b.Modules = append(b.Modules, name)
+					// RediSearch registers as "search" (2.x) or "ft" (1.x)
+					b.Search = b.Search || name == "search" || name == "ft"
+				}
+			}
+		}
+	}
+
+	b.KeyspaceEvents = "unknown"
+	if cfg, err := c.ConfigGet(ctx, "notify-keyspace-events").Result(); err == nil {
+		b.KeyspaceEvents = cfg["notify-keyspace-events"]
+	}
+
+	return b
+}
+
+// majorVersion returns the major number of a Redis version such as "7.2.4"
+func majorVersion(v string) int {
+	major, _, _ := strings.Cut(v, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
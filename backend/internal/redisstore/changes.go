@@ -0,0 +1,112 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Inbox change tracking layout (next to the inbox it tracks):
+//
+//	removed:<domain>:<local>  ZSET of message IDs deleted, quarantined or evicted, scored by removal time (unix seconds)
+//
+// Message IDs are ULIDs, so messages added after a given ID sort after it.
+// Expired messages leave no tombstone; InboxChanges reports the ones still
+// listed in the inbox.
+func removedKey(emailDomain, local string) string {
+	return fmt.Sprintf("removed:%s:%s", emailDomain, local)
+}
+
+// addRemoved queues a tombstone for id on pipe
+func (s *Store) addRemoved(ctx context.Context, pipe redis.Pipeliner, emailDomain, local, id string) {
+	key := removedKey(emailDomain, local)
+	now := time.Now().Unix()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now), Member: id})
+	if s.ttl > 0 {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now-int64(s.ttl/time.Second), 10))
+		pipe.Expire(ctx, key, s.ttl)
+	}
+}
+
+// InboxChanges is the delta of an inbox since a message ID
+type InboxChanges struct {
+	Added   []*domain.Message `json:"added"`
+	Removed []string          `json:"removed"`
+	// Cursor is the since_id to pass next time
+	Cursor string `json:"cursor"`
+	// More is set when Added was cut at the limit
+	More bool `json:"more"`
+}
+
+// GetInboxChanges returns up to limit messages added after sinceID, oldest
+// first, and the IDs removed since sinceID was created. An empty sinceID
+// returns the whole inbox. Removed may name messages the caller never saw.
+func (s *Store) GetInboxChanges(ctx context.Context, emailDomain, local, sinceID string, limit int) (*InboxChanges, error) {
+	sinceID = strings.ToUpper(sinceID)
+	client := s.clientFor(emailDomain)
+	ids, err := client.ZRange(ctx, fmt.Sprintf("inbox:%s:%s", emailDomain, local), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	changes := &InboxChanges{Added: []*domain.Message{}, Removed: []string{}, Cursor: sinceID}
+	if len(ids) > 0 {
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = "msg:" + id
+		}
+		vals, err := client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			id := strings.ToUpper(ids[i])
+			str, ok := v.(string)
+			if !ok {
+				// Expired but not yet trimmed from the inbox
+				if sinceID != "" && id <= sinceID {
+					changes.Removed = append(changes.Removed, ids[i])
+				}
+				continue
+			}
+			if id <= sinceID {
+				continue
+			}
+			if msg, err := decodeMessage([]byte(str)); err == nil {
+				changes.Added = append(changes.Added, msg)
+			}
+		}
+	}
+	sort.Slice(changes.Added, func(i, j int) bool {
+		return strings.ToUpper(changes.Added[i].ID) < strings.ToUpper(changes.Added[j].ID)
+	})
+	if len(changes.Added) > limit {
+		changes.Added, changes.More = changes.Added[:limit], true
+	}
+	if n := len(changes.Added); n > 0 {
+		changes.Cursor = strings.ToUpper(changes.Added[n-1].ID)
+	}
+
+	if sinceID != "" {
+		since, err := ulid.ParseStrict(sinceID)
+		if err != nil {
+			return nil, err
+		}
+		removed, err := client.ZRangeByScore(ctx, removedKey(emailDomain, local), &redis.ZRangeBy{
+			Min: strconv.FormatInt(int64(since.Time()/1000), 10),
+			Max: "+inf",
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		changes.Removed = append(changes.Removed, removed...)
+	}
+	return changes, nil
+}
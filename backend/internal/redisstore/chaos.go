@@ -0,0 +1,64 @@
+//go:build chaos
+
+package redisstore
+
+import (
+	"context"
+	"net"
+
+	"cattymail/internal/chaos"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisFaults are read once, for every client the store opens
+var redisFaults = chaos.FromEnv("redis")
+
+// newClient opens a Redis client with faults injected (see internal/chaos)
+func newClient(opts *redis.Options) *redis.Client {
+	c := redis.NewClient(opts)
+	c.AddHook(chaosHook{redisFaults})
+	return c
+}
+
+type chaosHook struct {
+	faults chaos.Faults
+}
+
+func (h chaosHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return h.faults.WrapConn(conn), nil
+	}
+}
+
+func (h chaosHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.faults.Delay(ctx); err != nil {
+			return err
+		}
+		if chaos.Hit(h.faults.ErrorPct) {
+			cmd.SetErr(chaos.ErrInjected)
+			return chaos.ErrInjected
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h chaosHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.faults.Delay(ctx); err != nil {
+			return err
+		}
+		if chaos.Hit(h.faults.DropPct) {
+			for _, cmd := range cmds {
+				cmd.SetErr(chaos.ErrInjected)
+			}
+			return chaos.ErrInjected
+		}
+		return next(ctx, cmds)
+	}
+}
@@ -0,0 +1,41 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+)
+
+// Link click layout:
+//
+//	clicks:<id>  HASH of link target -> clicks through the link proxy
+//
+// kept as long as messages so the abuse team can see what was followed.
+func clicksKey(id string) string {
+	return "clicks:" + id
+}
+
+// RecordClick counts a click on target in message id
+func (s *Store) RecordClick(ctx context.Context, id, target string) error {
+	pipe := s.client.Pipeline()
+	pipe.HIncrBy(ctx, clicksKey(id), target, 1)
+	if s.ttl > 0 {
+		pipe.Expire(ctx, clicksKey(id), s.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MessageClicks returns the click count of every link followed in message id
+func (s *Store) MessageClicks(ctx context.Context, id string) (map[string]int64, error) {
+	vals, err := s.client.HGetAll(ctx, clicksKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	clicks := make(map[string]int64, len(vals))
+	for target, v := range vals {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			clicks[target] = n
+		}
+	}
+	return clicks, nil
+}
@@ -0,0 +1,244 @@
+package redisstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Stored message payloads are either bare JSON (the default, readable by
+// every release) or an envelope:
+//
+//	0x00 <flags> <body>
+//
+// where the low nibble of flags names the encoding (codecJSON, codecMsgpack)
+// and flagGzip marks a gzip-compressed body. Decoding detects the format of
+// each key, so the codec can be switched without rewriting old messages.
+const (
+	envelopeMagic = 0x00
+	codecJSON     = 0x01
+	codecMsgpack  = 0x02
+	flagGzip      = 0x10
+)
+
+// MessageCodec selects how new messages are encoded
+type MessageCodec struct {
+	// Format is "json" (default) or "msgpack"
+	Format string
+	// GzipThreshold compresses payloads larger than this many bytes; 0
+	// disables compression
+	GzipThreshold int
+}
+
+// SetMessageCodec switches the encoding of newly stored messages
+func (s *Store) SetMessageCodec(c MessageCodec) error {
+	switch c.Format {
+	case "", "json", "msgpack":
+	default:
+		return fmt.Errorf("unknown message codec %q", c.Format)
+	}
+	s.codec = c
+	return nil
+}
+
+// messageUpgraders[v] upgrades a raw payload from schema version v to v+1.
+// Payloads written before versioning existed have no schema_version and are
+// treated as version 0.
+var messageUpgraders = map[int]func(raw map[string]json.RawMessage) error{
+	0: upgradeMessageV0,
+	1: upgradeMessageV1,
+}
+
+// encodeMessage serializes msg stamped with the current schema version
+func encodeMessage(msg *domain.Message, c MessageCodec) ([]byte, error) {
+	msg.SchemaVersion = domain.MessageSchemaVersion
+
+	format := byte(codecJSON)
+	var body []byte
+	var err error
+	if c.Format == "msgpack" {
+		format = codecMsgpack
+		body, err = marshalMsgpack(msg)
+	} else {
+		body, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.GzipThreshold <= 0 || len(body) <= c.GzipThreshold {
+		if format == codecJSON {
+			return body, nil
+		}
+		return append([]byte{envelopeMagic, format}, body...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write([]byte{envelopeMagic, format | flagGzip})
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeMessage deserializes a stored message in any supported encoding,
+// upgrading older payloads
+func decodeMessage(data []byte) (*domain.Message, error) {
+	if len(data) >= 2 && data[0] == envelopeMagic {
+		flags := data[1]
+		body := data[2:]
+		if flags&flagGzip != 0 {
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			if body, err = io.ReadAll(gz); err != nil {
+				return nil, err
+			}
+		}
+
+		switch flags & 0x0f {
+		case codecJSON:
+			data = body
+		case codecMsgpack:
+			msg, err := unmarshalMsgpack(body)
+			if err != nil {
+				return nil, err
+			}
+			if msg.SchemaVersion >= domain.MessageSchemaVersion {
+				return msg, nil
+			}
+			// Older schema: run the JSON upgraders
+			if data, err = json.Marshal(msg); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown message encoding 0x%02x", flags)
+		}
+	}
+
+	return decodeJSONMessage(data)
+}
+
+// msgpack reuses the json tags so both encodings share field names
+func marshalMsgpack(msg *domain.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetOmitEmpty(true)
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unmarshalMsgpack(data []byte) (*domain.Message, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	var msg domain.Message
+	if err := dec.Decode(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func decodeJSONMessage(data []byte) (*domain.Message, error) {
+	var probe struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	// Fast path: current (or newer, decoded best-effort) payloads
+	if probe.SchemaVersion >= domain.MessageSchemaVersion {
+		var msg domain.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for v := probe.SchemaVersion; v < domain.MessageSchemaVersion; v++ {
+		upgrade, ok := messageUpgraders[v]
+		if !ok {
+			return nil, fmt.Errorf("no upgrader for message schema version %d", v)
+		}
+		if err := upgrade(raw); err != nil {
+			return nil, fmt.Errorf("upgrade message schema %d: %w", v, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var msg domain.Message
+	if err := json.Unmarshal(upgraded, &msg); err != nil {
+		return nil, err
+	}
+	msg.SchemaVersion = domain.MessageSchemaVersion
+	return &msg, nil
+}
+
+// upgradeMessageV0 backfills the size of messages stored before it was
+// recorded, approximated from the decoded bodies.
+func upgradeMessageV0(raw map[string]json.RawMessage) error {
+	if _, ok := raw["size"]; ok {
+		return nil
+	}
+	var text, html string
+	if v, ok := raw["text"]; ok {
+		if err := json.Unmarshal(v, &text); err != nil {
+			return err
+		}
+	}
+	if v, ok := raw["html"]; ok {
+		if err := json.Unmarshal(v, &html); err != nil {
+			return err
+		}
+	}
+	raw["size"] = json.RawMessage(fmt.Sprint(len(text) + len(html)))
+	return nil
+}
+
+// upgradeMessageV1 splits the raw From value of older messages into
+// from_name and from_address
+func upgradeMessageV1(raw map[string]json.RawMessage) error {
+	if _, ok := raw["from_address"]; ok {
+		return nil
+	}
+	var from string
+	if v, ok := raw["from"]; ok {
+		if err := json.Unmarshal(v, &from); err != nil {
+			return err
+		}
+	}
+	name, address := email.SplitFrom(from)
+	for key, val := range map[string]string{"from_name": name, "from_address": address} {
+		if val == "" {
+			continue
+		}
+		b, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		raw[key] = b
+	}
+	return nil
+}
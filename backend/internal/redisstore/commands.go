@@ -0,0 +1,110 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Command bus between the API and the ingestor, over pub/sub:
+//
+//	ingest:commands       channel carrying Command JSON to the ingestor
+//	ingest:replies:<id>   channel carrying the CommandReply for command <id>
+//
+// The sender subscribes to the reply channel before publishing, so a
+// reply can never be missed; with several ingestors the first reply wins.
+const ChannelIngestCommands = "ingest:commands"
+
+func commandReplyChannel(id string) string {
+	return fmt.Sprintf("ingest:replies:%s", id)
+}
+
+var (
+	// ErrNoListener is returned when no ingestor is subscribed
+	ErrNoListener = errors.New("redisstore: no ingestor is listening for commands")
+	// ErrCommandTimeout is returned when no reply arrives in time
+	ErrCommandTimeout = errors.New("redisstore: ingestor did not reply in time")
+)
+
+// Command is a request to the ingestor
+type Command struct {
+	ID     string          `json:"id"`
+	Action string          `json:"action"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	SentAt time.Time       `json:"sent_at"`
+}
+
+// CommandReply answers the command with the same ID. Error is set when
+// the command failed.
+type CommandReply struct {
+	ID     string          `json:"id"`
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// SendCommand publishes action with args to the ingestor and waits up to
+// timeout for its reply
+func (s *Store) SendCommand(ctx context.Context, action string, args interface{}, timeout time.Duration) (*CommandReply, error) {
+	cmd := Command{ID: ulid.Make().String(), Action: action, SentAt: time.Now()}
+	if args != nil {
+		raw, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Args = raw
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sub := s.client.Subscribe(ctx, commandReplyChannel(cmd.ID))
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return nil, err
+	}
+
+	receivers, err := s.client.Publish(ctx, ChannelIngestCommands, data).Result()
+	if err != nil {
+		return nil, err
+	}
+	if receivers == 0 {
+		return nil, ErrNoListener
+	}
+
+	select {
+	case m, ok := <-sub.Channel():
+		if !ok {
+			return nil, ErrCommandTimeout
+		}
+		var reply CommandReply
+		if err := json.Unmarshal([]byte(m.Payload), &reply); err != nil {
+			return nil, err
+		}
+		return &reply, nil
+	case <-ctx.Done():
+		return nil, ErrCommandTimeout
+	}
+}
+
+// SubscribeCommands subscribes the ingestor to the command channel
+func (s *Store) SubscribeCommands(ctx context.Context) *redis.PubSub {
+	return s.client.Subscribe(ctx, ChannelIngestCommands)
+}
+
+// ReplyCommand publishes the reply to a command
+func (s *Store) ReplyCommand(ctx context.Context, reply *CommandReply) error {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(ctx, commandReplyChannel(reply.ID), data).Err()
+}
@@ -2,7 +2,9 @@ package redisstore
 
 import (
 	"context"
+	"time"
 	"cattymail/internal/config"
+	"cattymail/internal/email"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -15,24 +17,54 @@ const (
 	KeyConfigIMAPPass = "config:imap:pass"
 )
 
-// AddDomain adds a domain to the allowlist
+// AddDomain adds a domain to the allowlist. Unicode (IDN) domains are
+// stored in their punycode form.
 func (s *Store) AddDomain(ctx context.Context, domain string) error {
-	return s.client.SAdd(ctx, KeyConfigDomains, domain).Err()
+	domain, err := email.NormalizeDomain(domain)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.SAdd(ctx, KeyConfigDomains, domain)
+	pipe.ZAddNX(ctx, KeyDomainsAdded, redis.Z{Score: float64(time.Now().Unix()), Member: domain})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	s.invalidate(ctx, "domains")
+	return nil
 }
 
 // RemoveDomain removes a domain from the allowlist
 func (s *Store) RemoveDomain(ctx context.Context, domain string) error {
-	return s.client.SRem(ctx, KeyConfigDomains, domain).Err()
+	pipe := s.client.TxPipeline()
+	pipe.SRem(ctx, KeyConfigDomains, domain, email.CanonicalDomain(domain))
+	pipe.ZRem(ctx, KeyDomainsAdded, domain, email.CanonicalDomain(domain))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	s.invalidate(ctx, "domains")
+	return nil
 }
 
 // GetDomains returns all allowed domains from Redis
 // If empty, returns nil (caller should fallback to static config)
 func (s *Store) GetDomains(ctx context.Context) ([]string, error) {
-	domains, err := s.client.SMembers(ctx, KeyConfigDomains).Result()
+	if domains, ok := s.caches.domains.Get(KeyConfigDomains); ok {
+		return append([]string(nil), domains...), nil
+	}
+
+	domains, err := s.readerFor(s.client).SMembers(ctx, KeyConfigDomains).Result()
+	if err != nil && err != redis.Nil && s.replica != nil {
+		domains, err = s.client.SMembers(ctx, KeyConfigDomains).Result()
+	}
 	if err == redis.Nil {
 		return nil, nil
 	}
-	return domains, err
+	if err != nil {
+		return nil, err
+	}
+	s.caches.domains.Set(KeyConfigDomains, append([]string(nil), domains...))
+	return domains, nil
 }
 
 // UpdateIMAPConfig updates IMAP settings in Redis
@@ -0,0 +1,121 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Consent layout (on the primary):
+//
+//	config:consent         STRING JSON domain.ConsentPolicy, set by admins
+//	consent:session:<id>   STRING JSON domain.Consent, the latest acceptance of a session
+//	consent:ip:<hash>      same, by keyed hash of the client IP
+//	consent:counts         HASH "<terms>|<privacy>" -> acceptances of that pair
+//
+// Acceptances are kept for ConsentRetention as proof, longer than the
+// sessions they were given in.
+const (
+	KeyConsentPolicy = "config:consent"
+	KeyConsentCounts = "consent:counts"
+	ConsentRetention = 365 * 24 * time.Hour
+)
+
+// GetConsentPolicy returns the policy saved by admins, or nil
+func (s *Store) GetConsentPolicy(ctx context.Context) (*domain.ConsentPolicy, error) {
+	data, err := s.client.Get(ctx, KeyConsentPolicy).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p domain.ConsentPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveConsentPolicy replaces the consent policy
+func (s *Store) SaveConsentPolicy(ctx context.Context, p *domain.ConsentPolicy) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, KeyConsentPolicy, data, 0).Err()
+}
+
+// RecordConsent stores c as the latest acceptance of session sessionID
+// and of the IP behind c.IPHash
+func (s *Store) RecordConsent(ctx context.Context, sessionID string, c *domain.Consent) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	if sessionID != "" {
+		pipe.Set(ctx, "consent:session:"+sessionID, data, ConsentRetention)
+	}
+	if c.IPHash != "" {
+		pipe.Set(ctx, "consent:ip:"+c.IPHash, data, ConsentRetention)
+	}
+	pipe.HIncrBy(ctx, KeyConsentCounts, c.TermsVersion+"|"+c.PrivacyVersion, 1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ConsentOf returns the latest acceptance given in session sessionID or
+// from the IP behind ipHash, or nil
+func (s *Store) ConsentOf(ctx context.Context, sessionID, ipHash string) (*domain.Consent, error) {
+	var keys []string
+	if sessionID != "" {
+		keys = append(keys, "consent:session:"+sessionID)
+	}
+	if ipHash != "" {
+		keys = append(keys, "consent:ip:"+ipHash)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	vals, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	var latest *domain.Consent
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var c domain.Consent
+		if err := json.Unmarshal([]byte(str), &c); err != nil {
+			return nil, err
+		}
+		if latest == nil || c.AcceptedAt.After(latest.AcceptedAt) {
+			latest = &c
+		}
+	}
+	return latest, nil
+}
+
+// ConsentCounts returns how often each "<terms>|<privacy>" version pair
+// was accepted
+func (s *Store) ConsentCounts(ctx context.Context) (map[string]int64, error) {
+	vals, err := s.client.HGetAll(ctx, KeyConsentCounts).Result()
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(vals))
+	for pair, v := range vals {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			counts[pair] = n
+		}
+	}
+	return counts, nil
+}
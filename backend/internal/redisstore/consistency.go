@@ -0,0 +1,375 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Consistency checks. A message is described by several keys with their
+// own TTLs, written by different scripts and, for domains on their own
+// backend, by two separate calls (see save.go): msg:<id>, its inbox ZSET
+// entry, messages:index, the storage accounting and the sender index.
+// Messages shortened under memory pressure, evicted or quarantined, and
+// saves cut short halfway, leave these keys disagreeing. VerifyIndexes
+// finds the drift and can repair it.
+//
+// Every entry flagged as pointing at a missing message, or every message
+// flagged as missing from an index, is checked again just before it is
+// counted, so messages saved or deleted while the scan runs are not
+// reported (or repaired) by mistake.
+
+// IndexReport is what VerifyIndexes checked and the discrepancies found
+type IndexReport struct {
+	Messages            int  `json:"messages"`
+	Inboxes             int  `json:"inboxes"`
+	InboxEntries        int  `json:"inbox_entries"`
+	DanglingInbox       int  `json:"dangling_inbox_entries"`
+	OrphanedMessages    int  `json:"orphaned_messages"`
+	IndexEntries        int  `json:"index_entries"`
+	DanglingIndex       int  `json:"dangling_index_entries"`
+	UnindexedMessages   int  `json:"unindexed_messages"`
+	UnaccountedMessages int  `json:"unaccounted_messages"`
+	StaleAccounting     int  `json:"stale_accounting_entries"`
+	CounterDrift        int  `json:"counter_drift"`
+	Senders             int  `json:"senders"`
+	SenderEntries       int  `json:"sender_entries"`
+	DanglingSender      int  `json:"dangling_sender_entries"`
+	Repaired            bool `json:"repaired"`
+}
+
+// Discrepancies is the number of problems found
+func (r *IndexReport) Discrepancies() int {
+	return r.DanglingInbox + r.OrphanedMessages + r.DanglingIndex + r.UnindexedMessages +
+		r.UnaccountedMessages + r.StaleAccounting + r.CounterDrift + r.DanglingSender
+}
+
+// storedMessage is what the checker needs to know of a msg:<id> key
+type storedMessage struct {
+	inbox    string        // inbox ZSET key
+	score    float64       // inbox score (see inboxScore)
+	received time.Time     // ingest time, zero if not recorded
+	ttl      time.Duration // remaining TTL, <= 0 without expiry
+}
+
+// VerifyIndexes cross-checks, on every backend, the inbox ZSETs,
+// messages:index and the storage accounting against the msg:<id> keys,
+// then the sender index on the primary. With repair it removes dangling
+// entries, indexes the messages missing from an index and rebuilds the
+// storage counters when they are off (see RecountStorage).
+func (s *Store) VerifyIndexes(ctx context.Context, repair bool) (*IndexReport, error) {
+	rep := &IndexReport{Repaired: repair}
+	live := map[string]bool{}
+	for _, c := range s.clients() {
+		if err := s.verifyBackend(ctx, c, rep, repair, live); err != nil {
+			return rep, err
+		}
+	}
+	return rep, s.verifySenders(ctx, rep, repair, live)
+}
+
+func (s *Store) verifyBackend(ctx context.Context, c *redis.Client, rep *IndexReport, repair bool, live map[string]bool) error {
+	msgs := map[string]storedMessage{}
+	err := scanClient(ctx, c, "msg:*", func(keys []string) error {
+		pipe := c.Pipeline()
+		vals := make([]*redis.StringCmd, len(keys))
+		ttls := make([]*redis.DurationCmd, len(keys))
+		for i, key := range keys {
+			vals[i] = pipe.Get(ctx, key)
+			ttls[i] = pipe.PTTL(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return err
+		}
+		for i, key := range keys {
+			val := vals[i].Val()
+			if val == "" {
+				continue // Expired meanwhile
+			}
+			msg, err := decodeMessage([]byte(val))
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			id := strings.TrimPrefix(key, "msg:")
+			msgs[id] = storedMessage{
+				inbox:    fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local),
+				score:    float64(s.inboxScore(msg)),
+				received: msg.ReceivedAt,
+				ttl:      ttls[i].Val(),
+			}
+			live[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rep.Messages += len(msgs)
+
+	// Inbox ZSETs
+	listed := map[string]bool{}
+	err = scanClient(ctx, c, "inbox:*", func(keys []string) error {
+		for _, key := range keys {
+			ids, err := c.ZRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			rep.Inboxes++
+			rep.InboxEntries += len(ids)
+			var unknown []string
+			for _, id := range ids {
+				if m, ok := msgs[id]; !ok {
+					unknown = append(unknown, id)
+				} else if m.inbox == key {
+					listed[id] = true
+				}
+			}
+			_, gone, err := existing(ctx, c, unknown)
+			if err != nil {
+				return err
+			}
+			rep.DanglingInbox += len(gone)
+			if repair && len(gone) > 0 {
+				if err := c.ZRem(ctx, key, toInterfaces(gone)...).Err(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var unlisted []string
+	for id := range msgs {
+		if !listed[id] {
+			unlisted = append(unlisted, id)
+		}
+	}
+	orphans, _, err := existing(ctx, c, unlisted)
+	if err != nil {
+		return err
+	}
+	rep.OrphanedMessages += len(orphans)
+	if repair && len(orphans) > 0 {
+		pipe := c.Pipeline()
+		for _, id := range orphans {
+			m := msgs[id]
+			pipe.ZAdd(ctx, m.inbox, redis.Z{Score: m.score, Member: id})
+			if m.ttl > 0 {
+				// An inbox recreated here expires with its message
+				pipe.ExpireNX(ctx, m.inbox, m.ttl)
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	// messages:index
+	ids, err := c.ZRange(ctx, KeyMessageIndex, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	rep.IndexEntries += len(ids)
+	indexed := make(map[string]bool, len(ids))
+	var unknown []string
+	for _, id := range ids {
+		indexed[id] = true
+		if _, ok := msgs[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	_, gone, err := existing(ctx, c, unknown)
+	if err != nil {
+		return err
+	}
+	rep.DanglingIndex += len(gone)
+	if repair && len(gone) > 0 {
+		if err := c.ZRem(ctx, KeyMessageIndex, toInterfaces(gone)...).Err(); err != nil {
+			return err
+		}
+	}
+	var notIndexed []string
+	for id := range msgs {
+		if !indexed[id] {
+			notIndexed = append(notIndexed, id)
+		}
+	}
+	unindexed, _, err := existing(ctx, c, notIndexed)
+	if err != nil {
+		return err
+	}
+	rep.UnindexedMessages += len(unindexed)
+	if repair && len(unindexed) > 0 {
+		// Messages stored before ReceivedAt was recorded are indexed as
+		// of now, which keeps them until the index is pruned a TTL later
+		now := time.Now()
+		members := make([]redis.Z, len(unindexed))
+		for i, id := range unindexed {
+			at := msgs[id].received
+			if at.IsZero() {
+				at = now
+			}
+			members[i] = redis.Z{Score: float64(at.Unix()), Member: id}
+		}
+		if err := c.ZAddNX(ctx, KeyMessageIndex, members...).Err(); err != nil {
+			return err
+		}
+	}
+
+	// Storage accounting, read in one transaction so the counters and
+	// the sizes they sum up are consistent with each other
+	pipe := c.TxPipeline()
+	sizesCmd := pipe.HGetAll(ctx, KeyStorageSizes)
+	dueCmd := pipe.ZRangeByScore(ctx, KeyStorageExpiry, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	})
+	inboxesCmd := pipe.HGetAll(ctx, KeyStorageInboxes)
+	domainsCmd := pipe.HGetAll(ctx, KeyStorageDomains)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+	sizes := sizesCmd.Val()
+	due := map[string]bool{}
+	for _, id := range dueCmd.Val() {
+		due[id] = true // Expired, subtracted by the next sweep
+	}
+	unknown = nil
+	inboxes := map[string]int64{}
+	domains := map[string]int64{}
+	for id, v := range sizes {
+		if _, ok := msgs[id]; !ok && !due[id] {
+			unknown = append(unknown, id)
+		}
+		sizeStr, inbox, ok := strings.Cut(v, "|")
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		d, _, _ := strings.Cut(inbox, ":")
+		inboxes[inbox] += size
+		domains[d] += size
+	}
+	_, stale, err := existing(ctx, c, unknown)
+	if err != nil {
+		return err
+	}
+	var notCounted []string
+	for id := range msgs {
+		if _, ok := sizes[id]; !ok {
+			notCounted = append(notCounted, id)
+		}
+	}
+	unaccounted, _, err := existing(ctx, c, notCounted)
+	if err != nil {
+		return err
+	}
+	drift := counterDrift(inboxes, inboxesCmd.Val()) + counterDrift(domains, domainsCmd.Val())
+	rep.StaleAccounting += len(stale)
+	rep.UnaccountedMessages += len(unaccounted)
+	rep.CounterDrift += drift
+	if repair && len(stale)+len(unaccounted)+drift > 0 {
+		return recountBackend(ctx, c, &StorageRecount{})
+	}
+	return nil
+}
+
+// verifySenders removes from the sender index the messages that no longer
+// exist on any backend. The index outlives older messages by design (it
+// expires with the newest one), so some dangling entries are normal.
+func (s *Store) verifySenders(ctx context.Context, rep *IndexReport, repair bool, live map[string]bool) error {
+	return scanClient(ctx, s.client, "sender:*", func(keys []string) error {
+		for _, key := range keys {
+			if strings.HasSuffix(key, ":profile") || strings.HasSuffix(key, ":domains") {
+				continue
+			}
+			ids, err := s.client.ZRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+			rep.Senders++
+			rep.SenderEntries += len(ids)
+			var gone []string
+			for _, id := range ids {
+				if !live[id] {
+					gone = append(gone, id)
+				}
+			}
+			for _, c := range s.clients() {
+				if _, gone, err = existing(ctx, c, gone); err != nil {
+					return err
+				}
+			}
+			rep.DanglingSender += len(gone)
+			if repair && len(gone) > 0 {
+				if err := s.client.ZRem(ctx, key, toInterfaces(gone)...).Err(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// existing splits ids into those whose msg:<id> key exists on c and those
+// whose key does not
+func existing(ctx context.Context, c *redis.Client, ids []string) (present, missing []string, err error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+	pipe := c.Pipeline()
+	cmds := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.Exists(ctx, "msg:"+id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			present = append(present, ids[i])
+		} else {
+			missing = append(missing, ids[i])
+		}
+	}
+	return present, missing, nil
+}
+
+// counterDrift counts the counters in stored that differ from want
+func counterDrift(want map[string]int64, stored map[string]string) int {
+	drift := 0
+	for k, n := range want {
+		if got, _ := strconv.ParseInt(stored[k], 10, 64); got != n {
+			drift++
+		}
+	}
+	for k := range stored {
+		if _, ok := want[k]; !ok {
+			drift++
+		}
+	}
+	return drift
+}
+
+// scanClient calls fn with each batch of keys on c matching pattern
+func scanClient(ctx context.Context, c *redis.Client, pattern string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return err
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
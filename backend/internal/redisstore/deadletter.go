@@ -0,0 +1,135 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Dead-letter store layout:
+//
+//	dlq:index  ZSET of entry IDs scored by failure time
+//	dlq:<id>   HASH with folder, uid, reason, size, failed_at, raw
+const (
+	KeyDeadLetterIndex = "dlq:index"
+	deadLetterTTL      = 7 * 24 * time.Hour
+	deadLetterMax      = 1000
+)
+
+func deadLetterKey(id string) string {
+	return fmt.Sprintf("dlq:%s", id)
+}
+
+// SaveDeadLetter stores a message that failed ingestion, keeping at most
+// deadLetterMax entries.
+func (s *Store) SaveDeadLetter(ctx context.Context, dl *domain.DeadLetter) error {
+	key := deadLetterKey(dl.ID)
+
+	pipe := s.client.Pipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"folder":    dl.Folder,
+		"uid":       dl.UID,
+		"reason":    dl.Reason,
+		"size":      dl.Size,
+		"failed_at": dl.FailedAt.Unix(),
+		"raw":       dl.Raw,
+	})
+	pipe.Expire(ctx, key, deadLetterTTL)
+	pipe.ZAdd(ctx, KeyDeadLetterIndex, redis.Z{Score: float64(dl.FailedAt.Unix()), Member: dl.ID})
+	card := pipe.ZCard(ctx, KeyDeadLetterIndex)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if overflow := card.Val() - deadLetterMax; overflow > 0 {
+		evicted, err := s.client.ZPopMin(ctx, KeyDeadLetterIndex, overflow).Result()
+		if err != nil {
+			return err
+		}
+		for _, z := range evicted {
+			s.client.Del(ctx, deadLetterKey(fmt.Sprint(z.Member)))
+		}
+	}
+	return nil
+}
+
+// CountDeadLetters returns the number of indexed dead letters
+func (s *Store) CountDeadLetters(ctx context.Context) (int64, error) {
+	return s.client.ZCard(ctx, KeyDeadLetterIndex).Result()
+}
+
+// ListDeadLetters returns the newest dead letters without their raw bodies
+func (s *Store) ListDeadLetters(ctx context.Context, offset, limit int) ([]*domain.DeadLetter, error) {
+	ids, err := s.client.ZRevRange(ctx, KeyDeadLetterIndex, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HMGet(ctx, deadLetterKey(id), "folder", "uid", "reason", "size", "failed_at")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	entries := []*domain.DeadLetter{}
+	for i, cmd := range cmds {
+		vals := cmd.Val()
+		if len(vals) == 0 || vals[0] == nil {
+			// Hash expired, drop the dangling index entry
+			s.client.ZRem(ctx, KeyDeadLetterIndex, ids[i])
+			continue
+		}
+		entries = append(entries, parseDeadLetter(ids[i], vals))
+	}
+	return entries, nil
+}
+
+// GetDeadLetter returns a single dead letter including its raw body
+func (s *Store) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	vals, err := s.client.HMGet(ctx, deadLetterKey(id), "folder", "uid", "reason", "size", "failed_at", "raw").Result()
+	if err != nil {
+		return nil, err
+	}
+	if vals[0] == nil {
+		return nil, nil
+	}
+	dl := parseDeadLetter(id, vals)
+	dl.Raw, _ = vals[5].(string)
+	return dl, nil
+}
+
+// DeleteDeadLetter removes a dead letter
+func (s *Store) DeleteDeadLetter(ctx context.Context, id string) error {
+	pipe := s.client.Pipeline()
+	pipe.Del(ctx, deadLetterKey(id))
+	pipe.ZRem(ctx, KeyDeadLetterIndex, id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func parseDeadLetter(id string, vals []interface{}) *domain.DeadLetter {
+	str := func(i int) string {
+		v, _ := vals[i].(string)
+		return v
+	}
+	uid, _ := strconv.ParseUint(str(1), 10, 32)
+	size, _ := strconv.Atoi(str(3))
+	failedAt, _ := strconv.ParseInt(str(4), 10, 64)
+
+	return &domain.DeadLetter{
+		ID:       id,
+		Folder:   str(0),
+		UID:      uint32(uid),
+		Reason:   str(2),
+		Size:     size,
+		FailedAt: time.Unix(failedAt, 0),
+	}
+}
@@ -0,0 +1,90 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Inbox digest layout:
+//
+//	digest:<domain>:<local>  STRING recipient of the inbox's expiry digest
+//	digest:due               ZSET of <local>@<domain> by address expiry time
+//
+// The recipient expires with the address; the schedule entry is claimed by
+// whichever process sends the digest.
+const KeyDigestDue = "digest:due"
+
+func digestKey(emailDomain, local string) string {
+	return fmt.Sprintf("digest:%s:%s", emailDomain, local)
+}
+
+// AddressTTL returns how long an address has left, zero when it is gone
+func (s *Store) AddressTTL(ctx context.Context, emailDomain, local string) (time.Duration, error) {
+	ttl, err := s.clientFor(emailDomain).PTTL(ctx, fmt.Sprintf("addr:%s:%s", emailDomain, local)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// ScheduleDigest sends a digest of an inbox to recipient when the address
+// expires. Scheduling again moves the digest to the address's current
+// expiry.
+func (s *Store) ScheduleDigest(ctx context.Context, emailDomain, local, recipient string) error {
+	ttl, err := s.AddressTTL(ctx, emailDomain, local)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		return redis.Nil
+	}
+	if err := s.clientFor(emailDomain).Set(ctx, digestKey(emailDomain, local), recipient, ttl).Err(); err != nil {
+		return err
+	}
+	return s.client.ZAdd(ctx, KeyDigestDue, redis.Z{
+		Score:  float64(time.Now().Add(ttl).Unix()),
+		Member: local + "@" + emailDomain,
+	}).Err()
+}
+
+// CancelDigest drops the expiry digest of an inbox
+func (s *Store) CancelDigest(ctx context.Context, emailDomain, local string) error {
+	if err := s.clientFor(emailDomain).Del(ctx, digestKey(emailDomain, local)).Err(); err != nil {
+		return err
+	}
+	return s.client.ZRem(ctx, KeyDigestDue, local+"@"+emailDomain).Err()
+}
+
+// DigestRecipient returns the recipient of an inbox's expiry digest, ""
+// when none is scheduled
+func (s *Store) DigestRecipient(ctx context.Context, emailDomain, local string) (string, error) {
+	to, err := s.clientFor(emailDomain).Get(ctx, digestKey(emailDomain, local)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return to, err
+}
+
+// DueDigests returns up to limit inboxes (<local>@<domain>) whose address
+// expires before t
+func (s *Store) DueDigests(ctx context.Context, t time.Time, limit int64) ([]string, error) {
+	return s.client.ZRangeByScore(ctx, KeyDigestDue, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(t.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// ClaimDigest takes a due digest off the schedule. It reports false when
+// another process claimed it first.
+func (s *Store) ClaimDigest(ctx context.Context, inbox string) (bool, error) {
+	n, err := s.client.ZRem(ctx, KeyDigestDue, inbox).Result()
+	return n > 0, err
+}
@@ -0,0 +1,48 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"cattymail/internal/domain"
+)
+
+// Domain metadata layout:
+//
+//	config:domain_meta  HASH of domain -> DomainMeta JSON
+//
+// It lives under config: so backups carry it along with the domain list.
+const KeyConfigDomainMeta = "config:domain_meta"
+
+// SaveDomainMeta creates or replaces the metadata of meta.Domain
+func (s *Store) SaveDomainMeta(ctx context.Context, meta *domain.DomainMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyConfigDomainMeta, meta.Domain, data).Err()
+}
+
+// ListDomainMeta returns the stored metadata keyed by domain
+func (s *Store) ListDomainMeta(ctx context.Context) (map[string]*domain.DomainMeta, error) {
+	vals, err := s.client.HGetAll(ctx, KeyConfigDomainMeta).Result()
+	if err != nil {
+		return nil, err
+	}
+	metas := make(map[string]*domain.DomainMeta, len(vals))
+	for d, v := range vals {
+		var meta domain.DomainMeta
+		if err := json.Unmarshal([]byte(v), &meta); err != nil {
+			continue
+		}
+		metas[d] = &meta
+	}
+	return metas, nil
+}
+
+// DeleteDomainMeta removes the metadata of a domain. It reports false
+// when none was stored.
+func (s *Store) DeleteDomainMeta(ctx context.Context, emailDomain string) (bool, error) {
+	removed, err := s.client.HDel(ctx, KeyConfigDomainMeta, emailDomain).Result()
+	return removed > 0, err
+}
@@ -0,0 +1,58 @@
+package redisstore
+
+import (
+	"context"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+)
+
+// Domain lifecycle layout (on the primary):
+//
+//	config:domain_states  HASH of domain -> "draining" or "retired"
+//
+// Active domains have no entry. States are cached like the domain list and
+// invalidated with it.
+const KeyDomainStates = "config:domain_states"
+
+// GetDomainStates returns the state of every domain that is not active
+func (s *Store) GetDomainStates(ctx context.Context) (map[string]string, error) {
+	if states, ok := s.caches.domainStates.Get(KeyDomainStates); ok {
+		return states, nil
+	}
+	states, err := s.readerFor(s.client).HGetAll(ctx, KeyDomainStates).Result()
+	if err != nil && s.replica != nil {
+		states, err = s.client.HGetAll(ctx, KeyDomainStates).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.caches.domainStates.Set(KeyDomainStates, states)
+	return states, nil
+}
+
+// DomainState returns the state of emailDomain. Lookup errors count as
+// active so a Redis hiccup never takes domains offline.
+func (s *Store) DomainState(ctx context.Context, emailDomain string) string {
+	states, _ := s.GetDomainStates(ctx)
+	if state, ok := states[email.CanonicalDomain(emailDomain)]; ok {
+		return state
+	}
+	return domain.DomainActive
+}
+
+// SetDomainState moves emailDomain to state
+func (s *Store) SetDomainState(ctx context.Context, emailDomain, state string) error {
+	emailDomain = email.CanonicalDomain(emailDomain)
+	var err error
+	if state == domain.DomainActive {
+		err = s.client.HDel(ctx, KeyDomainStates, emailDomain).Err()
+	} else {
+		err = s.client.HSet(ctx, KeyDomainStates, emailDomain, state).Err()
+	}
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx, "domains")
+	return nil
+}
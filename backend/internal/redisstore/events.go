@@ -0,0 +1,65 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Address event layout:
+//
+//	pin:<domain>:<local>    STRING set when an address is created, expiring with the pin window
+//	event:<domain>:<local>  STRING latest AddressEvent JSON, same lifetime
+//
+// A message that arrives while the pin key exists (the typical signup
+// flow) leaves its code or link in the event key, which address
+// create/claim responses return as latest_event.
+func pinKey(emailDomain, local string) string {
+	return fmt.Sprintf("pin:%s:%s", emailDomain, local)
+}
+
+func eventKey(emailDomain, local string) string {
+	return fmt.Sprintf("event:%s:%s", emailDomain, local)
+}
+
+// OpenPinWindow starts the pin window of an address unless one is already
+// open, so repeated claims do not extend it
+func (s *Store) OpenPinWindow(ctx context.Context, emailDomain, local string, window time.Duration) error {
+	return s.clientFor(emailDomain).SetNX(ctx, pinKey(emailDomain, local), time.Now().Unix(), window).Err()
+}
+
+// PinWindowOpen reports whether an address was created within its window
+func (s *Store) PinWindowOpen(ctx context.Context, emailDomain, local string) (bool, error) {
+	n, err := s.clientFor(emailDomain).Exists(ctx, pinKey(emailDomain, local)).Result()
+	return n > 0, err
+}
+
+// SaveAddressEvent records ev as the latest event of its address for ttl
+func (s *Store) SaveAddressEvent(ctx context.Context, emailDomain, local string, ev *domain.AddressEvent, ttl time.Duration) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return s.clientFor(emailDomain).Set(ctx, eventKey(emailDomain, local), data, ttl).Err()
+}
+
+// LatestAddressEvent returns the pinned event of an address, nil if none
+func (s *Store) LatestAddressEvent(ctx context.Context, emailDomain, local string) (*domain.AddressEvent, error) {
+	data, err := s.clientFor(emailDomain).Get(ctx, eventKey(emailDomain, local)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ev domain.AddressEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
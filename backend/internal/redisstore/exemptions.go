@@ -0,0 +1,56 @@
+package redisstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"cattymail/internal/domain"
+)
+
+// Rate limit exemption layout (on the primary):
+//
+//	ratelimit:exempt  HASH of exemption ID -> RateExemption JSON
+//
+// Partner API keys are only kept as a hash, see RateKeyHash.
+const KeyRateExemptions = "ratelimit:exempt"
+
+// RateKeyHash is the stored form of a partner API key
+func RateKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveRateExemption creates or replaces an exemption
+func (s *Store) SaveRateExemption(ctx context.Context, ex *domain.RateExemption) error {
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyRateExemptions, ex.ID, data).Err()
+}
+
+// ListRateExemptions returns every exemption
+func (s *Store) ListRateExemptions(ctx context.Context) ([]*domain.RateExemption, error) {
+	vals, err := s.client.HGetAll(ctx, KeyRateExemptions).Result()
+	if err != nil {
+		return nil, err
+	}
+	exemptions := make([]*domain.RateExemption, 0, len(vals))
+	for _, v := range vals {
+		var ex domain.RateExemption
+		if err := json.Unmarshal([]byte(v), &ex); err != nil {
+			continue
+		}
+		exemptions = append(exemptions, &ex)
+	}
+	return exemptions, nil
+}
+
+// DeleteRateExemption removes an exemption. It reports false when it does
+// not exist.
+func (s *Store) DeleteRateExemption(ctx context.Context, id string) (bool, error) {
+	removed, err := s.client.HDel(ctx, KeyRateExemptions, id).Result()
+	return removed > 0, err
+}
@@ -0,0 +1,60 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Inbox filter layout:
+//
+//	filters:<domain>:<local>  STRING JSON domain.InboxFilters, expires with the address
+func filtersKey(emailDomain, local string) string {
+	return fmt.Sprintf("filters:%s:%s", emailDomain, local)
+}
+
+// GetInboxFilters returns the filters of an inbox, or nil
+func (s *Store) GetInboxFilters(ctx context.Context, emailDomain, local string) (*domain.InboxFilters, error) {
+	data, err := s.clientFor(emailDomain).Get(ctx, filtersKey(emailDomain, local)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f domain.InboxFilters
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SaveInboxFilters replaces the filters of an inbox. Filters with neither
+// an allowlist nor rules are deleted.
+func (s *Store) SaveInboxFilters(ctx context.Context, emailDomain, local string, f *domain.InboxFilters) error {
+	if len(f.AllowSenders) == 0 && len(f.Rules) == 0 {
+		return s.DeleteInboxFilters(ctx, emailDomain, local)
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	client := s.clientFor(emailDomain)
+	ttl, err := client.PTTL(ctx, fmt.Sprintf("addr:%s:%s", emailDomain, local)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	return client.Set(ctx, filtersKey(emailDomain, local), data, ttl).Err()
+}
+
+// DeleteInboxFilters removes the filters of an inbox
+func (s *Store) DeleteInboxFilters(ctx context.Context, emailDomain, local string) error {
+	return s.clientFor(emailDomain).Del(ctx, filtersKey(emailDomain, local)).Err()
+}
@@ -0,0 +1,114 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Frozen address layout (on the primary):
+//
+//	frozen_addresses                HASH "<domain>:<local>" -> JSON domain.FrozenAddress
+//	freeze:cooldown:<domain>:<local> STRING reason, set when a freeze is lifted
+//
+// A frozen address keeps its messages; they expire as usual. The local
+// cannot be created while frozen, nor during the cooldown after.
+const KeyFrozenAddresses = "frozen_addresses"
+
+func frozenField(emailDomain, local string) string {
+	return email.CanonicalDomain(emailDomain) + ":" + local
+}
+
+// FreezeAddress freezes an address, replacing any earlier freeze
+func (s *Store) FreezeAddress(ctx context.Context, f *domain.FrozenAddress) error {
+	if f.FrozenAt.IsZero() {
+		f.FrozenAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := s.client.HSet(ctx, KeyFrozenAddresses, frozenField(f.Domain, f.Local), data).Err(); err != nil {
+		return err
+	}
+	s.invalidate(ctx, "frozen")
+	return nil
+}
+
+// UnfreezeAddress lifts a freeze, keeping the local from being created
+// again for cooldown. Returns the lifted freeze, or nil if there was none.
+func (s *Store) UnfreezeAddress(ctx context.Context, emailDomain, local string, cooldown time.Duration) (*domain.FrozenAddress, error) {
+	field := frozenField(emailDomain, local)
+	data, err := s.client.HGet(ctx, KeyFrozenAddresses, field).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f domain.FrozenAddress
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HDel(ctx, KeyFrozenAddresses, field)
+	if cooldown > 0 {
+		pipe.Set(ctx, "freeze:cooldown:"+field, f.Reason, cooldown)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, "frozen")
+	return &f, nil
+}
+
+// ListFrozenAddresses returns every frozen address
+func (s *Store) ListFrozenAddresses(ctx context.Context) ([]*domain.FrozenAddress, error) {
+	vals, err := s.client.HGetAll(ctx, KeyFrozenAddresses).Result()
+	if err != nil {
+		return nil, err
+	}
+	frozen := make([]*domain.FrozenAddress, 0, len(vals))
+	for _, v := range vals {
+		var f domain.FrozenAddress
+		if err := json.Unmarshal([]byte(v), &f); err == nil {
+			frozen = append(frozen, &f)
+		}
+	}
+	return frozen, nil
+}
+
+// FrozenReason returns why local@emailDomain is frozen, if it is. The set
+// is cached like the blocked addresses; lookup errors count as not frozen.
+func (s *Store) FrozenReason(ctx context.Context, emailDomain, local string) (string, bool) {
+	frozen, ok := s.caches.frozen.Get(KeyFrozenAddresses)
+	if !ok {
+		vals, err := s.client.HGetAll(ctx, KeyFrozenAddresses).Result()
+		if err != nil {
+			return "", false
+		}
+		frozen = make(map[string]string, len(vals))
+		for field, v := range vals {
+			var f domain.FrozenAddress
+			if err := json.Unmarshal([]byte(v), &f); err == nil {
+				frozen[field] = f.Reason
+			}
+		}
+		s.caches.frozen.Set(KeyFrozenAddresses, frozen)
+	}
+	reason, ok := frozen[frozenField(emailDomain, local)]
+	return reason, ok
+}
+
+// InFreezeCooldown reports whether a lifted freeze still keeps the local
+// from being created
+func (s *Store) InFreezeCooldown(ctx context.Context, emailDomain, local string) (bool, error) {
+	n, err := s.client.Exists(ctx, "freeze:cooldown:"+frozenField(emailDomain, local)).Result()
+	return n > 0, err
+}
@@ -0,0 +1,179 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Greylisting layout:
+//
+//	config:greylist                          HASH of domain -> delay in seconds
+//	greylist:seen:<domain>:<local>:<sender>  STRING set when a sender first wrote to an inbox
+//	greylist:held                            ZSET of held message IDs by release time (primary)
+//	greylist:msg:<id>                        STRING held message payload, as under msg:<id>,
+//	                                         on the backend of the message's domain
+//
+// The first message from a sender to an inbox is held for the domain's
+// delay before it is saved like any other message; later ones are saved
+// immediately.
+const (
+	KeyConfigGreylist = "config:greylist"
+	KeyGreylistHeld   = "greylist:held"
+)
+
+func greylistMsgKey(id string) string {
+	return "greylist:msg:" + id
+}
+
+// SetGreylistDelay sets the delay of a domain; zero turns it off
+func (s *Store) SetGreylistDelay(ctx context.Context, emailDomain string, delay time.Duration) error {
+	if delay <= 0 {
+		return s.client.HDel(ctx, KeyConfigGreylist, emailDomain).Err()
+	}
+	return s.client.HSet(ctx, KeyConfigGreylist, emailDomain, int64(delay/time.Second)).Err()
+}
+
+// GreylistDelays returns the delay of every domain with greylisting on
+func (s *Store) GreylistDelays(ctx context.Context) (map[string]time.Duration, error) {
+	vals, err := s.client.HGetAll(ctx, KeyConfigGreylist).Result()
+	if err != nil {
+		return nil, err
+	}
+	delays := make(map[string]time.Duration, len(vals))
+	for d, v := range vals {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs > 0 {
+			delays[d] = time.Duration(secs) * time.Second
+		}
+	}
+	return delays, nil
+}
+
+// GreylistDelay returns the delay of one domain, zero when off
+func (s *Store) GreylistDelay(ctx context.Context, emailDomain string) (time.Duration, error) {
+	secs, err := s.client.HGet(ctx, KeyConfigGreylist, emailDomain).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// FirstContact records that sender wrote to local@emailDomain and reports
+// whether it is the first time within the address TTL
+func (s *Store) FirstContact(ctx context.Context, emailDomain, local, sender string) (bool, error) {
+	key := fmt.Sprintf("greylist:seen:%s:%s:%s", emailDomain, local, strings.ToLower(sender))
+	return s.client.SetNX(ctx, key, "1", s.ttl).Result()
+}
+
+// HoldMessage keeps msg out of its inbox until releaseAt. Its IMAP UID is
+// marked processed right away so a re-scan does not hold it twice.
+func (s *Store) HoldMessage(ctx context.Context, msg *domain.Message, releaseAt time.Time) error {
+	data, err := encodeMessage(msg, s.codec)
+	if err != nil {
+		return err
+	}
+	// Park the payload first so the release loop never finds an empty slot
+	if err := s.clientFor(msg.Domain).Set(ctx, greylistMsgKey(msg.ID), data, time.Until(releaseAt)+s.ttl).Err(); err != nil {
+		return err
+	}
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, KeyGreylistHeld, redis.Z{Score: float64(releaseAt.Unix()), Member: msg.ID})
+	if msg.IMAPUID > 0 && msg.IMAPFolder != "" {
+		pipe.Set(ctx, fmt.Sprintf("imap:uid:%s:%d", msg.IMAPFolder, msg.IMAPUID), "1", s.ttl)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// HeldMessage is a message waiting for release
+type HeldMessage struct {
+	Message   *domain.Message `json:"message"`
+	ReleaseAt time.Time       `json:"release_at"`
+}
+
+// ListHeldMessages returns held messages, soonest release first
+func (s *Store) ListHeldMessages(ctx context.Context, limit int64) ([]*HeldMessage, error) {
+	entries, err := s.client.ZRangeWithScores(ctx, KeyGreylistHeld, 0, limit-1).Result()
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = greylistMsgKey(e.Member.(string))
+	}
+	vals, err := s.payloads(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	held := make([]*HeldMessage, 0, len(entries))
+	for i, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		msg, err := decodeMessage([]byte(str))
+		if err != nil {
+			continue
+		}
+		held = append(held, &HeldMessage{Message: msg, ReleaseAt: time.Unix(int64(entries[i].Score), 0)})
+	}
+	return held, nil
+}
+
+// DueHeldMessages returns the IDs of held messages whose release time has
+// passed
+func (s *Store) DueHeldMessages(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	return s.client.ZRangeByScore(ctx, KeyGreylistHeld, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// takeHeld removes a message from the held index and returns it. Removing
+// it from the index decides which caller releases it. Returns nil if it is
+// not held.
+func (s *Store) takeHeld(ctx context.Context, id string) (*domain.Message, error) {
+	removed, err := s.client.ZRem(ctx, KeyGreylistHeld, id).Result()
+	if err != nil || removed == 0 {
+		return nil, err
+	}
+	key := greylistMsgKey(id)
+	for _, c := range s.clients() {
+		data, err := c.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Del(ctx, key)
+		return decodeMessage([]byte(data))
+	}
+	return nil, nil // Expired
+}
+
+// ReleaseMessage saves a held message into its inbox now. It reports
+// false when the message is not held (already released or unknown).
+func (s *Store) ReleaseMessage(ctx context.Context, id string) (bool, error) {
+	msg, err := s.takeHeld(ctx, id)
+	if err != nil || msg == nil {
+		return false, err
+	}
+	if err := s.SaveMessage(ctx, msg); err != nil {
+		// Put it back so a later attempt can deliver it
+		if herr := s.HoldMessage(ctx, msg, time.Now().Add(time.Minute)); herr != nil {
+			return false, fmt.Errorf("%w (and failed to re-hold: %v)", err, herr)
+		}
+		return false, err
+	}
+	return true, nil
+}
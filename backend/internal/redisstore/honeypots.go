@@ -0,0 +1,204 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Honeypot layout (on the primary unless noted):
+//
+//	honeypots                    HASH "<domain>:<local>" -> JSON domain.Honeypot ("*" domain: any)
+//	honeypot:hits                ZSET hit IDs scored by time (unix ms)
+//	honeypot:hit:<id>            STRING JSON domain.HoneypotHit
+//	honeypot:msg:<id>            STRING stored message payload, as under msg:<id>, on
+//	                             the backend of the message's domain
+//	reputation:sender:<address>  HASH honeypot_hits, last_honeypot_at
+//	reputation:ip:<ip>           same, for relays and API clients
+//
+// Mail to a honeypot never reaches an inbox; it is kept here for analysis.
+// Hits, messages and reputation entries expire after HoneypotRetention.
+const (
+	KeyHoneypots      = "honeypots"
+	KeyHoneypotHits   = "honeypot:hits"
+	HoneypotRetention = 30 * 24 * time.Hour
+	// AnyDomain in a honeypot matches its local on every domain
+	AnyDomain = "*"
+)
+
+func honeypotField(emailDomain, local string) string {
+	if emailDomain != AnyDomain {
+		emailDomain = email.CanonicalDomain(emailDomain)
+	}
+	return emailDomain + ":" + local
+}
+
+// ListHoneypots returns every honeypot
+func (s *Store) ListHoneypots(ctx context.Context) ([]*domain.Honeypot, error) {
+	vals, err := s.client.HGetAll(ctx, KeyHoneypots).Result()
+	if err != nil {
+		return nil, err
+	}
+	pots := make([]*domain.Honeypot, 0, len(vals))
+	for _, v := range vals {
+		var hp domain.Honeypot
+		if err := json.Unmarshal([]byte(v), &hp); err == nil {
+			pots = append(pots, &hp)
+		}
+	}
+	return pots, nil
+}
+
+// SaveHoneypot creates or replaces a honeypot
+func (s *Store) SaveHoneypot(ctx context.Context, hp *domain.Honeypot) error {
+	data, err := json.Marshal(hp)
+	if err != nil {
+		return err
+	}
+	if err := s.client.HSet(ctx, KeyHoneypots, honeypotField(hp.Domain, hp.Local), data).Err(); err != nil {
+		return err
+	}
+	s.invalidate(ctx, "honeypots")
+	return nil
+}
+
+// DeleteHoneypot removes a honeypot. Reports whether there was one.
+func (s *Store) DeleteHoneypot(ctx context.Context, emailDomain, local string) (bool, error) {
+	n, err := s.client.HDel(ctx, KeyHoneypots, honeypotField(emailDomain, local)).Result()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	s.invalidate(ctx, "honeypots")
+	return true, nil
+}
+
+// IsHoneypot reports whether local@emailDomain is a honeypot. The set is
+// cached like the domain list; lookup errors count as no.
+func (s *Store) IsHoneypot(ctx context.Context, emailDomain, local string) bool {
+	pots, ok := s.caches.honeypots.Get(KeyHoneypots)
+	if !ok {
+		fields, err := s.client.HKeys(ctx, KeyHoneypots).Result()
+		if err != nil {
+			return false
+		}
+		pots = make(map[string]bool, len(fields))
+		for _, f := range fields {
+			pots[f] = true
+		}
+		s.caches.honeypots.Set(KeyHoneypots, pots)
+	}
+	return pots[honeypotField(emailDomain, local)] || pots[honeypotField(AnyDomain, local)]
+}
+
+// RecordHoneypotHit stores hit, and msg when it is mail (marking its IMAP
+// UID processed like SaveMessage does), and counts it against the sender
+// and the IPs involved
+func (s *Store) RecordHoneypotHit(ctx context.Context, hit *domain.HoneypotHit, msg *domain.Message) error {
+	if hit.ID == "" {
+		hit.ID = ulid.Make().String()
+	}
+	if hit.At.IsZero() {
+		hit.At = time.Now().UTC()
+	}
+	pipe := s.client.TxPipeline()
+	if msg != nil {
+		data, err := encodeMessage(msg, s.codec)
+		if err != nil {
+			return err
+		}
+		if err := s.clientFor(msg.Domain).Set(ctx, "honeypot:msg:"+msg.ID, data, HoneypotRetention).Err(); err != nil {
+			return err
+		}
+		hit.MessageID = msg.ID
+		if msg.IMAPUID > 0 && msg.IMAPFolder != "" {
+			pipe.Set(ctx, fmt.Sprintf("imap:uid:%s:%d", msg.IMAPFolder, msg.IMAPUID), "1", s.ttl)
+		}
+	}
+	data, err := json.Marshal(hit)
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, "honeypot:hit:"+hit.ID, data, HoneypotRetention)
+	pipe.ZAdd(ctx, KeyHoneypotHits, redis.Z{Score: float64(hit.At.UnixMilli()), Member: hit.ID})
+	pipe.ZRemRangeByScore(ctx, KeyHoneypotHits, "-inf", strconv.FormatInt(hit.At.Add(-HoneypotRetention).UnixMilli(), 10))
+
+	blame := func(key string) {
+		pipe.HIncrBy(ctx, key, "honeypot_hits", 1)
+		pipe.HSet(ctx, key, "last_honeypot_at", hit.At.Unix())
+		pipe.Expire(ctx, key, HoneypotRetention)
+	}
+	if hit.Sender != "" {
+		blame("reputation:sender:" + hit.Sender)
+	}
+	for _, ip := range []string{hit.SenderIP, hit.ClientIP} {
+		if ip != "" {
+			blame("reputation:ip:" + ip)
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListHoneypotHits returns up to limit hits, newest first
+func (s *Store) ListHoneypotHits(ctx context.Context, limit int64) ([]*domain.HoneypotHit, error) {
+	ids, err := s.client.ZRevRange(ctx, KeyHoneypotHits, 0, limit-1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = "honeypot:hit:" + id
+	}
+	vals, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*domain.HoneypotHit, 0, len(ids))
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue // Expired
+		}
+		var hit domain.HoneypotHit
+		if err := json.Unmarshal([]byte(str), &hit); err == nil {
+			hits = append(hits, &hit)
+		}
+	}
+	return hits, nil
+}
+
+// CountHoneypotHits returns how many hits happened since t
+func (s *Store) CountHoneypotHits(ctx context.Context, since time.Time) (int64, error) {
+	return s.client.ZCount(ctx, KeyHoneypotHits, strconv.FormatInt(since.UnixMilli(), 10), "+inf").Result()
+}
+
+// GetHoneypotMessage returns a message sent to a honeypot, or nil
+func (s *Store) GetHoneypotMessage(ctx context.Context, id string) (*domain.Message, error) {
+	vals, err := s.payloads(ctx, []string{"honeypot:msg:" + id})
+	if err != nil {
+		return nil, err
+	}
+	val, ok := vals[0].(string)
+	if !ok {
+		return nil, nil
+	}
+	return decodeMessage([]byte(val))
+}
+
+// HoneypotHitsOf returns how many honeypot hits a sender ("sender") or an
+// IP ("ip") is behind
+func (s *Store) HoneypotHitsOf(ctx context.Context, kind, value string) (int64, error) {
+	n, err := s.client.HGet(ctx, "reputation:"+kind+":"+value, "honeypot_hits").Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
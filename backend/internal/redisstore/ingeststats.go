@@ -0,0 +1,130 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Ingestion statistics layout:
+//
+//	ingest:stats:folder:<folder>  HASH of outcome counters plus last_<outcome>_at
+//	ingest:stats:domain:<domain>  same, per recipient domain
+//	ingest:stats:folders          SET of folders with statistics
+//	ingest:stats:domains          SET of domains with statistics
+//
+// Messages dropped or skipped before a recipient is known only count
+// towards their folder.
+const (
+	KeyIngestStatsFolders = "ingest:stats:folders"
+	KeyIngestStatsDomains = "ingest:stats:domains"
+)
+
+// Ingestion outcomes
+const (
+	IngestIngested = "ingested"
+	IngestSkipped  = "skipped" // no recipient on an allowed domain
+	IngestDropped  = "dropped" // too large, over the storage quota, or by an inbox rule
+	IngestErrored  = "errored"
+)
+
+// IngestCounters are the totals for one folder or domain
+type IngestCounters struct {
+	Ingested       int64      `json:"ingested"`
+	Skipped        int64      `json:"skipped"`
+	Dropped        int64      `json:"dropped"`
+	Errored        int64      `json:"errored"`
+	LastIngestedAt *time.Time `json:"last_ingested_at,omitempty"`
+	LastErroredAt  *time.Time `json:"last_errored_at,omitempty"`
+}
+
+// IngestStats breaks ingestion down by IMAP folder and recipient domain
+type IngestStats struct {
+	Folders map[string]*IngestCounters `json:"folders"`
+	Domains map[string]*IngestCounters `json:"domains"`
+}
+
+// RecordIngest counts one message with the given outcome. emailDomain may
+// be empty when the recipient is unknown.
+func (s *Store) RecordIngest(ctx context.Context, folder, emailDomain, outcome string) error {
+	now := time.Now().Unix()
+	pipe := s.client.Pipeline()
+	record := func(key string) {
+		pipe.HIncrBy(ctx, key, outcome, 1)
+		pipe.HSet(ctx, key, "last_"+outcome+"_at", now)
+	}
+	if folder != "" {
+		record("ingest:stats:folder:" + folder)
+		pipe.SAdd(ctx, KeyIngestStatsFolders, folder)
+	}
+	if emailDomain != "" {
+		record("ingest:stats:domain:" + emailDomain)
+		pipe.SAdd(ctx, KeyIngestStatsDomains, emailDomain)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetIngestStats returns the counters of every folder and domain
+func (s *Store) GetIngestStats(ctx context.Context) (*IngestStats, error) {
+	folders, err := s.client.SMembers(ctx, KeyIngestStatsFolders).Result()
+	if err != nil {
+		return nil, err
+	}
+	domains, err := s.client.SMembers(ctx, KeyIngestStatsDomains).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	folderCmds := make([]*redis.MapStringStringCmd, len(folders))
+	for i, f := range folders {
+		folderCmds[i] = pipe.HGetAll(ctx, "ingest:stats:folder:"+f)
+	}
+	domainCmds := make([]*redis.MapStringStringCmd, len(domains))
+	for i, d := range domains {
+		domainCmds[i] = pipe.HGetAll(ctx, "ingest:stats:domain:"+d)
+	}
+	if len(folders)+len(domains) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	stats := &IngestStats{
+		Folders: make(map[string]*IngestCounters, len(folders)),
+		Domains: make(map[string]*IngestCounters, len(domains)),
+	}
+	for i, f := range folders {
+		stats.Folders[f] = parseIngestCounters(folderCmds[i].Val())
+	}
+	for i, d := range domains {
+		stats.Domains[d] = parseIngestCounters(domainCmds[i].Val())
+	}
+	return stats, nil
+}
+
+func parseIngestCounters(vals map[string]string) *IngestCounters {
+	n := func(k string) int64 {
+		v, _ := strconv.ParseInt(vals[k], 10, 64)
+		return v
+	}
+	at := func(k string) *time.Time {
+		ts := n(k)
+		if ts == 0 {
+			return nil
+		}
+		t := time.Unix(ts, 0)
+		return &t
+	}
+	return &IngestCounters{
+		Ingested:       n(IngestIngested),
+		Skipped:        n(IngestSkipped),
+		Dropped:        n(IngestDropped),
+		Errored:        n(IngestErrored),
+		LastIngestedAt: at("last_" + IngestIngested + "_at"),
+		LastErroredAt:  at("last_" + IngestErrored + "_at"),
+	}
+}
@@ -0,0 +1,134 @@
+package redisstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// JWT signing keys, shared by every API and ingestor process:
+//
+//	jwt:keys     HASH of key ID -> JWTKey JSON
+//	jwt:current  STRING ID of the key new tokens are signed with
+//
+// Rotating keeps the previous key valid until its ExpiresAt so tokens
+// issued just before stay accepted; expired keys are pruned on rotation.
+const (
+	KeyJWTKeys    = "jwt:keys"
+	KeyJWTCurrent = "jwt:current"
+)
+
+// JWTKey is one HMAC signing key
+type JWTKey struct {
+	ID        string     `json:"kid"`
+	Secret    []byte     `json:"secret"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // set once rotated out
+}
+
+// Expired reports whether tokens signed with k must be rejected
+func (k *JWTKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && now.After(*k.ExpiresAt)
+}
+
+// JWTKeyring is the set of valid keys and the one to sign with
+type JWTKeyring struct {
+	Current string
+	Keys    map[string]*JWTKey
+}
+
+// NewJWTKey returns a key with a random 256-bit secret
+func NewJWTKey() (*JWTKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &JWTKey{ID: ulid.Make().String(), Secret: secret, CreatedAt: time.Now()}, nil
+}
+
+// ensureJWTKeyScript installs ARGV[1] as current key unless one exists
+var ensureJWTKeyScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// EnsureJWTKey installs initial as the current key when the keyring is
+// empty; concurrent callers agree on whichever got there first
+func (s *Store) EnsureJWTKey(ctx context.Context, initial *JWTKey) error {
+	data, err := json.Marshal(initial)
+	if err != nil {
+		return err
+	}
+	return ensureJWTKeyScript.Run(ctx, s.client, []string{KeyJWTCurrent, KeyJWTKeys}, initial.ID, data).Err()
+}
+
+// GetJWTKeyring returns every stored key. Current is empty when no key
+// has been installed yet.
+func (s *Store) GetJWTKeyring(ctx context.Context) (*JWTKeyring, error) {
+	pipe := s.client.Pipeline()
+	current := pipe.Get(ctx, KeyJWTCurrent)
+	all := pipe.HGetAll(ctx, KeyJWTKeys)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	ring := &JWTKeyring{Current: current.Val(), Keys: make(map[string]*JWTKey)}
+	for id, v := range all.Val() {
+		var k JWTKey
+		if err := json.Unmarshal([]byte(v), &k); err != nil {
+			continue
+		}
+		ring.Keys[id] = &k
+	}
+	return ring, nil
+}
+
+// ErrNoJWTKey is returned when rotating an empty keyring
+var ErrNoJWTKey = errors.New("redisstore: no JWT signing key installed")
+
+// RotateJWTKey makes next the current key. The previous key stays valid
+// for grace; keys whose grace ended are deleted.
+func (s *Store) RotateJWTKey(ctx context.Context, next *JWTKey, grace time.Duration) error {
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		ring, err := s.GetJWTKeyring(ctx)
+		if err != nil {
+			return err
+		}
+		prev, ok := ring.Keys[ring.Current]
+		if !ok {
+			return ErrNoJWTKey
+		}
+
+		now := time.Now()
+		until := now.Add(grace)
+		prev.ExpiresAt = &until
+		prevData, err := json.Marshal(prev)
+		if err != nil {
+			return err
+		}
+		nextData, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for id, k := range ring.Keys {
+				if k.Expired(now) {
+					pipe.HDel(ctx, KeyJWTKeys, id)
+				}
+			}
+			pipe.HSet(ctx, KeyJWTKeys, prev.ID, prevData, next.ID, nextData)
+			pipe.Set(ctx, KeyJWTCurrent, next.ID, 0)
+			return nil
+		})
+		return err
+	}, KeyJWTCurrent, KeyJWTKeys)
+}
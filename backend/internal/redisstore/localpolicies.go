@@ -0,0 +1,77 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+)
+
+// Local part policy layout (on the primary):
+//
+//	config:local_policies  HASH domain (or "*") -> JSON domain.LocalPolicy
+//
+// The "*" entry applies to domains without a policy of their own.
+const (
+	KeyLocalPolicies   = "config:local_policies"
+	DefaultLocalPolicy = "*"
+)
+
+// GetLocalPolicies returns every saved policy, keyed by domain or "*"
+func (s *Store) GetLocalPolicies(ctx context.Context) (map[string]*domain.LocalPolicy, error) {
+	vals, err := s.client.HGetAll(ctx, KeyLocalPolicies).Result()
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]*domain.LocalPolicy, len(vals))
+	for d, v := range vals {
+		var p domain.LocalPolicy
+		if err := json.Unmarshal([]byte(v), &p); err == nil {
+			policies[d] = &p
+		}
+	}
+	return policies, nil
+}
+
+// LocalPolicyFor returns the policy applying to emailDomain: its own, else
+// the default one, else nil
+func (s *Store) LocalPolicyFor(ctx context.Context, emailDomain string) (*domain.LocalPolicy, error) {
+	vals, err := s.client.HMGet(ctx, KeyLocalPolicies, email.CanonicalDomain(emailDomain), DefaultLocalPolicy).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vals {
+		if str, ok := v.(string); ok {
+			var p domain.LocalPolicy
+			if err := json.Unmarshal([]byte(str), &p); err != nil {
+				return nil, err
+			}
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+// SetLocalPolicy saves the policy of emailDomain, or the default one when
+// it is "*"
+func (s *Store) SetLocalPolicy(ctx context.Context, emailDomain string, p *domain.LocalPolicy) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyLocalPolicies, localPolicyField(emailDomain), data).Err()
+}
+
+// DeleteLocalPolicy drops a saved policy. Reports whether there was one.
+func (s *Store) DeleteLocalPolicy(ctx context.Context, emailDomain string) (bool, error) {
+	n, err := s.client.HDel(ctx, KeyLocalPolicies, localPolicyField(emailDomain)).Result()
+	return n > 0, err
+}
+
+func localPolicyField(emailDomain string) string {
+	if emailDomain == DefaultLocalPolicy {
+		return emailDomain
+	}
+	return email.CanonicalDomain(emailDomain)
+}
@@ -0,0 +1,133 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Request log layout:
+//
+//	logs:requests  STREAM of access/error entries, capped (approximately)
+//	               at the configured length; entry IDs encode the time
+const KeyRequestLog = "logs:requests"
+
+// logLevels orders levels for minimum-level filtering
+var logLevels = map[string]int{"info": 0, "warn": 1, "error": 2}
+
+// LogQuery filters ListLogs. Zero values match everything.
+type LogQuery struct {
+	Level string // minimum level
+	Route string // prefix of the route pattern or path
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// AppendLog adds an entry to the request log, trimming it to maxLen entries
+func (s *Store) AppendLog(ctx context.Context, e *domain.LogEntry, maxLen int64) error {
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: KeyRequestLog,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"level":  e.Level,
+			"method": e.Method,
+			"route":  e.Route,
+			"path":   e.Path,
+			"status": e.Status,
+			"ms":     e.DurationMs,
+			"ip":     e.IP,
+			"error":  e.Error,
+		},
+	}).Err()
+}
+
+// ListLogs returns matching entries, newest first
+func (s *Store) ListLogs(ctx context.Context, q LogQuery) ([]*domain.LogEntry, error) {
+	const batch = 500
+
+	end, start := "+", "-"
+	if !q.Until.IsZero() {
+		end = strconv.FormatInt(q.Until.UnixMilli(), 10)
+	}
+	if !q.Since.IsZero() {
+		start = strconv.FormatInt(q.Since.UnixMilli(), 10)
+	}
+	minLevel := logLevels[q.Level]
+
+	entries := []*domain.LogEntry{}
+	for len(entries) < q.Limit {
+		msgs, err := s.client.XRevRangeN(ctx, KeyRequestLog, end, start, batch).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			e := parseLogEntry(m)
+			if logLevels[e.Level] < minLevel {
+				continue
+			}
+			if q.Route != "" && !strings.HasPrefix(e.Route, q.Route) && !strings.HasPrefix(e.Path, q.Route) {
+				continue
+			}
+			entries = append(entries, e)
+			if len(entries) == q.Limit {
+				break
+			}
+		}
+		if len(msgs) < batch {
+			break
+		}
+		// Continue strictly before the oldest entry seen
+		end = "(" + msgs[len(msgs)-1].ID
+	}
+	return entries, nil
+}
+
+func parseLogEntry(m redis.XMessage) *domain.LogEntry {
+	str := func(k string) string {
+		v, _ := m.Values[k].(string)
+		return v
+	}
+	status, _ := strconv.Atoi(str("status"))
+	ms, _ := strconv.ParseInt(str("ms"), 10, 64)
+
+	e := &domain.LogEntry{
+		ID:         m.ID,
+		Level:      str("level"),
+		Method:     str("method"),
+		Route:      str("route"),
+		Path:       str("path"),
+		Status:     status,
+		DurationMs: ms,
+		IP:         str("ip"),
+		Error:      str("error"),
+	}
+	if ts, _, ok := strings.Cut(m.ID, "-"); ok {
+		if millis, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			e.Time = time.UnixMilli(millis)
+		}
+	}
+	return e
+}
+
+// RequestErrorRate counts logged requests since the given time and how
+// many of them failed with a 5xx status
+func (s *Store) RequestErrorRate(ctx context.Context, since time.Time) (total, failed int64, err error) {
+	msgs, err := s.client.XRange(ctx, KeyRequestLog, strconv.FormatInt(since.UnixMilli(), 10), "+").Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, m := range msgs {
+		total++
+		if v, _ := m.Values["level"].(string); v == "error" {
+			failed++
+		}
+	}
+	return total, failed, nil
+}
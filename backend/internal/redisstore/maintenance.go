@@ -0,0 +1,146 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Maintenance helpers for operator tooling (cmd/cattyadmin). They scan
+// whole keyspaces, so they are meant for occasional use, not for request
+// paths.
+
+// InboxCount is an inbox and the number of message IDs its ZSET lists
+type InboxCount struct {
+	Domain   string `json:"domain"`
+	Local    string `json:"local"`
+	Messages int64  `json:"messages"`
+}
+
+// ListInboxes returns every inbox with its message count, optionally only
+// those of emailDomain
+func (s *Store) ListInboxes(ctx context.Context, emailDomain string) ([]InboxCount, error) {
+	pattern := "inbox:*"
+	if emailDomain != "" {
+		pattern = "inbox:" + emailDomain + ":*"
+	}
+	var inboxes []InboxCount
+	var scanErr error
+	err := s.scanKeys(ctx, pattern, func(c *redis.Client, keys []string) bool {
+		pipe := c.Pipeline()
+		counts := make([]*redis.IntCmd, len(keys))
+		for i, key := range keys {
+			counts[i] = pipe.ZCard(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			scanErr = err
+			return false
+		}
+		for i, key := range keys {
+			d, local, ok := strings.Cut(strings.TrimPrefix(key, "inbox:"), ":")
+			if !ok {
+				continue
+			}
+			inboxes = append(inboxes, InboxCount{Domain: d, Local: local, Messages: counts[i].Val()})
+		}
+		return true
+	})
+	if err == nil {
+		err = scanErr
+	}
+	return inboxes, err
+}
+
+// StorageRecount is the result of RecountStorage
+type StorageRecount struct {
+	Messages int   `json:"messages"`
+	Bytes    int64 `json:"bytes"`
+	Inboxes  int   `json:"inboxes"`
+	Domains  int   `json:"domains"`
+}
+
+// RecountStorage rebuilds the storage accounting (see storage.go) of every
+// backend from the stored messages. Messages saved while it runs may be
+// missed until they expire, so run it while ingest is quiet.
+func (s *Store) RecountStorage(ctx context.Context) (*StorageRecount, error) {
+	total := &StorageRecount{}
+	for _, c := range s.clients() {
+		if err := recountBackend(ctx, c, total); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func recountBackend(ctx context.Context, c *redis.Client, total *StorageRecount) error {
+	now := time.Now()
+	sizes := map[string]interface{}{}
+	var expiry []redis.Z
+	inboxes := map[string]int64{}
+	domains := map[string]int64{}
+
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(ctx, cursor, "msg:*", 200).Result()
+		if err != nil {
+			return err
+		}
+		pipe := c.Pipeline()
+		vals := make([]*redis.StringCmd, len(keys))
+		ttls := make([]*redis.DurationCmd, len(keys))
+		for i, key := range keys {
+			vals[i] = pipe.Get(ctx, key)
+			ttls[i] = pipe.PTTL(ctx, key)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return err
+		}
+		for i, key := range keys {
+			val := vals[i].Val()
+			if val == "" {
+				continue // Expired meanwhile
+			}
+			msg, err := decodeMessage([]byte(val))
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			id := strings.TrimPrefix(key, "msg:")
+			inbox := msg.Domain + ":" + msg.Local
+			size := int64(len(val))
+			sizes[id] = fmt.Sprintf("%d|%s", size, inbox)
+			inboxes[inbox] += size
+			domains[msg.Domain] += size
+			score := math.Inf(1)
+			if ttl := ttls[i].Val(); ttl > 0 {
+				score = float64(now.Add(ttl).Unix())
+			}
+			expiry = append(expiry, redis.Z{Score: score, Member: id})
+			total.Messages++
+			total.Bytes += size
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	pipe := c.TxPipeline()
+	pipe.Del(ctx, storageKeys...)
+	if len(sizes) > 0 {
+		pipe.HSet(ctx, KeyStorageSizes, sizes)
+		pipe.ZAdd(ctx, KeyStorageExpiry, expiry...)
+	}
+	for inbox, n := range inboxes {
+		pipe.HSet(ctx, KeyStorageInboxes, inbox, n)
+	}
+	for d, n := range domains {
+		pipe.HSet(ctx, KeyStorageDomains, d, n)
+	}
+	total.Inboxes += len(inboxes)
+	total.Domains += len(domains)
+	_, err := pipe.Exec(ctx)
+	return err
+}
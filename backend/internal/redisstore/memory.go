@@ -0,0 +1,117 @@
+package redisstore
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// indexKeys are single keys reported together as the "indexes" namespace
+var indexKeys = map[string]bool{
+	KeyMessageIndex:        true,
+	KeyDeadLetterIndex:     true,
+	KeyVerifiedUserDomains: true,
+}
+
+// nestedNamespaces are reported separately from their parent prefix
+var nestedNamespaces = []string{"imap:uid:", "addrgen:bloom:", "billing:plan:", "account:email:"}
+
+// NamespaceMemory is the estimated memory held by one key namespace
+type NamespaceMemory struct {
+	Namespace string `json:"namespace"`
+	Keys      int64  `json:"keys"`
+	Sampled   int    `json:"sampled"`
+	// Bytes is the sampled average MEMORY USAGE times Keys
+	Bytes int64 `json:"bytes"`
+}
+
+// MemoryReport breaks Redis memory down by namespace across every backend
+type MemoryReport struct {
+	UsedBytes  int64             `json:"used_bytes"` // used_memory summed over backends
+	Namespaces []NamespaceMemory `json:"namespaces"` // largest first
+}
+
+// namespaceOf maps a key to the namespace it is reported under
+func namespaceOf(key string) string {
+	if indexKeys[key] {
+		return "indexes"
+	}
+	for _, ns := range nestedNamespaces {
+		if strings.HasPrefix(key, ns) {
+			return ns
+		}
+	}
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i+1]
+	}
+	return key
+}
+
+// MemoryByNamespace counts every key and runs MEMORY USAGE on up to
+// samplesPerNamespace keys of each namespace to estimate its footprint.
+// It scans the whole keyspace, so keep it to admin use.
+func (s *Store) MemoryByNamespace(ctx context.Context, samplesPerNamespace int) (*MemoryReport, error) {
+	stats := make(map[string]*NamespaceMemory)
+	sampledBytes := make(map[string]int64)
+	var scanErr error
+
+	err := s.scanKeys(ctx, "*", func(c *redis.Client, keys []string) bool {
+		pipe := c.Pipeline()
+		usage := make(map[string]*redis.IntCmd)
+		for _, key := range keys {
+			ns := namespaceOf(key)
+			st, ok := stats[ns]
+			if !ok {
+				st = &NamespaceMemory{Namespace: ns}
+				stats[ns] = st
+			}
+			st.Keys++
+			if st.Sampled < samplesPerNamespace {
+				st.Sampled++
+				usage[key] = pipe.MemoryUsage(ctx, key)
+			}
+		}
+		if len(usage) == 0 {
+			return true
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			scanErr = err
+			return false
+		}
+		for key, cmd := range usage {
+			// Keys that expired mid-scan report nil and count as zero
+			sampledBytes[namespaceOf(key)] += cmd.Val()
+		}
+		return true
+	})
+	if err == nil {
+		err = scanErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MemoryReport{Namespaces: make([]NamespaceMemory, 0, len(stats))}
+	for ns, st := range stats {
+		if st.Sampled > 0 {
+			st.Bytes = sampledBytes[ns] / int64(st.Sampled) * st.Keys
+		}
+		report.Namespaces = append(report.Namespaces, *st)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Bytes > report.Namespaces[j].Bytes
+	})
+
+	for _, c := range s.clients() {
+		info, err := c.InfoMap(ctx, "memory").Result()
+		if err != nil {
+			return nil, err
+		}
+		used, _ := strconv.ParseInt(info["Memory"]["used_memory"], 10, 64)
+		report.UsedBytes += used
+	}
+	return report, nil
+}
@@ -0,0 +1,229 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cattymail/internal/config"
+	"cattymail/internal/email"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Schema version bookkeeping keys
+const (
+	KeySchemaVersion = "schema:version"
+	KeySchemaLock    = "schema:lock"
+)
+
+// migration transforms keys written by older releases into the current
+// layout. Migrations run in version order and each runs exactly once per
+// Redis database.
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, m *migrator) error
+}
+
+var migrations = []migration{
+	{1, "per-account folder UID cursors", migrateFolderCursors},
+	{2, "punycode-normalize dynamic domains", migrateIDNDomains},
+	{3, "build global message index", migrateMessageIndex},
+}
+
+// migrator gives migrations dry-run aware helpers
+type migrator struct {
+	client *redis.Client
+	// every backend, primary first, for migrations of keys routed by
+	// domain (see residency.go)
+	clients []*redis.Client
+	cfg     *config.Config
+	dryRun  bool
+}
+
+// SchemaVersion returns the schema version recorded in Redis (0 if unset)
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	v, err := s.client.Get(ctx, KeySchemaVersion).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// LatestSchemaVersion is the schema version this binary expects
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// RunMigrations applies every pending migration. Concurrent callers (API and
+// ingestor starting together) are serialized through a lock key; whoever
+// loses waits for the winner to finish. In dry-run mode the planned changes
+// are only logged and the schema version is left untouched.
+func (s *Store) RunMigrations(ctx context.Context, cfg *config.Config, dryRun bool) error {
+	const lockTTL = 5 * time.Minute
+
+	for {
+		ok, err := s.client.SetNX(ctx, KeySchemaLock, time.Now().Unix(), lockTTL).Result()
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		log.Println("[migrate] Another instance is migrating, waiting...")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+	defer s.client.Del(context.Background(), KeySchemaLock)
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	m := &migrator{client: s.client, clients: s.clients(), cfg: cfg, dryRun: dryRun}
+	for _, mig := range migrations {
+		if mig.version <= current {
+			continue
+		}
+
+		log.Printf("[migrate] Applying %d: %s (dry-run=%v)", mig.version, mig.name, dryRun)
+		if err := mig.up(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", mig.version, mig.name, err)
+		}
+		if dryRun {
+			continue
+		}
+		if err := s.client.Set(ctx, KeySchemaVersion, mig.version, 0).Err(); err != nil {
+			return fmt.Errorf("record schema version %d: %w", mig.version, err)
+		}
+		current = mig.version
+	}
+
+	log.Printf("[migrate] Schema at version %d", current)
+	return nil
+}
+
+// renameNX moves from to to unless to already exists
+func (m *migrator) renameNX(ctx context.Context, from, to string) error {
+	exists, err := m.client.Exists(ctx, from).Result()
+	if err != nil || exists == 0 {
+		return err
+	}
+	log.Printf("[migrate]   RENAMENX %s -> %s", from, to)
+	if m.dryRun {
+		return nil
+	}
+	if err := m.client.RenameNX(ctx, from, to).Err(); err != nil {
+		return err
+	}
+	// Target already existed: the old key is stale, drop it
+	return m.client.Del(ctx, from).Err()
+}
+
+// migrateFolderCursors moves IMAP UID cursors from the legacy global
+// "imap:last_uid" and per-folder "imap:last_uid:<folder>" keys to the
+// per-account "imap:last_uid:<user>:<folder>" layout used by the worker.
+func migrateFolderCursors(ctx context.Context, m *migrator) error {
+	for _, folder := range []string{"INBOX", "INBOX.spam", "INBOX.Junk"} {
+		from := fmt.Sprintf("imap:last_uid:%s", folder)
+		to := fmt.Sprintf("imap:last_uid:%s:%s", m.cfg.IMAPUser, folder)
+		if err := m.renameNX(ctx, from, to); err != nil {
+			return err
+		}
+	}
+	return m.renameNX(ctx, "imap:last_uid", fmt.Sprintf("imap:last_uid:%s:INBOX", m.cfg.IMAPUser))
+}
+
+// migrateIDNDomains rewrites dynamic domains added before IDN support to
+// their lowercase punycode form
+func migrateIDNDomains(ctx context.Context, m *migrator) error {
+	domains, err := m.client.SMembers(ctx, KeyConfigDomains).Result()
+	if err != nil {
+		return err
+	}
+	for _, d := range domains {
+		canonical := email.CanonicalDomain(d)
+		if canonical == d {
+			continue
+		}
+		log.Printf("[migrate]   domain %q -> %q", d, canonical)
+		if m.dryRun {
+			continue
+		}
+		pipe := m.client.TxPipeline()
+		pipe.SRem(ctx, KeyConfigDomains, d)
+		pipe.SAdd(ctx, KeyConfigDomains, canonical)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateMessageIndex adds messages stored before the global index existed
+// to the index of the backend holding them. The ingest time is
+// reconstructed from the remaining TTL.
+func migrateMessageIndex(ctx context.Context, m *migrator) error {
+	for _, c := range m.clients {
+		if err := indexMessages(ctx, m, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexMessages(ctx context.Context, m *migrator, c *redis.Client) error {
+	ttl := time.Duration(m.cfg.TTLSeconds) * time.Second
+	var cursor uint64
+	indexed := 0
+	for {
+		keys, next, err := c.Scan(ctx, cursor, "msg:*", 200).Result()
+		if err != nil {
+			return err
+		}
+
+		pipe := c.Pipeline()
+		pttls := make([]*redis.DurationCmd, len(keys))
+		for i, key := range keys {
+			pttls[i] = pipe.PTTL(ctx, key)
+		}
+		if len(keys) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+				return err
+			}
+		}
+
+		var zs []redis.Z
+		for i, key := range keys {
+			remaining := pttls[i].Val()
+			if remaining == -2 {
+				continue
+			}
+			ingested := time.Now()
+			if remaining > 0 && remaining < ttl {
+				ingested = ingested.Add(remaining - ttl)
+			}
+			zs = append(zs, redis.Z{Score: float64(ingested.Unix()), Member: strings.TrimPrefix(key, "msg:")})
+		}
+		indexed += len(zs)
+		if len(zs) > 0 && !m.dryRun {
+			if err := c.ZAdd(ctx, KeyMessageIndex, zs...).Err(); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	log.Printf("[migrate]   indexed %d messages on %s", indexed, c.Options().Addr)
+	return nil
+}
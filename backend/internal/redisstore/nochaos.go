@@ -0,0 +1,11 @@
+//go:build !chaos
+
+package redisstore
+
+import "github.com/redis/go-redis/v9"
+
+// newClient opens a Redis client. Builds with -tags chaos inject faults
+// here (see chaos.go).
+func newClient(opts *redis.Options) *redis.Client {
+	return redis.NewClient(opts)
+}
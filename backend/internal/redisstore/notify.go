@@ -0,0 +1,103 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+)
+
+// Notification channel layout:
+//
+//	notify:channel:<id>             HASH with kind, scope, target, url, template, created_at
+//	notify:scope:<scope>:<target>   SET of channel IDs for an inbox or domain
+const notifyMaxPerTarget = 5
+
+func notifyChannelKey(id string) string {
+	return fmt.Sprintf("notify:channel:%s", id)
+}
+
+func notifyScopeKey(scope, target string) string {
+	return fmt.Sprintf("notify:scope:%s:%s", scope, target)
+}
+
+// ErrTooManyChannels is returned when a target already has the maximum
+// number of notification channels
+var ErrTooManyChannels = fmt.Errorf("at most %d notification channels per target", notifyMaxPerTarget)
+
+// SaveNotifyChannel stores ch. Inbox channels expire together with the
+// address; domain channels are kept until deleted.
+func (s *Store) SaveNotifyChannel(ctx context.Context, ch *domain.NotifyChannel) error {
+	scopeKey := notifyScopeKey(ch.Scope, ch.Target)
+	n, err := s.client.SCard(ctx, scopeKey).Result()
+	if err != nil {
+		return err
+	}
+	if n >= notifyMaxPerTarget {
+		return ErrTooManyChannels
+	}
+
+	key := notifyChannelKey(ch.ID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"kind":       ch.Kind,
+		"scope":      ch.Scope,
+		"target":     ch.Target,
+		"url":        ch.WebhookURL,
+		"template":   ch.Template,
+		"created_at": ch.CreatedAt.Unix(),
+	})
+	pipe.SAdd(ctx, scopeKey, ch.ID)
+	if ch.Scope == "inbox" {
+		pipe.Expire(ctx, key, s.ttl)
+		pipe.Expire(ctx, scopeKey, s.ttl)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListNotifyChannels returns the channels configured for scope/target
+func (s *Store) ListNotifyChannels(ctx context.Context, scope, target string) ([]*domain.NotifyChannel, error) {
+	ids, err := s.client.SMembers(ctx, notifyScopeKey(scope, target)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make([]*domain.NotifyChannel, 0, len(ids))
+	for _, id := range ids {
+		vals, err := s.client.HMGet(ctx, notifyChannelKey(id), "kind", "scope", "target", "url", "template", "created_at").Result()
+		if err != nil {
+			return nil, err
+		}
+		if vals[0] == nil {
+			// Expired channel, drop the dangling reference
+			s.client.SRem(ctx, notifyScopeKey(scope, target), id)
+			continue
+		}
+
+		ch := &domain.NotifyChannel{ID: id}
+		ch.Kind, _ = vals[0].(string)
+		ch.Scope, _ = vals[1].(string)
+		ch.Target, _ = vals[2].(string)
+		ch.WebhookURL, _ = vals[3].(string)
+		ch.Template, _ = vals[4].(string)
+		if v, ok := vals[5].(string); ok {
+			ts, _ := strconv.ParseInt(v, 10, 64)
+			ch.CreatedAt = time.Unix(ts, 0)
+		}
+		channels = append(channels, ch)
+	}
+	return channels, nil
+}
+
+// DeleteNotifyChannel removes channel id from scope/target. It reports
+// false when the channel does not belong to that target.
+func (s *Store) DeleteNotifyChannel(ctx context.Context, scope, target, id string) (bool, error) {
+	removed, err := s.client.SRem(ctx, notifyScopeKey(scope, target), id).Result()
+	if err != nil || removed == 0 {
+		return false, err
+	}
+	return true, s.client.Del(ctx, notifyChannelKey(id)).Err()
+}
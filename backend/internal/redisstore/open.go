@@ -0,0 +1,43 @@
+package redisstore
+
+import (
+	"fmt"
+	"time"
+
+	"cattymail/internal/config"
+)
+
+// Open connects the store described by cfg: the primary, per-domain
+// backends, inbox limit, storage quotas, message codec, memory pressure
+// thresholds and, with SHADOW_WRITES on, the shadow store.
+// The read replica is left to callers that serve reads (UseReplica).
+func Open(cfg *config.Config) (*Store, error) {
+	store, err := New(cfg.RedisURL, cfg.TTLSeconds)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.RouteDomains(cfg.RedisDomainURLs); err != nil {
+		return nil, fmt.Errorf("domain backends: %w", err)
+	}
+	store.SetInboxLimit(cfg.InboxMaxMessages)
+	store.SetInboxOrder(cfg.InboxOrder)
+	store.SetStorageQuotas(int64(cfg.InboxQuotaBytes), int64(cfg.DomainQuotaBytes))
+	if err := store.SetMessageCodec(MessageCodec{Format: cfg.MessageCodec, GzipThreshold: cfg.MessageGzipThreshold}); err != nil {
+		return nil, fmt.Errorf("message codec: %w", err)
+	}
+	store.SetPressureConfig(PressureConfig{
+		LimitBytes:      int64(cfg.RedisMemoryLimitBytes),
+		DegradedPct:     cfg.MemoryDegradedPct,
+		PausedPct:       cfg.MemoryPausedPct,
+		MaxMessageBytes: cfg.DegradedMaxBytes,
+		TTL:             time.Duration(cfg.DegradedTTLSeconds) * time.Second,
+	})
+	if cfg.ShadowWrites {
+		shadow, err := OpenShadow(cfg.ShadowStoreURL, time.Duration(cfg.TTLSeconds)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("shadow store: %w", err)
+		}
+		store.UseShadow(shadow)
+	}
+	return store, nil
+}
@@ -0,0 +1,166 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Memory pressure: instead of letting Redis evict arbitrary keys once it
+// runs out of memory, the store watches used_memory on every backend and
+// degrades SaveMessage ahead of time:
+//
+//	normal    everything is stored as usual
+//	degraded  oversized messages are rejected and new messages get a
+//	          shortened TTL
+//	paused    no new messages are stored; ingestion waits for memory to
+//	          free up
+//
+// The mode follows the fullest backend.
+
+// PressureMode is the current storage mode
+type PressureMode int32
+
+const (
+	PressureNormal PressureMode = iota
+	PressureDegraded
+	PressurePaused
+)
+
+func (m PressureMode) String() string {
+	switch m {
+	case PressureDegraded:
+		return "degraded"
+	case PressurePaused:
+		return "paused"
+	default:
+		return "normal"
+	}
+}
+
+// MarshalText renders the mode by name in JSON
+func (m PressureMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+var (
+	// ErrIngestPaused is returned by SaveMessage while Redis is too full
+	// to accept new mail
+	ErrIngestPaused = errors.New("redisstore: ingestion paused, redis memory critical")
+	// ErrMessageTooLarge is returned by SaveMessage for messages above
+	// the degraded size limit
+	ErrMessageTooLarge = errors.New("redisstore: message too large while redis memory is degraded")
+)
+
+// PressureConfig holds the memory thresholds. Percentages are of Redis
+// maxmemory, or of LimitBytes when Redis has no maxmemory set; with
+// neither the store stays in normal mode.
+type PressureConfig struct {
+	LimitBytes  int64
+	DegradedPct int
+	PausedPct   int
+	// MaxMessageBytes is the largest encoded message stored while degraded
+	MaxMessageBytes int
+	// TTL replaces the message TTL while degraded when shorter
+	TTL time.Duration
+}
+
+// PressureStatus is the last memory reading
+type PressureStatus struct {
+	Mode       PressureMode `json:"mode"`
+	UsedBytes  int64        `json:"used_bytes"`
+	LimitBytes int64        `json:"limit_bytes"`
+	CheckedAt  time.Time    `json:"checked_at"`
+}
+
+// SetPressureConfig sets the thresholds used by WatchMemory
+func (s *Store) SetPressureConfig(cfg PressureConfig) {
+	s.pressureCfg = cfg
+}
+
+// Pressure returns the current storage mode
+func (s *Store) Pressure() PressureStatus {
+	if p := s.pressure.Load(); p != nil {
+		return *p
+	}
+	return PressureStatus{Mode: PressureNormal}
+}
+
+// WatchMemory samples memory usage every interval until ctx is cancelled,
+// starting immediately
+func (s *Store) WatchMemory(ctx context.Context, interval time.Duration) {
+	for {
+		if err := s.CheckMemory(ctx); err != nil {
+			log.Printf("redisstore: memory check failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// CheckMemory takes one memory sample and updates the storage mode
+func (s *Store) CheckMemory(ctx context.Context) error {
+	cfg := s.pressureCfg
+	worst := PressureStatus{Mode: PressureNormal, CheckedAt: time.Now()}
+	var worstRatio float64 = -1
+
+	for _, c := range s.clients() {
+		info, err := c.InfoMap(ctx, "memory").Result()
+		if err != nil {
+			return err
+		}
+		used, _ := strconv.ParseInt(info["Memory"]["used_memory"], 10, 64)
+		limit, _ := strconv.ParseInt(info["Memory"]["maxmemory"], 10, 64)
+		if limit == 0 {
+			limit = cfg.LimitBytes
+		}
+		if limit <= 0 {
+			continue
+		}
+		ratio := float64(used) / float64(limit)
+		if ratio > worstRatio {
+			worstRatio = ratio
+			worst.UsedBytes, worst.LimitBytes = used, limit
+		}
+	}
+
+	if worstRatio >= 0 {
+		pct := worstRatio * 100
+		switch {
+		case cfg.PausedPct > 0 && pct >= float64(cfg.PausedPct):
+			worst.Mode = PressurePaused
+		case cfg.DegradedPct > 0 && pct >= float64(cfg.DegradedPct):
+			worst.Mode = PressureDegraded
+		}
+	}
+
+	if prev := s.Pressure(); prev.Mode != worst.Mode {
+		log.Printf("redisstore: storage mode %s -> %s (%d/%d bytes)", prev.Mode, worst.Mode, worst.UsedBytes, worst.LimitBytes)
+	}
+	s.pressure.Store(&worst)
+	return nil
+}
+
+// admitMessage decides whether a message of size encoded bytes may be
+// stored in the current mode and returns the TTL to store it with
+func (s *Store) admitMessage(size int) (time.Duration, error) {
+	switch s.Pressure().Mode {
+	case PressurePaused:
+		return 0, ErrIngestPaused
+	case PressureDegraded:
+		cfg := s.pressureCfg
+		if cfg.MaxMessageBytes > 0 && size > cfg.MaxMessageBytes {
+			return 0, fmt.Errorf("%w (%d bytes)", ErrMessageTooLarge, size)
+		}
+		if cfg.TTL > 0 && (s.ttl == 0 || cfg.TTL < s.ttl) {
+			return cfg.TTL, nil
+		}
+	}
+	return s.ttl, nil
+}
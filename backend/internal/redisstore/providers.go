@@ -0,0 +1,66 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/email"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Provider report layout (on the primary):
+//
+//	stats:providers:<YYYY-MM-DD>  HASH "<domain>|<provider>" -> messages stored that day
+//
+// Days are UTC and kept for ProviderStatsDays.
+const ProviderStatsDays = 30
+
+func providerStatsKey(day time.Time) string {
+	return "stats:providers:" + day.UTC().Format("2006-01-02")
+}
+
+// RecordProvider counts a message stored for emailDomain from provider
+func (s *Store) RecordProvider(ctx context.Context, emailDomain, provider string) error {
+	key := providerStatsKey(time.Now())
+	pipe := s.client.Pipeline()
+	pipe.HIncrBy(ctx, key, email.CanonicalDomain(emailDomain)+"|"+provider, 1)
+	pipe.Expire(ctx, key, (ProviderStatsDays+1)*24*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ProviderCounts returns the daily message counts of the last days, by
+// domain then provider, oldest first and ending today
+func (s *Store) ProviderCounts(ctx context.Context, days int) (map[string]map[string][]int64, error) {
+	now := time.Now()
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, days)
+	for i := range cmds {
+		cmds[i] = pipe.HGetAll(ctx, providerStatsKey(now.AddDate(0, 0, i-days+1)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]map[string][]int64)
+	for i, cmd := range cmds {
+		for field, v := range cmd.Val() {
+			d, provider, ok := strings.Cut(field, "|")
+			n, err := strconv.ParseInt(v, 10, 64)
+			if !ok || err != nil {
+				continue
+			}
+			if counts[d] == nil {
+				counts[d] = make(map[string][]int64)
+			}
+			if counts[d][provider] == nil {
+				counts[d][provider] = make([]int64, days)
+			}
+			counts[d][provider][i] = n
+		}
+	}
+	return counts, nil
+}
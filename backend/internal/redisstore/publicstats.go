@@ -0,0 +1,59 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Public statistics layout (on the primary):
+//
+//	stats:daily:<YYYY-MM-DD>  HASH delivered, latency_ms_sum, latency_count
+//
+// Days are UTC. The hash is a handful of counters bumped once per stored
+// message so the public stats endpoint never has to scan anything.
+const dailyStatsRetention = 8 * 24 * time.Hour
+
+func dailyStatsKey(day time.Time) string {
+	return "stats:daily:" + day.UTC().Format("2006-01-02")
+}
+
+// DailyStats are the counters of one UTC day
+type DailyStats struct {
+	Delivered int64
+	// Mean time from arrival at the mail provider to storage, zero when
+	// no latency was recorded
+	AvgLatency time.Duration
+}
+
+// RecordDelivery counts a stored message. latency is the time it took
+// from arrival at the mail provider to storage; negative means unknown.
+func (s *Store) RecordDelivery(ctx context.Context, latency time.Duration) error {
+	key := dailyStatsKey(time.Now())
+	pipe := s.client.Pipeline()
+	pipe.HIncrBy(ctx, key, "delivered", 1)
+	if latency >= 0 {
+		pipe.HIncrBy(ctx, key, "latency_ms_sum", latency.Milliseconds())
+		pipe.HIncrBy(ctx, key, "latency_count", 1)
+	}
+	pipe.Expire(ctx, key, dailyStatsRetention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetDailyStats returns the counters of the UTC day containing day
+func (s *Store) GetDailyStats(ctx context.Context, day time.Time) (*DailyStats, error) {
+	vals, err := s.client.HGetAll(ctx, dailyStatsKey(day)).Result()
+	if err != nil {
+		return nil, err
+	}
+	n := func(k string) int64 {
+		v, _ := strconv.ParseInt(vals[k], 10, 64)
+		return v
+	}
+	stats := &DailyStats{Delivered: n("delivered")}
+	if count := n("latency_count"); count > 0 {
+		stats.AvgLatency = time.Duration(n("latency_ms_sum")/count) * time.Millisecond
+	}
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Web Push layout:
+//
+//	push:<domain>:<local>   HASH endpoint -> subscription JSON, expires with the address
+//	config:vapid            STRING "<private>:<public>" VAPID key pair shared by all processes
+const (
+	KeyConfigVAPID  = "config:vapid"
+	pushMaxPerInbox = 10
+)
+
+// ErrTooManySubscriptions is returned when an inbox already has the
+// maximum number of push subscriptions
+var ErrTooManySubscriptions = fmt.Errorf("at most %d push subscriptions per inbox", pushMaxPerInbox)
+
+func pushKey(emailDomain, local string) string {
+	return fmt.Sprintf("push:%s:%s", emailDomain, local)
+}
+
+// SavePushSubscription stores a browser subscription for an inbox, keyed by
+// its endpoint so re-subscribing replaces the old entry
+func (s *Store) SavePushSubscription(ctx context.Context, emailDomain, local, endpoint string, subscription []byte) error {
+	key := pushKey(emailDomain, local)
+
+	exists, err := s.client.HExists(ctx, key, endpoint).Result()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		n, err := s.client.HLen(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n >= pushMaxPerInbox {
+			return ErrTooManySubscriptions
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, endpoint, subscription)
+	pipe.Expire(ctx, key, s.ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListPushSubscriptions returns the raw subscriptions of an inbox by endpoint
+func (s *Store) ListPushSubscriptions(ctx context.Context, emailDomain, local string) (map[string]string, error) {
+	return s.client.HGetAll(ctx, pushKey(emailDomain, local)).Result()
+}
+
+// DeletePushSubscription removes a subscription, reporting whether it existed
+func (s *Store) DeletePushSubscription(ctx context.Context, emailDomain, local, endpoint string) (bool, error) {
+	n, err := s.client.HDel(ctx, pushKey(emailDomain, local), endpoint).Result()
+	return n > 0, err
+}
+
+// VAPIDKeys returns the VAPID key pair shared by every process, storing
+// generate()'s result on first use
+func (s *Store) VAPIDKeys(ctx context.Context, generate func() (string, string, error)) (string, string, error) {
+	pair, err := s.client.Get(ctx, KeyConfigVAPID).Result()
+	if err == redis.Nil {
+		priv, pub, err := generate()
+		if err != nil {
+			return "", "", err
+		}
+		// Another process may have won the race; keep whichever landed first
+		if _, err := s.client.SetNX(ctx, KeyConfigVAPID, priv+":"+pub, 0).Result(); err != nil {
+			return "", "", err
+		}
+		pair, err = s.client.Get(ctx, KeyConfigVAPID).Result()
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	priv, pub, ok := strings.Cut(pair, ":")
+	if !ok {
+		return "", "", errors.New("malformed VAPID key pair in " + KeyConfigVAPID)
+	}
+	return priv, pub, nil
+}
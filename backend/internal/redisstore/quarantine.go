@@ -0,0 +1,177 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Quarantine layout:
+//
+//	quarantine          ZSET of message IDs scored by report time (primary)
+//	quarantine:reported SET of the quarantined IDs users reported as spam (primary)
+//	quarantine:msg:<id> STRING stored message payload, as under msg:<id>, on
+//	                    the backend of the message's domain
+//
+// A quarantined message is taken out of its inbox and the message index
+// (or never delivered, when the malware scan quarantines it), so it does
+// not show up anywhere users can reach until an admin releases or deletes
+// it.
+const (
+	KeyQuarantine         = "quarantine"
+	KeyQuarantineReported = "quarantine:reported"
+)
+
+func quarantineMsgKey(id string) string {
+	return "quarantine:msg:" + id
+}
+
+// QuarantineMessage moves a message out of its inbox into quarantine.
+// Returns nil if the message does not exist (or is already quarantined).
+func (s *Store) QuarantineMessage(ctx context.Context, id string) (*domain.Message, error) {
+	client, val, err := s.messageClient(ctx, id)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	msg, err := decodeMessage([]byte(val))
+	if err != nil {
+		return nil, err
+	}
+
+	// Park the payload first so a failure below never loses the message
+	if err := s.parkQuarantined(ctx, msg, val); err != nil {
+		return nil, err
+	}
+
+	pipe := client.Pipeline()
+	pipe.Del(ctx, fmt.Sprintf("msg:%s", id))
+	pipe.ZRem(ctx, fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local), id)
+	pipe.ZRem(ctx, KeyMessageIndex, id)
+	s.addRemoved(ctx, pipe, msg.Domain, msg.Local, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.unaccount(ctx, client, id); err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, "msg:"+id)
+	return msg, nil
+}
+
+// ReportSpam quarantines a message a user reported as spam, remembering
+// the report until an admin confirms (DeleteQuarantined) or dismisses
+// (ReleaseQuarantined) it. Returns nil if the message does not exist.
+func (s *Store) ReportSpam(ctx context.Context, id string) (*domain.Message, error) {
+	msg, err := s.QuarantineMessage(ctx, id)
+	if err != nil || msg == nil {
+		return msg, err
+	}
+	return msg, s.client.SAdd(ctx, KeyQuarantineReported, id).Err()
+}
+
+// QuarantineIncoming stores a message that was never delivered straight
+// into quarantine, marking its IMAP UID processed like SaveMessage does
+func (s *Store) QuarantineIncoming(ctx context.Context, msg *domain.Message) error {
+	data, err := encodeMessage(msg, s.codec)
+	if err != nil {
+		return err
+	}
+	if err := s.parkQuarantined(ctx, msg, data); err != nil {
+		return err
+	}
+	if msg.IMAPUID > 0 && msg.IMAPFolder != "" {
+		return s.client.Set(ctx, fmt.Sprintf("imap:uid:%s:%d", msg.IMAPFolder, msg.IMAPUID), "1", s.ttl).Err()
+	}
+	return nil
+}
+
+// parkQuarantined stores a quarantined payload on the backend of its
+// domain, then lists it in the quarantine index on the primary
+func (s *Store) parkQuarantined(ctx context.Context, msg *domain.Message, data interface{}) error {
+	if err := s.clientFor(msg.Domain).Set(ctx, quarantineMsgKey(msg.ID), data, s.ttl).Err(); err != nil {
+		return err
+	}
+	return s.client.ZAdd(ctx, KeyQuarantine, redis.Z{Score: float64(time.Now().Unix()), Member: msg.ID}).Err()
+}
+
+// ListQuarantine returns quarantined messages, most recently reported first
+func (s *Store) ListQuarantine(ctx context.Context, limit int64) ([]*domain.Message, error) {
+	ids, err := s.client.ZRevRange(ctx, KeyQuarantine, 0, limit-1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = quarantineMsgKey(id)
+	}
+	vals, err := s.payloads(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*domain.Message, 0, len(ids))
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue // Expired
+		}
+		if msg, err := decodeMessage([]byte(str)); err == nil {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+// takeQuarantined removes a message from quarantine and returns it, and
+// whether a user reported it. Removing it from the index decides which
+// caller acts on it. Returns nil if it is not quarantined.
+func (s *Store) takeQuarantined(ctx context.Context, id string) (*domain.Message, bool, error) {
+	removed, err := s.client.ZRem(ctx, KeyQuarantine, id).Result()
+	if err != nil || removed == 0 {
+		return nil, false, err
+	}
+	reported, err := s.client.SRem(ctx, KeyQuarantineReported, id).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	key := quarantineMsgKey(id)
+	for _, c := range s.clients() {
+		data, err := c.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		c.Del(ctx, key)
+		msg, err := decodeMessage([]byte(data))
+		return msg, reported > 0, err
+	}
+	return nil, false, nil // Expired
+}
+
+// ReleaseQuarantined puts a quarantined message back into its inbox.
+// Returns nil if it is not quarantined.
+func (s *Store) ReleaseQuarantined(ctx context.Context, id string) (*domain.Message, error) {
+	msg, reported, err := s.takeQuarantined(ctx, id)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+	if err := s.SaveMessage(ctx, msg); err != nil {
+		// Keep it quarantined so the release can be retried
+		if data, eerr := encodeMessage(msg, s.codec); eerr == nil && s.parkQuarantined(ctx, msg, data) == nil && reported {
+			s.client.SAdd(ctx, KeyQuarantineReported, id)
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// DeleteQuarantined drops a quarantined message for good. It returns the
+// message and whether a user reported it, or nil if it is not quarantined.
+func (s *Store) DeleteQuarantined(ctx context.Context, id string) (*domain.Message, bool, error) {
+	return s.takeQuarantined(ctx, id)
+}
@@ -0,0 +1,251 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate limit policy layout (on the primary):
+//
+//	ratelimit:policies                 HASH action -> JSON domain.RatePolicy
+//	ratelimit:bucket:<action>:<key>    HASH tokens, ts (token bucket state)
+//	ratelimit:stats:<YYYYMMDDHHMM>     HASH <action>:allowed, <action>:rejected for that minute (UTC)
+//	ratelimit:rejected:<YYYYMMDDHHMM>  ZSET <action>|<key> -> requests rejected that minute
+//
+// Policies saved here are set by admins and override the configured ones.
+// Per-minute stats are kept for RateStatsMinutes.
+const (
+	KeyRatePolicies  = "ratelimit:policies"
+	RateStatsMinutes = 60
+)
+
+func rateBucketKey(action, key string) string {
+	return "ratelimit:bucket:" + action + ":" + key
+}
+
+func rateMinute(t time.Time) string {
+	return t.UTC().Format("200601021504")
+}
+
+// GetRatePolicies returns the policies saved by admins, keyed by action
+func (s *Store) GetRatePolicies(ctx context.Context) (map[string]domain.RatePolicy, error) {
+	vals, err := s.client.HGetAll(ctx, KeyRatePolicies).Result()
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]domain.RatePolicy, len(vals))
+	for action, v := range vals {
+		var p domain.RatePolicy
+		if err := json.Unmarshal([]byte(v), &p); err == nil {
+			policies[action] = p
+		}
+	}
+	return policies, nil
+}
+
+// SetRatePolicy saves the policy of an action
+func (s *Store) SetRatePolicy(ctx context.Context, action string, p domain.RatePolicy) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyRatePolicies, action, data).Err()
+}
+
+// DeleteRatePolicy drops a saved policy so the configured one applies
+// again. Reports whether there was one.
+func (s *Store) DeleteRatePolicy(ctx context.Context, action string) (bool, error) {
+	n, err := s.client.HDel(ctx, KeyRatePolicies, action).Result()
+	return n > 0, err
+}
+
+// takeTokenScript takes one token from a bucket holding up to ARGV[1]
+// tokens that refills at ARGV[2] tokens per millisecond. Returns 1 and 0
+// when a token was taken, otherwise 0 and the milliseconds until the next
+// token. The outcome is counted in the minute's stats (KEYS[2], KEYS[3]).
+var takeTokenScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+if allowed == 1 then
+	redis.call('HINCRBY', KEYS[2], ARGV[5] .. ':allowed', 1)
+else
+	redis.call('HINCRBY', KEYS[2], ARGV[5] .. ':rejected', 1)
+	redis.call('ZINCRBY', KEYS[3], 1, ARGV[5] .. '|' .. ARGV[6])
+	redis.call('EXPIRE', KEYS[3], ARGV[7])
+end
+redis.call('EXPIRE', KEYS[2], ARGV[7])
+return {allowed, wait}
+`)
+
+// TakeRateToken applies policy p to key (a client IP, an inbox, ...) for
+// action: a token bucket of Limit+Burst tokens refilled at Limit per
+// window. When no token is left it returns how long until the next one.
+// A zero Limit blocks the action.
+func (s *Store) TakeRateToken(ctx context.Context, action, key string, p domain.RatePolicy) (bool, time.Duration, error) {
+	window := time.Duration(p.WindowSecs) * time.Second
+	if p.Limit <= 0 {
+		return false, window, nil
+	}
+	rate := float64(p.Limit) / float64(window.Milliseconds())
+	now := time.Now()
+	keys := []string{rateBucketKey(action, key), "ratelimit:stats:" + rateMinute(now), "ratelimit:rejected:" + rateMinute(now)}
+	res, err := takeTokenScript.Run(ctx, s.client, keys,
+		p.Limit+p.Burst, rate, now.UnixMilli(), window.Milliseconds(), action, key, RateStatsMinutes*60).Int64Slice()
+	if err != nil || len(res) != 2 {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// RateStats are the rate limit decisions of one action over a period
+type RateStats struct {
+	Allowed  int64 `json:"allowed"`
+	Rejected int64 `json:"rejected"`
+}
+
+// RateCounter is the token bucket of one client (IP, inbox, ...) of an
+// action, and the requests it had rejected over the period asked for
+type RateCounter struct {
+	Action    string    `json:"action"`
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"` // left at UpdatedAt, before refill
+	UpdatedAt time.Time `json:"updated_at"`
+	Rejected  int64     `json:"rejected"`
+}
+
+// RateLimitStats sums the decisions per action over the last minutes
+// (at most RateStatsMinutes)
+func (s *Store) RateLimitStats(ctx context.Context, minutes int) (map[string]*RateStats, error) {
+	pipe := s.client.Pipeline()
+	now := time.Now()
+	cmds := make([]*redis.MapStringStringCmd, minutes)
+	for i := range cmds {
+		cmds[i] = pipe.HGetAll(ctx, "ratelimit:stats:"+rateMinute(now.Add(-time.Duration(i)*time.Minute)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*RateStats)
+	for _, cmd := range cmds {
+		for field, v := range cmd.Val() {
+			i := strings.LastIndexByte(field, ':')
+			if i < 0 {
+				continue
+			}
+			action := field[:i]
+			st := stats[action]
+			if st == nil {
+				st = &RateStats{}
+				stats[action] = st
+			}
+			n, _ := strconv.ParseInt(v, 10, 64)
+			if field[i+1:] == "allowed" {
+				st.Allowed += n
+			} else {
+				st.Rejected += n
+			}
+		}
+	}
+	return stats, nil
+}
+
+// TopRateLimited returns the clients with the most rejected requests over
+// the last minutes, with their current bucket, optionally for one action
+func (s *Store) TopRateLimited(ctx context.Context, action string, minutes, limit int) ([]*RateCounter, error) {
+	pipe := s.client.Pipeline()
+	now := time.Now()
+	cmds := make([]*redis.ZSliceCmd, minutes)
+	for i := range cmds {
+		key := "ratelimit:rejected:" + rateMinute(now.Add(-time.Duration(i)*time.Minute))
+		cmds[i] = pipe.ZRevRangeWithScores(ctx, key, 0, 999)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	byMember := make(map[string]*RateCounter)
+	for _, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			member, _ := z.Member.(string)
+			a, key, ok := strings.Cut(member, "|")
+			if !ok || (action != "" && a != action) {
+				continue
+			}
+			c := byMember[member]
+			if c == nil {
+				c = &RateCounter{Action: a, Key: key}
+				byMember[member] = c
+			}
+			c.Rejected += int64(z.Score)
+		}
+	}
+	counters := make([]*RateCounter, 0, len(byMember))
+	for _, c := range byMember {
+		counters = append(counters, c)
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Rejected != counters[j].Rejected {
+			return counters[i].Rejected > counters[j].Rejected
+		}
+		return counters[i].Action+counters[i].Key < counters[j].Action+counters[j].Key
+	})
+	if len(counters) > limit {
+		counters = counters[:limit]
+	}
+	return counters, s.loadRateBuckets(ctx, counters)
+}
+
+// loadRateBuckets fills in the bucket state of counters
+func (s *Store) loadRateBuckets(ctx context.Context, counters []*RateCounter) error {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(counters))
+	for i, c := range counters {
+		cmds[i] = pipe.HMGet(ctx, rateBucketKey(c.Action, c.Key), "tokens", "ts")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return err
+	}
+	for i, c := range counters {
+		vals := cmds[i].Val()
+		if len(vals) != 2 || vals[0] == nil {
+			continue // Bucket expired: full again
+		}
+		tokens, _ := vals[0].(string)
+		ts, _ := vals[1].(string)
+		c.Tokens, _ = strconv.ParseFloat(tokens, 64)
+		if ms, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			c.UpdatedAt = time.UnixMilli(ms)
+		}
+	}
+	return nil
+}
+
+// ResetRateLimit clears the bucket of key for action, so its next request
+// starts with a full bucket. Reports whether there was one.
+func (s *Store) ResetRateLimit(ctx context.Context, action, key string) (bool, error) {
+	n, err := s.client.Del(ctx, rateBucketKey(action, key)).Result()
+	return n > 0, err
+}
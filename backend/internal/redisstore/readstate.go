@@ -0,0 +1,125 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Read state is kept per inbox as a SET of message IDs that expires with
+// the inbox:
+//
+//	read:<domain>:<local>        SET of read message IDs
+//	readclean:<domain>:<local>   STRING minutes after which read messages are deleted
+//	readclean:due                ZSET of read message IDs by deletion time
+//
+// Marking a message read in an inbox with auto-clean on schedules its
+// deletion; the ingestor deletes it once due.
+const KeyReadCleanDue = "readclean:due"
+
+func readKey(emailDomain, local string) string {
+	return fmt.Sprintf("read:%s:%s", emailDomain, local)
+}
+
+func readCleanKey(emailDomain, local string) string {
+	return fmt.Sprintf("readclean:%s:%s", emailDomain, local)
+}
+
+// MarkRead flags ids as read in an inbox
+func (s *Store) MarkRead(ctx context.Context, emailDomain, local string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		members[i] = id
+	}
+
+	key := readKey(emailDomain, local)
+	pipe := s.clientFor(emailDomain).Pipeline()
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, s.ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	after, err := s.ReadCleanAfter(ctx, emailDomain, local)
+	if err != nil || after <= 0 {
+		return err
+	}
+	due := float64(time.Now().Add(after).Unix())
+	scheduled := make([]redis.Z, len(ids))
+	for i, id := range ids {
+		scheduled[i] = redis.Z{Score: due, Member: id}
+	}
+	// NX keeps the first read: reading again does not push deletion back
+	return s.client.ZAddNX(ctx, KeyReadCleanDue, scheduled...).Err()
+}
+
+// ReadIDs returns the set of read message IDs of an inbox
+func (s *Store) ReadIDs(ctx context.Context, emailDomain, local string) (map[string]bool, error) {
+	ids, err := s.clientFor(emailDomain).SMembers(ctx, readKey(emailDomain, local)).Result()
+	if err != nil {
+		return nil, err
+	}
+	read := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		read[id] = true
+	}
+	return read, nil
+}
+
+// SetReadCleanAfter makes an inbox delete messages this long after they
+// are read; zero turns it off. The setting expires with the address.
+func (s *Store) SetReadCleanAfter(ctx context.Context, emailDomain, local string, after time.Duration) error {
+	client := s.clientFor(emailDomain)
+	if after <= 0 {
+		return client.Del(ctx, readCleanKey(emailDomain, local)).Err()
+	}
+	ttl, err := client.PTTL(ctx, fmt.Sprintf("addr:%s:%s", emailDomain, local)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	return client.Set(ctx, readCleanKey(emailDomain, local), int64(after/time.Minute), ttl).Err()
+}
+
+// ReadCleanAfter returns how long after being read an inbox's messages
+// are deleted, zero when auto-clean is off
+func (s *Store) ReadCleanAfter(ctx context.Context, emailDomain, local string) (time.Duration, error) {
+	val, err := s.clientFor(emailDomain).Get(ctx, readCleanKey(emailDomain, local)).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// DueReadCleanups returns up to limit read message IDs due for deletion
+func (s *Store) DueReadCleanups(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	return s.client.ZRangeByScore(ctx, KeyReadCleanDue, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+}
+
+// CleanReadMessage deletes a read message that is due and drops it from
+// the schedule. Messages that already expired are only unscheduled.
+func (s *Store) CleanReadMessage(ctx context.Context, id string) error {
+	if err := s.DeleteMessage(ctx, id); err != nil && err != redis.Nil {
+		return err
+	}
+	return s.client.ZRem(ctx, KeyReadCleanDue, id).Err()
+}
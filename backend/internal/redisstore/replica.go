@@ -0,0 +1,39 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Read replica: GetInbox, GetMessage and GetDomains read primary data from
+// REDIS_REPLICA_URL when configured and fall back to the primary on error.
+// Replicas lag, so GetMessage also falls back on a miss: a message
+// announced over pub/sub may not have replicated yet. Per-domain backends
+// (see residency.go) are always read directly.
+
+// UseReplica connects the read replica. Call it once at startup.
+func (s *Store) UseReplica(url string) error {
+	if url == "" {
+		return nil
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return fmt.Errorf("redis replica: %w", err)
+	}
+	replica := newClient(opts)
+	if err := replica.Ping(context.Background()).Err(); err != nil {
+		return fmt.Errorf("redis replica: %w", err)
+	}
+	s.replica = replica
+	return nil
+}
+
+// readerFor returns the client to read c's data from
+func (s *Store) readerFor(c *redis.Client) *redis.Client {
+	if c == s.client && s.replica != nil {
+		return s.replica
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Data residency: mail for selected domains can live in its own Redis
+// (e.g. an EU-only instance). These keys are routed by recipient domain:
+//
+//	addr:<domain>:<local>, inbox:<domain>:<local>, msg:<id>,
+//	read:<domain>:<local>, token:<domain>:<local>, blob:<id>:<name>, blobs:<id>,
+//	quarantine:msg:<id>, greylist:msg:<id>, honeypot:msg:<id>,
+//	the storage:* accounting of those messages and the
+//	inbox:<domain>:<local> pub/sub channel
+//
+// Everything else (config, accounts, sessions, sender index, rate limits,
+// the ingest feed and IMAP cursors) stays on the primary. Backups cover
+// every backend and restore keys to the backend of their domain.
+
+// RouteDomains connects a Redis backend for each domain in urls. Call it
+// once at startup before the store is used.
+func (s *Store) RouteDomains(urls map[string]string) error {
+	byURL := make(map[string]*redis.Client)
+	for emailDomain, url := range urls {
+		client, ok := byURL[url]
+		if !ok {
+			opts, err := redis.ParseURL(url)
+			if err != nil {
+				return fmt.Errorf("redis backend for %s: %w", emailDomain, err)
+			}
+			client = newClient(opts)
+			if err := client.Ping(context.Background()).Err(); err != nil {
+				return fmt.Errorf("redis backend for %s: %w", emailDomain, err)
+			}
+			byURL[url] = client
+		}
+		if s.backends == nil {
+			s.backends = make(map[string]*redis.Client)
+		}
+		s.backends[emailDomain] = client
+	}
+	return nil
+}
+
+// clientFor returns the Redis holding the inbox data of emailDomain
+func (s *Store) clientFor(emailDomain string) *redis.Client {
+	if c, ok := s.backends[emailDomain]; ok {
+		return c
+	}
+	return s.client
+}
+
+// pipelineFor returns the pipeline in pipes for emailDomain's backend,
+// creating it on first use
+func (s *Store) pipelineFor(pipes map[*redis.Client]redis.Pipeliner, emailDomain string) redis.Pipeliner {
+	c := s.clientFor(emailDomain)
+	if _, ok := pipes[c]; !ok {
+		pipes[c] = c.Pipeline()
+	}
+	return pipes[c]
+}
+
+// clients returns every backend, primary first
+func (s *Store) clients() []*redis.Client {
+	all := []*redis.Client{s.client}
+	seen := map[*redis.Client]bool{s.client: true}
+	for _, c := range s.backends {
+		if !seen[c] {
+			seen[c] = true
+			all = append(all, c)
+		}
+	}
+	return all
+}
+
+// messageClient finds the backend holding msg:<id> and returns it along
+// with the stored payload, or nil when the message does not exist
+func (s *Store) messageClient(ctx context.Context, id string) (*redis.Client, string, error) {
+	key := fmt.Sprintf("msg:%s", id)
+	for _, c := range s.clients() {
+		val, err := c.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return c, val, nil
+	}
+	return nil, "", nil
+}
+
+// messagePayloads fetches msg:<id> for every id from all backends,
+// preserving order. Missing messages yield nil entries.
+func (s *Store) messagePayloads(ctx context.Context, ids []string) ([]interface{}, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = fmt.Sprintf("msg:%s", id)
+	}
+	return s.payloads(ctx, keys)
+}
+
+// payloads fetches every key from all backends, preserving order. Keys
+// missing everywhere yield nil entries.
+func (s *Store) payloads(ctx context.Context, keys []string) ([]interface{}, error) {
+	var merged []interface{}
+	for _, c := range s.clients() {
+		vals, err := c.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = vals
+			continue
+		}
+		for i, v := range vals {
+			if merged[i] == nil {
+				merged[i] = v
+			}
+		}
+	}
+	return merged, nil
+}
+
+// scanKeys calls fn with every batch of keys matching pattern on every
+// backend. Returning false from fn stops the scan.
+func (s *Store) scanKeys(ctx context.Context, pattern string, fn func(c *redis.Client, keys []string) bool) error {
+	for _, c := range s.clients() {
+		var cursor uint64
+		for {
+			keys, next, err := c.Scan(ctx, cursor, pattern, 100).Result()
+			if err != nil {
+				return err
+			}
+			if !fn(c, keys) {
+				return nil
+			}
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
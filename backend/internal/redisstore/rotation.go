@@ -0,0 +1,96 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Domain rotation layout (on the primary):
+//
+//	config:domain_rotation          STRING DomainRotation JSON, set by admins
+//	config:domains:added            ZSET of dynamic domains scored by when they were added
+//	rotation:assigned:<YYYYMMDDHH>  ZSET of domain -> addresses handed out that hour (UTC)
+const (
+	KeyDomainRotation = "config:domain_rotation"
+	KeyDomainsAdded   = "config:domains:added"
+)
+
+// assignedRetention keeps the current and the previous hour
+const assignedRetention = 2 * time.Hour
+
+func assignedKey(t time.Time) string {
+	return "rotation:assigned:" + t.UTC().Format("2006010215")
+}
+
+// GetDomainRotation returns the rotation saved by admins, or nil
+func (s *Store) GetDomainRotation(ctx context.Context) (*domain.DomainRotation, error) {
+	data, err := s.client.Get(ctx, KeyDomainRotation).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rot domain.DomainRotation
+	if err := json.Unmarshal(data, &rot); err != nil {
+		return nil, err
+	}
+	return &rot, nil
+}
+
+// SaveDomainRotation replaces the rotation settings
+func (s *Store) SaveDomainRotation(ctx context.Context, rot *domain.DomainRotation) error {
+	data, err := json.Marshal(rot)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, KeyDomainRotation, data, 0).Err()
+}
+
+// RecordDomainAssigned counts an address handed out on emailDomain
+func (s *Store) RecordDomainAssigned(ctx context.Context, emailDomain string) error {
+	key := assignedKey(time.Now())
+	pipe := s.client.Pipeline()
+	pipe.ZIncrBy(ctx, key, 1, emailDomain)
+	pipe.Expire(ctx, key, assignedRetention)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DomainLoad returns the addresses handed out per domain over the current
+// and the previous hour
+func (s *Store) DomainLoad(ctx context.Context, domains []string) (map[string]int64, error) {
+	now := time.Now()
+	pipe := s.client.Pipeline()
+	current := pipe.ZMScore(ctx, assignedKey(now), domains...)
+	previous := pipe.ZMScore(ctx, assignedKey(now.Add(-time.Hour)), domains...)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	load := make(map[string]int64, len(domains))
+	for i, d := range domains {
+		load[d] = int64(current.Val()[i] + previous.Val()[i])
+	}
+	return load, nil
+}
+
+// DomainsAddedAt returns when each dynamic domain was added. Domains from
+// the static configuration are absent.
+func (s *Store) DomainsAddedAt(ctx context.Context) (map[string]time.Time, error) {
+	entries, err := s.client.ZRangeWithScores(ctx, KeyDomainsAdded, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[string]time.Time, len(entries))
+	for _, z := range entries {
+		if d, ok := z.Member.(string); ok {
+			added[d] = time.Unix(int64(z.Score), 0)
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,189 @@
+package redisstore
+
+import (
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyMessageIndex lists every stored message on a backend, newest last:
+//
+//	messages:index   ZSET of message IDs scored by ingest time (unix seconds)
+//
+// It is pruned of expired entries whenever a message is saved.
+const KeyMessageIndex = "messages:index"
+
+// saveMessageScript stores a message in one atomic step. It has two parts
+// so it can be split when the recipient domain lives on its own backend
+// (see residency.go); on a single Redis both run in one call.
+//
+// Store part (ARGV[11] == "1"), on the recipient domain's backend:
+// storage quota check (nothing is written when it fails), message payload,
+// inbox entry, inbox trim to ARGV[5] entries (evicted messages are
+// unlisted and tombstoned, see changes.go), the message index, storage accounting (see storage.go) and the
+// inbox pub/sub notification. It returns the IDs of evicted messages;
+// their payloads and blobs are not among KEYS, so the caller deletes them.
+//
+// Index part (ARGV[12] == "1"), on the primary: IMAP UID marker, sender
+// index and profile counters, and the ingest feed event.
+//
+// KEYS: msg, inbox, uid marker, sender, sender profile, sender domains,
+// message index, then the storage keys and the inbox tombstones (unused
+// keys are passed as "").
+// ARGV: payload, ttl ms, id, score, inbox limit, inbox channel, now,
+// recipient domain, feed channel, feed payload, store part, index part,
+// message ttl ms (shorter than ttl under memory pressure, see pressure.go),
+// inbox quota, domain quota, "<domain>:<local>".
+var saveMessageScript = redis.NewScript(storageLua + `
+local ttl = tonumber(ARGV[2])
+local msgTTL = tonumber(ARGV[13])
+local S = {KEYS[8], KEYS[9], KEYS[10], KEYS[11]}
+local evicted = {}
+
+-- A zero TTL means keys never expire
+local function expire(key)
+	if ttl > 0 then
+		redis.call("PEXPIRE", key, ttl)
+	end
+end
+
+-- freed returns the bytes the sweep and the re-save of id are about to
+-- subtract from inbox and domain d, using reads only
+local function freed(now, id, inbox, d)
+	local ids = redis.call("ZRANGEBYSCORE", S[2], "-inf", now, "LIMIT", 0, 100)
+	table.insert(ids, id)
+	local seen, fromInbox, fromDomain = {}, 0, 0
+	for _, x in ipairs(ids) do
+		local v = not seen[x] and redis.call("HGET", S[1], x)
+		seen[x] = true
+		if v then
+			local sep = string.find(v, "|", 1, true)
+			local size = tonumber(string.sub(v, 1, sep - 1))
+			local owner = string.sub(v, sep + 1)
+			if owner == inbox then
+				fromInbox = fromInbox + size
+			end
+			if string.sub(owner, 1, string.find(owner, ":", 1, true) - 1) == d then
+				fromDomain = fromDomain + size
+			end
+		end
+	end
+	return fromInbox, fromDomain
+end
+
+if ARGV[11] == "1" then
+	-- Lua keeps writes made before an error reply, so the quota is decided
+	-- before anything is written
+	local size = string.len(ARGV[1])
+	local fromInbox, fromDomain = freed(ARGV[7], ARGV[3], ARGV[16], ARGV[8])
+	local quotas = {{"inbox", KEYS[10], ARGV[16], tonumber(ARGV[14]), fromInbox}, {"domain", KEYS[11], ARGV[8], tonumber(ARGV[15]), fromDomain}}
+	for _, q in ipairs(quotas) do
+		local used = math.max(0, tonumber(redis.call("HGET", q[2], q[3]) or "0") - q[5])
+		if q[4] > 0 and used + size > q[4] then
+			return redis.error_reply("QUOTA " .. q[1] .. " " .. used .. " " .. q[4])
+		end
+	end
+
+	sweep(S, ARGV[7])
+	-- A message saved again (e.g. released from quarantine) replaces its
+	-- previous accounting
+	unaccount(S, ARGV[3])
+
+	redis.call("SET", KEYS[1], ARGV[1])
+	if msgTTL > 0 then
+		redis.call("PEXPIRE", KEYS[1], msgTTL)
+	end
+	redis.call("ZADD", KEYS[2], ARGV[4], ARGV[3])
+	expire(KEYS[2])
+	-- A message saved again is no longer removed
+	redis.call("ZREM", KEYS[12], ARGV[3])
+	redis.call("ZADD", KEYS[7], ARGV[7], ARGV[3])
+	if ttl > 0 then
+		redis.call("ZREMRANGEBYSCORE", KEYS[7], "-inf", "(" .. (tonumber(ARGV[7]) - math.floor(ttl / 1000)))
+	end
+
+	local limit = tonumber(ARGV[5])
+	if limit > 0 then
+		local excess = redis.call("ZCARD", KEYS[2]) - limit
+		if excess > 0 then
+			evicted = redis.call("ZRANGE", KEYS[2], 0, excess - 1)
+			redis.call("ZREMRANGEBYRANK", KEYS[2], 0, excess - 1)
+			for _, id in ipairs(evicted) do
+				redis.call("ZREM", KEYS[7], id)
+				unaccount(S, id)
+				redis.call("ZADD", KEYS[12], ARGV[7], id)
+			end
+		end
+	end
+
+	if ttl > 0 and redis.call("EXISTS", KEYS[12]) == 1 then
+		redis.call("ZREMRANGEBYSCORE", KEYS[12], "-inf", "(" .. (tonumber(ARGV[7]) - math.floor(ttl / 1000)))
+		expire(KEYS[12])
+	end
+
+	redis.call("HINCRBY", KEYS[10], ARGV[16], size)
+	redis.call("HINCRBY", KEYS[11], ARGV[8], size)
+	redis.call("HSET", KEYS[8], ARGV[3], size .. "|" .. ARGV[16])
+	local expiresAt = "+inf"
+	if msgTTL > 0 then
+		expiresAt = tonumber(ARGV[7]) + math.ceil(msgTTL / 1000)
+	end
+	redis.call("ZADD", KEYS[9], expiresAt, ARGV[3])
+
+	redis.call("PUBLISH", ARGV[6], ARGV[3])
+end
+
+if ARGV[12] == "1" then
+	if KEYS[3] ~= "" then
+		redis.call("SET", KEYS[3], "1")
+		expire(KEYS[3])
+	end
+
+	if KEYS[4] ~= "" then
+		redis.call("ZADD", KEYS[4], ARGV[7], ARGV[3])
+		redis.call("HSETNX", KEYS[5], "first_seen", ARGV[7])
+		redis.call("HSET", KEYS[5], "last_seen", ARGV[7])
+		redis.call("HINCRBY", KEYS[5], "count", 1)
+		redis.call("SADD", KEYS[6], ARGV[8])
+		-- Keep the index around as long as the newest message from this sender
+		expire(KEYS[4])
+		expire(KEYS[5])
+		expire(KEYS[6])
+	end
+
+	redis.call("PUBLISH", ARGV[9], ARGV[10])
+end
+
+return evicted
+`)
+
+// SetInboxOrder sets what inboxes are ordered by: "received" (ingest time,
+// the default) or "date" (the sender-supplied Date header, which a sender
+// can set in the future to stay on top). It applies to messages saved from
+// then on.
+func (s *Store) SetInboxOrder(order string) {
+	s.orderByDate = order == "date"
+}
+
+// InboxTime is the time msg is ordered by in its inbox, which is also what
+// GetInbox's before pages on. Messages stored before ReceivedAt was
+// recorded fall back to their Date.
+func (s *Store) InboxTime(msg *domain.Message) time.Time {
+	if s.orderByDate || msg.ReceivedAt.IsZero() {
+		return msg.Date
+	}
+	return msg.ReceivedAt
+}
+
+// inboxScore is the score of msg in its inbox ZSET
+func (s *Store) inboxScore(msg *domain.Message) int64 {
+	return s.InboxTime(msg).Unix()
+}
+
+// SetInboxLimit caps how many messages an inbox keeps; older ones are
+// evicted when new mail arrives. 0 means unlimited.
+func (s *Store) SetInboxLimit(n int) {
+	s.inboxLimit = n
+}
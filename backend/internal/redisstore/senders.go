@@ -0,0 +1,132 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cattymail/internal/domain"
+	"cattymail/internal/email"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Sender index layout (written by saveMessageScript):
+//
+//	sender:<address>          ZSET of message IDs scored by ingest time
+//	sender:<address>:profile  HASH with count, first_seen, last_seen, spam_reports
+//	sender:<address>:domains  SET of recipient domains targeted
+func senderKey(address string) string {
+	return fmt.Sprintf("sender:%s", address)
+}
+
+// SenderAddress extracts the normalized bare address from a From value
+// such as `"Name" <user@example.com>`. Returns "" if nothing usable is found.
+func SenderAddress(from string) string {
+	_, address := email.SplitFrom(from)
+	return address
+}
+
+// GetSenderProfile returns the profile of a sender with up to limit of their
+// most recent messages. Returns nil if the sender is unknown.
+func (s *Store) GetSenderProfile(ctx context.Context, address string, limit int) (*domain.SenderProfile, error) {
+	address = SenderAddress(address)
+	key := senderKey(address)
+
+	pipe := s.client.Pipeline()
+	profileCmd := pipe.HGetAll(ctx, key+":profile")
+	domainsCmd := pipe.SMembers(ctx, key+":domains")
+	idsCmd := pipe.ZRevRange(ctx, key, 0, int64(limit-1))
+	honeypotCmd := pipe.HGet(ctx, "reputation:sender:"+address, "honeypot_hits")
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	profile := profileCmd.Val()
+	if len(profile) == 0 {
+		return nil, nil
+	}
+
+	result := &domain.SenderProfile{
+		Address:        address,
+		Domains:        domainsCmd.Val(),
+		RecentMessages: []*domain.Message{},
+	}
+	fmt.Sscan(profile["count"], &result.MessageCount)
+	fmt.Sscan(profile["spam_reports"], &result.SpamReports)
+	result.Reputation = reputation(result.MessageCount, result.SpamReports)
+	if result.HoneypotHits, _ = honeypotCmd.Int64(); result.HoneypotHits > 0 {
+		// Only scrapers mail addresses no one was given
+		result.Reputation = 0
+	}
+	var first, last int64
+	fmt.Sscan(profile["first_seen"], &first)
+	fmt.Sscan(profile["last_seen"], &last)
+	result.FirstSeen = time.Unix(first, 0)
+	result.LastSeen = time.Unix(last, 0)
+
+	ids := idsCmd.Val()
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	vals, err := s.messagePayloads(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, val := range vals {
+		str, ok := val.(string)
+		if !ok {
+			continue // Expired
+		}
+		if msg, err := decodeMessage([]byte(str)); err == nil {
+			result.RecentMessages = append(result.RecentMessages, msg)
+		}
+	}
+
+	return result, nil
+}
+
+// reputation scores a sender from 1 (never reported) down to 0 (every
+// message reported as spam)
+func reputation(count, reports int64) float64 {
+	if count <= 0 || reports <= 0 {
+		return 1
+	}
+	if reports >= count {
+		return 0
+	}
+	return 1 - float64(reports)/float64(count)
+}
+
+// recordSpamReportScript counts a report against a known sender; unknown
+// (expired) senders are left alone so no profile is created without a TTL
+var recordSpamReportScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+redis.call("HINCRBY", KEYS[1], "spam_reports", 1)
+local count = tonumber(redis.call("HGET", KEYS[1], "count") or "0")
+local reports = tonumber(redis.call("HGET", KEYS[1], "spam_reports"))
+return {count, reports}
+`)
+
+// RecordSpamReport lowers the reputation of the sender of a From value and
+// returns the new score (1 for unknown senders)
+func (s *Store) RecordSpamReport(ctx context.Context, from string) (float64, error) {
+	address := SenderAddress(from)
+	if address == "" {
+		return 1, nil
+	}
+	res, err := recordSpamReportScript.Run(ctx, s.client, []string{senderKey(address) + ":profile"}).Result()
+	if err != nil {
+		return 0, err
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return 1, nil
+	}
+	count, _ := vals[0].(int64)
+	reports, _ := vals[1].(int64)
+	return reputation(count, reports), nil
+}
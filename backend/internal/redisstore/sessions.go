@@ -0,0 +1,126 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Anonymous sessions group several temp addresses under one client. A
+// session is a SET of "local@domain" members that lives as long as the
+// addresses it holds and is extended together with them.
+
+// sessionTouchInterval throttles how often an active session re-extends
+// its addresses
+const sessionTouchInterval = 5 * time.Minute
+
+func sessionKey(id string) string {
+	return fmt.Sprintf("session:%s", id)
+}
+
+// AddSessionAddress binds local@emailDomain to session id for ttl
+func (s *Store) AddSessionAddress(ctx context.Context, id, emailDomain, local string, ttl time.Duration) error {
+	key := sessionKey(id)
+	pipe := s.client.TxPipeline()
+	pipe.SAdd(ctx, key, local+"@"+emailDomain)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RemoveSessionAddress unbinds local@emailDomain from session id. The
+// address itself keeps running until it expires.
+func (s *Store) RemoveSessionAddress(ctx context.Context, id, emailDomain, local string) error {
+	return s.client.SRem(ctx, sessionKey(id), local+"@"+emailDomain).Err()
+}
+
+// CountSessionAddresses returns how many addresses are bound to session id,
+// including ones that expired since the last listing
+func (s *Store) CountSessionAddresses(ctx context.Context, id string) (int64, error) {
+	return s.client.SCard(ctx, sessionKey(id)).Result()
+}
+
+// SessionAddress is an address bound to a session along with its remaining
+// lifetime
+type SessionAddress struct {
+	Local  string
+	Domain string
+	TTL    time.Duration
+}
+
+// SessionAddresses lists the live addresses of session id, pruning members
+// whose address has already expired
+func (s *Store) SessionAddresses(ctx context.Context, id string) ([]SessionAddress, error) {
+	key := sessionKey(id)
+	members, err := s.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipes := make(map[*redis.Client]redis.Pipeliner)
+	ttls := make([]*redis.DurationCmd, len(members))
+	for i, m := range members {
+		local, d, _ := strings.Cut(m, "@")
+		ttls[i] = s.pipelineFor(pipes, d).PTTL(ctx, fmt.Sprintf("addr:%s:%s", d, local))
+	}
+	for _, pipe := range pipes {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, err
+		}
+	}
+
+	var live []SessionAddress
+	var stale []interface{}
+	for i, m := range members {
+		ttl := ttls[i].Val()
+		// PTTL yields -2 for a missing key
+		if ttl == -2 {
+			stale = append(stale, m)
+			continue
+		}
+		local, d, _ := strings.Cut(m, "@")
+		live = append(live, SessionAddress{Local: local, Domain: d, TTL: ttl})
+	}
+	if len(stale) > 0 {
+		s.client.SRem(ctx, key, stale...)
+	}
+	return live, nil
+}
+
+// TouchSession extends the session and every address bound to it by ttl.
+// Calls within sessionTouchInterval of the last extension
+// are no-ops so it is cheap to call on every request.
+func (s *Store) TouchSession(ctx context.Context, id string, ttl time.Duration) error {
+	key := sessionKey(id)
+	fresh, err := s.client.SetNX(ctx, key+":touched", "1", sessionTouchInterval).Result()
+	if err != nil || !fresh {
+		return err
+	}
+
+	members, err := s.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	pipes := map[*redis.Client]redis.Pipeliner{s.client: s.client.Pipeline()}
+	pipes[s.client].Expire(ctx, key, ttl)
+	for _, m := range members {
+		local, d, _ := strings.Cut(m, "@")
+		pipe := s.pipelineFor(pipes, d)
+		// XX: never resurrect an address that has already expired
+		pipe.ExpireXX(ctx, fmt.Sprintf("addr:%s:%s", d, local), ttl)
+		pipe.ExpireXX(ctx, fmt.Sprintf("inbox:%s:%s", d, local), ttl)
+	}
+	for _, pipe := range pipes {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package redisstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Shadow ingestion dark-launches a second message store: with
+// SHADOW_WRITES on, SaveMessage also writes every message to the
+// ShadowStore at SHADOW_STORE_URL, and WatchShadow compares recent
+// messages against it. Shadow writes never fail or slow down ingest; reads
+// keep coming from Redis until the shadow has run clean.
+//
+//	shadow:stats   HASH writes, write_errors, compared, missing, mismatched, last_compared_at
+//	shadow:diffs   LIST of the latest JSON domain.ShadowDivergence, newest first
+const (
+	KeyShadowStats = "shadow:stats"
+	KeyShadowDiffs = "shadow:diffs"
+	// ShadowDiffsMax caps the divergences kept for review
+	ShadowDiffsMax = 500
+
+	shadowTimeout = 5 * time.Second
+	// shadowSettle skips messages saved this recently, whose shadow write
+	// may still be in flight
+	shadowSettle = 30 * time.Second
+	// shadowSample is how many recent messages each comparison checks
+	shadowSample = 100
+)
+
+// Divergence kinds
+const (
+	ShadowMissing  = "missing"
+	ShadowMismatch = "mismatch"
+)
+
+// ShadowStore is a message backend being dark-launched. GetMessage
+// returns nil, nil for unknown IDs.
+type ShadowStore interface {
+	SaveMessage(ctx context.Context, msg *domain.Message) error
+	GetMessage(ctx context.Context, id string) (*domain.Message, error)
+}
+
+// OpenShadow connects the shadow store at url. Only Redis URLs are
+// supported so far, which is enough to rehearse a migration; other
+// backends plug in by implementing ShadowStore.
+func OpenShadow(url string, ttl time.Duration) (ShadowStore, error) {
+	if !strings.HasPrefix(url, "redis://") && !strings.HasPrefix(url, "rediss://") {
+		return nil, fmt.Errorf("unsupported shadow store %q", strings.SplitN(url, ":", 2)[0])
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+	return &redisShadow{client: client, ttl: ttl}, nil
+}
+
+// UseShadow makes SaveMessage write every message to shadow as well
+func (s *Store) UseShadow(shadow ShadowStore) {
+	s.shadow = shadow
+}
+
+// redisShadow keeps plain JSON messages in another Redis:
+//
+//	msg:<id>   STRING Message JSON, expiring with the message
+type redisShadow struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func (r *redisShadow) SaveMessage(ctx context.Context, msg *domain.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, "msg:"+msg.ID, data, r.ttl).Err()
+}
+
+func (r *redisShadow) GetMessage(ctx context.Context, id string) (*domain.Message, error) {
+	data, err := r.client.Get(ctx, "msg:"+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msg domain.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// writeShadow copies a saved message to the shadow store in the
+// background. Failures are logged and counted, never returned.
+func (s *Store) writeShadow(ctx context.Context, msg *domain.Message) {
+	if s.shadow == nil {
+		return
+	}
+	cp := *msg
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, shadowTimeout)
+		defer cancel()
+		field := "writes"
+		if err := s.shadow.SaveMessage(ctx, &cp); err != nil {
+			log.Printf("redisstore: shadow write of %s failed: %v", cp.ID, err)
+			field = "write_errors"
+		}
+		s.client.HIncrBy(ctx, KeyShadowStats, field, 1)
+	}()
+}
+
+// WatchShadow compares recent messages with the shadow store every
+// interval until ctx is cancelled. It returns at once when no shadow
+// store is in use.
+func (s *Store) WatchShadow(ctx context.Context, interval time.Duration) {
+	if s.shadow == nil {
+		return
+	}
+	for {
+		if err := s.CompareShadow(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("redisstore: shadow comparison failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// CompareShadow checks the latest messages of every backend against the
+// shadow store and records the divergences
+func (s *Store) CompareShadow(ctx context.Context) error {
+	if s.shadow == nil {
+		return errors.New("no shadow store in use")
+	}
+	now := time.Now()
+	var compared, missing, mismatched int64
+	var diffs []interface{}
+	for _, c := range s.clients() {
+		ids, err := c.ZRevRangeByScore(ctx, KeyMessageIndex, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(now.Add(-shadowSettle).Unix(), 10),
+			Count: shadowSample,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			primary, err := s.GetMessage(ctx, id)
+			if err != nil {
+				return err
+			}
+			if primary == nil {
+				continue // Expired or deleted meanwhile
+			}
+			shadow, err := s.shadow.GetMessage(ctx, id)
+			if err != nil {
+				return fmt.Errorf("shadow: %w", err)
+			}
+
+			compared++
+			div := domain.ShadowDivergence{ID: id, ComparedAt: now}
+			if shadow == nil {
+				div.Kind = ShadowMissing
+				missing++
+			} else if div.Fields = diffMessages(primary, shadow); len(div.Fields) > 0 {
+				div.Kind = ShadowMismatch
+				mismatched++
+			} else {
+				continue
+			}
+			data, err := json.Marshal(div)
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, data)
+		}
+	}
+
+	pipe := s.client.Pipeline()
+	pipe.HIncrBy(ctx, KeyShadowStats, "compared", compared)
+	pipe.HIncrBy(ctx, KeyShadowStats, "missing", missing)
+	pipe.HIncrBy(ctx, KeyShadowStats, "mismatched", mismatched)
+	pipe.HSet(ctx, KeyShadowStats, "last_compared_at", now.Unix())
+	if len(diffs) > 0 {
+		pipe.LPush(ctx, KeyShadowDiffs, diffs...)
+		pipe.LTrim(ctx, KeyShadowDiffs, 0, ShadowDiffsMax-1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// diffMessages returns the JSON fields in which a and b differ, sorted
+func diffMessages(a, b *domain.Message) []string {
+	fa, fb := messageFields(a), messageFields(b)
+	var fields []string
+	for name, va := range fa {
+		if !bytes.Equal(va, fb[name]) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range fb {
+		if _, ok := fa[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// messageFields splits msg into its JSON fields. Dates are compared in UTC
+// since codecs may not keep the location.
+func messageFields(msg *domain.Message) map[string]json.RawMessage {
+	cp := *msg
+	cp.Date = cp.Date.UTC()
+	cp.ReceivedAt = cp.ReceivedAt.UTC()
+	fields := map[string]json.RawMessage{}
+	if data, err := json.Marshal(&cp); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	return fields
+}
+
+// ShadowStats returns the shadow write and comparison counters, and
+// whether a shadow store is in use
+func (s *Store) ShadowStats(ctx context.Context) (map[string]int64, bool, error) {
+	vals, err := s.client.HGetAll(ctx, KeyShadowStats).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	stats := make(map[string]int64, len(vals))
+	for k, v := range vals {
+		stats[k], _ = strconv.ParseInt(v, 10, 64)
+	}
+	return stats, s.shadow != nil, nil
+}
+
+// ShadowDivergences returns up to limit of the latest divergences
+func (s *Store) ShadowDivergences(ctx context.Context, limit int64) ([]*domain.ShadowDivergence, error) {
+	vals, err := s.client.LRange(ctx, KeyShadowDiffs, 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]*domain.ShadowDivergence, 0, len(vals))
+	for _, v := range vals {
+		var d domain.ShadowDivergence
+		if json.Unmarshal([]byte(v), &d) == nil {
+			diffs = append(diffs, &d)
+		}
+	}
+	return diffs, nil
+}
+
+// ResetShadow clears the shadow counters and divergences, e.g. after a
+// fix to the new backend
+func (s *Store) ResetShadow(ctx context.Context) error {
+	return s.client.Del(ctx, KeyShadowStats, KeyShadowDiffs).Err()
+}
@@ -0,0 +1,49 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"cattymail/internal/domain"
+)
+
+// Skipped-recipient sample layout:
+//
+//	skipped:recent  LIST of SkippedMessage JSON, newest first, capped
+const KeySkippedRecent = "skipped:recent"
+
+// SaveSkipped adds a skipped message to the rolling sample, keeping the
+// newest max entries
+func (s *Store) SaveSkipped(ctx context.Context, sm *domain.SkippedMessage, max int) error {
+	data, err := json.Marshal(sm)
+	if err != nil {
+		return err
+	}
+	pipe := s.client.Pipeline()
+	pipe.LPush(ctx, KeySkippedRecent, data)
+	pipe.LTrim(ctx, KeySkippedRecent, 0, int64(max-1))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListSkipped returns the sampled skipped messages, newest first
+func (s *Store) ListSkipped(ctx context.Context) ([]*domain.SkippedMessage, error) {
+	vals, err := s.client.LRange(ctx, KeySkippedRecent, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*domain.SkippedMessage, 0, len(vals))
+	for _, v := range vals {
+		var sm domain.SkippedMessage
+		if err := json.Unmarshal([]byte(v), &sm); err != nil {
+			continue
+		}
+		out = append(out, &sm)
+	}
+	return out, nil
+}
+
+// ClearSkipped empties the sample
+func (s *Store) ClearSkipped(ctx context.Context) error {
+	return s.client.Del(ctx, KeySkippedRecent).Err()
+}
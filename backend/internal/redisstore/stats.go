@@ -2,245 +2,250 @@ package redisstore
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // GetTotalAddresses returns count of all address keys
 func (s *Store) GetTotalAddresses(ctx context.Context) (int64, error) {
-	var cursor uint64
 	var count int64
-
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "addr:*", 100).Result()
-		if err != nil {
-			return 0, err
-		}
+	err := s.scanKeys(ctx, "addr:*", func(_ *redis.Client, keys []string) bool {
 		count += int64(len(keys))
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
-	}
-	return count, nil
+		return true
+	})
+	return count, err
 }
 
 // GetTotalMessages returns count of all message keys
 func (s *Store) GetTotalMessages(ctx context.Context) (int64, error) {
-	var cursor uint64
 	var count int64
-
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "msg:*", 100).Result()
+	for _, c := range s.clients() {
+		// Drop entries whose message has expired since the last save
+		if s.ttl > 0 {
+			cutoff := fmt.Sprintf("(%d", time.Now().Add(-s.ttl).Unix())
+			c.ZRemRangeByScore(ctx, KeyMessageIndex, "-inf", cutoff)
+		}
+		n, err := c.ZCard(ctx, KeyMessageIndex).Result()
 		if err != nil {
 			return 0, err
 		}
-		count += int64(len(keys))
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+		count += n
 	}
 	return count, nil
 }
 
 // GetActiveAddresses returns count of addresses with TTL > 0
 func (s *Store) GetActiveAddresses(ctx context.Context) (int64, error) {
-	var cursor uint64
 	var count int64
-
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "addr:*", 100).Result()
-		if err != nil {
-			return 0, err
+	var scanErr error
+	err := s.scanKeys(ctx, "addr:*", func(c *redis.Client, keys []string) bool {
+		// Check TTL for each key in one round trip per batch
+		pipe := c.Pipeline()
+		ttls := make([]*redis.DurationCmd, len(keys))
+		for i, key := range keys {
+			ttls[i] = pipe.TTL(ctx, key)
 		}
-		
-		// Check TTL for each key
-		for _, key := range keys {
-			ttl, err := s.client.TTL(ctx, key).Result()
-			if err == nil && ttl > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			scanErr = err
+			return false
+		}
+		for _, ttl := range ttls {
+			if ttl.Val() > 0 {
 				count++
 			}
 		}
-		
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+		return true
+	})
+	if err == nil {
+		err = scanErr
 	}
-	return count, nil
+	return count, err
 }
 
 // GetMessagesLast24h returns count of messages from last 24 hours
 func (s *Store) GetMessagesLast24h(ctx context.Context) (int64, error) {
-	var cursor uint64
 	var count int64
-	yesterday := time.Now().Add(-24 * time.Hour).Unix()
+	yesterday := fmt.Sprintf("%d", time.Now().Add(-24*time.Hour).Unix())
 
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "inbox:*", 100).Result()
+	for _, c := range s.clients() {
+		n, err := c.ZCount(ctx, KeyMessageIndex, yesterday, "+inf").Result()
 		if err != nil {
 			return 0, err
 		}
-
-		for _, inboxKey := range keys {
-			// Count messages with score > yesterday
-			c, err := s.client.ZCount(ctx, inboxKey, fmt.Sprintf("%d", yesterday), "+inf").Result()
-			if err == nil {
-				count += c
-			}
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+		count += n
 	}
 	return count, nil
 }
 
 // GetAllAddresses returns paginated list of all addresses
 func (s *Store) GetAllAddresses(ctx context.Context, offset, limit int) ([]string, error) {
-	var cursor uint64
 	var addresses []string
 	skip := offset
-	collected := 0
-
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "addr:*", 100).Result()
-		if err != nil {
-			return nil, err
-		}
 
+	err := s.scanKeys(ctx, "addr:*", func(_ *redis.Client, keys []string) bool {
 		for _, key := range keys {
 			if skip > 0 {
 				skip--
 				continue
 			}
-			if collected >= limit {
-				return addresses, nil
+			if len(addresses) >= limit {
+				return false
 			}
 			// Extract address from key format "addr:domain:local"
 			addresses = append(addresses, key)
-			collected++
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	return addresses, nil
 }
 
 // GetAllMessages returns paginated list of all messages
 func (s *Store) GetAllMessages(ctx context.Context, offset, limit int) ([]*domain.Message, error) {
-	var cursor uint64
-	var messageIDs []string
-	skip := offset
-	collected := 0
+	if offset < 0 || limit <= 0 {
+		return []*domain.Message{}, nil
+	}
 
-	// First, collect message IDs
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "msg:*", 100).Result()
+	// Newest offset+limit entries of every backend, merged by ingest time
+	type entry struct {
+		client *redis.Client
+		id     string
+		score  float64
+	}
+	var entries []entry
+	for _, c := range s.clients() {
+		zs, err := c.ZRevRangeWithScores(ctx, KeyMessageIndex, 0, int64(offset+limit-1)).Result()
 		if err != nil {
 			return nil, err
 		}
+		for _, z := range zs {
+			entries = append(entries, entry{client: c, id: fmt.Sprint(z.Member), score: z.Score})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].score > entries[j].score })
+	if offset >= len(entries) {
+		return []*domain.Message{}, nil
+	}
+	entries = entries[offset:]
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
 
-		for _, key := range keys {
-			if skip > 0 {
-				skip--
+	// One MGET per backend
+	byClient := make(map[*redis.Client][]int)
+	for i, e := range entries {
+		byClient[e.client] = append(byClient[e.client], i)
+	}
+	hydrated := make([]*domain.Message, len(entries))
+	for c, idx := range byClient {
+		keys := make([]string, len(idx))
+		for k, i := range idx {
+			keys[k] = fmt.Sprintf("msg:%s", entries[i].id)
+		}
+		vals, err := c.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		var gone []interface{}
+		for k, val := range vals {
+			str, ok := val.(string)
+			if !ok {
+				// Expired or deleted behind the index's back
+				gone = append(gone, entries[idx[k]].id)
 				continue
 			}
-			if collected >= limit {
-				break
+			if msg, err := decodeMessage([]byte(str)); err == nil {
+				hydrated[idx[k]] = msg
 			}
-			messageIDs = append(messageIDs, key)
-			collected++
 		}
-
-		if collected >= limit {
-			break
-		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
+		if len(gone) > 0 {
+			c.ZRem(ctx, KeyMessageIndex, gone...)
 		}
 	}
 
-	// Fetch actual messages
-	var messages []*domain.Message
-	for _, msgKey := range messageIDs {
-		val, err := s.client.Get(ctx, msgKey).Result()
-		if err != nil {
-			continue
-		}
-
-		var msg domain.Message
-		if err := json.Unmarshal([]byte(val), &msg); err == nil {
-			messages = append(messages, &msg)
+	messages := make([]*domain.Message, 0, len(hydrated))
+	for _, msg := range hydrated {
+		if msg != nil {
+			messages = append(messages, msg)
 		}
 	}
-
 	return messages, nil
 }
 
 // DeleteMessage deletes a message by ID
 func (s *Store) DeleteMessage(ctx context.Context, id string) error {
 	msgKey := fmt.Sprintf("msg:%s", id)
-	
+
 	// Get message to find its inbox
-	val, err := s.client.Get(ctx, msgKey).Result()
+	client, val, err := s.messageClient(ctx, id)
 	if err != nil {
 		return err
 	}
+	if client == nil {
+		return redis.Nil
+	}
 
-	var msg domain.Message
-	if err := json.Unmarshal([]byte(val), &msg); err != nil {
+	msg, err := decodeMessage([]byte(val))
+	if err != nil {
 		return err
 	}
 
 	// Delete from inbox and message
-	pipe := s.client.Pipeline()
+	pipe := client.Pipeline()
 	pipe.Del(ctx, msgKey)
 	inboxKey := fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local)
 	pipe.ZRem(ctx, inboxKey, id)
+	pipe.ZRem(ctx, KeyMessageIndex, id)
+	s.addRemoved(ctx, pipe, msg.Domain, msg.Local, id)
 	_, err = pipe.Exec(ctx)
-	
-	return err
+	if err != nil {
+		return err
+	}
+	if err := s.unaccount(ctx, client, id); err != nil {
+		return err
+	}
+
+	s.invalidate(ctx, "msg:"+id)
+	return nil
 }
 
 // GetDomainStats returns message count per domain
 func (s *Store) GetDomainStats(ctx context.Context) (map[string]int64, error) {
 	stats := make(map[string]int64)
-	var cursor uint64
+	var scanErr error
 
-	for {
-		keys, nextCursor, err := s.client.Scan(ctx, cursor, "inbox:*", 100).Result()
-		if err != nil {
-			return nil, err
+	err := s.scanKeys(ctx, "inbox:*", func(c *redis.Client, keys []string) bool {
+		pipe := c.Pipeline()
+		counts := make([]*redis.IntCmd, len(keys))
+		for i, inboxKey := range keys {
+			counts[i] = pipe.ZCard(ctx, inboxKey)
 		}
-
-		for _, inboxKey := range keys {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			scanErr = err
+			return false
+		}
+		for i, inboxKey := range keys {
 			// Extract domain from "inbox:domain:local"
 			parts := splitInboxKey(inboxKey)
 			if len(parts) >= 2 {
-				domain := parts[1]
-				count, err := s.client.ZCard(ctx, inboxKey).Result()
-				if err == nil {
-					stats[domain] += count
-				}
+				stats[parts[1]] += counts[i].Val()
 			}
 		}
-
-		cursor = nextCursor
-		if cursor == 0 {
-			break
-		}
+		return true
+	})
+	if err == nil {
+		err = scanErr
+	}
+	if err != nil {
+		return nil, err
 	}
 	return stats, nil
 }
@@ -0,0 +1,178 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Storage accounting layout (on the recipient domain's backend, next to
+// the messages it counts):
+//
+//	storage:inboxes  HASH of "<domain>:<local>" -> bytes stored
+//	storage:domains  HASH of domain -> bytes stored
+//	storage:sizes    HASH of message ID -> "<bytes>|<domain>:<local>"
+//	storage:expiry   ZSET of message IDs scored by expiry (unix seconds)
+//
+// saveMessageScript adds a message's encoded size; deleting, evicting or
+// quarantining it subtracts it again. Expired messages are subtracted
+// lazily: every save and report first sweeps storage:expiry.
+const (
+	KeyStorageInboxes = "storage:inboxes"
+	KeyStorageDomains = "storage:domains"
+	KeyStorageSizes   = "storage:sizes"
+	KeyStorageExpiry  = "storage:expiry"
+)
+
+// storageKeys are passed to the scripts below in this order
+var storageKeys = []string{KeyStorageSizes, KeyStorageExpiry, KeyStorageInboxes, KeyStorageDomains}
+
+// storageLua defines unaccount and sweep for the scripts using it; S is
+// the table of storage keys
+const storageLua = `
+local function unaccount(S, id)
+	redis.call("ZREM", S[2], id)
+	local v = redis.call("HGET", S[1], id)
+	if not v then
+		return
+	end
+	redis.call("HDEL", S[1], id)
+	local sep = string.find(v, "|", 1, true)
+	local size = tonumber(string.sub(v, 1, sep - 1))
+	local inbox = string.sub(v, sep + 1)
+	local d = string.sub(inbox, 1, string.find(inbox, ":", 1, true) - 1)
+	if redis.call("HINCRBY", S[3], inbox, -size) <= 0 then
+		redis.call("HDEL", S[3], inbox)
+	end
+	if redis.call("HINCRBY", S[4], d, -size) <= 0 then
+		redis.call("HDEL", S[4], d)
+	end
+end
+
+local function sweep(S, now)
+	for _, id in ipairs(redis.call("ZRANGEBYSCORE", S[2], "-inf", now, "LIMIT", 0, 100)) do
+		unaccount(S, id)
+	end
+end
+`
+
+// unaccountScript subtracts a removed message. KEYS: storage keys.
+// ARGV: message ID.
+var unaccountScript = redis.NewScript(storageLua + `
+unaccount(KEYS, ARGV[1])
+return 1
+`)
+
+// sweepScript subtracts expired messages. KEYS: storage keys. ARGV: now.
+var sweepScript = redis.NewScript(storageLua + `
+sweep(KEYS, ARGV[1])
+return 1
+`)
+
+// ErrQuotaExceeded is returned by SaveMessage when the message would take
+// its inbox or domain over the storage quota
+var ErrQuotaExceeded = errors.New("redisstore: storage quota exceeded")
+
+// SetStorageQuotas caps the bytes stored per inbox and per domain; 0 means
+// unlimited
+func (s *Store) SetStorageQuotas(inboxBytes, domainBytes int64) {
+	s.inboxQuota, s.domainQuota = inboxBytes, domainBytes
+}
+
+// StorageQuotas returns the quotas set with SetStorageQuotas
+func (s *Store) StorageQuotas() (inboxBytes, domainBytes int64) {
+	return s.inboxQuota, s.domainQuota
+}
+
+// quotaError turns the script's "QUOTA <scope> <used> <quota>" reply into
+// an ErrQuotaExceeded
+func quotaError(err error) error {
+	if err == nil {
+		return nil
+	}
+	fields := strings.Fields(err.Error())
+	if len(fields) != 4 || fields[0] != "QUOTA" {
+		return err
+	}
+	return fmt.Errorf("%w: %s uses %s of %s bytes", ErrQuotaExceeded, fields[1], fields[2], fields[3])
+}
+
+// unaccount subtracts message id from the storage counters of its backend
+func (s *Store) unaccount(ctx context.Context, client *redis.Client, id string) error {
+	return unaccountScript.Run(ctx, client, storageKeys, id).Err()
+}
+
+// InboxUsage returns the bytes stored for an inbox
+func (s *Store) InboxUsage(ctx context.Context, emailDomain, local string) (int64, error) {
+	client := s.clientFor(emailDomain)
+	if err := sweepScript.Run(ctx, client, storageKeys, time.Now().Unix()).Err(); err != nil {
+		return 0, err
+	}
+	n, err := client.HGet(ctx, KeyStorageInboxes, emailDomain+":"+local).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// StorageUsage is the bytes stored by an inbox or domain
+type StorageUsage struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// StorageReport lists the limit biggest inboxes and every domain, largest
+// first, across all backends
+func (s *Store) StorageReport(ctx context.Context, limit int) (inboxes, domains []StorageUsage, err error) {
+	now := time.Now().Unix()
+	for _, c := range s.clients() {
+		if err := sweepScript.Run(ctx, c, storageKeys, now).Err(); err != nil {
+			return nil, nil, err
+		}
+		in, err := c.HGetAll(ctx, KeyStorageInboxes).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		dom, err := c.HGetAll(ctx, KeyStorageDomains).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		inboxes = appendUsage(inboxes, in)
+		domains = appendUsage(domains, dom)
+	}
+	for i := range inboxes {
+		// Report inboxes as addresses
+		d, local, _ := strings.Cut(inboxes[i].Name, ":")
+		inboxes[i].Name = local + "@" + d
+	}
+	sortUsage(inboxes)
+	sortUsage(domains)
+	if len(inboxes) > limit {
+		inboxes = inboxes[:limit]
+	}
+	return inboxes, domains, nil
+}
+
+func appendUsage(usage []StorageUsage, counts map[string]string) []StorageUsage {
+	for name, v := range counts {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			usage = append(usage, StorageUsage{Name: name, Bytes: n})
+		}
+	}
+	return usage
+}
+
+func sortUsage(usage []StorageUsage) {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Bytes != usage[j].Bytes {
+			return usage[i].Bytes > usage[j].Bytes
+		}
+		return usage[i].Name < usage[j].Name
+	})
+}
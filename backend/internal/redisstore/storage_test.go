@@ -0,0 +1,58 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotaRejectionLeavesAccountingAlone(t *testing.T) {
+	s := benchStore(t)
+	ctx := context.Background()
+
+	local := fmt.Sprintf("quota%d", time.Now().UnixNano())
+	first := benchMessage(local)
+	if err := s.SaveMessage(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	field := "bench.test:" + local
+	before, err := s.client.HGet(ctx, KeyStorageInboxes, field).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for the first message and a little more
+	s.SetStorageQuotas(before+64, 0)
+	defer s.SetStorageQuotas(0, 0)
+
+	second := benchMessage(local)
+	resaved := *first
+	resaved.HTML = strings.Repeat("<p>grown</p>", 512)
+	for _, tc := range []struct {
+		name string
+		save func() error
+	}{
+		{"new message", func() error { return s.SaveMessage(ctx, second) }},
+		{"grown re-save", func() error { return s.SaveMessage(ctx, &resaved) }},
+	} {
+		if err := tc.save(); !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("%s: SaveMessage = %v, want ErrQuotaExceeded", tc.name, err)
+		}
+		after, err := s.client.HGet(ctx, KeyStorageInboxes, field).Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after != before {
+			t.Errorf("%s: inbox bytes = %d after rejection, want %d", tc.name, after, before)
+		}
+		if n := s.client.ZCard(ctx, "inbox:bench.test:"+local).Val(); n != 1 {
+			t.Errorf("%s: inbox holds %d messages after rejection, want 1", tc.name, n)
+		}
+	}
+	if n := s.client.Exists(ctx, "msg:"+second.ID).Val(); n != 0 {
+		t.Errorf("rejected message was stored")
+	}
+}
@@ -4,16 +4,43 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"cattymail/internal/domain"
+	"cattymail/internal/email"
 
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
+// ChannelIngestFeed is the global pub/sub channel carrying metadata for
+// every stored message (see domain.IngestEvent).
+const ChannelIngestFeed = "feed:ingest"
+
 type Store struct {
 	client *redis.Client
 	ttl    time.Duration
+	caches *storeCaches
+	reads  singleflight.Group
+	// Per-domain backends for data residency (see residency.go)
+	backends map[string]*redis.Client
+	// Optional read replica of the primary (see replica.go)
+	replica *redis.Client
+	// Maximum messages per inbox, 0 for unlimited (see save.go)
+	inboxLimit int
+	codec      MessageCodec
+	// Storage quotas in bytes, 0 for unlimited (see storage.go)
+	inboxQuota, domainQuota int64
+	// Memory pressure state (see pressure.go)
+	pressure    atomic.Pointer[PressureStatus]
+	pressureCfg PressureConfig
+	// Inboxes ordered by Date header instead of ingest time (see save.go)
+	orderByDate bool
+	// Second message store being dark-launched (see shadow.go)
+	shadow ShadowStore
+	// Result of the startup self-check (see capabilities.go)
+	capabilities atomic.Pointer[CapabilityReport]
 }
 
 func New(redisURL string, ttlSeconds int) (*Store, error) {
@@ -22,72 +49,174 @@ func New(redisURL string, ttlSeconds int) (*Store, error) {
 		return nil, err
 	}
 
-	client := redis.NewClient(opts)
+	client := newClient(opts)
 	if err := client.Ping(context.Background()).Err(); err != nil {
 		return nil, err
 	}
 
-	return &Store{
+	s := &Store{
 		client: client,
 		ttl:    time.Duration(ttlSeconds) * time.Second,
-	}, nil
+		caches: newStoreCaches(),
+	}
+	go s.watchInvalidations(context.Background())
+
+	return s, nil
+}
+
+// PoolStats exposes the Redis connection pool counters
+func (s *Store) PoolStats() *redis.PoolStats {
+	return s.client.PoolStats()
 }
 
 func (s *Store) ReserveAddress(ctx context.Context, emailDomain, local string) (bool, error) {
 	key := fmt.Sprintf("addr:%s:%s", emailDomain, local)
-	success, err := s.client.SetNX(ctx, key, "1", s.ttl).Result()
+	success, err := s.clientFor(emailDomain).SetNX(ctx, key, "1", s.ttl).Result()
 	if err != nil {
 		return false, err
 	}
 	return success, nil
 }
 
+// AddressExists reports whether local@emailDomain is currently held
+func (s *Store) AddressExists(ctx context.Context, emailDomain, local string) (bool, error) {
+	key := fmt.Sprintf("addr:%s:%s", emailDomain, local)
+	n, err := s.clientFor(emailDomain).Exists(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
 func (s *Store) EnsureAddress(ctx context.Context, emailDomain, local string) error {
 	key := fmt.Sprintf("addr:%s:%s", emailDomain, local)
 	// Set (Upsert) - always succeeds and refreshes TTL
-	return s.client.Set(ctx, key, "1", s.ttl).Err()
+	return s.clientFor(emailDomain).Set(ctx, key, "1", s.ttl).Err()
 }
 
 func (s *Store) SaveMessage(ctx context.Context, msg *domain.Message) error {
-	// 1. Save message content
-	msgKey := fmt.Sprintf("msg:%s", msg.ID)
-	data, err := json.Marshal(msg)
+	if msg.FromAddress == "" && msg.From != "" {
+		msg.FromName, msg.FromAddress = email.SplitFrom(msg.From)
+	}
+	// Messages saved again (released from quarantine, restored) keep
+	// their original ingest time
+	if msg.ReceivedAt.IsZero() {
+		msg.ReceivedAt = time.Now()
+	}
+	data, err := encodeMessage(msg, s.codec)
+	if err != nil {
+		return err
+	}
+	msgTTL, err := s.admitMessage(len(data))
 	if err != nil {
 		return err
 	}
 
-	pipe := s.client.Pipeline()
-	pipe.Set(ctx, msgKey, data, s.ttl)
-
-	// 2. Add to inbox
-	inboxKey := fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local)
-	pipe.ZAdd(ctx, inboxKey, redis.Z{
-		Score:  float64(msg.Date.Unix()),
-		Member: msg.ID,
-	})
-	pipe.Expire(ctx, inboxKey, s.ttl)
+	// Metadata for the global admin feed
+	event := domain.IngestEvent{
+		ID:         msg.ID,
+		From:       msg.From,
+		To:         msg.OriginalTo,
+		Inbox:      msg.Local + "@" + msg.Domain,
+		Subject:    msg.Subject,
+		Size:       msg.Size,
+		Folder:     msg.IMAPFolder,
+		ReceivedAt: msg.ReceivedAt,
+	}
+	feed, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
 
-	// 3. Mark IMAP UID as processed (if present) - include folder for uniqueness
+	keys := []string{
+		fmt.Sprintf("msg:%s", msg.ID),
+		fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local),
+		"", "", "", "",
+		KeyMessageIndex,
+		KeyStorageSizes, KeyStorageExpiry, KeyStorageInboxes, KeyStorageDomains,
+		removedKey(msg.Domain, msg.Local),
+	}
+	// Mark IMAP UID as processed (if present) - include folder for uniqueness
 	if msg.IMAPUID > 0 && msg.IMAPFolder != "" {
-		uidKey := fmt.Sprintf("imap:uid:%s:%d", msg.IMAPFolder, msg.IMAPUID)
-		pipe.Set(ctx, uidKey, "1", s.ttl)
+		keys[2] = fmt.Sprintf("imap:uid:%s:%d", msg.IMAPFolder, msg.IMAPUID)
+	}
+	if address := msg.FromAddress; address != "" {
+		key := senderKey(address)
+		keys[3], keys[4], keys[5] = key, key+":profile", key+":domains"
 	}
 
-	_, err = pipe.Exec(ctx)
+	args := []interface{}{
+		data,
+		s.ttl.Milliseconds(),
+		msg.ID,
+		s.inboxScore(msg),
+		s.inboxLimit,
+		fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local),
+		time.Now().Unix(),
+		msg.Domain,
+		ChannelIngestFeed,
+		feed,
+	}
+	run := func(c *redis.Client, keys []string, storePart, indexPart string) ([]string, error) {
+		evicted, err := saveMessageScript.Run(ctx, c, keys, append(args, storePart, indexPart, msgTTL.Milliseconds(),
+			s.inboxQuota, s.domainQuota, msg.Domain+":"+msg.Local)...).StringSlice()
+		return evicted, quotaError(err)
+	}
+
+	// Sender index, IMAP markers and the feed are global and live on the
+	// primary; when the domain has its own backend the script runs twice
+	client := s.clientFor(msg.Domain)
+	if client == s.client {
+		evicted, err := run(client, keys, "1", "1")
+		if err != nil {
+			return err
+		}
+		s.writeShadow(ctx, msg)
+		return s.dropEvicted(ctx, client, evicted)
+	}
+	storeKeys := append([]string{keys[0], keys[1], "", "", "", "", keys[6]}, keys[7:]...)
+	evicted, err := run(client, storeKeys, "1", "0")
 	if err != nil {
 		return err
 	}
+	indexKeys := []string{"", "", keys[2], keys[3], keys[4], keys[5], "", "", "", "", "", ""}
+	if _, err := run(s.client, indexKeys, "0", "1"); err != nil {
+		return err
+	}
+	s.writeShadow(ctx, msg)
+	return s.dropEvicted(ctx, client, evicted)
+}
 
-	// 4. Publish SSE notification
-	channel := fmt.Sprintf("inbox:%s:%s", msg.Domain, msg.Local)
-	_ = s.client.Publish(ctx, channel, msg.ID).Err()
-
+// dropEvicted deletes the payloads and blobs of messages the save script
+// evicted from a full inbox and drops them from every process's cache
+func (s *Store) dropEvicted(ctx context.Context, client *redis.Client, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pipe := client.Pipeline()
+	for _, id := range ids {
+		pipe.Del(ctx, fmt.Sprintf("msg:%s", id))
+		if err := s.deleteMessageBlobs(ctx, client, pipe, id); err != nil {
+			return err
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.invalidate(ctx, "msg:"+id)
+	}
 	return nil
 }
 
 func (s *Store) Subscribe(ctx context.Context, emailDomain, local string) *redis.PubSub {
 	channel := fmt.Sprintf("inbox:%s:%s", emailDomain, local)
-	return s.client.Subscribe(ctx, channel)
+	return s.clientFor(emailDomain).Subscribe(ctx, channel)
+}
+
+// SubscribeIngestFeed subscribes to the global feed of ingested messages
+func (s *Store) SubscribeIngestFeed(ctx context.Context) *redis.PubSub {
+	return s.client.Subscribe(ctx, ChannelIngestFeed)
 }
 
 func (s *Store) IsUIDProcessed(ctx context.Context, folder string, uid uint32) (bool, error) {
@@ -96,6 +225,12 @@ func (s *Store) IsUIDProcessed(ctx context.Context, folder string, uid uint32) (
 	return exists > 0, err
 }
 
+// MarkUIDProcessed records a message that was handled without being
+// stored, so it is not fetched again
+func (s *Store) MarkUIDProcessed(ctx context.Context, folder string, uid uint32) error {
+	return s.client.Set(ctx, fmt.Sprintf("imap:uid:%s:%d", folder, uid), "1", s.ttl).Err()
+}
+
 func (s *Store) GetLastProcessedUID(ctx context.Context) (uint32, error) {
 	val, err := s.client.Get(ctx, "imap:last_uid").Uint64()
 	if err == redis.Nil {
@@ -128,7 +263,57 @@ func (s *Store) SetFolderLastUID(ctx context.Context, folder string, uid uint32)
 	return s.client.Set(ctx, key, uid, 0).Err()
 }
 
+// KeyIngestHeartbeat holds the unix time of the last completed IMAP cycle
+const KeyIngestHeartbeat = "imap:last_cycle"
+
+// MarkIngestCycle records that the ingestor finished a polling cycle
+func (s *Store) MarkIngestCycle(ctx context.Context) error {
+	return s.client.Set(ctx, KeyIngestHeartbeat, time.Now().Unix(), 0).Err()
+}
+
+// LastIngestCycle returns when the ingestor last finished a polling cycle,
+// or the zero time if it never has
+func (s *Store) LastIngestCycle(ctx context.Context) (time.Time, error) {
+	ts, err := s.client.Get(ctx, KeyIngestHeartbeat).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0), nil
+}
+
+// GetInbox returns up to limit messages older than before, newest first.
+// Concurrent identical reads (many tabs polling one inbox) share a single
+// Redis round trip.
 func (s *Store) GetInbox(ctx context.Context, emailDomain, local string, limit int, before int64) ([]*domain.Message, error) {
+	flightKey := fmt.Sprintf("inbox:%s:%s:%d:%d", emailDomain, local, limit, before)
+	v, err, _ := s.reads.Do(flightKey, func() (interface{}, error) {
+		// Detach from the caller so one client disconnecting doesn't fail
+		// the read for everyone sharing it
+		return s.getInbox(context.WithoutCancel(ctx), emailDomain, local, limit, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers get their own slice; messages themselves are treated as read-only
+	shared := v.([]*domain.Message)
+	return append([]*domain.Message{}, shared...), nil
+}
+
+func (s *Store) getInbox(ctx context.Context, emailDomain, local string, limit int, before int64) ([]*domain.Message, error) {
+	client := s.clientFor(emailDomain)
+	if reader := s.readerFor(client); reader != client {
+		if msgs, err := s.readInbox(ctx, reader, emailDomain, local, limit, before); err == nil {
+			return msgs, nil
+		}
+	}
+	return s.readInbox(ctx, client, emailDomain, local, limit, before)
+}
+
+func (s *Store) readInbox(ctx context.Context, client *redis.Client, emailDomain, local string, limit int, before int64) ([]*domain.Message, error) {
 	inboxKey := fmt.Sprintf("inbox:%s:%s", emailDomain, local)
 
 	// Default range: -inf to +inf (all)
@@ -139,7 +324,7 @@ func (s *Store) GetInbox(ctx context.Context, emailDomain, local string, limit i
 	}
 
 	// RevRangeByScore to get newest first
-	ids, err := s.client.ZRevRangeByScore(ctx, inboxKey, &redis.ZRangeBy{
+	ids, err := client.ZRevRangeByScore(ctx, inboxKey, &redis.ZRangeBy{
 		Min:   "-inf",
 		Max:   max,
 		Count: int64(limit),
@@ -159,7 +344,7 @@ func (s *Store) GetInbox(ctx context.Context, emailDomain, local string, limit i
 	}
 
 	// MGet to fetch all
-	vals, err := s.client.MGet(ctx, keys...).Result()
+	vals, err := client.MGet(ctx, keys...).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -169,10 +354,9 @@ func (s *Store) GetInbox(ctx context.Context, emailDomain, local string, limit i
 		if val == nil {
 			continue // Expired?
 		}
-		var msg domain.Message
 		if str, ok := val.(string); ok {
-			if err := json.Unmarshal([]byte(str), &msg); err == nil {
-				messages = append(messages, &msg)
+			if msg, err := decodeMessage([]byte(str)); err == nil {
+				messages = append(messages, msg)
 			}
 		}
 	}
@@ -181,19 +365,36 @@ func (s *Store) GetInbox(ctx context.Context, emailDomain, local string, limit i
 }
 
 func (s *Store) GetMessage(ctx context.Context, id string) (*domain.Message, error) {
-	val, err := s.client.Get(ctx, fmt.Sprintf("msg:%s", id)).Result()
-	if err != nil {
-		if err == redis.Nil {
+	if cached, ok := s.caches.messages.Get(id); ok {
+		cp := *cached
+		return &cp, nil
+	}
+
+	var val string
+	var err error
+	if s.replica != nil {
+		val, err = s.replica.Get(ctx, fmt.Sprintf("msg:%s", id)).Result()
+	}
+	// A replica miss may be lag or a message on a domain backend
+	if s.replica == nil || err != nil {
+		var client *redis.Client
+		client, val, err = s.messageClient(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil {
 			return nil, nil // Not found
 		}
-		return nil, err
 	}
 
-	var msg domain.Message
-	if err := json.Unmarshal([]byte(val), &msg); err != nil {
+	msg, err := decodeMessage([]byte(val))
+	if err != nil {
 		return nil, err
 	}
-	return &msg, nil
+
+	cp := *msg
+	s.caches.messages.Set(id, &cp)
+	return msg, nil
 }
 
 func (s *Store) RateLimit(ctx context.Context, ip string, action string, limit int, window time.Duration) (bool, error) {
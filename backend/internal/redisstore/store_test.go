@@ -0,0 +1,90 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Tests and benchmarks run against a real Redis. Point BENCH_REDIS_URL at
+// a scratch database (e.g. redis://localhost:6379/15); keys expire after a
+// minute.
+func benchStore(tb testing.TB) *Store {
+	url := os.Getenv("BENCH_REDIS_URL")
+	if url == "" {
+		tb.Skip("BENCH_REDIS_URL not set")
+	}
+	s, err := New(url, 60)
+	if err != nil {
+		tb.Skipf("Redis unavailable: %v", err)
+	}
+	return s
+}
+
+func benchMessage(local string) *domain.Message {
+	return &domain.Message{
+		ID:         ulid.Make().String(),
+		Domain:     "bench.test",
+		Local:      local,
+		OriginalTo: local + "@bench.test",
+		From:       "\"GitHub\" <noreply@github.com>",
+		Subject:    "[GitHub] Please verify your device",
+		Date:       time.Now(),
+		Text:       "Verification code: 482913",
+		HTML:       "<p>Verification code: <b>482913</b></p>" + strings.Repeat("<p>padding</p>", 256),
+	}
+}
+
+func BenchmarkSaveMessage(b *testing.B) {
+	s := benchStore(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := s.SaveMessage(ctx, benchMessage(fmt.Sprintf("save%d", i%100))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetInbox(b *testing.B) {
+	s := benchStore(b)
+	ctx := context.Background()
+
+	local := fmt.Sprintf("inbox%d", time.Now().UnixNano())
+	for i := 0; i < 50; i++ {
+		if err := s.SaveMessage(ctx, benchMessage(local)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetInbox(ctx, "bench.test", local, 50, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	// Many tabs polling the same inbox exercise read coalescing
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := s.GetInbox(ctx, "bench.test", local, 50, 0); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
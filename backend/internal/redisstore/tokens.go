@@ -0,0 +1,49 @@
+package redisstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Inbox access tokens let mail clients (POP3) authenticate as an address.
+// Only a SHA-256 of the token is stored:
+//
+//	token:<domain>:<local>   STRING hex sha256, expires with the address
+func tokenKey(emailDomain, local string) string {
+	return fmt.Sprintf("token:%s:%s", emailDomain, local)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetInboxToken replaces the access token of an inbox
+func (s *Store) SetInboxToken(ctx context.Context, emailDomain, local, token string) error {
+	client := s.clientFor(emailDomain)
+	ttl, err := client.PTTL(ctx, fmt.Sprintf("addr:%s:%s", emailDomain, local)).Result()
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	return client.Set(ctx, tokenKey(emailDomain, local), hashToken(token), ttl).Err()
+}
+
+// CheckInboxToken reports whether token grants access to the inbox
+func (s *Store) CheckInboxToken(ctx context.Context, emailDomain, local, token string) (bool, error) {
+	stored, err := s.clientFor(emailDomain).Get(ctx, tokenKey(emailDomain, local)).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(hashToken(token))) == 1, nil
+}
@@ -0,0 +1,25 @@
+package redisstore
+
+import (
+	"context"
+)
+
+// Unsubscribe layout:
+//
+//	unsubscribed:<id>  STRING set once a message's list was unsubscribed
+//
+// so repeated requests for one message do not hit the sender again.
+func unsubscribedKey(id string) string {
+	return "unsubscribed:" + id
+}
+
+// ClaimUnsubscribe marks a message unsubscribed and reports whether this
+// caller is the first to do so
+func (s *Store) ClaimUnsubscribe(ctx context.Context, id string) (bool, error) {
+	return s.client.SetNX(ctx, unsubscribedKey(id), "1", s.ttl).Result()
+}
+
+// ReleaseUnsubscribe undoes ClaimUnsubscribe after a failed attempt
+func (s *Store) ReleaseUnsubscribe(ctx context.Context, id string) error {
+	return s.client.Del(ctx, unsubscribedKey(id)).Err()
+}
@@ -0,0 +1,126 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cattymail/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// User domain store layout:
+//
+//	userdomain:<domain>       HASH with account, token, created_at, verified_at
+//	account:<id>:domains      SET of domains the account connected
+//	userdomains:verified      SET of every verified user domain, read by the ingestor
+const KeyVerifiedUserDomains = "userdomains:verified"
+
+// ErrDomainClaimed is returned when another account already connected a domain
+var ErrDomainClaimed = errors.New("domain already claimed")
+
+func userDomainKey(d string) string {
+	return fmt.Sprintf("userdomain:%s", d)
+}
+
+// ClaimUserDomain records ud as pending verification for its account
+func (s *Store) ClaimUserDomain(ctx context.Context, ud *domain.UserDomain) error {
+	key := userDomainKey(ud.Domain)
+	ok, err := s.client.HSetNX(ctx, key, "account", ud.AccountID).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		owner, err := s.client.HGet(ctx, key, "account").Result()
+		if err != nil {
+			return err
+		}
+		if owner != ud.AccountID {
+			return ErrDomainClaimed
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, "token", ud.Token, "created_at", ud.CreatedAt.Unix())
+	pipe.HDel(ctx, key, "verified_at")
+	pipe.SRem(ctx, KeyVerifiedUserDomains, ud.Domain)
+	pipe.SAdd(ctx, accountKey(ud.AccountID)+":domains", ud.Domain)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetUserDomain returns the user domain d, or nil if nobody connected it
+func (s *Store) GetUserDomain(ctx context.Context, d string) (*domain.UserDomain, error) {
+	vals, err := s.client.HMGet(ctx, userDomainKey(d), "account", "token", "created_at", "verified_at").Result()
+	if err != nil {
+		return nil, err
+	}
+	if vals[0] == nil {
+		return nil, nil
+	}
+
+	ud := &domain.UserDomain{Domain: d}
+	ud.AccountID, _ = vals[0].(string)
+	ud.Token, _ = vals[1].(string)
+	if v, ok := vals[2].(string); ok {
+		ts, _ := strconv.ParseInt(v, 10, 64)
+		ud.CreatedAt = time.Unix(ts, 0)
+	}
+	if v, ok := vals[3].(string); ok {
+		ts, _ := strconv.ParseInt(v, 10, 64)
+		ud.Verified = true
+		ud.VerifiedAt = time.Unix(ts, 0)
+	}
+	return ud, nil
+}
+
+// ListAccountDomains returns every domain connected by accountID
+func (s *Store) ListAccountDomains(ctx context.Context, accountID string) ([]*domain.UserDomain, error) {
+	names, err := s.client.SMembers(ctx, accountKey(accountID)+":domains").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	domains := make([]*domain.UserDomain, 0, len(names))
+	for _, d := range names {
+		ud, err := s.GetUserDomain(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if ud != nil && ud.AccountID == accountID {
+			domains = append(domains, ud)
+		}
+	}
+	return domains, nil
+}
+
+// MarkUserDomainVerified opens d for ingestion
+func (s *Store) MarkUserDomainVerified(ctx context.Context, d string) error {
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, userDomainKey(d), "verified_at", time.Now().Unix())
+	pipe.SAdd(ctx, KeyVerifiedUserDomains, d)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteUserDomain disconnects d from accountID
+func (s *Store) DeleteUserDomain(ctx context.Context, accountID, d string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, userDomainKey(d))
+	pipe.SRem(ctx, KeyVerifiedUserDomains, d)
+	pipe.SRem(ctx, accountKey(accountID)+":domains", d)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetVerifiedUserDomains returns every user domain that accepts mail
+func (s *Store) GetVerifiedUserDomains(ctx context.Context) ([]string, error) {
+	domains, err := s.client.SMembers(ctx, KeyVerifiedUserDomains).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return domains, err
+}
@@ -0,0 +1,84 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cattymail/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// WebAuthn layout:
+//
+//	webauthn:credentials     HASH of credential ID -> WebAuthnCredential JSON
+//	webauthn:challenge:<id>  STRING challenge of a pending ceremony, with TTL
+const KeyWebAuthnCredentials = "webauthn:credentials"
+
+func webAuthnChallengeKey(id string) string {
+	return "webauthn:challenge:" + id
+}
+
+// SaveWebAuthnCredential creates or replaces a credential
+func (s *Store) SaveWebAuthnCredential(ctx context.Context, cred *domain.WebAuthnCredential) error {
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, KeyWebAuthnCredentials, cred.ID, data).Err()
+}
+
+// GetWebAuthnCredential returns a credential, or nil when it does not exist
+func (s *Store) GetWebAuthnCredential(ctx context.Context, id string) (*domain.WebAuthnCredential, error) {
+	data, err := s.client.HGet(ctx, KeyWebAuthnCredentials, id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cred domain.WebAuthnCredential
+	if err := json.Unmarshal(data, &cred); err != nil {
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// ListWebAuthnCredentials returns every registered credential
+func (s *Store) ListWebAuthnCredentials(ctx context.Context) ([]*domain.WebAuthnCredential, error) {
+	vals, err := s.client.HGetAll(ctx, KeyWebAuthnCredentials).Result()
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]*domain.WebAuthnCredential, 0, len(vals))
+	for _, v := range vals {
+		var cred domain.WebAuthnCredential
+		if err := json.Unmarshal([]byte(v), &cred); err != nil {
+			continue
+		}
+		creds = append(creds, &cred)
+	}
+	return creds, nil
+}
+
+// DeleteWebAuthnCredential removes a credential. It reports false when the
+// credential does not exist.
+func (s *Store) DeleteWebAuthnCredential(ctx context.Context, id string) (bool, error) {
+	removed, err := s.client.HDel(ctx, KeyWebAuthnCredentials, id).Result()
+	return removed > 0, err
+}
+
+// SaveWebAuthnChallenge stores the challenge of a ceremony until ttl
+func (s *Store) SaveWebAuthnChallenge(ctx context.Context, id string, challenge []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, webAuthnChallengeKey(id), challenge, ttl).Err()
+}
+
+// TakeWebAuthnChallenge returns and deletes a challenge so it can only be
+// answered once. It returns nil when the ceremony is unknown or expired.
+func (s *Store) TakeWebAuthnChallenge(ctx context.Context, id string) ([]byte, error) {
+	challenge, err := s.client.GetDel(ctx, webAuthnChallengeKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return challenge, err
+}
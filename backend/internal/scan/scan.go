@@ -0,0 +1,96 @@
+// Package scan checks message parts for malware before they are stored.
+// Scanner is the extension point; Clamd talks to a ClamAV daemon over TCP.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scanner reports the signature found in data, or "" when it is clean
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, data []byte) (string, error)
+}
+
+// chunkSize is the INSTREAM chunk size; clamd's StreamMaxLength still
+// bounds the total
+const chunkSize = 64 * 1024
+
+// Clamd scans with clamd's INSTREAM command
+type Clamd struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamd returns a scanner for the clamd listening on addr (host:port)
+func NewClamd(addr string, timeout time.Duration) *Clamd {
+	return &Clamd{addr: addr, timeout: timeout}
+}
+
+func (c *Clamd) Name() string { return "clamav" }
+
+func (c *Clamd) Scan(ctx context.Context, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// z-prefixed commands are NUL-terminated, as is the reply
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	var size [4]byte
+	for len(data) > 0 {
+		n := min(len(data), chunkSize)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		if _, err := conn.Write(size[:]); err != nil {
+			return "", fmt.Errorf("clamd: %w", err)
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return "", fmt.Errorf("clamd: %w", err)
+		}
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && len(reply) == 0 {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	return parseReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+// parseReply interprets "stream: OK", "stream: <signature> FOUND" and
+// "<reason> ERROR"
+func parseReply(reply string) (string, error) {
+	reply = strings.TrimSpace(reply)
+	_, result, _ := strings.Cut(reply, ": ")
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return "", errors.New("clamd: " + strings.TrimSuffix(reply, " ERROR"))
+	}
+	return "", fmt.Errorf("clamd: unexpected reply %q", reply)
+}
@@ -0,0 +1,87 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Chrome renders with a headless Chrome/Chromium binary. Each screenshot
+// runs in a throwaway profile with DNS disabled, so the page cannot reach
+// the network even if something slipped past the sanitizer.
+type Chrome struct {
+	Path string
+	// Flags are appended to the command line, e.g. --no-sandbox when
+	// running as root in a container
+	Flags []string
+}
+
+// Screenshot implements Renderer
+func (c *Chrome) Screenshot(ctx context.Context, doc []byte, width, height int) ([]byte, error) {
+	return c.run(ctx, doc, "shot.png", func(out string) []string {
+		return []string{
+			"--hide-scrollbars",
+			fmt.Sprintf("--window-size=%d,%d", width, height),
+			"--screenshot=" + out,
+		}
+	})
+}
+
+// PrintPDF implements Printer
+func (c *Chrome) PrintPDF(ctx context.Context, doc []byte) ([]byte, error) {
+	return c.run(ctx, doc, "message.pdf", func(out string) []string {
+		return []string{
+			"--no-pdf-header-footer",
+			"--print-to-pdf=" + out,
+		}
+	})
+}
+
+// run loads doc in a throwaway Chrome and returns the file it wrote to
+// output, given the flags returned by mode
+func (c *Chrome) run(ctx context.Context, doc []byte, output string, mode func(out string) []string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "cattymail-thumb-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	page := filepath.Join(dir, "message.html")
+	if err := os.WriteFile(page, doc, 0o600); err != nil {
+		return nil, err
+	}
+	out := filepath.Join(dir, output)
+
+	args := []string{
+		"--headless=new",
+		"--disable-gpu",
+		"--disable-extensions",
+		"--no-first-run",
+		"--mute-audio",
+		"--host-resolver-rules=MAP * ~NOTFOUND",
+		"--user-data-dir=" + filepath.Join(dir, "profile"),
+	}
+	args = append(args, mode(out)...)
+	args = append(args, c.Flags...)
+	args = append(args, "file://"+page)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Path, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("chrome: %w: %s", err, strings.TrimSpace(lastLine(stderr.String())))
+	}
+	return os.ReadFile(out)
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,244 @@
+// Package thumbnail produces PNG previews of message HTML for inbox UIs,
+// and PDF exports of messages. Rendering is delegated to a Renderer
+// (headless Chrome by default) and the result is cached in the message
+// blob store so each message is rendered at most once.
+package thumbnail
+
+import (
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/preview"
+	"cattymail/internal/redisstore"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// blobName is the blob store entry a thumbnail is cached under
+	blobName = "thumbnail.png"
+	// pdfBlobName is the blob store entry a PDF export is cached under
+	pdfBlobName = "message.pdf"
+	// The page is captured at a desktop mail client size and scaled down
+	viewportWidth  = 1024
+	viewportHeight = 1280
+	renderTimeout  = 20 * time.Second
+)
+
+// Renderer screenshots an HTML document to PNG at the given viewport
+type Renderer interface {
+	Screenshot(ctx context.Context, doc []byte, width, height int) ([]byte, error)
+}
+
+// Printer is a Renderer that can also print an HTML document to PDF
+type Printer interface {
+	PrintPDF(ctx context.Context, doc []byte) ([]byte, error)
+}
+
+// ErrNoPrinter is returned by PDF when the renderer cannot print
+var ErrNoPrinter = errors.New("thumbnail: renderer cannot print to PDF")
+
+// Service renders and caches thumbnails
+type Service struct {
+	cfg      *config.Config
+	store    *redisstore.Store
+	renderer Renderer
+	renders  singleflight.Group
+	slots    chan struct{}
+}
+
+// New returns a service rendering with headless Chrome, or nil when
+// THUMBNAIL_CHROME_PATH is not configured
+func New(cfg *config.Config, store *redisstore.Store) *Service {
+	if cfg.ThumbnailChromePath == "" {
+		return nil
+	}
+	return NewWithRenderer(cfg, store, &Chrome{Path: cfg.ThumbnailChromePath, Flags: strings.Fields(cfg.ThumbnailChromeFlags)})
+}
+
+// NewWithRenderer returns a service using r
+func NewWithRenderer(cfg *config.Config, store *redisstore.Store, r Renderer) *Service {
+	concurrency := cfg.ThumbnailConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Service{
+		cfg:      cfg,
+		store:    store,
+		renderer: r,
+		slots:    make(chan struct{}, concurrency),
+	}
+}
+
+// Get returns the thumbnail of msg, rendering it on first use
+func (s *Service) Get(ctx context.Context, msg *domain.Message) ([]byte, error) {
+	cached, err := s.store.GetMessageBlob(ctx, msg.ID, blobName)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	v, err, _ := s.renders.Do(msg.ID, func() (interface{}, error) {
+		img, err := s.render(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.store.PutMessageBlob(ctx, msg.ID, blobName, img); err != nil {
+			log.Printf("thumbnail: failed to cache %s: %v", msg.ID, err)
+		}
+		return img, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+func (s *Service) render(ctx context.Context, msg *domain.Message) ([]byte, error) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+
+	shot, err := s.renderer.Screenshot(ctx, preview.Render(msg).HTML, viewportWidth, viewportHeight)
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail: render %s: %w", msg.ID, err)
+	}
+	src, err := png.Decode(bytes.NewReader(shot))
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail: decode screenshot: %w", err)
+	}
+
+	var out bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&out, scale(src, s.cfg.ThumbnailWidth)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// PDF returns the printable rendering of msg as a PDF, printing it on
+// first use
+func (s *Service) PDF(ctx context.Context, msg *domain.Message) ([]byte, error) {
+	printer, ok := s.renderer.(Printer)
+	if !ok {
+		return nil, ErrNoPrinter
+	}
+	cached, err := s.store.GetMessageBlob(ctx, msg.ID, pdfBlobName)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return cached, nil
+	}
+
+	v, err, _ := s.renders.Do(msg.ID+":pdf", func() (interface{}, error) {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+		defer cancel()
+
+		doc, err := printer.PrintPDF(ctx, preview.RenderWith(msg, preview.Options{Print: true}).HTML)
+		if err != nil {
+			return nil, fmt.Errorf("thumbnail: print %s: %w", msg.ID, err)
+		}
+		if err := s.store.PutMessageBlob(ctx, msg.ID, pdfBlobName, doc); err != nil {
+			log.Printf("thumbnail: failed to cache PDF of %s: %v", msg.ID, err)
+		}
+		return doc, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+// Run renders thumbnails for new messages as they are ingested. It blocks
+// until ctx is cancelled and should run in one process only.
+func (s *Service) Run(ctx context.Context) {
+	sub := s.store.SubscribeIngestFeed(ctx)
+	defer sub.Close()
+
+	log.Println("Thumbnail renderer started")
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			var ev domain.IngestEvent
+			if err := json.Unmarshal([]byte(m.Payload), &ev); err != nil {
+				continue
+			}
+			go func(id string) {
+				msg, err := s.store.GetMessage(ctx, id)
+				if err != nil || msg == nil {
+					return
+				}
+				if _, err := s.Get(ctx, msg); err != nil {
+					log.Printf("thumbnail: %v", err)
+				}
+			}(ev.ID)
+		}
+	}
+}
+
+// scale shrinks src to width pixels wide by averaging source pixels
+func scale(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if width <= 0 || width >= b.Dx() {
+		return src
+	}
+	height := b.Dy() * width / b.Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		sy0 := b.Min.Y + y*b.Dy()/height
+		sy1 := b.Min.Y + (y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			sx0 := b.Min.X + x*b.Dx()/width
+			sx1 := b.Min.X + (x+1)*b.Dx()/width
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+	return dst
+}
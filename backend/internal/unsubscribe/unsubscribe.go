@@ -0,0 +1,202 @@
+// Package unsubscribe acts on a message's List-Unsubscribe header (RFC
+// 2369): a one-click POST (RFC 8058) when the sender supports it,
+// otherwise a fixed "unsubscribe" mail to the mailto: address through the
+// outbound SMTP relay, when that address belongs to the sender.
+package unsubscribe
+
+import (
+	"bytes"
+	"cattymail/internal/config"
+	"cattymail/internal/domain"
+	"cattymail/internal/notify"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	MethodOneClick = "one-click"
+	MethodMailto   = "mailto"
+)
+
+var (
+	// ErrNoMethod means the message offers nothing this package can act on
+	ErrNoMethod = errors.New("unsubscribe: no one-click or mailto unsubscribe offered")
+	// ErrFailed wraps failures of the sender's endpoint or the relay
+	ErrFailed = errors.New("unsubscribe: request failed")
+)
+
+// Result says how a message was unsubscribed
+type Result struct {
+	Method string `json:"method"`
+	Target string `json:"target"`
+}
+
+// Unsubscriber performs unsubscribe requests
+type Unsubscriber struct {
+	cfg    *config.Config
+	client *http.Client
+}
+
+func New(cfg *config.Config) *Unsubscriber {
+	return &Unsubscriber{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: notify.PublicTransport(),
+		},
+	}
+}
+
+// Parse returns the URIs of a List-Unsubscribe value, in order
+func Parse(header string) []string {
+	var uris []string
+	for {
+		start := strings.IndexByte(header, '<')
+		if start < 0 {
+			return uris
+		}
+		end := strings.IndexByte(header[start:], '>')
+		if end < 0 {
+			return uris
+		}
+		if uri := strings.TrimSpace(header[start+1 : start+end]); uri != "" {
+			uris = append(uris, uri)
+		}
+		header = header[start+end+1:]
+	}
+}
+
+// Unsubscribe unsubscribes the inbox msg was delivered to
+func (u *Unsubscriber) Unsubscribe(ctx context.Context, msg *domain.Message) (*Result, error) {
+	var list, post string
+	if v := msg.Headers["List-Unsubscribe"]; len(v) > 0 {
+		list = v[0]
+	}
+	if v := msg.Headers["List-Unsubscribe-Post"]; len(v) > 0 {
+		post = v[0]
+	}
+	uris := Parse(list)
+
+	// RFC 8058: one-click only when the sender asks for it
+	if strings.EqualFold(strings.TrimSpace(post), "List-Unsubscribe=One-Click") {
+		for _, uri := range uris {
+			if strings.HasPrefix(strings.ToLower(uri), "https://") {
+				if err := u.oneClick(ctx, uri); err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrFailed, err)
+				}
+				return &Result{Method: MethodOneClick, Target: uri}, nil
+			}
+		}
+	}
+	if u.cfg.SMTPAddr != "" {
+		for _, uri := range uris {
+			if !strings.HasPrefix(strings.ToLower(uri), "mailto:") {
+				continue
+			}
+			to, err := mailtoAddress(uri)
+			if err != nil || !senderOwns(msg, to) {
+				continue
+			}
+			if err := u.mailto(msg, to); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrFailed, err)
+			}
+			return &Result{Method: MethodMailto, Target: to}, nil
+		}
+	}
+	return nil, ErrNoMethod
+}
+
+// mailtoAddress returns the single recipient of a mailto: URI. Its
+// subject and body are ignored: they are chosen by whoever sent the mail.
+func mailtoAddress(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	to := parsed.Opaque
+	if to == "" {
+		to = parsed.Path
+	}
+	if to, err = url.PathUnescape(to); err != nil || strings.Count(to, "@") != 1 || strings.ContainsAny(to, ",;<>\" \r\n") {
+		return "", fmt.Errorf("invalid mailto address %q", to)
+	}
+	return to, nil
+}
+
+// senderOwns reports whether to is in the domain (or a subdomain) of the
+// From or envelope (Return-Path) address of msg, so mail can't get us to
+// write to arbitrary third parties
+func senderOwns(msg *domain.Message, to string) bool {
+	toDomain := domainOf(to)
+	if toDomain == "" {
+		return false
+	}
+	senders := []string{msg.FromAddress}
+	if v := msg.Headers["Return-Path"]; len(v) > 0 {
+		senders = append(senders, strings.Trim(strings.TrimSpace(v[0]), "<>"))
+	}
+	for _, sender := range senders {
+		d := domainOf(sender)
+		if d != "" && (toDomain == d || strings.HasSuffix(toDomain, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+func domainOf(address string) string {
+	i := strings.LastIndexByte(address, '@')
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(address[i+1:]), ".")
+}
+
+func (u *Unsubscriber) oneClick(ctx context.Context, uri string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mailto sends a fixed unsubscribe mail to the list address to from the
+// inbox's own address, which is the one the list knows
+func (u *Unsubscriber) mailto(msg *domain.Message, to string) error {
+	from := msg.Local + "@" + msg.Domain
+	envelope := u.cfg.SMTPFrom
+	if envelope == "" {
+		envelope = from
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", to)
+	body.WriteString("Subject: unsubscribe\r\n")
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString("unsubscribe\r\n")
+
+	var auth smtp.Auth
+	if u.cfg.SMTPUser != "" {
+		host, _, _ := net.SplitHostPort(u.cfg.SMTPAddr)
+		auth = smtp.PlainAuth("", u.cfg.SMTPUser, u.cfg.SMTPPass, host)
+	}
+	return smtp.SendMail(u.cfg.SMTPAddr, auth, envelope, []string{to}, body.Bytes())
+}
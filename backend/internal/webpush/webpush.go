@@ -0,0 +1,262 @@
+// Package webpush sends encrypted Web Push messages (RFC 8030) with VAPID
+// authentication (RFC 8292) and aes128gcm payload encryption (RFC 8291).
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrInvalidSubscription = errors.New("webpush: invalid subscription")
+	ErrUnknownPushService  = errors.New("webpush: endpoint is not a known push service")
+	// ErrGone means the subscription expired or was revoked and should be deleted
+	ErrGone = errors.New("webpush: subscription gone")
+)
+
+// pushServices are the host suffixes browsers hand out endpoints for. Only
+// these are contacted, so subscriptions can't point the server elsewhere.
+var pushServices = []string{
+	"fcm.googleapis.com",
+	"push.services.mozilla.com",
+	"push.apple.com",
+	"notify.windows.com",
+}
+
+// recordSize is the aes128gcm record size advertised in the header
+const recordSize = 4096
+
+// Subscription is the PushSubscription JSON produced by browsers
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Validate checks the subscription is complete and targets a known service
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return ErrInvalidSubscription
+	}
+	known := false
+	host := strings.ToLower(u.Hostname())
+	for _, suffix := range pushServices {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			known = true
+		}
+	}
+	if !known {
+		return ErrUnknownPushService
+	}
+
+	if key, err := decode(s.Keys.P256dh); err != nil || len(key) != 65 {
+		return ErrInvalidSubscription
+	}
+	if auth, err := decode(s.Keys.Auth); err != nil || len(auth) != 16 {
+		return ErrInvalidSubscription
+	}
+	return nil
+}
+
+// VAPID identifies the application server to push services
+type VAPID struct {
+	PrivateKey string // base64url P-256 scalar
+	PublicKey  string // base64url uncompressed point, shared with browsers
+	Subject    string // mailto: or https: contact
+}
+
+// GenerateVAPIDKeys returns a new base64url-encoded P-256 key pair
+func GenerateVAPIDKeys() (string, string, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return encode(key.Bytes()), encode(key.PublicKey().Bytes()), nil
+}
+
+// Send encrypts payload for sub and delivers it. ttl is how long the push
+// service should hold the message for an offline browser.
+func Send(ctx context.Context, client *http.Client, sub *Subscription, payload []byte, vapid *VAPID, ttl time.Duration) error {
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	auth, err := vapidAuthorization(sub.Endpoint, vapid)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl/time.Second)))
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("webpush: push service returned %s", resp.Status)
+	}
+	return nil
+}
+
+// encrypt implements the aes128gcm content coding for a single record
+func encrypt(sub *Subscription, payload []byte) ([]byte, error) {
+	uaPublicBytes, _ := decode(sub.Keys.P256dh)
+	authSecret, _ := decode(sub.Keys.Auth)
+
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, ErrInvalidSubscription
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	ecdhSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// IKM = HKDF(auth_secret, ecdh_secret, "WebPush: info" || 0 || ua_public || as_public)
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := expand(ecdhSecret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := expand(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload)+1+gcm.Overhead() > recordSize {
+		return nil, fmt.Errorf("webpush: payload of %d bytes exceeds one record", len(payload))
+	}
+	// 0x02 marks the last (and only) record
+	plaintext := append(append([]byte(nil), payload...), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, recordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// vapidAuthorization builds the "vapid t=..., k=..." header for endpoint
+func vapidAuthorization(endpoint string, vapid *VAPID) (string, error) {
+	key, err := signingKey(vapid.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	u, _ := url.Parse(endpoint)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{u.Scheme + "://" + u.Host},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)),
+		Subject:   vapid.Subject,
+	})
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", signed, vapid.PublicKey), nil
+}
+
+func signingKey(private string) (*ecdsa.PrivateKey, error) {
+	raw, err := decode(private)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("webpush: invalid VAPID private key: %w", err)
+	}
+
+	pub := key.PublicKey().Bytes() // 0x04 || X || Y
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, nil
+}
+
+func expand(secret, salt, info []byte, n int) ([]byte, error) {
+	out := make([]byte, n)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode accepts both padded and unpadded base64url, as browsers differ
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,32 @@
+// Service worker for Web Push new-mail notifications.
+// Register with navigator.serviceWorker.register('/push-sw.js') and subscribe
+// using the key from GET /api/push/vapid-public-key.
+self.addEventListener('push', (event) => {
+  let data = {}
+  try {
+    data = event.data ? event.data.json() : {}
+  } catch {
+    data = { title: 'New mail', body: event.data ? event.data.text() : '' }
+  }
+
+  event.waitUntil(
+    self.registration.showNotification(data.title || 'New mail', {
+      body: data.body || '',
+      tag: data.inbox || 'cattymail',
+      data: { inbox: data.inbox, id: data.id },
+      icon: '/vite.svg',
+    }),
+  )
+})
+
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close()
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((clients) => {
+      for (const client of clients) {
+        if ('focus' in client) return client.focus()
+      }
+      return self.clients.openWindow('/')
+    }),
+  )
+})
@@ -14,6 +14,7 @@ export interface Message {
   from: string;
   subject: string;
   date: string;
+  received_at: string;
   text: string;
   html?: string;
   original_to: string;